@@ -39,6 +39,7 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/elastic/cloud-on-k8s/pkg/about"
@@ -59,6 +60,7 @@ import (
 	associationctl "github.com/elastic/cloud-on-k8s/pkg/controller/association/controller"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/autoscaling"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/beat"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/bootstrapindex"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/common/certificates"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/common/container"
 	commonlicense "github.com/elastic/cloud-on-k8s/pkg/controller/common/license"
@@ -73,11 +75,13 @@ import (
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/settings"
 	esvalidation "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/validation"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/enterprisesearch"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/indextemplate"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/kibana"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/license"
 	licensetrial "github.com/elastic/cloud-on-k8s/pkg/controller/license/trial"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/maps"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/remoteca"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/snapshotrestore"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/webhook"
 	"github.com/elastic/cloud-on-k8s/pkg/dev"
 	"github.com/elastic/cloud-on-k8s/pkg/dev/portforward"
@@ -418,6 +422,12 @@ func startOperator(ctx context.Context) error {
 		return fmt.Errorf("development mode must be enabled to use %s", operator.AutoPortForwardFlag)
 	} else if autoPortForward {
 		log.Info("Warning: auto-port-forwarding is enabled, which is intended for development only")
+		// expose the forwarders metrics along with the operator ones, and probe the forwarders to report their health
+		if err := portforward.RegisterMetrics(crmetrics.Registry); err != nil {
+			log.Error(err, "Failed to register port forwarding metrics")
+			return err
+		}
+		portforward.EnableHealthProbes()
 		dialer = portforward.NewForwardingDialer()
 	}
 
@@ -751,6 +761,10 @@ func registerControllers(mgr manager.Manager, params operator.Parameters, access
 		{name: "LicenseTrial", registerFunc: licensetrial.Add},
 		{name: "Agent", registerFunc: agent.Add},
 		{name: "Maps", registerFunc: maps.Add},
+		{name: "SnapshotRestore", registerFunc: snapshotrestore.Add},
+		{name: "ComponentTemplate", registerFunc: indextemplate.AddComponentTemplate},
+		{name: "IndexTemplate", registerFunc: indextemplate.AddIndexTemplate},
+		{name: "BootstrapIndex", registerFunc: bootstrapindex.Add},
 	}
 
 	for _, c := range controllers {
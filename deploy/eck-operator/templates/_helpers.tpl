@@ -183,6 +183,18 @@ updating docs/operating-eck/eck-permissions.asciidoc file.
   - elasticsearches
   - elasticsearches/status
   - elasticsearches/finalizers # needed for ownerReferences with blockOwnerDeletion on OCP
+  - snapshotrestores
+  - snapshotrestores/status
+  - snapshotrestores/finalizers # needed for ownerReferences with blockOwnerDeletion on OCP
+  - componenttemplates
+  - componenttemplates/status
+  - componenttemplates/finalizers # needed for ownerReferences with blockOwnerDeletion on OCP
+  - indextemplates
+  - indextemplates/status
+  - indextemplates/finalizers # needed for ownerReferences with blockOwnerDeletion on OCP
+  - bootstrapindices
+  - bootstrapindices/status
+  - bootstrapindices/finalizers # needed for ownerReferences with blockOwnerDeletion on OCP
   verbs:
   - get
   - list
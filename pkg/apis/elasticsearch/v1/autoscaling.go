@@ -319,7 +319,7 @@ func (as AutoscalingSpec) GetAutoscalingSpecFor(nodeSet NodeSet) (*AutoscalingPo
 // getNodeSetRoles attempts to parse the roles specified in the configuration of a given nodeSet.
 func getNodeSetRoles(v version.Version, nodeSet NodeSet) ([]string, error) {
 	cfg := ElasticsearchSettings{}
-	if err := UnpackConfig(nodeSet.Config, v, &cfg); err != nil {
+	if err := nodeSet.UnpackConfig(v, &cfg); err != nil {
 		return nil, err
 	}
 	if cfg.Node == nil {
@@ -0,0 +1,33 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1
+
+// AutoFollowPattern makes Elasticsearch create follower indices replicating the indices of a remote cluster with
+// cross-cluster replication.
+type AutoFollowPattern struct {
+	// Name is the identifier of the auto-follow pattern in Elasticsearch. It must be unique across all the remote
+	// clusters.
+	Name string `json:"name"`
+	// LeaderIndexPatterns are the wildcard expressions matching the names of the indices of the remote cluster to
+	// replicate.
+	// +kubebuilder:validation:MinItems=1
+	LeaderIndexPatterns []string `json:"leaderIndexPatterns"`
+	// FollowIndexPattern is the name of the follower indices, where {{leader_index}} is replaced by the name of the
+	// replicated index. Defaults to {{leader_index}}.
+	// +kubebuilder:validation:Optional
+	FollowIndexPattern string `json:"followIndexPattern,omitempty"`
+}
+
+// CrossClusterReplicationStatus summarizes the replication of the follower indices of a remote cluster, as reported
+// by Elasticsearch.
+type CrossClusterReplicationStatus struct {
+	// RemoteCluster is the name of the remote cluster holding the leader indices.
+	RemoteCluster string `json:"remoteCluster"`
+	// FollowerIndices is the number of indices replicated from the remote cluster.
+	FollowerIndices int32 `json:"followerIndices"`
+	// OperationsBehind is the number of operations the follower indices still have to replicate from their leader
+	// indices.
+	OperationsBehind int64 `json:"operationsBehind"`
+}
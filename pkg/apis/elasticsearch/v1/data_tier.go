@@ -0,0 +1,81 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1
+
+import (
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/stringsutil"
+)
+
+// DataTier is the data tier of the nodes of a NodeSet.
+type DataTier string
+
+const (
+	HotTier    DataTier = "hot"
+	WarmTier   DataTier = "warm"
+	ColdTier   DataTier = "cold"
+	FrozenTier DataTier = "frozen"
+
+	// NodeAttrData is the node attribute set to the data tier of the nodes, for index lifecycle policies allocating
+	// shards with node attributes rather than with data tier roles.
+	NodeAttrData = NodeAttr + ".data"
+)
+
+// DataRoles are the node roles holding data in a data tier.
+var DataRoles = []NodeRole{DataRole, DataHotRole, DataWarmRole, DataColdRole, DataFrozenRole}
+
+// nonDataRoles are the roles held by default by the nodes of a data tier, as they are by nodes without node.roles.
+var nonDataRoles = []NodeRole{MasterRole, IngestRole, MLRole, TransformRole, RemoteClusterClientRole}
+
+// Roles returns the node roles of the data tier. Nodes of the hot tier also hold the data_content role, since indices
+// that are not part of a data stream are allocated to the content tier.
+func (t DataTier) Roles() []NodeRole {
+	switch t {
+	case HotTier:
+		return []NodeRole{DataHotRole, DataContentRole}
+	case WarmTier:
+		return []NodeRole{DataWarmRole}
+	case ColdTier:
+		return []NodeRole{DataColdRole}
+	case FrozenTier:
+		return []NodeRole{DataFrozenRole}
+	}
+	return nil
+}
+
+// WithRoles returns the given node roles completed with the roles of the data tier. If roles is nil, that is if
+// node.roles is not set, nodes of the data tier hold all the other roles that are not about data as well.
+func (t DataTier) WithRoles(roles []string) []string {
+	if t == "" {
+		return roles
+	}
+	withRoles := append([]string{}, roles...)
+	if roles == nil {
+		for _, role := range nonDataRoles {
+			withRoles = append(withRoles, string(role))
+		}
+	}
+	for _, role := range t.Roles() {
+		if !stringsutil.StringInSlice(string(role), withRoles) {
+			withRoles = append(withRoles, string(role))
+		}
+	}
+	return withRoles
+}
+
+// UnpackConfig unpacks the configuration of the NodeSet into a typed subset, including the roles of its data tier.
+func (n NodeSet) UnpackConfig(ver version.Version, out *ElasticsearchSettings) error {
+	if err := UnpackConfig(n.Config, ver, out); err != nil {
+		return err
+	}
+	if n.DataTier == "" {
+		return nil
+	}
+	if out.Node == nil {
+		out.Node = &Node{}
+	}
+	out.Node.Roles = n.DataTier.WithRoles(out.Node.Roles)
+	return nil
+}
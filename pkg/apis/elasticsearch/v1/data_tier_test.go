@@ -0,0 +1,77 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+)
+
+func TestDataTier_WithRoles(t *testing.T) {
+	tests := []struct {
+		name  string
+		tier  DataTier
+		roles []string
+		want  []string
+	}{
+		{
+			name:  "no data tier",
+			roles: []string{"master"},
+			want:  []string{"master"},
+		},
+		{
+			name: "hot tier without node.roles",
+			tier: HotTier,
+			want: []string{"master", "ingest", "ml", "transform", "remote_cluster_client", "data_hot", "data_content"},
+		},
+		{
+			name:  "cold tier with empty node.roles",
+			tier:  ColdTier,
+			roles: []string{},
+			want:  []string{"data_cold"},
+		},
+		{
+			name:  "warm tier with node.roles",
+			tier:  WarmTier,
+			roles: []string{"master", "ingest"},
+			want:  []string{"master", "ingest", "data_warm"},
+		},
+		{
+			name:  "roles of the tier already in node.roles",
+			tier:  HotTier,
+			roles: []string{"data_content", "ingest"},
+			want:  []string{"data_content", "ingest", "data_hot"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.tier.WithRoles(tt.roles))
+		})
+	}
+}
+
+func TestNodeSet_UnpackConfig(t *testing.T) {
+	nodeSet := NodeSet{
+		DataTier: ColdTier,
+		Config:   &commonv1.Config{Data: map[string]interface{}{"node.roles": []string{"remote_cluster_client"}}},
+	}
+	cfg := ElasticsearchSettings{}
+	require.NoError(t, nodeSet.UnpackConfig(version.MustParse("7.16.0"), &cfg))
+	require.Equal(t, []string{"remote_cluster_client", "data_cold"}, cfg.Node.Roles)
+	require.True(t, cfg.Node.HasRole(DataColdRole))
+	require.False(t, cfg.Node.HasRole(MasterRole))
+
+	// the nodes of a data tier without node.roles hold the roles that are not about data as well
+	nodeSet.Config = nil
+	cfg = ElasticsearchSettings{}
+	require.NoError(t, nodeSet.UnpackConfig(version.MustParse("7.16.0"), &cfg))
+	require.True(t, cfg.Node.HasRole(DataColdRole))
+	require.True(t, cfg.Node.HasRole(MasterRole))
+	require.False(t, cfg.Node.HasRole(DataHotRole))
+}
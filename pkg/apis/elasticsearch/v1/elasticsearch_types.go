@@ -14,8 +14,10 @@ import (
 
 	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/common/hash"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
 	"github.com/elastic/cloud-on-k8s/pkg/utils/pointer"
 	"github.com/elastic/cloud-on-k8s/pkg/utils/set"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/stringsutil"
 )
 
 const (
@@ -39,6 +41,9 @@ const (
 	SuspendAnnotation = "eck.k8s.elastic.co/suspend"
 	// DisableDowngradeValidationAnnotation allows circumventing downgrade/upgrade checks.
 	DisableDowngradeValidationAnnotation = "eck.k8s.elastic.co/disable-downgrade-validation"
+	// ReloadSecureSettingsAnnotation allows users to opt in to the update of the keystore of the running Pods and to the
+	// reload of the reloadable secure settings, instead of a rolling restart on any secure settings change.
+	ReloadSecureSettingsAnnotation = "eck.k8s.elastic.co/reload-secure-settings"
 	// Kind is inferred from the struct name using reflection in SchemeBuilder.Register()
 	// we duplicate it as a constant here for practical purposes.
 	Kind = "Elasticsearch"
@@ -116,6 +121,38 @@ type ElasticsearchSpec struct {
 	// Elasticsearch monitoring clusters running in the same Kubernetes cluster.
 	// +kubebuilder:validation:Optional
 	Monitoring Monitoring `json:"monitoring,omitempty"`
+
+	// ZoneAwareness makes Elasticsearch allocate the copies of a shard to nodes running in different zones, the zone of
+	// each node being the one of the Kubernetes node running its Pod.
+	// +kubebuilder:validation:Optional
+	ZoneAwareness *ZoneAwareness `json:"zoneAwareness,omitempty"`
+
+	// Plugins to install on all the Elasticsearch nodes before Elasticsearch starts. Changing the list of plugins
+	// triggers a rolling restart of the nodes.
+	// +kubebuilder:validation:Optional
+	Plugins []Plugin `json:"plugins,omitempty"`
+
+	// JVMHeap configures how the heap of the JVM running Elasticsearch is sized. By default it is left to Elasticsearch,
+	// or to the ES_JAVA_OPTS environment variable set in the Pod template.
+	// +kubebuilder:validation:Optional
+	JVMHeap *JVMHeap `json:"jvmHeap,omitempty"`
+
+	// SnapshotRepositories to register in Elasticsearch. The operator keeps them in sync with the specification and
+	// removes the repositories it registered once they are removed from the specification.
+	// +kubebuilder:validation:Optional
+	SnapshotRepositories []SnapshotRepository `json:"snapshotRepositories,omitempty"`
+
+	// SnapshotLifecyclePolicies to create in Elasticsearch. The operator reverts the changes made to them through the
+	// Elasticsearch API, and deletes the policies it created once they are removed from the specification.
+	// Available as of Elasticsearch 7.4.0.
+	// +kubebuilder:validation:Optional
+	SnapshotLifecyclePolicies []SnapshotLifecyclePolicy `json:"snapshotLifecyclePolicies,omitempty"`
+
+	// IndexLifecyclePolicies to create in Elasticsearch. The operator reverts the changes made to them through the
+	// Elasticsearch API, and deletes the policies it created once they are removed from the specification. Policies
+	// which already exist in Elasticsearch but were not created by the operator are never overwritten.
+	// +kubebuilder:validation:Optional
+	IndexLifecyclePolicies []IndexLifecyclePolicy `json:"indexLifecyclePolicies,omitempty"`
 }
 
 type Monitoring struct {
@@ -193,6 +230,23 @@ type RemoteCluster struct {
 	// ElasticsearchRef is a reference to an Elasticsearch cluster running within the same k8s cluster.
 	ElasticsearchRef commonv1.ObjectSelector `json:"elasticsearchRef,omitempty"`
 
+	// Seeds are the transport addresses (host:port) of a remote cluster which is not managed by this operator, used
+	// instead of ElasticsearchRef. The trust between both clusters must be configured manually.
+	// +kubebuilder:validation:Optional
+	Seeds []string `json:"seeds,omitempty"`
+
+	// SkipUnavailable makes cross-cluster searches skip this remote cluster if none of its nodes are available,
+	// instead of failing. Defaults to false.
+	// +kubebuilder:validation:Optional
+	SkipUnavailable *bool `json:"skipUnavailable,omitempty"`
+
+	// AutoFollowPatterns to create in Elasticsearch to replicate the matching indices of the remote cluster with
+	// cross-cluster replication. The operator reverts the changes made to them through the Elasticsearch API, and
+	// deletes the patterns it created once they are removed from the specification, leaving the follower indices
+	// untouched.
+	// +kubebuilder:validation:Optional
+	AutoFollowPatterns []AutoFollowPattern `json:"autoFollowPatterns,omitempty"`
+
 	// TODO: Allow the user to specify some options (transport.compress, transport.ping_schedule)
 
 }
@@ -223,6 +277,16 @@ type Auth struct {
 	Roles []RoleSource `json:"roles,omitempty"`
 	// FileRealm to propagate to the Elasticsearch cluster.
 	FileRealm []FileRealmSource `json:"fileRealm,omitempty"`
+	// SAML realms to configure in the Elasticsearch cluster. Their configuration is rendered in the configuration
+	// of all the nodes, which are restarted when the referenced secrets change.
+	SAML []SAMLRealm `json:"saml,omitempty"`
+	// OIDC realms to configure in the Elasticsearch cluster. Their client secrets are stored in the keystore of the
+	// nodes, which are restarted when they change.
+	OIDC []OIDCRealm `json:"oidc,omitempty"`
+	// LDAP realms to configure in the Elasticsearch cluster, authenticating users against LDAP directories or Active
+	// Directory domains. Their bind passwords are stored in the keystore of the nodes, which are restarted when they
+	// change.
+	LDAP []LDAPRealm `json:"ldap,omitempty"`
 }
 
 // RoleSource references roles to create in the Elasticsearch cluster.
@@ -301,6 +365,14 @@ type NodeSet struct {
 	// +kubebuilder:validation:Optional
 	Count int32 `json:"count"`
 
+	// DataTier is the data tier of the nodes of this NodeSet: hot, warm, cold or frozen. The roles of the tier are added
+	// to the node.roles setting, which defaults to all the roles that are not about data if not set, and node.attr.data
+	// is set to the name of the tier for index lifecycle policies allocating shards by node attribute. Available as of
+	// Elasticsearch 7.10.0.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=hot;warm;cold;frozen
+	DataTier DataTier `json:"dataTier,omitempty"`
+
 	// PodTemplate provides customisation options (labels, annotations, affinity rules, resource requests, and so on) for the Pods belonging to this NodeSet.
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:pruning:PreserveUnknownFields
@@ -412,14 +484,21 @@ type Elasticsearch struct {
 	AssocConfs map[types.NamespacedName]commonv1.AssociationConf `json:"-"`
 }
 
-// DownwardNodeLabels returns the set of expected node labels to be copied as annotations on the Elasticsearch Pods.
+// DownwardNodeLabels returns the set of expected node labels to be copied as annotations on the Elasticsearch Pods,
+// including the zone label if zone awareness is enabled.
 func (es Elasticsearch) DownwardNodeLabels() []string {
+	var nodeLabels []string
 	expectedAnnotations, exist := es.Annotations[DownwardNodeLabelsAnnotation]
 	expectedAnnotations = strings.TrimSpace(expectedAnnotations)
-	if !exist || expectedAnnotations == "" {
-		return nil
+	if exist && expectedAnnotations != "" {
+		nodeLabels = strings.Split(expectedAnnotations, ",")
+	}
+	if es.Spec.ZoneAwareness != nil {
+		if zoneLabel := es.Spec.ZoneAwareness.TopologyKeyOrDefault(); !stringsutil.StringInSlice(zoneLabel, nodeLabels) {
+			nodeLabels = append(nodeLabels, zoneLabel)
+		}
 	}
-	return strings.Split(expectedAnnotations, ",")
+	return nodeLabels
 }
 
 // HasDownwardNodeLabels returns true if some node labels are expected on the Elasticsearch Pods.
@@ -438,6 +517,12 @@ func (es Elasticsearch) IsConfiguredToAllowDowngrades() bool {
 	return exists && val == "true"
 }
 
+// IsSecureSettingsReloadEnabled returns true if the ReloadSecureSettings annotation is set to the value of true.
+func (es Elasticsearch) IsSecureSettingsReloadEnabled() bool {
+	val, exists := es.Annotations[ReloadSecureSettingsAnnotation]
+	return exists && val == "true"
+}
+
 func (es *Elasticsearch) ServiceAccountName() string {
 	return es.Spec.ServiceAccountName
 }
@@ -454,7 +539,27 @@ func (es Elasticsearch) AutoscalingSpec() string {
 }
 
 func (es Elasticsearch) SecureSettings() []commonv1.SecretSource {
-	return es.Spec.SecureSettings
+	if len(es.Spec.SnapshotRepositories) == 0 && len(es.Spec.Auth.OIDC) == 0 && len(es.Spec.Auth.LDAP) == 0 {
+		return es.Spec.SecureSettings
+	}
+	// the credentials of the snapshot repositories and the secrets of the OIDC and LDAP realms are stored in the
+	// keystore as well
+	secureSettings := append([]commonv1.SecretSource{}, es.Spec.SecureSettings...)
+	for _, repository := range es.Spec.SnapshotRepositories {
+		secureSettings = append(secureSettings, repository.SecureSettings...)
+	}
+	for _, realm := range es.Spec.Auth.OIDC {
+		secureSettings = append(secureSettings, realm.ClientSecretSource())
+	}
+	// the name of the bind password setting depends on the version, which is validated before any reconciliation
+	if ver, err := version.Parse(es.Spec.Version); err == nil {
+		for _, realm := range es.Spec.Auth.LDAP {
+			if realm.BindPasswordSecretName != "" {
+				secureSettings = append(secureSettings, realm.BindPasswordSource(ver))
+			}
+		}
+	}
+	return secureSettings
 }
 
 func (es Elasticsearch) SuspendedPodNames() set.StringSet {
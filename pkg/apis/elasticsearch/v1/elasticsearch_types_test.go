@@ -15,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
 	"github.com/elastic/cloud-on-k8s/pkg/utils/pointer"
 	"github.com/elastic/cloud-on-k8s/pkg/utils/set"
 )
@@ -258,6 +259,45 @@ func TestElasticsearch_SuspendedPodNames(t *testing.T) {
 	}
 }
 
+func TestElasticsearch_DownwardNodeLabels(t *testing.T) {
+	tests := []struct {
+		name          string
+		annotations   map[string]string
+		zoneAwareness *ZoneAwareness
+		want          []string
+	}{
+		{
+			name: "no node labels",
+		},
+		{
+			name:        "node labels from the annotation",
+			annotations: map[string]string{DownwardNodeLabelsAnnotation: "rack,topology.kubernetes.io/region"},
+			want:        []string{"rack", "topology.kubernetes.io/region"},
+		},
+		{
+			name:          "default zone label with zone awareness",
+			annotations:   map[string]string{DownwardNodeLabelsAnnotation: "rack"},
+			zoneAwareness: &ZoneAwareness{},
+			want:          []string{"rack", "topology.kubernetes.io/zone"},
+		},
+		{
+			name:          "custom zone label already in the annotation",
+			annotations:   map[string]string{DownwardNodeLabelsAnnotation: "rack,failure-domain"},
+			zoneAwareness: &ZoneAwareness{TopologyKey: "failure-domain"},
+			want:          []string{"rack", "failure-domain"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := Elasticsearch{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},
+				Spec:       ElasticsearchSpec{ZoneAwareness: tt.zoneAwareness},
+			}
+			assert.Equal(t, tt.want, es.DownwardNodeLabels())
+		})
+	}
+}
+
 func TestElasticsearch_DisabledPredicates(t *testing.T) {
 	tests := []struct {
 		name string
@@ -314,3 +354,81 @@ func TestElasticsearch_DisabledPredicates(t *testing.T) {
 		})
 	}
 }
+
+func TestElasticsearch_SecureSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		spec ElasticsearchSpec
+		want []commonv1.SecretSource
+	}{
+		{
+			name: "no secure settings",
+			spec: ElasticsearchSpec{},
+			want: nil,
+		},
+		{
+			name: "user secure settings",
+			spec: ElasticsearchSpec{SecureSettings: []commonv1.SecretSource{{SecretName: "user"}}},
+			want: []commonv1.SecretSource{{SecretName: "user"}},
+		},
+		{
+			name: "secure settings of the snapshot repositories and of the OIDC realms",
+			spec: ElasticsearchSpec{
+				SecureSettings:       []commonv1.SecretSource{{SecretName: "user"}},
+				SnapshotRepositories: []SnapshotRepository{{Name: "repo", SecureSettings: []commonv1.SecretSource{{SecretName: "repo-credentials"}}}},
+				Auth: Auth{OIDC: []OIDCRealm{{
+					Name: "oidc1",
+					RP:   OIDCRelyingParty{ClientSecretName: "oidc-client-secret"},
+				}}},
+			},
+			want: []commonv1.SecretSource{
+				{SecretName: "user"},
+				{SecretName: "repo-credentials"},
+				{
+					SecretName: "oidc-client-secret",
+					Entries:    []commonv1.KeyToPath{{Key: "client_secret", Path: "xpack.security.authc.realms.oidc.oidc1.rp.client_secret"}},
+				},
+			},
+		},
+		{
+			name: "bind passwords of the LDAP realms",
+			spec: ElasticsearchSpec{
+				Version: "7.16.0",
+				Auth: Auth{LDAP: []LDAPRealm{
+					{Name: "ldap1", BindDN: "cn=es,dc=example,dc=com", BindPasswordSecretName: "ldap-bind"},
+					{Name: "ad1", Type: ActiveDirectoryType, BindDN: "es@example.com", BindPasswordSecretName: "ad-bind"},
+					{Name: "anonymous"},
+				}},
+			},
+			want: []commonv1.SecretSource{
+				{
+					SecretName: "ldap-bind",
+					Entries:    []commonv1.KeyToPath{{Key: "bind_password", Path: "xpack.security.authc.realms.ldap.ldap1.secure_bind_password"}},
+				},
+				{
+					SecretName: "ad-bind",
+					Entries:    []commonv1.KeyToPath{{Key: "bind_password", Path: "xpack.security.authc.realms.active_directory.ad1.secure_bind_password"}},
+				},
+			},
+		},
+		{
+			name: "bind password of an LDAP realm before 7.0.0",
+			spec: ElasticsearchSpec{
+				Version: "6.8.0",
+				Auth:    Auth{LDAP: []LDAPRealm{{Name: "ldap1", BindDN: "cn=es,dc=example,dc=com", BindPasswordSecretName: "ldap-bind"}}},
+			},
+			want: []commonv1.SecretSource{
+				{
+					SecretName: "ldap-bind",
+					Entries:    []commonv1.KeyToPath{{Key: "bind_password", Path: "xpack.security.authc.realms.ldap1.secure_bind_password"}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := Elasticsearch{Spec: tt.spec}
+			require.Equal(t, tt.want, es.SecureSettings())
+		})
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1
+
+import (
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+)
+
+// IndexLifecyclePolicy is an index lifecycle management policy created in Elasticsearch by the operator.
+type IndexLifecyclePolicy struct {
+	// Name is the identifier of the policy in Elasticsearch.
+	Name string `json:"name"`
+	// Phases of the policy (hot, warm, cold, frozen, delete) with their minimum age and actions, as documented in the
+	// Elasticsearch documentation of the index lifecycle management API.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Phases *commonv1.Config `json:"phases"`
+}
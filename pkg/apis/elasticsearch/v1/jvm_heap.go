@@ -0,0 +1,14 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1
+
+// JVMHeap configures how the heap of the JVM running Elasticsearch is sized.
+type JVMHeap struct {
+	// FromMemoryLimit sets the minimum and maximum heap size of the JVM to half of the memory limit of the Elasticsearch
+	// container, capped below the compressed ordinary object pointers threshold. The heap size is updated when the
+	// memory limit changes. Heap sizes set by the user in the ES_JAVA_OPTS environment variable take precedence.
+	// +kubebuilder:validation:Optional
+	FromMemoryLimit bool `json:"fromMemoryLimit,omitempty"`
+}
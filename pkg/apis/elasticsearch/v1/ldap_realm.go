@@ -0,0 +1,117 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1
+
+import (
+	"fmt"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+)
+
+// LDAPRealmType is the type of a realm authenticating users against a directory.
+type LDAPRealmType string
+
+const (
+	LDAPDirectoryType   LDAPRealmType = "ldap"
+	ActiveDirectoryType LDAPRealmType = "active_directory"
+)
+
+const (
+	// LDAPBindPasswordKey is the key of the bind password in the secret referenced by an LDAP realm.
+	LDAPBindPasswordKey = "bind_password"
+)
+
+// RealmSettingsPrefix returns the prefix of the settings of a realm, which includes the type of the realm as of
+// Elasticsearch 7.0.0.
+func RealmSettingsPrefix(ver version.Version, realmType string, name string) string {
+	if ver.LT(version.From(7, 0, 0)) {
+		return fmt.Sprintf("xpack.security.authc.realms.%s", name)
+	}
+	return fmt.Sprintf("xpack.security.authc.realms.%s.%s", realmType, name)
+}
+
+// LDAPRealm configures a realm authenticating users against an LDAP directory or an Active Directory domain.
+type LDAPRealm struct {
+	// Name of the realm in Elasticsearch. It must be unique across all the realms, and only contain alphanumeric
+	// characters, hyphens and underscores.
+	Name string `json:"name"`
+	// Order of the realm in the realm chain. It must be unique across all the realms.
+	Order int32 `json:"order"`
+	// Type of the realm: ldap, or active_directory to authenticate users against an Active Directory domain.
+	// Defaults to ldap.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=ldap;active_directory
+	Type LDAPRealmType `json:"type,omitempty"`
+	// URLs of the directory servers, such as ldaps://ldap.example.com:636. They are required for the ldap type. For the
+	// active_directory type, they default to the domain controllers of the domain.
+	// +kubebuilder:validation:Optional
+	URLs []string `json:"urls,omitempty"`
+	// DomainName is the name of the Active Directory domain. It is required for the active_directory type, and not
+	// allowed for the ldap type.
+	// +kubebuilder:validation:Optional
+	DomainName string `json:"domainName,omitempty"`
+	// BindDN is the distinguished name of the user Elasticsearch binds as to search the directory. It must be set
+	// along with BindPasswordSecretName.
+	// +kubebuilder:validation:Optional
+	BindDN string `json:"bindDN,omitempty"`
+	// BindPasswordSecretName references a secret in the same namespace as the Elasticsearch resource, holding the
+	// password of the bind user under the bind_password entry. It is stored in the keystore of the Elasticsearch nodes.
+	// +kubebuilder:validation:Optional
+	BindPasswordSecretName string `json:"bindPasswordSecretName,omitempty"`
+	// UserSearch configures the search of the users in the directory. For the ldap type, exactly one of UserSearch
+	// and UserDNTemplates must be set.
+	// +kubebuilder:validation:Optional
+	UserSearch *LDAPUserSearch `json:"userSearch,omitempty"`
+	// UserDNTemplates are the templates of the distinguished names of the users, such as
+	// cn={0},ou=users,dc=example,dc=com where {0} is the username. They are not allowed for the active_directory type.
+	// +kubebuilder:validation:Optional
+	UserDNTemplates []string `json:"userDNTemplates,omitempty"`
+	// GroupSearch configures the search of the groups of the users in the directory.
+	// +kubebuilder:validation:Optional
+	GroupSearch *LDAPGroupSearch `json:"groupSearch,omitempty"`
+	// CertificateAuthoritiesSecretName references a secret in the same namespace as the Elasticsearch resource,
+	// holding the PEM encoded certificate authorities trusted to connect to the directory servers under the ca.crt
+	// entry.
+	// +kubebuilder:validation:Optional
+	CertificateAuthoritiesSecretName string `json:"certificateAuthoritiesSecretName,omitempty"`
+	// Config holds additional settings of the realm, relative to the realm prefix, such as unmapped_groups_as_roles
+	// or timeout.tcp_read. Settings generated from the other fields take precedence.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Config *commonv1.Config `json:"config,omitempty"`
+}
+
+// TypeOrDefault returns the type of the realm, or the ldap type if not set.
+func (r LDAPRealm) TypeOrDefault() LDAPRealmType {
+	if r.Type == "" {
+		return LDAPDirectoryType
+	}
+	return r.Type
+}
+
+// BindPasswordSource returns the source of the bind password of the realm, to be stored in the keystore.
+func (r LDAPRealm) BindPasswordSource(ver version.Version) commonv1.SecretSource {
+	setting := RealmSettingsPrefix(ver, string(r.TypeOrDefault()), r.Name) + ".secure_bind_password"
+	return commonv1.SecretSource{
+		SecretName: r.BindPasswordSecretName,
+		Entries:    []commonv1.KeyToPath{{Key: LDAPBindPasswordKey, Path: setting}},
+	}
+}
+
+// LDAPUserSearch configures the search of the users in the directory.
+type LDAPUserSearch struct {
+	// BaseDN is the distinguished name of the container to search the users in.
+	BaseDN string `json:"baseDN"`
+	// Filter is the LDAP filter matching the users, such as (uid={0}) where {0} is the username.
+	// +kubebuilder:validation:Optional
+	Filter string `json:"filter,omitempty"`
+}
+
+// LDAPGroupSearch configures the search of the groups of the users in the directory.
+type LDAPGroupSearch struct {
+	// BaseDN is the distinguished name of the container to search the groups in.
+	BaseDN string `json:"baseDN"`
+}
@@ -0,0 +1,106 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1
+
+import (
+	"fmt"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+)
+
+const (
+	// OIDCClientSecretKey is the key of the client secret in the secret referenced by an OpenID Connect realm.
+	OIDCClientSecretKey = "client_secret"
+)
+
+// OIDCRealm configures a realm authenticating users through an OpenID Connect provider.
+type OIDCRealm struct {
+	// Name of the realm in Elasticsearch. It must be unique across all the realms, and only contain alphanumeric
+	// characters, hyphens and underscores.
+	Name string `json:"name"`
+	// Order of the realm in the realm chain. It must be unique across all the realms.
+	Order int32 `json:"order"`
+	// OP is the OpenID Connect provider authenticating the users.
+	OP OIDCProvider `json:"op"`
+	// RP describes Kibana as the relying party of the realm.
+	RP OIDCRelyingParty `json:"rp"`
+	// Claims maps the claims released by the OpenID Connect provider to the properties of the users.
+	Claims OIDCClaims `json:"claims"`
+	// Config holds additional settings of the realm, relative to the realm prefix, such as rp.requested_scopes or
+	// op.userinfo_endpoint. Settings generated from the other fields take precedence.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Config *commonv1.Config `json:"config,omitempty"`
+}
+
+// ClientSecretSetting returns the name of the secure setting holding the client secret of the realm.
+func (r OIDCRealm) ClientSecretSetting() string {
+	return fmt.Sprintf("xpack.security.authc.realms.oidc.%s.rp.client_secret", r.Name)
+}
+
+// ClientSecretSource returns the source of the client secret of the realm, to be stored in the keystore.
+func (r OIDCRealm) ClientSecretSource() commonv1.SecretSource {
+	return commonv1.SecretSource{
+		SecretName: r.RP.ClientSecretName,
+		Entries:    []commonv1.KeyToPath{{Key: OIDCClientSecretKey, Path: r.ClientSecretSetting()}},
+	}
+}
+
+// OIDCProvider describes the OpenID Connect provider of a realm.
+type OIDCProvider struct {
+	// Issuer is the issuer identifier of the OpenID Connect provider.
+	Issuer string `json:"issuer"`
+	// AuthorizationEndpoint is the URL of the authorization endpoint of the OpenID Connect provider.
+	AuthorizationEndpoint string `json:"authorizationEndpoint"`
+	// TokenEndpoint is the URL of the token endpoint of the OpenID Connect provider.
+	TokenEndpoint string `json:"tokenEndpoint"`
+	// JWKSetURL is the URL of the JSON Web Key Set of the OpenID Connect provider, used to validate the ID tokens.
+	JWKSetURL string `json:"jwkSetURL"`
+	// UserInfoEndpoint is the URL of the user info endpoint of the OpenID Connect provider.
+	// +kubebuilder:validation:Optional
+	UserInfoEndpoint string `json:"userInfoEndpoint,omitempty"`
+	// EndSessionEndpoint is the URL of the end session endpoint of the OpenID Connect provider, used for single logout.
+	// +kubebuilder:validation:Optional
+	EndSessionEndpoint string `json:"endSessionEndpoint,omitempty"`
+}
+
+// OIDCRelyingParty describes Kibana as the relying party of an OpenID Connect realm.
+type OIDCRelyingParty struct {
+	// ClientID is the identifier of Kibana at the OpenID Connect provider.
+	ClientID string `json:"clientID"`
+	// ClientSecretName references a secret in the same namespace as the Elasticsearch resource, holding the client
+	// secret of Kibana at the OpenID Connect provider under the client_secret entry. It is stored in the keystore of
+	// the Elasticsearch nodes.
+	ClientSecretName string `json:"clientSecretName"`
+	// RedirectURI is the URL the OpenID Connect provider redirects the users to after authentication, usually
+	// <kibana-url>/api/security/oidc/callback.
+	RedirectURI string `json:"redirectURI"`
+	// PostLogoutRedirectURI is the URL the OpenID Connect provider redirects the users to after logout, usually
+	// <kibana-url>/security/logged_out.
+	// +kubebuilder:validation:Optional
+	PostLogoutRedirectURI string `json:"postLogoutRedirectURI,omitempty"`
+	// ResponseType is the OAuth 2.0 response type, which defines the authentication flow. Defaults to code.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=code;id_token;id_token token
+	ResponseType string `json:"responseType,omitempty"`
+}
+
+// OIDCClaims maps the claims released by the OpenID Connect provider to the properties of the users.
+type OIDCClaims struct {
+	// Principal is the claim holding the username of the users.
+	Principal string `json:"principal"`
+	// Groups is the claim holding the groups of the users, used in role mappings.
+	// +kubebuilder:validation:Optional
+	Groups string `json:"groups,omitempty"`
+	// Name is the claim holding the full name of the users.
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+	// Mail is the claim holding the email address of the users.
+	// +kubebuilder:validation:Optional
+	Mail string `json:"mail,omitempty"`
+	// DN is the claim holding the distinguished name of the users.
+	// +kubebuilder:validation:Optional
+	DN string `json:"dn,omitempty"`
+}
@@ -0,0 +1,34 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1
+
+// Plugin is an Elasticsearch plugin installed on all the nodes of the cluster before Elasticsearch starts.
+type Plugin struct {
+	// Name of the plugin. Official plugins are installed by name, unless a URL or a bundle is specified.
+	Name string `json:"name"`
+	// URL to install the plugin from. Plugin archives available in a volume of the Pod template can be installed with a
+	// file:// URL.
+	// +kubebuilder:validation:Optional
+	URL string `json:"url,omitempty"`
+	// Bundle references a ConfigMap holding the plugin archive, to install the plugin without network access.
+	// +kubebuilder:validation:Optional
+	Bundle *PluginBundle `json:"bundle,omitempty"`
+}
+
+// PluginBundle references a plugin archive stored in a ConfigMap in the namespace of the Elasticsearch resource.
+type PluginBundle struct {
+	// ConfigMapName is the name of the ConfigMap holding the plugin archive.
+	ConfigMapName string `json:"configMapName"`
+	// Key of the plugin archive in the binary data of the ConfigMap.
+	Key string `json:"key"`
+}
+
+// PluginStatus is a plugin installed on the Elasticsearch nodes, as reported by Elasticsearch.
+type PluginStatus struct {
+	// Name of the plugin.
+	Name string `json:"name"`
+	// Version of the plugin.
+	Version string `json:"version"`
+}
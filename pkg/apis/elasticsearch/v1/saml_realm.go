@@ -0,0 +1,80 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1
+
+import (
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+)
+
+const (
+	// SAMLMetadataKey is the key of the IdP metadata in the secret referenced by a SAML realm.
+	SAMLMetadataKey = "metadata.xml"
+)
+
+// SAMLRealm configures a realm authenticating users through a SAML identity provider.
+type SAMLRealm struct {
+	// Name of the realm in Elasticsearch. It must be unique across all the realms, and only contain alphanumeric
+	// characters, hyphens and underscores.
+	Name string `json:"name"`
+	// Order of the realm in the realm chain. It must be unique across all the realms.
+	Order int32 `json:"order"`
+	// IdP is the SAML identity provider authenticating the users.
+	IdP SAMLIdentityProvider `json:"idp"`
+	// SP describes Kibana as the SAML service provider of the realm.
+	SP SAMLServiceProvider `json:"sp"`
+	// Attributes maps the SAML attributes released by the identity provider to the properties of the users.
+	Attributes SAMLAttributes `json:"attributes"`
+	// SigningSecretName references a secret in the same namespace as the Elasticsearch resource, holding the PEM
+	// encoded certificate and private key used to sign the SAML messages under the tls.crt and tls.key entries.
+	// +kubebuilder:validation:Optional
+	SigningSecretName string `json:"signingSecretName,omitempty"`
+	// EncryptionSecretName references a secret in the same namespace as the Elasticsearch resource, holding the PEM
+	// encoded certificate and private key used to decrypt the SAML messages under the tls.crt and tls.key entries.
+	// +kubebuilder:validation:Optional
+	EncryptionSecretName string `json:"encryptionSecretName,omitempty"`
+	// Config holds additional settings of the realm, relative to the realm prefix, such as nameid_format or
+	// force_authn. Settings generated from the other fields take precedence.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Config *commonv1.Config `json:"config,omitempty"`
+}
+
+// SAMLIdentityProvider describes the SAML identity provider of a realm.
+type SAMLIdentityProvider struct {
+	// EntityID is the SAML entity ID of the identity provider.
+	EntityID string `json:"entityID"`
+	// MetadataSecretName references a secret in the same namespace as the Elasticsearch resource, holding the SAML
+	// metadata of the identity provider under the metadata.xml entry.
+	MetadataSecretName string `json:"metadataSecretName"`
+}
+
+// SAMLServiceProvider describes Kibana as the SAML service provider of a realm.
+type SAMLServiceProvider struct {
+	// EntityID is the SAML entity ID of Kibana, usually its URL.
+	EntityID string `json:"entityID"`
+	// ACS is the URL of the assertion consumer service of Kibana, usually <kibana-url>/api/security/saml/callback.
+	ACS string `json:"acs"`
+	// Logout is the URL of the single logout service of Kibana, usually <kibana-url>/logout.
+	// +kubebuilder:validation:Optional
+	Logout string `json:"logout,omitempty"`
+}
+
+// SAMLAttributes maps the SAML attributes released by the identity provider to the properties of the users.
+type SAMLAttributes struct {
+	// Principal is the SAML attribute holding the username of the users.
+	Principal string `json:"principal"`
+	// Groups is the SAML attribute holding the groups of the users, used in role mappings.
+	// +kubebuilder:validation:Optional
+	Groups string `json:"groups,omitempty"`
+	// Name is the SAML attribute holding the full name of the users.
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+	// Mail is the SAML attribute holding the email address of the users.
+	// +kubebuilder:validation:Optional
+	Mail string `json:"mail,omitempty"`
+	// DN is the SAML attribute holding the distinguished name of the users.
+	// +kubebuilder:validation:Optional
+	DN string `json:"dn,omitempty"`
+}
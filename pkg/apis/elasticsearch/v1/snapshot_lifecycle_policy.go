@@ -0,0 +1,55 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1
+
+import (
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+)
+
+// SnapshotLifecyclePolicy is a snapshot lifecycle management policy created in Elasticsearch by the operator.
+type SnapshotLifecyclePolicy struct {
+	// Name is the identifier of the policy in Elasticsearch.
+	Name string `json:"name"`
+	// SnapshotName is the name of the snapshots taken by the policy, supporting date math.
+	// Defaults to <name-{now/d}>, name being the name of the policy.
+	// +kubebuilder:validation:Optional
+	SnapshotName string `json:"snapshotName,omitempty"`
+	// Schedule is the cron expression defining when the snapshots are taken.
+	Schedule string `json:"schedule"`
+	// Repository is the name of the snapshot repository holding the snapshots, usually one of the repositories declared
+	// in spec.snapshotRepositories.
+	Repository string `json:"repository"`
+	// Config of the snapshots (indices, include_global_state, ...), as documented in the Elasticsearch documentation
+	// of the snapshot lifecycle management API.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Config *commonv1.Config `json:"config,omitempty"`
+	// Retention defines which snapshots taken by the policy are deleted, and when.
+	// +kubebuilder:validation:Optional
+	Retention *SnapshotRetention `json:"retention,omitempty"`
+}
+
+// SnapshotNameOrDefault returns the name of the snapshots taken by the policy.
+func (p SnapshotLifecyclePolicy) SnapshotNameOrDefault() string {
+	if p.SnapshotName == "" {
+		return "<" + p.Name + "-{now/d}>"
+	}
+	return p.SnapshotName
+}
+
+// SnapshotRetention defines the retention of the snapshots taken by a snapshot lifecycle management policy.
+type SnapshotRetention struct {
+	// ExpireAfter is the time period after which a snapshot is eligible for deletion, for example 30d.
+	// +kubebuilder:validation:Optional
+	ExpireAfter string `json:"expireAfter,omitempty"`
+	// MinCount is the minimum number of snapshots to retain, even if they expired.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	MinCount *int32 `json:"minCount,omitempty"`
+	// MaxCount is the maximum number of snapshots to retain, even if they did not expire yet.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	MaxCount *int32 `json:"maxCount,omitempty"`
+}
@@ -0,0 +1,37 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1
+
+import (
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+)
+
+// SnapshotRepositoryType is the type of a snapshot repository.
+type SnapshotRepositoryType string
+
+const (
+	S3SnapshotRepository         SnapshotRepositoryType = "s3"
+	GCSSnapshotRepository        SnapshotRepositoryType = "gcs"
+	AzureSnapshotRepository      SnapshotRepositoryType = "azure"
+	FileSystemSnapshotRepository SnapshotRepositoryType = "fs"
+)
+
+// SnapshotRepository is a snapshot repository registered in Elasticsearch by the operator.
+type SnapshotRepository struct {
+	// Name of the repository in Elasticsearch.
+	Name string `json:"name"`
+	// Type of the repository: s3, gcs, azure or fs. The matching repository plugin must be installed for the s3, gcs
+	// and azure types before Elasticsearch 8.0.0.
+	// +kubebuilder:validation:Enum=s3;gcs;azure;fs
+	Type SnapshotRepositoryType `json:"type"`
+	// Settings of the repository, as documented for its type in the Elasticsearch documentation.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Settings *commonv1.Config `json:"settings,omitempty"`
+	// SecureSettings is a list of references to Kubernetes secrets holding the credentials of the repository client.
+	// They are added to the Elasticsearch keystore along with the secure settings of the cluster.
+	// +kubebuilder:validation:Optional
+	SecureSettings []commonv1.SecretSource `json:"secureSettings,omitempty"`
+}
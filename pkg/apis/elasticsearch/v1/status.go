@@ -81,6 +81,16 @@ type ElasticsearchStatus struct {
 	// If the generation observed in status diverges from the generation in metadata, the Elasticsearch
 	// controller has not yet processed the changes contained in the Elasticsearch specification.
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Plugins are the plugins installed on the Elasticsearch nodes, as reported by Elasticsearch. Only reported if
+	// plugins are specified in the Elasticsearch specification.
+	// +optional
+	Plugins []PluginStatus `json:"plugins,omitempty"`
+
+	// CrossClusterReplication summarizes the replication of the follower indices of each remote cluster. Only reported
+	// if auto-follow patterns are specified in the Elasticsearch specification.
+	// +optional
+	CrossClusterReplication []CrossClusterReplicationStatus `json:"crossClusterReplication,omitempty"`
 }
 
 // IsDegraded returns true if the current status is worse than the previous.
@@ -112,6 +122,14 @@ const (
 	ElasticsearchIsReachable ConditionType = "ElasticsearchIsReachable"
 	ReconciliationComplete   ConditionType = "ReconciliationComplete"
 	RunningDesiredVersion    ConditionType = "RunningDesiredVersion"
+	// SnapshotRepositoriesRegistered is only reported if snapshot repositories are managed by the operator.
+	SnapshotRepositoriesRegistered ConditionType = "SnapshotRepositoriesRegistered"
+	// SnapshotLifecyclePoliciesApplied is only reported if snapshot lifecycle policies are managed by the operator.
+	SnapshotLifecyclePoliciesApplied ConditionType = "SnapshotLifecyclePoliciesApplied"
+	// IndexLifecyclePoliciesApplied is only reported if index lifecycle policies are managed by the operator.
+	IndexLifecyclePoliciesApplied ConditionType = "IndexLifecyclePoliciesApplied"
+	// AutoFollowPatternsApplied is only reported if auto-follow patterns are managed by the operator.
+	AutoFollowPatternsApplied ConditionType = "AutoFollowPatternsApplied"
 )
 
 // Condition represents Elasticsearch resource's condition.
@@ -0,0 +1,24 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ZoneAwareness configures the shard allocation awareness of Elasticsearch with the zones of the Kubernetes nodes.
+type ZoneAwareness struct {
+	// TopologyKey is the label of the Kubernetes nodes holding their zone. Defaults to topology.kubernetes.io/zone.
+	// +kubebuilder:validation:Optional
+	TopologyKey string `json:"topologyKey,omitempty"`
+}
+
+// TopologyKeyOrDefault returns the label of the Kubernetes nodes holding their zone.
+func (z ZoneAwareness) TopologyKeyOrDefault() string {
+	if z.TopologyKey == "" {
+		return corev1.LabelTopologyZone
+	}
+	return z.TopologyKey
+}
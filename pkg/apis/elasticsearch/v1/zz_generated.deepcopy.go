@@ -29,6 +29,27 @@ func (in *Auth) DeepCopyInto(out *Auth) {
 		*out = make([]FileRealmSource, len(*in))
 		copy(*out, *in)
 	}
+	if in.SAML != nil {
+		in, out := &in.SAML, &out.SAML
+		*out = make([]SAMLRealm, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OIDC != nil {
+		in, out := &in.OIDC, &out.OIDC
+		*out = make([]OIDCRealm, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LDAP != nil {
+		in, out := &in.LDAP, &out.LDAP
+		*out = make([]LDAPRealm, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Auth.
@@ -41,6 +62,26 @@ func (in *Auth) DeepCopy() *Auth {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoFollowPattern) DeepCopyInto(out *AutoFollowPattern) {
+	*out = *in
+	if in.LeaderIndexPatterns != nil {
+		in, out := &in.LeaderIndexPatterns, &out.LeaderIndexPatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoFollowPattern.
+func (in *AutoFollowPattern) DeepCopy() *AutoFollowPattern {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoFollowPattern)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ChangeBudget) DeepCopyInto(out *ChangeBudget) {
 	*out = *in
@@ -123,6 +164,21 @@ func (in Conditions) DeepCopy() Conditions {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CrossClusterReplicationStatus) DeepCopyInto(out *CrossClusterReplicationStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CrossClusterReplicationStatus.
+func (in *CrossClusterReplicationStatus) DeepCopy() *CrossClusterReplicationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CrossClusterReplicationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DownscaleOperation) DeepCopyInto(out *DownscaleOperation) {
 	*out = *in
@@ -287,9 +343,49 @@ func (in *ElasticsearchSpec) DeepCopyInto(out *ElasticsearchSpec) {
 	if in.RemoteClusters != nil {
 		in, out := &in.RemoteClusters, &out.RemoteClusters
 		*out = make([]RemoteCluster, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	in.Monitoring.DeepCopyInto(&out.Monitoring)
+	if in.ZoneAwareness != nil {
+		in, out := &in.ZoneAwareness, &out.ZoneAwareness
+		*out = new(ZoneAwareness)
+		**out = **in
+	}
+	if in.Plugins != nil {
+		in, out := &in.Plugins, &out.Plugins
+		*out = make([]Plugin, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.JVMHeap != nil {
+		in, out := &in.JVMHeap, &out.JVMHeap
+		*out = new(JVMHeap)
+		**out = **in
+	}
+	if in.SnapshotRepositories != nil {
+		in, out := &in.SnapshotRepositories, &out.SnapshotRepositories
+		*out = make([]SnapshotRepository, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SnapshotLifecyclePolicies != nil {
+		in, out := &in.SnapshotLifecyclePolicies, &out.SnapshotLifecyclePolicies
+		*out = make([]SnapshotLifecyclePolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.IndexLifecyclePolicies != nil {
+		in, out := &in.IndexLifecyclePolicies, &out.IndexLifecyclePolicies
+		*out = make([]IndexLifecyclePolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticsearchSpec.
@@ -320,6 +416,16 @@ func (in *ElasticsearchStatus) DeepCopyInto(out *ElasticsearchStatus) {
 		}
 	}
 	in.InProgressOperations.DeepCopyInto(&out.InProgressOperations)
+	if in.Plugins != nil {
+		in, out := &in.Plugins, &out.Plugins
+		*out = make([]PluginStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.CrossClusterReplication != nil {
+		in, out := &in.CrossClusterReplication, &out.CrossClusterReplication
+		*out = make([]CrossClusterReplicationStatus, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticsearchStatus.
@@ -387,6 +493,109 @@ func (in *InProgressOperations) DeepCopy() *InProgressOperations {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexLifecyclePolicy) DeepCopyInto(out *IndexLifecyclePolicy) {
+	*out = *in
+	if in.Phases != nil {
+		in, out := &in.Phases, &out.Phases
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexLifecyclePolicy.
+func (in *IndexLifecyclePolicy) DeepCopy() *IndexLifecyclePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexLifecyclePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JVMHeap) DeepCopyInto(out *JVMHeap) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVMHeap.
+func (in *JVMHeap) DeepCopy() *JVMHeap {
+	if in == nil {
+		return nil
+	}
+	out := new(JVMHeap)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPGroupSearch) DeepCopyInto(out *LDAPGroupSearch) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPGroupSearch.
+func (in *LDAPGroupSearch) DeepCopy() *LDAPGroupSearch {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPGroupSearch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPRealm) DeepCopyInto(out *LDAPRealm) {
+	*out = *in
+	if in.URLs != nil {
+		in, out := &in.URLs, &out.URLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UserSearch != nil {
+		in, out := &in.UserSearch, &out.UserSearch
+		*out = new(LDAPUserSearch)
+		**out = **in
+	}
+	if in.UserDNTemplates != nil {
+		in, out := &in.UserDNTemplates, &out.UserDNTemplates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GroupSearch != nil {
+		in, out := &in.GroupSearch, &out.GroupSearch
+		*out = new(LDAPGroupSearch)
+		**out = **in
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPRealm.
+func (in *LDAPRealm) DeepCopy() *LDAPRealm {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPRealm)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPUserSearch) DeepCopyInto(out *LDAPUserSearch) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPUserSearch.
+func (in *LDAPUserSearch) DeepCopy() *LDAPUserSearch {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPUserSearch)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LogsMonitoring) DeepCopyInto(out *LogsMonitoring) {
 	*out = *in
@@ -546,10 +755,144 @@ func (in *NodeSet) DeepCopy() *NodeSet {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCClaims) DeepCopyInto(out *OIDCClaims) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCClaims.
+func (in *OIDCClaims) DeepCopy() *OIDCClaims {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCClaims)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCProvider) DeepCopyInto(out *OIDCProvider) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCProvider.
+func (in *OIDCProvider) DeepCopy() *OIDCProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCRealm) DeepCopyInto(out *OIDCRealm) {
+	*out = *in
+	out.OP = in.OP
+	out.RP = in.RP
+	out.Claims = in.Claims
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCRealm.
+func (in *OIDCRealm) DeepCopy() *OIDCRealm {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCRealm)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCRelyingParty) DeepCopyInto(out *OIDCRelyingParty) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCRelyingParty.
+func (in *OIDCRelyingParty) DeepCopy() *OIDCRelyingParty {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCRelyingParty)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Plugin) DeepCopyInto(out *Plugin) {
+	*out = *in
+	if in.Bundle != nil {
+		in, out := &in.Bundle, &out.Bundle
+		*out = new(PluginBundle)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Plugin.
+func (in *Plugin) DeepCopy() *Plugin {
+	if in == nil {
+		return nil
+	}
+	out := new(Plugin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginBundle) DeepCopyInto(out *PluginBundle) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginBundle.
+func (in *PluginBundle) DeepCopy() *PluginBundle {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginBundle)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginStatus) DeepCopyInto(out *PluginStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginStatus.
+func (in *PluginStatus) DeepCopy() *PluginStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RemoteCluster) DeepCopyInto(out *RemoteCluster) {
 	*out = *in
 	out.ElasticsearchRef = in.ElasticsearchRef
+	if in.Seeds != nil {
+		in, out := &in.Seeds, &out.Seeds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SkipUnavailable != nil {
+		in, out := &in.SkipUnavailable, &out.SkipUnavailable
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AutoFollowPatterns != nil {
+		in, out := &in.AutoFollowPatterns, &out.AutoFollowPatterns
+		*out = make([]AutoFollowPattern, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteCluster.
@@ -578,6 +921,148 @@ func (in *RoleSource) DeepCopy() *RoleSource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLAttributes) DeepCopyInto(out *SAMLAttributes) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLAttributes.
+func (in *SAMLAttributes) DeepCopy() *SAMLAttributes {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLAttributes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLIdentityProvider) DeepCopyInto(out *SAMLIdentityProvider) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLIdentityProvider.
+func (in *SAMLIdentityProvider) DeepCopy() *SAMLIdentityProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLIdentityProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLRealm) DeepCopyInto(out *SAMLRealm) {
+	*out = *in
+	out.IdP = in.IdP
+	out.SP = in.SP
+	out.Attributes = in.Attributes
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLRealm.
+func (in *SAMLRealm) DeepCopy() *SAMLRealm {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLRealm)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAMLServiceProvider) DeepCopyInto(out *SAMLServiceProvider) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAMLServiceProvider.
+func (in *SAMLServiceProvider) DeepCopy() *SAMLServiceProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(SAMLServiceProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotLifecyclePolicy) DeepCopyInto(out *SnapshotLifecyclePolicy) {
+	*out = *in
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = (*in).DeepCopy()
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(SnapshotRetention)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotLifecyclePolicy.
+func (in *SnapshotLifecyclePolicy) DeepCopy() *SnapshotLifecyclePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotLifecyclePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotRepository) DeepCopyInto(out *SnapshotRepository) {
+	*out = *in
+	if in.Settings != nil {
+		in, out := &in.Settings, &out.Settings
+		*out = (*in).DeepCopy()
+	}
+	if in.SecureSettings != nil {
+		in, out := &in.SecureSettings, &out.SecureSettings
+		*out = make([]commonv1.SecretSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotRepository.
+func (in *SnapshotRepository) DeepCopy() *SnapshotRepository {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotRepository)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotRetention) DeepCopyInto(out *SnapshotRetention) {
+	*out = *in
+	if in.MinCount != nil {
+		in, out := &in.MinCount, &out.MinCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxCount != nil {
+		in, out := &in.MaxCount, &out.MaxCount
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotRetention.
+func (in *SnapshotRetention) DeepCopy() *SnapshotRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TransportConfig) DeepCopyInto(out *TransportConfig) {
 	*out = *in
@@ -702,3 +1187,18 @@ func (in *UpscaleOperation) DeepCopy() *UpscaleOperation {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZoneAwareness) DeepCopyInto(out *ZoneAwareness) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZoneAwareness.
+func (in *ZoneAwareness) DeepCopy() *ZoneAwareness {
+	if in == nil {
+		return nil
+	}
+	out := new(ZoneAwareness)
+	in.DeepCopyInto(out)
+	return out
+}
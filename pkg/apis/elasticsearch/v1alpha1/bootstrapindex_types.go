@@ -0,0 +1,115 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+)
+
+const (
+	// BootstrapIndexKind is inferred from the struct name using reflection in SchemeBuilder.Register()
+	// we duplicate it as a constant here for practical purposes.
+	BootstrapIndexKind = "BootstrapIndex"
+)
+
+// BootstrapIndexSpec holds the specification of an index or a data stream to create once in Elasticsearch.
+type BootstrapIndexSpec struct {
+	// ElasticsearchRef is a reference to the Elasticsearch cluster the index or data stream is created in.
+	ElasticsearchRef ElasticsearchRef `json:"elasticsearchRef"`
+
+	// Name of the index or data stream in Elasticsearch. Defaults to the name of the resource.
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+
+	// DataStream creates a data stream instead of a regular index. A composable index template with data streams
+	// enabled must match its name, and its settings, mappings and aliases are the ones of the template.
+	// Available as of Elasticsearch 7.9.0.
+	// +kubebuilder:validation:Optional
+	DataStream bool `json:"dataStream,omitempty"`
+
+	// Settings of the index, as documented in the Elasticsearch documentation of the create index API.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Settings *commonv1.Config `json:"settings,omitempty"`
+
+	// Mappings of the index, as documented in the Elasticsearch documentation of the create index API.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Mappings *commonv1.Config `json:"mappings,omitempty"`
+
+	// Aliases of the index, as documented in the Elasticsearch documentation of the create index API.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Aliases *commonv1.Config `json:"aliases,omitempty"`
+}
+
+// BootstrapIndexPhase is the phase of the bootstrap of an index or a data stream.
+type BootstrapIndexPhase string
+
+const (
+	// BootstrapIndexPendingPhase is used while waiting for the Elasticsearch cluster to be available.
+	BootstrapIndexPendingPhase BootstrapIndexPhase = "Pending"
+	// BootstrapIndexCreatedPhase is used once the index or data stream exists in Elasticsearch, whether it was created
+	// by the operator or already existed.
+	BootstrapIndexCreatedPhase BootstrapIndexPhase = "Created"
+	// BootstrapIndexFailedPhase is used when Elasticsearch rejected the creation of the index or data stream.
+	BootstrapIndexFailedPhase BootstrapIndexPhase = "Failed"
+)
+
+// BootstrapIndexStatus defines the observed state of the bootstrap of an index or a data stream.
+type BootstrapIndexStatus struct {
+	// Phase of the bootstrap.
+	Phase BootstrapIndexPhase `json:"phase,omitempty"`
+
+	// Message gives details about the current phase.
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BootstrapIndex represents an index or a data stream created once in an Elasticsearch cluster. The operator never
+// updates nor deletes it, and does not create it again if it is deleted through the Elasticsearch API.
+// +kubebuilder:resource:categories=elastic,shortName=esbootstrap
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="elasticsearch",type="string",JSONPath=".spec.elasticsearchRef.name",description="Elasticsearch cluster"
+// +kubebuilder:printcolumn:name="phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:storageversion
+type BootstrapIndex struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BootstrapIndexSpec   `json:"spec,omitempty"`
+	Status BootstrapIndexStatus `json:"status,omitempty"`
+}
+
+// ElasticsearchKey returns the namespaced name of the referenced Elasticsearch cluster.
+func (b BootstrapIndex) ElasticsearchKey() types.NamespacedName {
+	return types.NamespacedName{Namespace: b.Namespace, Name: b.Spec.ElasticsearchRef.Name}
+}
+
+// IndexName returns the name of the index or data stream in Elasticsearch.
+func (b BootstrapIndex) IndexName() string {
+	if b.Spec.Name != "" {
+		return b.Spec.Name
+	}
+	return b.Name
+}
+
+// +kubebuilder:object:root=true
+
+// BootstrapIndexList contains a list of BootstrapIndex
+type BootstrapIndexList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BootstrapIndex `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BootstrapIndex{}, &BootstrapIndexList{})
+}
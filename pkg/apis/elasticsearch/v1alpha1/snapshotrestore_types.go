@@ -0,0 +1,137 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1alpha1
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+)
+
+const (
+	// SnapshotRestoreKind is inferred from the struct name using reflection in SchemeBuilder.Register()
+	// we duplicate it as a constant here for practical purposes.
+	SnapshotRestoreKind = "SnapshotRestore"
+)
+
+// SnapshotRestoreSpec holds the specification of the restore of an Elasticsearch snapshot.
+type SnapshotRestoreSpec struct {
+	// ElasticsearchRef is a reference to the Elasticsearch cluster the snapshot is restored into.
+	ElasticsearchRef ElasticsearchRef `json:"elasticsearchRef"`
+
+	// Repository is the name of the snapshot repository holding the snapshot. The repository must be registered in
+	// the Elasticsearch cluster.
+	// +kubebuilder:validation:MinLength=1
+	Repository string `json:"repository"`
+
+	// Snapshot is the name of the snapshot to restore. Wildcard patterns such as `nightly-*` are resolved to the most
+	// recent successful snapshot matching the pattern.
+	// +kubebuilder:validation:MinLength=1
+	Snapshot string `json:"snapshot"`
+
+	// Indices to restore. Defaults to all the indices and data streams of the snapshot.
+	// +kubebuilder:validation:Optional
+	Indices []string `json:"indices,omitempty"`
+
+	// Config holds additional parameters of the restore request such as `include_global_state` or `rename_pattern`.
+	// See: https://www.elastic.co/guide/en/elasticsearch/reference/current/restore-snapshot-api.html#restore-snapshot-api-request-body
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Config *commonv1.Config `json:"config,omitempty"`
+}
+
+// ElasticsearchRef is a reference to an Elasticsearch cluster in the same namespace.
+type ElasticsearchRef struct {
+	// Name of the Elasticsearch cluster.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// SnapshotRestorePhase is the phase of a snapshot restore.
+type SnapshotRestorePhase string
+
+const (
+	// SnapshotRestorePendingPhase is used while waiting for the Elasticsearch cluster or the snapshot to be available.
+	SnapshotRestorePendingPhase SnapshotRestorePhase = "Pending"
+	// SnapshotRestoreInProgressPhase is used once the restore is being submitted to Elasticsearch, and until it completes.
+	SnapshotRestoreInProgressPhase SnapshotRestorePhase = "InProgress"
+	// SnapshotRestoreCompletedPhase is used once all the restored shards have been recovered.
+	SnapshotRestoreCompletedPhase SnapshotRestorePhase = "Completed"
+	// SnapshotRestoreFailedPhase is used when Elasticsearch rejected the restore.
+	SnapshotRestoreFailedPhase SnapshotRestorePhase = "Failed"
+)
+
+// SnapshotRestoreStatus defines the observed state of a snapshot restore.
+type SnapshotRestoreStatus struct {
+	// Phase of the restore.
+	Phase SnapshotRestorePhase `json:"phase,omitempty"`
+
+	// Snapshot is the name of the snapshot being restored, once resolved from the specification.
+	Snapshot string `json:"snapshot,omitempty"`
+
+	// TotalShards is the number of shards being restored.
+	TotalShards int32 `json:"totalShards,omitempty"`
+
+	// RestoredShards is the number of shards fully restored.
+	RestoredShards int32 `json:"restoredShards,omitempty"`
+
+	// StartTime is the time at which the restore was accepted by Elasticsearch.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time at which the restore completed or failed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Message gives details about the current phase.
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SnapshotRestore represents the restore of an Elasticsearch snapshot into an Elasticsearch cluster.
+// +kubebuilder:resource:categories=elastic,shortName=esrestore
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="elasticsearch",type="string",JSONPath=".spec.elasticsearchRef.name",description="Elasticsearch cluster"
+// +kubebuilder:printcolumn:name="snapshot",type="string",JSONPath=".status.snapshot",description="Restored snapshot"
+// +kubebuilder:printcolumn:name="phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="restored",type="integer",JSONPath=".status.restoredShards",description="Restored shards"
+// +kubebuilder:printcolumn:name="age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:storageversion
+type SnapshotRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SnapshotRestoreSpec   `json:"spec,omitempty"`
+	Status SnapshotRestoreStatus `json:"status,omitempty"`
+}
+
+// ElasticsearchKey returns the namespaced name of the referenced Elasticsearch cluster.
+func (r SnapshotRestore) ElasticsearchKey() types.NamespacedName {
+	return types.NamespacedName{Namespace: r.Namespace, Name: r.Spec.ElasticsearchRef.Name}
+}
+
+// IsPattern returns true if the snapshot to restore is a wildcard pattern to be resolved.
+func (s SnapshotRestoreSpec) IsPattern() bool {
+	return strings.Contains(s.Snapshot, "*")
+}
+
+// IsFinished returns true if the restore reached a terminal phase.
+func (s SnapshotRestoreStatus) IsFinished() bool {
+	return s.Phase == SnapshotRestoreCompletedPhase || s.Phase == SnapshotRestoreFailedPhase
+}
+
+// +kubebuilder:object:root=true
+
+// SnapshotRestoreList contains a list of SnapshotRestore
+type SnapshotRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SnapshotRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SnapshotRestore{}, &SnapshotRestoreList{})
+}
@@ -0,0 +1,203 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+)
+
+const (
+	// ComponentTemplateKind is inferred from the struct name using reflection in SchemeBuilder.Register()
+	// we duplicate it as a constant here for practical purposes.
+	ComponentTemplateKind = "ComponentTemplate"
+	// IndexTemplateKind is inferred from the struct name using reflection in SchemeBuilder.Register()
+	// we duplicate it as a constant here for practical purposes.
+	IndexTemplateKind = "IndexTemplate"
+)
+
+// ComponentTemplateSpec holds the specification of a component template, to be composed into index templates.
+type ComponentTemplateSpec struct {
+	// ElasticsearchRef is a reference to the Elasticsearch cluster the component template is created in.
+	ElasticsearchRef ElasticsearchRef `json:"elasticsearchRef"`
+
+	// Name of the component template in Elasticsearch. Defaults to the name of the resource.
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+
+	// Template holds the settings, mappings and aliases of the component template, as documented in the Elasticsearch
+	// documentation of the component template API.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Template *commonv1.Config `json:"template"`
+}
+
+// IndexTemplateSpec holds the specification of a composable index template.
+type IndexTemplateSpec struct {
+	// ElasticsearchRef is a reference to the Elasticsearch cluster the index template is created in.
+	ElasticsearchRef ElasticsearchRef `json:"elasticsearchRef"`
+
+	// Name of the index template in Elasticsearch. Defaults to the name of the resource.
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+
+	// IndexPatterns are the wildcard expressions matching the names of the indices and data streams the template
+	// applies to.
+	// +kubebuilder:validation:MinItems=1
+	IndexPatterns []string `json:"indexPatterns"`
+
+	// ComposedOf is the ordered list of the component templates the index template is composed of. Elasticsearch
+	// rejects the index template until all of them exist.
+	// +kubebuilder:validation:Optional
+	ComposedOf []string `json:"composedOf,omitempty"`
+
+	// Priority of the template when several templates match the name of an index. Defaults to 0.
+	// +kubebuilder:validation:Optional
+	Priority *int64 `json:"priority,omitempty"`
+
+	// Template holds the settings, mappings and aliases of the index template, which take precedence over the ones of
+	// the component templates.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Template *commonv1.Config `json:"template,omitempty"`
+
+	// DataStream makes the template create data streams instead of regular indices for the matching names.
+	// +kubebuilder:validation:Optional
+	DataStream bool `json:"dataStream,omitempty"`
+}
+
+// TemplatePhase is the phase of a component or index template.
+type TemplatePhase string
+
+const (
+	// TemplatePendingPhase is used while waiting for the Elasticsearch cluster to be available.
+	TemplatePendingPhase TemplatePhase = "Pending"
+	// TemplateAppliedPhase is used once the template is up-to-date in Elasticsearch.
+	TemplateAppliedPhase TemplatePhase = "Applied"
+	// TemplateConflictPhase is used when a template with the same name, which was not created from this resource,
+	// already exists in Elasticsearch. It is never overwritten.
+	TemplateConflictPhase TemplatePhase = "Conflict"
+	// TemplateFailedPhase is used when Elasticsearch rejected the template.
+	TemplateFailedPhase TemplatePhase = "Failed"
+)
+
+// TemplateStatus defines the observed state of a component or index template.
+type TemplateStatus struct {
+	// Phase of the template.
+	Phase TemplatePhase `json:"phase,omitempty"`
+
+	// Message gives details about the current phase.
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration is the most recent generation of the resource observed by the operator.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ComponentTemplate represents a component template created in an Elasticsearch cluster, to be composed into index
+// templates. Changes made to the template through the Elasticsearch API are reverted, and the template is deleted from
+// Elasticsearch with the resource.
+// +kubebuilder:resource:categories=elastic,shortName=escomponent
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="elasticsearch",type="string",JSONPath=".spec.elasticsearchRef.name",description="Elasticsearch cluster"
+// +kubebuilder:printcolumn:name="phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:storageversion
+type ComponentTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ComponentTemplateSpec `json:"spec,omitempty"`
+	Status TemplateStatus        `json:"status,omitempty"`
+}
+
+// ElasticsearchKey returns the namespaced name of the referenced Elasticsearch cluster.
+func (t ComponentTemplate) ElasticsearchKey() types.NamespacedName {
+	return types.NamespacedName{Namespace: t.Namespace, Name: t.Spec.ElasticsearchRef.Name}
+}
+
+// TemplateName returns the name of the component template in Elasticsearch.
+func (t ComponentTemplate) TemplateName() string {
+	if t.Spec.Name != "" {
+		return t.Spec.Name
+	}
+	return t.Name
+}
+
+// GetTemplateStatus returns the status of the component template.
+func (t ComponentTemplate) GetTemplateStatus() TemplateStatus {
+	return t.Status
+}
+
+// SetTemplateStatus sets the status of the component template.
+func (t *ComponentTemplate) SetTemplateStatus(status TemplateStatus) {
+	t.Status = status
+}
+
+// +kubebuilder:object:root=true
+
+// ComponentTemplateList contains a list of ComponentTemplate
+type ComponentTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ComponentTemplate `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+
+// IndexTemplate represents a composable index template created in an Elasticsearch cluster. Changes made to the
+// template through the Elasticsearch API are reverted, and the template is deleted from Elasticsearch with the
+// resource.
+// +kubebuilder:resource:categories=elastic,shortName=esindextemplate
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="elasticsearch",type="string",JSONPath=".spec.elasticsearchRef.name",description="Elasticsearch cluster"
+// +kubebuilder:printcolumn:name="phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:storageversion
+type IndexTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IndexTemplateSpec `json:"spec,omitempty"`
+	Status TemplateStatus    `json:"status,omitempty"`
+}
+
+// ElasticsearchKey returns the namespaced name of the referenced Elasticsearch cluster.
+func (t IndexTemplate) ElasticsearchKey() types.NamespacedName {
+	return types.NamespacedName{Namespace: t.Namespace, Name: t.Spec.ElasticsearchRef.Name}
+}
+
+// TemplateName returns the name of the index template in Elasticsearch.
+func (t IndexTemplate) TemplateName() string {
+	if t.Spec.Name != "" {
+		return t.Spec.Name
+	}
+	return t.Name
+}
+
+// GetTemplateStatus returns the status of the index template.
+func (t IndexTemplate) GetTemplateStatus() TemplateStatus {
+	return t.Status
+}
+
+// SetTemplateStatus sets the status of the index template.
+func (t *IndexTemplate) SetTemplateStatus(status TemplateStatus) {
+	t.Status = status
+}
+
+// +kubebuilder:object:root=true
+
+// IndexTemplateList contains a list of IndexTemplate
+type IndexTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IndexTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ComponentTemplate{}, &ComponentTemplateList{}, &IndexTemplate{}, &IndexTemplateList{})
+}
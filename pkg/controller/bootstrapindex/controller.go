@@ -0,0 +1,215 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package bootstrapindex
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esv1alpha1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1alpha1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/operator"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/tracing"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/user"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+	ulog "github.com/elastic/cloud-on-k8s/pkg/utils/log"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/net"
+)
+
+const (
+	controllerName = "bootstrapindex-controller"
+)
+
+var (
+	log = ulog.Log.WithName(controllerName)
+
+	// pendingRequeue is used while waiting for the Elasticsearch cluster to be available.
+	pendingRequeue = reconcile.Result{Requeue: true, RequeueAfter: 30 * time.Second}
+	// retryRequeue is used for the indices and data streams rejected by Elasticsearch, which may be created once the
+	// index template they rely on exists, or once the cluster is upgraded.
+	retryRequeue = reconcile.Result{Requeue: true, RequeueAfter: time.Minute}
+
+	// dataStreamsMinVersion is the first version of Elasticsearch supporting data streams.
+	dataStreamsMinVersion = version.From(7, 9, 0)
+)
+
+type EsClientProvider func(ctx context.Context, c k8s.Client, dialer net.Dialer, es esv1.Elasticsearch) (esclient.Client, error)
+
+// Add creates a new BootstrapIndex Controller and adds it to the Manager with default RBAC. The Manager will set fields
+// on the Controller and Start it when the Manager is Started.
+func Add(mgr manager.Manager, params operator.Parameters) error {
+	r := newReconciler(mgr, params)
+	c, err := common.NewController(mgr, controllerName, r, params)
+	if err != nil {
+		return err
+	}
+	// Watch for changes to BootstrapIndex
+	return c.Watch(&source.Kind{Type: &esv1alpha1.BootstrapIndex{}}, &handler.EnqueueRequestForObject{})
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager, params operator.Parameters) *ReconcileBootstrapIndex {
+	return &ReconcileBootstrapIndex{
+		Client:           mgr.GetClient(),
+		Parameters:       params,
+		esClientProvider: user.NewElasticsearchClient,
+	}
+}
+
+var _ reconcile.Reconciler = &ReconcileBootstrapIndex{}
+
+// ReconcileBootstrapIndex creates the indices and data streams specified by BootstrapIndex resources.
+type ReconcileBootstrapIndex struct {
+	k8s.Client
+	operator.Parameters
+	esClientProvider EsClientProvider
+	// iteration is the number of times this controller has run its Reconcile method
+	iteration uint64
+}
+
+// Reconcile creates the index or data stream specified in a BootstrapIndex once the referenced Elasticsearch cluster is
+// ready. An index or a data stream which already exists is left untouched. The resource is not reconciled anymore once
+// it reached the Created phase: the index is never updated nor deleted, and is not created again if it is deleted
+// through the Elasticsearch API.
+func (r *ReconcileBootstrapIndex) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	defer common.LogReconciliationRun(log, request, "bootstrap_name", &r.iteration)()
+	tx, ctx := tracing.NewTransaction(ctx, r.Tracer, request.NamespacedName, "bootstrapindex")
+	defer tracing.EndTransaction(tx)
+
+	var index esv1alpha1.BootstrapIndex
+	if err := r.Client.Get(ctx, request.NamespacedName, &index); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, tracing.CaptureError(ctx, err)
+	}
+
+	if common.IsUnmanaged(&index) {
+		log.Info("Object is currently not managed by this controller. Skipping reconciliation", "namespace", index.Namespace, "bootstrap_name", index.Name)
+		return reconcile.Result{}, nil
+	}
+
+	if index.Status.Phase == esv1alpha1.BootstrapIndexCreatedPhase {
+		return reconcile.Result{}, nil
+	}
+
+	status := index.Status
+	results, err := r.doReconcile(ctx, index, &status)
+	if err != nil {
+		return reconcile.Result{}, tracing.CaptureError(ctx, err)
+	}
+	if err := r.updateStatus(ctx, &index, status); err != nil {
+		if apierrors.IsConflict(err) {
+			return reconcile.Result{Requeue: true}, nil
+		}
+		return reconcile.Result{}, tracing.CaptureError(ctx, err)
+	}
+	return results, nil
+}
+
+func (r *ReconcileBootstrapIndex) doReconcile(
+	ctx context.Context,
+	index esv1alpha1.BootstrapIndex,
+	status *esv1alpha1.BootstrapIndexStatus,
+) (reconcile.Result, error) {
+	if index.Spec.DataStream && (index.Spec.Settings != nil || index.Spec.Mappings != nil || index.Spec.Aliases != nil) {
+		// retrying with the same specification would not help
+		setPhase(status, esv1alpha1.BootstrapIndexFailedPhase, "The settings, mappings and aliases of a data stream come from its index template")
+		return reconcile.Result{}, nil
+	}
+
+	var es esv1.Elasticsearch
+	if err := r.Client.Get(ctx, index.ElasticsearchKey(), &es); err != nil {
+		if apierrors.IsNotFound(err) {
+			setPhase(status, esv1alpha1.BootstrapIndexPendingPhase, fmt.Sprintf("Elasticsearch cluster %s does not exist", index.Spec.ElasticsearchRef.Name))
+			return pendingRequeue, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if es.Status.Phase != esv1.ElasticsearchReadyPhase {
+		setPhase(status, esv1alpha1.BootstrapIndexPendingPhase, fmt.Sprintf("Waiting for Elasticsearch cluster %s to be ready", es.Name))
+		return pendingRequeue, nil
+	}
+	if index.Spec.DataStream {
+		v, err := version.Parse(es.Spec.Version)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if !v.GTE(dataStreamsMinVersion) {
+			setPhase(status, esv1alpha1.BootstrapIndexFailedPhase, fmt.Sprintf("Data streams are not available in version %s of Elasticsearch", es.Spec.Version))
+			return retryRequeue, nil
+		}
+	}
+
+	esClient, err := r.esClientProvider(ctx, r.Client, r.Dialer, es)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	defer esClient.Close()
+
+	name := index.IndexName()
+	err = create(ctx, esClient, index.Spec, name)
+	switch {
+	case esclient.IsAlreadyExists(err):
+		log.Info("Index or data stream already exists", "namespace", es.Namespace, "es_name", es.Name, "index", name)
+		setPhase(status, esv1alpha1.BootstrapIndexCreatedPhase, fmt.Sprintf("%s already existed and was left untouched", name))
+	case esclient.Is4xx(err):
+		setPhase(status, esv1alpha1.BootstrapIndexFailedPhase, fmt.Sprintf("Creation of %s rejected by Elasticsearch: %s", name, err.Error()))
+		return retryRequeue, nil
+	case err != nil:
+		return reconcile.Result{}, err
+	default:
+		log.Info("Bootstrapped index or data stream", "namespace", es.Namespace, "es_name", es.Name, "index", name)
+		setPhase(status, esv1alpha1.BootstrapIndexCreatedPhase, "")
+	}
+	return reconcile.Result{}, nil
+}
+
+// create creates the given index or data stream in Elasticsearch.
+func create(ctx context.Context, esClient esclient.Client, spec esv1alpha1.BootstrapIndexSpec, name string) error {
+	if spec.DataStream {
+		return esClient.CreateDataStream(ctx, name)
+	}
+	var definition esclient.Index
+	if spec.Settings != nil {
+		definition.Settings = spec.Settings.Data
+	}
+	if spec.Mappings != nil {
+		definition.Mappings = spec.Mappings.Data
+	}
+	if spec.Aliases != nil {
+		definition.Aliases = spec.Aliases.Data
+	}
+	return esClient.CreateIndex(ctx, name, definition)
+}
+
+// updateStatus updates the status of the given index, which is kept up-to-date with the updated resource.
+func (r *ReconcileBootstrapIndex) updateStatus(
+	ctx context.Context,
+	index *esv1alpha1.BootstrapIndex,
+	status esv1alpha1.BootstrapIndexStatus,
+) error {
+	if reflect.DeepEqual(index.Status, status) {
+		return nil
+	}
+	index.Status = status
+	return r.Client.Status().Update(ctx, index)
+}
+
+func setPhase(status *esv1alpha1.BootstrapIndexStatus, phase esv1alpha1.BootstrapIndexPhase, msg string) {
+	status.Phase = phase
+	status.Message = msg
+}
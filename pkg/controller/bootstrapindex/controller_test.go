@@ -0,0 +1,223 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package bootstrapindex
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esv1alpha1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1alpha1"
+	controllerscheme "github.com/elastic/cloud-on-k8s/pkg/controller/common/scheme"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/net"
+)
+
+type fakeESClient struct {
+	esclient.Client
+	createErr error
+
+	indices     map[string]esclient.Index
+	dataStreams []string
+}
+
+func (f *fakeESClient) CreateIndex(_ context.Context, name string, index esclient.Index) error {
+	if f.createErr != nil {
+		return f.createErr
+	}
+	if f.indices == nil {
+		f.indices = map[string]esclient.Index{}
+	}
+	f.indices[name] = index
+	return nil
+}
+
+func (f *fakeESClient) CreateDataStream(_ context.Context, name string) error {
+	if f.createErr != nil {
+		return f.createErr
+	}
+	f.dataStreams = append(f.dataStreams, name)
+	return nil
+}
+
+func (f *fakeESClient) Close() {}
+
+func (f *fakeESClient) provider(_ context.Context, _ k8s.Client, _ net.Dialer, _ esv1.Elasticsearch) (esclient.Client, error) {
+	return f, nil
+}
+
+func elasticsearch(version string, phase esv1.ElasticsearchOrchestrationPhase) *esv1.Elasticsearch {
+	return &esv1.Elasticsearch{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "es"},
+		Spec:       esv1.ElasticsearchSpec{Version: version},
+		Status:     esv1.ElasticsearchStatus{Phase: phase},
+	}
+}
+
+func bootstrapIndex(spec esv1alpha1.BootstrapIndexSpec, status esv1alpha1.BootstrapIndexStatus) *esv1alpha1.BootstrapIndex {
+	spec.ElasticsearchRef = esv1alpha1.ElasticsearchRef{Name: "es"}
+	return &esv1alpha1.BootstrapIndex{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "inventory"},
+		Spec:       spec,
+		Status:     status,
+	}
+}
+
+func TestReconcileBootstrapIndex_Reconcile(t *testing.T) {
+	controllerscheme.SetupScheme()
+	settings := &commonv1.Config{Data: map[string]interface{}{"number_of_shards": 1}}
+	inventory := esv1alpha1.BootstrapIndexSpec{Settings: settings}
+	logs := esv1alpha1.BootstrapIndexSpec{Name: "logs-app-default", DataStream: true}
+	alreadyExists := &esclient.APIError{Status: "400 Bad Request", StatusCode: http.StatusBadRequest}
+	alreadyExists.ErrorResponse.Error.Type = "resource_already_exists_exception"
+	noTemplate := &esclient.APIError{Status: "400 Bad Request", StatusCode: http.StatusBadRequest}
+
+	tests := []struct {
+		name            string
+		index           *esv1alpha1.BootstrapIndex
+		es              *esv1.Elasticsearch
+		esClient        *fakeESClient
+		wantErr         bool
+		wantResult      reconcile.Result
+		wantStatus      esv1alpha1.BootstrapIndexStatus
+		wantIndices     map[string]esclient.Index
+		wantDataStreams []string
+	}{
+		{
+			name:       "Elasticsearch cluster does not exist",
+			index:      bootstrapIndex(inventory, esv1alpha1.BootstrapIndexStatus{}),
+			esClient:   &fakeESClient{},
+			wantResult: pendingRequeue,
+			wantStatus: esv1alpha1.BootstrapIndexStatus{
+				Phase:   esv1alpha1.BootstrapIndexPendingPhase,
+				Message: "Elasticsearch cluster es does not exist",
+			},
+		},
+		{
+			name:       "Elasticsearch cluster not ready",
+			index:      bootstrapIndex(inventory, esv1alpha1.BootstrapIndexStatus{}),
+			es:         elasticsearch("7.16.0", esv1.ElasticsearchApplyingChangesPhase),
+			esClient:   &fakeESClient{},
+			wantResult: pendingRequeue,
+			wantStatus: esv1alpha1.BootstrapIndexStatus{
+				Phase:   esv1alpha1.BootstrapIndexPendingPhase,
+				Message: "Waiting for Elasticsearch cluster es to be ready",
+			},
+		},
+		{
+			name:        "Create the index under the name of the resource",
+			index:       bootstrapIndex(inventory, esv1alpha1.BootstrapIndexStatus{}),
+			es:          elasticsearch("7.16.0", esv1.ElasticsearchReadyPhase),
+			esClient:    &fakeESClient{},
+			wantResult:  reconcile.Result{},
+			wantStatus:  esv1alpha1.BootstrapIndexStatus{Phase: esv1alpha1.BootstrapIndexCreatedPhase},
+			wantIndices: map[string]esclient.Index{"inventory": {Settings: settings.Data}},
+		},
+		{
+			name:            "Create the data stream",
+			index:           bootstrapIndex(logs, esv1alpha1.BootstrapIndexStatus{}),
+			es:              elasticsearch("7.16.0", esv1.ElasticsearchReadyPhase),
+			esClient:        &fakeESClient{},
+			wantResult:      reconcile.Result{},
+			wantStatus:      esv1alpha1.BootstrapIndexStatus{Phase: esv1alpha1.BootstrapIndexCreatedPhase},
+			wantDataStreams: []string{"logs-app-default"},
+		},
+		{
+			name:       "Leave an existing index untouched",
+			index:      bootstrapIndex(inventory, esv1alpha1.BootstrapIndexStatus{}),
+			es:         elasticsearch("7.16.0", esv1.ElasticsearchReadyPhase),
+			esClient:   &fakeESClient{createErr: alreadyExists},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.BootstrapIndexStatus{
+				Phase:   esv1alpha1.BootstrapIndexCreatedPhase,
+				Message: "inventory already existed and was left untouched",
+			},
+		},
+		{
+			name:       "Do not create the index again",
+			index:      bootstrapIndex(inventory, esv1alpha1.BootstrapIndexStatus{Phase: esv1alpha1.BootstrapIndexCreatedPhase}),
+			es:         elasticsearch("7.16.0", esv1.ElasticsearchReadyPhase),
+			esClient:   &fakeESClient{},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.BootstrapIndexStatus{Phase: esv1alpha1.BootstrapIndexCreatedPhase},
+		},
+		{
+			name:       "Retry the data stream rejected by Elasticsearch",
+			index:      bootstrapIndex(logs, esv1alpha1.BootstrapIndexStatus{}),
+			es:         elasticsearch("7.16.0", esv1.ElasticsearchReadyPhase),
+			esClient:   &fakeESClient{createErr: noTemplate},
+			wantResult: retryRequeue,
+			wantStatus: esv1alpha1.BootstrapIndexStatus{
+				Phase:   esv1alpha1.BootstrapIndexFailedPhase,
+				Message: "Creation of logs-app-default rejected by Elasticsearch: " + noTemplate.Error(),
+			},
+		},
+		{
+			name:       "Data streams not available",
+			index:      bootstrapIndex(logs, esv1alpha1.BootstrapIndexStatus{}),
+			es:         elasticsearch("7.8.1", esv1.ElasticsearchReadyPhase),
+			esClient:   &fakeESClient{},
+			wantResult: retryRequeue,
+			wantStatus: esv1alpha1.BootstrapIndexStatus{
+				Phase:   esv1alpha1.BootstrapIndexFailedPhase,
+				Message: "Data streams are not available in version 7.8.1 of Elasticsearch",
+			},
+		},
+		{
+			name:       "Data stream with settings",
+			index:      bootstrapIndex(esv1alpha1.BootstrapIndexSpec{DataStream: true, Settings: settings}, esv1alpha1.BootstrapIndexStatus{}),
+			es:         elasticsearch("7.16.0", esv1.ElasticsearchReadyPhase),
+			esClient:   &fakeESClient{},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.BootstrapIndexStatus{
+				Phase:   esv1alpha1.BootstrapIndexFailedPhase,
+				Message: "The settings, mappings and aliases of a data stream come from its index template",
+			},
+		},
+		{
+			name:     "Elasticsearch unavailable",
+			index:    bootstrapIndex(inventory, esv1alpha1.BootstrapIndexStatus{}),
+			es:       elasticsearch("7.16.0", esv1.ElasticsearchReadyPhase),
+			esClient: &fakeESClient{createErr: errors.New("connection refused")},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []runtime.Object{tt.index}
+			if tt.es != nil {
+				objects = append(objects, tt.es)
+			}
+			c := k8s.NewFakeClient(objects...)
+			r := &ReconcileBootstrapIndex{
+				Client:           c,
+				esClientProvider: tt.esClient.provider,
+			}
+			key := types.NamespacedName{Namespace: "ns", Name: "inventory"}
+			result, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+			require.Equal(t, tt.wantErr, err != nil)
+			require.Equal(t, tt.wantIndices, tt.esClient.indices)
+			require.Equal(t, tt.wantDataStreams, tt.esClient.dataStreams)
+			if tt.wantErr {
+				return
+			}
+			require.Equal(t, tt.wantResult, result)
+
+			var index esv1alpha1.BootstrapIndex
+			require.NoError(t, c.Get(context.Background(), key, &index))
+			require.Equal(t, tt.wantStatus, index.Status)
+		})
+	}
+}
@@ -32,6 +32,16 @@ const (
 	EventAssociationStatusChange = "AssociationStatusChange"
 )
 
+// Event reasons for the snapshot restore controller
+const (
+	// EventReasonRestoreStarted describes an event fired when a snapshot restore is accepted by Elasticsearch.
+	EventReasonRestoreStarted = "RestoreStarted"
+	// EventReasonRestoreCompleted describes an event fired when all the shards of a snapshot are restored.
+	EventReasonRestoreCompleted = "RestoreCompleted"
+	// EventReasonRestoreFailed describes an event fired when a snapshot restore is rejected by Elasticsearch.
+	EventReasonRestoreFailed = "RestoreFailed"
+)
+
 // Event reasons for common error conditions
 const (
 	// EventReconciliationError describes an error detected during reconciliation of an object.
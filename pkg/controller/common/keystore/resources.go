@@ -11,6 +11,7 @@ import (
 
 	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/common/driver"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/hash"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/common/name"
 	ulog "github.com/elastic/cloud-on-k8s/pkg/utils/log"
 )
@@ -25,6 +26,8 @@ type Resources struct {
 	InitContainer corev1.Container
 	// version of the secret provided by the user
 	Version string
+	// hash of the value of each secure setting, keyed by setting name
+	SettingHashes map[string]string
 }
 
 // HasKeystore interface represents an Elastic Stack application that offers a keystore which in ECK
@@ -56,7 +59,7 @@ func ReconcileResources(
 	initContainerParams InitContainerParameters,
 ) (*Resources, error) {
 	// setup a volume from the user-provided secure settings secret
-	secretVolume, version, err := secureSettingsVolume(r, hasKeystore, labels, namer)
+	secretVolume, secret, err := secureSettingsVolume(r, hasKeystore, labels, namer)
 	if err != nil {
 		return nil, err
 	}
@@ -74,6 +77,19 @@ func ReconcileResources(
 	return &Resources{
 		Volume:        secretVolume.Volume(),
 		InitContainer: initContainer,
-		Version:       version,
+		// resource version will be included in pod labels,
+		// to recreate pods on any secret change.
+		Version:       secret.GetResourceVersion(),
+		SettingHashes: settingHashes(secret.Data),
 	}, nil
 }
+
+// settingHashes returns the hash of the value of each secure setting, to detect which settings have changed without
+// keeping their values around.
+func settingHashes(data map[string][]byte) map[string]string {
+	hashes := make(map[string]string, len(data))
+	for setting, value := range data {
+		hashes[setting] = hash.HashObject(value)
+	}
+	return hashes
+}
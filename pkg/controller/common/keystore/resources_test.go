@@ -17,6 +17,7 @@ import (
 	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
 	kbv1 "github.com/elastic/cloud-on-k8s/pkg/apis/kibana/v1"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/common/driver"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/hash"
 	watches2 "github.com/elastic/cloud-on-k8s/pkg/controller/common/watches"
 	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
 )
@@ -238,6 +239,9 @@ echo "Keystore initialization successful."
 				assert.Equal(t, resources.InitContainer.SecurityContext, tt.wantContainers.SecurityContext)
 				assert.Equal(t, resources.InitContainer.Resources, tt.wantContainers.Resources)
 				assert.Equal(t, resources.Version, tt.wantVersion)
+				// the hash of each secure setting is exposed, not its value
+				assert.Equal(t, len(resources.SettingHashes), 1)
+				assert.Equal(t, resources.SettingHashes["key1"], hash.HashObject([]byte("value1")))
 			}
 		})
 	}
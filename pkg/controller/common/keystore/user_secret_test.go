@@ -101,9 +101,13 @@ func Test_secureSettingsVolume(t *testing.T) {
 				Watches:      tt.w,
 				FakeRecorder: record.NewFakeRecorder(1000),
 			}
-			vol, version, err := secureSettingsVolume(testDriver, &tt.kb, nil, kbNamer)
+			vol, secret, err := secureSettingsVolume(testDriver, &tt.kb, nil, kbNamer)
 			require.NoError(t, err)
 			assert.Equal(t, tt.wantVolume, vol)
+			var version string
+			if secret != nil {
+				version = secret.ResourceVersion
+			}
 			assert.Equal(t, tt.wantVersion, version)
 
 			require.Equal(t, tt.wantWatches, tt.w.Secrets.Registrations())
@@ -0,0 +1,92 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+type CrossClusterReplicationClient interface {
+	// GetAutoFollowPatterns returns the auto-follow patterns of the cluster.
+	// Introduced in: Elasticsearch 6.5.0
+	GetAutoFollowPatterns(ctx context.Context) (AutoFollowPatterns, error)
+	// UpsertAutoFollowPattern creates or updates an auto-follow pattern.
+	// Introduced in: Elasticsearch 6.5.0
+	UpsertAutoFollowPattern(ctx context.Context, name string, pattern AutoFollowPattern) error
+	// DeleteAutoFollowPattern deletes an auto-follow pattern, leaving the follower indices it created untouched.
+	// Introduced in: Elasticsearch 6.5.0
+	DeleteAutoFollowPattern(ctx context.Context, name string) error
+	// GetCrossClusterReplicationStats returns the replication statistics of the shards of the follower indices.
+	// Introduced in: Elasticsearch 6.5.0
+	GetCrossClusterReplicationStats(ctx context.Context) (CrossClusterReplicationStats, error)
+}
+
+// AutoFollowPatterns maps the name of the auto-follow patterns to their definition.
+type AutoFollowPatterns map[string]AutoFollowPattern
+
+// AutoFollowPattern models an auto-follow pattern as returned and expected by the CCR API.
+type AutoFollowPattern struct {
+	RemoteCluster       string   `json:"remote_cluster"`
+	LeaderIndexPatterns []string `json:"leader_index_patterns"`
+	FollowIndexPattern  string   `json:"follow_index_pattern,omitempty"`
+}
+
+type autoFollowPatternsResponse struct {
+	Patterns []struct {
+		Name    string            `json:"name"`
+		Pattern AutoFollowPattern `json:"pattern"`
+	} `json:"patterns"`
+}
+
+// CrossClusterReplicationStats models the response of the CCR stats API, limited to the follower indices.
+type CrossClusterReplicationStats struct {
+	FollowStats struct {
+		Indices []FollowerIndexStats `json:"indices"`
+	} `json:"follow_stats"`
+}
+
+// FollowerIndexStats holds the replication statistics of the shards of a follower index.
+type FollowerIndexStats struct {
+	Index  string               `json:"index"`
+	Shards []FollowerShardStats `json:"shards"`
+}
+
+// FollowerShardStats holds the replication statistics of a shard of a follower index.
+type FollowerShardStats struct {
+	RemoteCluster            string `json:"remote_cluster"`
+	LeaderIndex              string `json:"leader_index"`
+	LeaderGlobalCheckpoint   int64  `json:"leader_global_checkpoint"`
+	FollowerGlobalCheckpoint int64  `json:"follower_global_checkpoint"`
+}
+
+func (c *clientV6) GetAutoFollowPatterns(ctx context.Context) (AutoFollowPatterns, error) {
+	var response autoFollowPatternsResponse
+	if err := c.get(ctx, "/_ccr/auto_follow", &response); err != nil {
+		return nil, err
+	}
+	patterns := make(AutoFollowPatterns, len(response.Patterns))
+	for _, pattern := range response.Patterns {
+		patterns[pattern.Name] = pattern.Pattern
+	}
+	return patterns, nil
+}
+
+func (c *clientV6) UpsertAutoFollowPattern(ctx context.Context, name string, pattern AutoFollowPattern) error {
+	path := fmt.Sprintf("/_ccr/auto_follow/%s", url.PathEscape(name))
+	return c.put(ctx, path, pattern, nil)
+}
+
+func (c *clientV6) DeleteAutoFollowPattern(ctx context.Context, name string) error {
+	path := fmt.Sprintf("/_ccr/auto_follow/%s", url.PathEscape(name))
+	return c.delete(ctx, path)
+}
+
+func (c *clientV6) GetCrossClusterReplicationStats(ctx context.Context) (CrossClusterReplicationStats, error) {
+	var stats CrossClusterReplicationStats
+	err := c.get(ctx, "/_ccr/stats", &stats)
+	return stats, err
+}
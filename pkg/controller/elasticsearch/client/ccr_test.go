@@ -0,0 +1,64 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+)
+
+func TestClient_GetAutoFollowPatterns(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodGet, req.Method)
+		require.Equal(t, "/_ccr/auto_follow", req.URL.Path)
+		return NewMockResponse(200, req, `{"patterns":[{"name":"logs","pattern":{"active":true,"remote_cluster":"leader","leader_index_patterns":["logs-*"],"leader_index_exclusion_patterns":[],"follow_index_pattern":"{{leader_index}}-copy"}}]}`)
+	})
+	patterns, err := testClient.GetAutoFollowPatterns(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, AutoFollowPatterns{
+		"logs": {RemoteCluster: "leader", LeaderIndexPatterns: []string{"logs-*"}, FollowIndexPattern: "{{leader_index}}-copy"},
+	}, patterns)
+}
+
+func TestClient_UpsertAutoFollowPattern(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodPut, req.Method)
+		require.Equal(t, "/_ccr/auto_follow/logs", req.URL.Path)
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"remote_cluster":"leader","leader_index_patterns":["logs-*"]}`, string(body))
+		return NewMockResponse(200, req, `{"acknowledged":true}`)
+	})
+	err := testClient.UpsertAutoFollowPattern(context.Background(), "logs", AutoFollowPattern{
+		RemoteCluster:       "leader",
+		LeaderIndexPatterns: []string{"logs-*"},
+	})
+	require.NoError(t, err)
+}
+
+func TestClient_GetCrossClusterReplicationStats(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodGet, req.Method)
+		require.Equal(t, "/_ccr/stats", req.URL.Path)
+		return NewMockResponse(200, req, `{"auto_follow_stats":{"number_of_failed_follow_indices":0},"follow_stats":{"indices":[{"index":"logs-1","shards":[{"remote_cluster":"leader","leader_index":"logs-1","follower_index":"logs-1","shard_id":0,"leader_global_checkpoint":1024,"follower_global_checkpoint":1000}]}]}}`)
+	})
+	stats, err := testClient.GetCrossClusterReplicationStats(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []FollowerIndexStats{{
+		Index: "logs-1",
+		Shards: []FollowerShardStats{{
+			RemoteCluster:            "leader",
+			LeaderIndex:              "logs-1",
+			LeaderGlobalCheckpoint:   1024,
+			FollowerGlobalCheckpoint: 1000,
+		}},
+	}}, stats.FollowStats.Indices)
+}
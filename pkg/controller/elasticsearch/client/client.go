@@ -57,8 +57,13 @@ type Role struct {
 type Client interface {
 	AllocationSetter
 	AutoscalingClient
+	CrossClusterReplicationClient
+	IndexClient
+	IndexLifecycleClient
+	IndexTemplateClient
 	ShardLister
 	LicenseClient
+	SnapshotClient
 	// Close idle connections in the underlying http client.
 	Close()
 	// Equal returns true if other can be considered as the same client.
@@ -90,6 +95,8 @@ type Client interface {
 	ReloadSecureSettings(ctx context.Context) error
 	// GetNodes calls the _nodes api to return a map(nodeName -> Node)
 	GetNodes(ctx context.Context) (Nodes, error)
+	// GetNodesPlugins calls the _nodes/plugins api to return a map(nodeName -> Node) including the installed plugins
+	GetNodesPlugins(ctx context.Context) (Nodes, error)
 	// GetNodesStats calls the _nodes/stats api to return a map(nodeName -> NodeStats)
 	GetNodesStats(ctx context.Context) (NodesStats, error)
 	// ClusterBootstrappedForZen2 returns true if the cluster is relying on zen2 orchestration.
@@ -314,6 +314,26 @@ func TestClientGetNodes(t *testing.T) {
 	require.ElementsMatch(t, []string{"master", "data", "ingest"}, resp.Nodes["iXqjbgPYThO-6S7reL5_HA"].Roles)
 }
 
+func TestClientGetNodesPlugins(t *testing.T) {
+	expectedPath := "/_nodes/_all/plugins"
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, expectedPath, req.URL.Path)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(fixtures.NodesPluginsSample)),
+			Header:     make(http.Header),
+			Request:    req,
+		}
+	})
+	resp, err := testClient.GetNodesPlugins(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, len(resp.Nodes))
+	require.Equal(t,
+		[]NodePlugin{{Name: "analysis-icu", Version: "7.16.0"}, {Name: "repository-gcs", Version: "7.16.0"}},
+		resp.Nodes["Rt-o5-ZBQaq-Nkhhy0p7JA"].Plugins,
+	)
+}
+
 func TestClientGetNodesStats(t *testing.T) {
 	expectedPath := "/_nodes/_all/stats/os"
 	testClient := NewMockClient(version.MustParse("6.8.0"), func(req *http.Request) *http.Response {
@@ -566,6 +586,7 @@ func TestAPIError_Types(t *testing.T) {
 		wantConflict  bool
 		wantForbidden bool
 		wantNotFound  bool
+		wantExists    bool
 	}{
 		{
 			name: "500 is not any of the explicitly supported error types",
@@ -594,6 +615,13 @@ func TestAPIError_Types(t *testing.T) {
 			},
 			wantNotFound: true,
 		},
+		{
+			name: "400 with a resource_already_exists_exception is an already exists",
+			args: args{
+				err: newAPIError(NewMockResponse(400, nil, `{"error":{"type":"resource_already_exists_exception"},"status":400}`)), //nolint:bodyclose
+			},
+			wantExists: true,
+		},
 		{
 			name: "no api error",
 			args: args{
@@ -613,6 +641,9 @@ func TestAPIError_Types(t *testing.T) {
 			if got := IsConflict(tt.args.err); got != tt.wantConflict {
 				t.Errorf("IsConflict() = %v, want %v", got, tt.wantConflict)
 			}
+			if got := IsAlreadyExists(tt.args.err); got != tt.wantExists {
+				t.Errorf("IsAlreadyExists() = %v, want %v", got, tt.wantExists)
+			}
 		})
 	}
 }
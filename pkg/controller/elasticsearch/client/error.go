@@ -80,6 +80,16 @@ func IsConflict(err error) bool {
 	return isHTTPError(err, http.StatusConflict)
 }
 
+// IsAlreadyExists checks whether the error was caused by the creation of a resource, such as an index, which already
+// exists.
+func IsAlreadyExists(err error) bool {
+	apiErr := new(APIError)
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorResponse.Error.Type == "resource_already_exists_exception"
+	}
+	return false
+}
+
 func Is4xx(err error) bool {
 	apiErr := new(APIError)
 	if errors.As(err, &apiErr) {
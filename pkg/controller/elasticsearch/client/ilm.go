@@ -0,0 +1,58 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+type IndexLifecycleClient interface {
+	// GetIndexLifecyclePolicies returns the index lifecycle management policies of the cluster.
+	// Introduced in: Elasticsearch 6.6.0
+	GetIndexLifecyclePolicies(ctx context.Context) (IndexLifecyclePolicies, error)
+	// UpsertIndexLifecyclePolicy creates or updates an index lifecycle management policy.
+	// Introduced in: Elasticsearch 6.6.0
+	UpsertIndexLifecyclePolicy(ctx context.Context, name string, policy IndexLifecyclePolicy) error
+	// DeleteIndexLifecyclePolicy deletes an index lifecycle management policy. Elasticsearch rejects the deletion of
+	// policies still in use by indices.
+	// Introduced in: Elasticsearch 6.6.0
+	DeleteIndexLifecyclePolicy(ctx context.Context, name string) error
+}
+
+// IndexLifecyclePolicies maps the name of the index lifecycle management policies to their definition.
+type IndexLifecyclePolicies map[string]IndexLifecyclePolicyDefinition
+
+// IndexLifecyclePolicyDefinition models an index lifecycle management policy as returned by the ILM API.
+type IndexLifecyclePolicyDefinition struct {
+	Version int64                `json:"version"`
+	Policy  IndexLifecyclePolicy `json:"policy"`
+}
+
+// IndexLifecyclePolicy models an index lifecycle management policy as expected by the ILM API.
+type IndexLifecyclePolicy struct {
+	Phases map[string]interface{} `json:"phases"`
+}
+
+type indexLifecyclePolicyRequest struct {
+	Policy IndexLifecyclePolicy `json:"policy"`
+}
+
+func (c *clientV6) GetIndexLifecyclePolicies(ctx context.Context) (IndexLifecyclePolicies, error) {
+	var policies IndexLifecyclePolicies
+	err := c.get(ctx, "/_ilm/policy", &policies)
+	return policies, err
+}
+
+func (c *clientV6) UpsertIndexLifecyclePolicy(ctx context.Context, name string, policy IndexLifecyclePolicy) error {
+	path := fmt.Sprintf("/_ilm/policy/%s", url.PathEscape(name))
+	return c.put(ctx, path, indexLifecyclePolicyRequest{Policy: policy}, nil)
+}
+
+func (c *clientV6) DeleteIndexLifecyclePolicy(ctx context.Context, name string) error {
+	path := fmt.Sprintf("/_ilm/policy/%s", url.PathEscape(name))
+	return c.delete(ctx, path)
+}
@@ -0,0 +1,58 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+)
+
+func TestClient_GetIndexLifecyclePolicies(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodGet, req.Method)
+		require.Equal(t, "/_ilm/policy", req.URL.Path)
+		return NewMockResponse(200, req, `{"logs":{"version":2,"modified_date":"2022-01-01T00:00:00.000Z","policy":{"phases":{"delete":{"min_age":"30d","actions":{"delete":{"delete_searchable_snapshot":true}}}}},"in_use_by":{"indices":[]}}}`)
+	})
+	policies, err := testClient.GetIndexLifecyclePolicies(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, IndexLifecyclePolicies{
+		"logs": {Version: 2, Policy: IndexLifecyclePolicy{Phases: map[string]interface{}{
+			"delete": map[string]interface{}{
+				"min_age": "30d",
+				"actions": map[string]interface{}{"delete": map[string]interface{}{"delete_searchable_snapshot": true}},
+			},
+		}}},
+	}, policies)
+}
+
+func TestClient_UpsertIndexLifecyclePolicy(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodPut, req.Method)
+		require.Equal(t, "/_ilm/policy/logs", req.URL.Path)
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"policy":{"phases":{"hot":{"actions":{"rollover":{"max_age":"1d"}}}}}}`, string(body))
+		return NewMockResponse(200, req, `{"acknowledged":true}`)
+	})
+	err := testClient.UpsertIndexLifecyclePolicy(context.Background(), "logs", IndexLifecyclePolicy{
+		Phases: map[string]interface{}{"hot": map[string]interface{}{"actions": map[string]interface{}{"rollover": map[string]interface{}{"max_age": "1d"}}}},
+	})
+	require.NoError(t, err)
+}
+
+func TestClient_DeleteIndexLifecyclePolicy(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodDelete, req.Method)
+		require.Equal(t, "/_ilm/policy/logs", req.URL.Path)
+		return NewMockResponse(200, req, `{"acknowledged":true}`)
+	})
+	require.NoError(t, testClient.DeleteIndexLifecyclePolicy(context.Background(), "logs"))
+}
@@ -0,0 +1,35 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+type IndexClient interface {
+	// CreateIndex creates an index. It fails with a resource_already_exists_exception if the index already exists.
+	CreateIndex(ctx context.Context, name string, index Index) error
+	// CreateDataStream creates a data stream, which must match a composable index template with data streams enabled.
+	// It fails with a resource_already_exists_exception if the data stream already exists.
+	// Introduced in: Elasticsearch 7.9.0
+	CreateDataStream(ctx context.Context, name string) error
+}
+
+// Index models the definition of an index as expected by the create index API.
+type Index struct {
+	Settings map[string]interface{} `json:"settings,omitempty"`
+	Mappings map[string]interface{} `json:"mappings,omitempty"`
+	Aliases  map[string]interface{} `json:"aliases,omitempty"`
+}
+
+func (c *clientV6) CreateIndex(ctx context.Context, name string, index Index) error {
+	return c.put(ctx, fmt.Sprintf("/%s", url.PathEscape(name)), index, nil)
+}
+
+func (c *clientV7) CreateDataStream(ctx context.Context, name string) error {
+	return c.put(ctx, fmt.Sprintf("/_data_stream/%s", url.PathEscape(name)), nil, nil)
+}
@@ -0,0 +1,58 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+)
+
+func TestClient_CreateIndex(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodPut, req.Method)
+		require.Equal(t, "/inventory", req.URL.Path)
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"settings":{"number_of_shards":1},"aliases":{"products":{}}}`, string(body))
+		return NewMockResponse(200, req, `{"acknowledged":true,"shards_acknowledged":true,"index":"inventory"}`)
+	})
+	err := testClient.CreateIndex(context.Background(), "inventory", Index{
+		Settings: map[string]interface{}{"number_of_shards": 1},
+		Aliases:  map[string]interface{}{"products": map[string]interface{}{}},
+	})
+	require.NoError(t, err)
+}
+
+func TestClient_CreateIndexAlreadyExists(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		return NewMockResponse(400, req, `{"error":{"type":"resource_already_exists_exception","reason":"index [inventory/x] already exists"},"status":400}`)
+	})
+	err := testClient.CreateIndex(context.Background(), "inventory", Index{})
+	require.Error(t, err)
+	require.True(t, IsAlreadyExists(err))
+}
+
+func TestClient_CreateDataStream(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodPut, req.Method)
+		require.Equal(t, "/_data_stream/logs-app-default", req.URL.Path)
+		return NewMockResponse(200, req, `{"acknowledged":true}`)
+	})
+	require.NoError(t, testClient.CreateDataStream(context.Background(), "logs-app-default"))
+}
+
+func TestClient_CreateDataStreamNotSupportedInEs6x(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("6.8.0"), func(req *http.Request) *http.Response {
+		t.Fatalf("unexpected request to %s", req.URL.Path)
+		return nil
+	})
+	require.ErrorIs(t, testClient.CreateDataStream(context.Background(), "logs-app-default"), errNotSupportedInEs6x)
+}
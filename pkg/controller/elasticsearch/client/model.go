@@ -80,6 +80,14 @@ type Node struct {
 	Name    string   `json:"name"`
 	Version string   `json:"version"`
 	Roles   []string `json:"roles"`
+	// Plugins are only retrieved from /_nodes/plugins
+	Plugins []NodePlugin `json:"plugins,omitempty"`
+}
+
+// NodePlugin partially models a plugin installed on an Elasticsearch node.
+type NodePlugin struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
 }
 
 func (n Node) isV7OrAbove() (bool, error) {
@@ -378,9 +386,12 @@ type RemoteClusters struct {
 	RemoteClusters map[string]RemoteCluster `json:"remote,omitempty"`
 }
 
-// RemoteCluster is the set of seeds to use in a remote cluster setting.
+// RemoteCluster is the set of seeds to use in a remote cluster setting, along with whether to skip it in cross-cluster
+// searches when it is not available. A nil SkipUnavailable resets the setting to its default value, which must also be
+// done when deleting the remote cluster.
 type RemoteCluster struct {
-	Seeds []string `json:"seeds"`
+	Seeds           []string `json:"seeds"`
+	SkipUnavailable *bool    `json:"skip_unavailable"`
 }
 
 // Hit represents a single search hit.
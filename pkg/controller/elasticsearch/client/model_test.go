@@ -10,6 +10,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/utils/pointer"
 )
 
 func TestModel_RemoteCluster(t *testing.T) {
@@ -31,7 +32,23 @@ func TestModel_RemoteCluster(t *testing.T) {
 					},
 				},
 			},
-			want: `{"persistent":{"cluster":{"remote":{"leader":{"seeds":["127.0.0.1:9300"]}}}}}`,
+			want: `{"persistent":{"cluster":{"remote":{"leader":{"seeds":["127.0.0.1:9300"],"skip_unavailable":null}}}}}`,
+		},
+		{
+			name: "Remote cluster skipped when unavailable",
+			arg: RemoteClustersSettings{
+				PersistentSettings: &SettingsGroup{
+					Cluster: RemoteClusters{
+						RemoteClusters: map[string]RemoteCluster{
+							"leader": {
+								Seeds:           []string{"127.0.0.1:9300"},
+								SkipUnavailable: pointer.BoolPtr(true),
+							},
+						},
+					},
+				},
+			},
+			want: `{"persistent":{"cluster":{"remote":{"leader":{"seeds":["127.0.0.1:9300"],"skip_unavailable":true}}}}}`,
 		},
 		{
 			name: "Deleted remote cluster",
@@ -46,7 +63,7 @@ func TestModel_RemoteCluster(t *testing.T) {
 					},
 				},
 			},
-			want: `{"persistent":{"cluster":{"remote":{"leader":{"seeds":null}}}}}`,
+			want: `{"persistent":{"cluster":{"remote":{"leader":{"seeds":null,"skip_unavailable":null}}}}}`,
 		},
 	}
 	for _, tt := range tests {
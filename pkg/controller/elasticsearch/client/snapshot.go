@@ -0,0 +1,166 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+type SnapshotClient interface {
+	// GetSnapshotRepositories returns the snapshot repositories registered in the cluster.
+	GetSnapshotRepositories(ctx context.Context) (SnapshotRepositories, error)
+	// UpsertSnapshotRepository registers or updates a snapshot repository.
+	UpsertSnapshotRepository(ctx context.Context, name string, repository SnapshotRepository) error
+	// DeleteSnapshotRepository unregisters a snapshot repository, leaving the snapshots it holds untouched.
+	DeleteSnapshotRepository(ctx context.Context, name string) error
+	// GetSnapshotLifecyclePolicies returns the snapshot lifecycle management policies of the cluster.
+	// Introduced in: Elasticsearch 7.4.0
+	GetSnapshotLifecyclePolicies(ctx context.Context) (SnapshotLifecyclePolicies, error)
+	// UpsertSnapshotLifecyclePolicy creates or updates a snapshot lifecycle management policy.
+	// Introduced in: Elasticsearch 7.4.0
+	UpsertSnapshotLifecyclePolicy(ctx context.Context, id string, policy SnapshotLifecyclePolicy) error
+	// DeleteSnapshotLifecyclePolicy deletes a snapshot lifecycle management policy, leaving the snapshots it took
+	// untouched.
+	// Introduced in: Elasticsearch 7.4.0
+	DeleteSnapshotLifecyclePolicy(ctx context.Context, id string) error
+	// GetSnapshots returns the snapshots of a repository matching the given name or wildcard pattern.
+	GetSnapshots(ctx context.Context, repository string, pattern string) (Snapshots, error)
+	// RestoreSnapshot starts the restore of a snapshot without waiting for its completion.
+	RestoreSnapshot(ctx context.Context, repository string, snapshot string, request map[string]interface{}) error
+	// GetRecoveries returns the ongoing and completed shard recoveries of the cluster.
+	GetRecoveries(ctx context.Context) (Recoveries, error)
+}
+
+// SnapshotRepositories maps the name of the snapshot repositories to their definition.
+type SnapshotRepositories map[string]SnapshotRepository
+
+// SnapshotRepository models a snapshot repository as returned and expected by the snapshot repository API.
+type SnapshotRepository struct {
+	Type     string                 `json:"type"`
+	Settings map[string]interface{} `json:"settings"`
+}
+
+// SnapshotLifecyclePolicies maps the identifier of the snapshot lifecycle management policies to their definition.
+type SnapshotLifecyclePolicies map[string]SnapshotLifecyclePolicyDefinition
+
+// SnapshotLifecyclePolicyDefinition models a snapshot lifecycle management policy as returned by the SLM API.
+type SnapshotLifecyclePolicyDefinition struct {
+	Version int64                   `json:"version"`
+	Policy  SnapshotLifecyclePolicy `json:"policy"`
+}
+
+// SnapshotLifecyclePolicy models a snapshot lifecycle management policy as expected by the SLM API.
+type SnapshotLifecyclePolicy struct {
+	Name       string                      `json:"name"`
+	Schedule   string                      `json:"schedule"`
+	Repository string                      `json:"repository"`
+	Config     map[string]interface{}      `json:"config,omitempty"`
+	Retention  *SnapshotLifecycleRetention `json:"retention,omitempty"`
+}
+
+// SnapshotLifecycleRetention models the retention of a snapshot lifecycle management policy.
+type SnapshotLifecycleRetention struct {
+	ExpireAfter string `json:"expire_after,omitempty"`
+	MinCount    *int32 `json:"min_count,omitempty"`
+	MaxCount    *int32 `json:"max_count,omitempty"`
+}
+
+// Snapshots models the response of the get snapshot API.
+type Snapshots struct {
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// Snapshot models a snapshot as returned by the get snapshot API.
+type Snapshot struct {
+	Snapshot          string   `json:"snapshot"`
+	State             string   `json:"state"`
+	Indices           []string `json:"indices"`
+	StartTimeInMillis int64    `json:"start_time_in_millis"`
+}
+
+// SnapshotSuccessState is the state of snapshots that completed successfully.
+const SnapshotSuccessState = "SUCCESS"
+
+// Recoveries maps index names to their shard recoveries as returned by the index recovery API.
+type Recoveries map[string]IndexRecovery
+
+// IndexRecovery models the shard recoveries of an index.
+type IndexRecovery struct {
+	Shards []ShardRecovery `json:"shards"`
+}
+
+// ShardRecovery models the recovery of a single shard.
+type ShardRecovery struct {
+	ID     int                 `json:"id"`
+	Type   string              `json:"type"`
+	Stage  string              `json:"stage"`
+	Source ShardRecoverySource `json:"source"`
+}
+
+// ShardRecoverySource models the source of a shard recovery. Repository and Snapshot are only set for recoveries
+// from a snapshot.
+type ShardRecoverySource struct {
+	Repository string `json:"repository,omitempty"`
+	Snapshot   string `json:"snapshot,omitempty"`
+}
+
+const (
+	// SnapshotRecoveryType is the type of shard recoveries restoring a snapshot.
+	SnapshotRecoveryType = "SNAPSHOT"
+	// RecoveryDoneStage is the stage of completed shard recoveries.
+	RecoveryDoneStage = "DONE"
+)
+
+func (c *clientV6) GetSnapshotRepositories(ctx context.Context) (SnapshotRepositories, error) {
+	var repositories SnapshotRepositories
+	err := c.get(ctx, "/_snapshot", &repositories)
+	return repositories, err
+}
+
+func (c *clientV6) UpsertSnapshotRepository(ctx context.Context, name string, repository SnapshotRepository) error {
+	path := fmt.Sprintf("/_snapshot/%s", url.PathEscape(name))
+	return c.put(ctx, path, repository, nil)
+}
+
+func (c *clientV6) DeleteSnapshotRepository(ctx context.Context, name string) error {
+	path := fmt.Sprintf("/_snapshot/%s", url.PathEscape(name))
+	return c.delete(ctx, path)
+}
+
+func (c *clientV7) GetSnapshotLifecyclePolicies(ctx context.Context) (SnapshotLifecyclePolicies, error) {
+	var policies SnapshotLifecyclePolicies
+	err := c.get(ctx, "/_slm/policy", &policies)
+	return policies, err
+}
+
+func (c *clientV7) UpsertSnapshotLifecyclePolicy(ctx context.Context, id string, policy SnapshotLifecyclePolicy) error {
+	path := fmt.Sprintf("/_slm/policy/%s", url.PathEscape(id))
+	return c.put(ctx, path, policy, nil)
+}
+
+func (c *clientV7) DeleteSnapshotLifecyclePolicy(ctx context.Context, id string) error {
+	path := fmt.Sprintf("/_slm/policy/%s", url.PathEscape(id))
+	return c.delete(ctx, path)
+}
+
+func (c *clientV6) GetSnapshots(ctx context.Context, repository string, pattern string) (Snapshots, error) {
+	var snapshots Snapshots
+	path := fmt.Sprintf("/_snapshot/%s/%s", url.PathEscape(repository), url.PathEscape(pattern))
+	err := c.get(ctx, path, &snapshots)
+	return snapshots, err
+}
+
+func (c *clientV6) RestoreSnapshot(ctx context.Context, repository string, snapshot string, request map[string]interface{}) error {
+	path := fmt.Sprintf("/_snapshot/%s/%s/_restore", url.PathEscape(repository), url.PathEscape(snapshot))
+	return c.post(ctx, path, request, nil)
+}
+
+func (c *clientV6) GetRecoveries(ctx context.Context) (Recoveries, error) {
+	var recoveries Recoveries
+	err := c.get(ctx, "/_recovery", &recoveries)
+	return recoveries, err
+}
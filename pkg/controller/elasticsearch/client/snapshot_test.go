@@ -0,0 +1,145 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+)
+
+func TestClient_GetSnapshotRepositories(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodGet, req.Method)
+		require.Equal(t, "/_snapshot", req.URL.Path)
+		return NewMockResponse(200, req, `{"backups":{"type":"gcs","settings":{"bucket":"my-bucket","client":"default"}}}`)
+	})
+	repositories, err := testClient.GetSnapshotRepositories(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, SnapshotRepositories{
+		"backups": {Type: "gcs", Settings: map[string]interface{}{"bucket": "my-bucket", "client": "default"}},
+	}, repositories)
+}
+
+func TestClient_UpsertSnapshotRepository(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodPut, req.Method)
+		require.Equal(t, "/_snapshot/backups", req.URL.Path)
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"type":"fs","settings":{"location":"/mnt/backups"}}`, string(body))
+		return NewMockResponse(200, req, `{"acknowledged":true}`)
+	})
+	err := testClient.UpsertSnapshotRepository(context.Background(), "backups", SnapshotRepository{
+		Type:     "fs",
+		Settings: map[string]interface{}{"location": "/mnt/backups"},
+	})
+	require.NoError(t, err)
+}
+
+func TestClient_DeleteSnapshotRepository(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodDelete, req.Method)
+		require.Equal(t, "/_snapshot/backups", req.URL.Path)
+		return NewMockResponse(200, req, `{"acknowledged":true}`)
+	})
+	require.NoError(t, testClient.DeleteSnapshotRepository(context.Background(), "backups"))
+}
+
+func TestClient_GetSnapshotLifecyclePolicies(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodGet, req.Method)
+		require.Equal(t, "/_slm/policy", req.URL.Path)
+		return NewMockResponse(200, req, `{"nightly":{"version":1,"modified_date_millis":1640995200000,"policy":{"name":"<nightly-{now/d}>","schedule":"0 30 1 * * ?","repository":"backups","retention":{"expire_after":"30d","min_count":5}}}}`)
+	})
+	policies, err := testClient.GetSnapshotLifecyclePolicies(context.Background())
+	require.NoError(t, err)
+	minCount := int32(5)
+	require.Equal(t, SnapshotLifecyclePolicies{
+		"nightly": {
+			Version: 1,
+			Policy: SnapshotLifecyclePolicy{
+				Name:       "<nightly-{now/d}>",
+				Schedule:   "0 30 1 * * ?",
+				Repository: "backups",
+				Retention:  &SnapshotLifecycleRetention{ExpireAfter: "30d", MinCount: &minCount},
+			},
+		},
+	}, policies)
+}
+
+func TestClient_UpsertSnapshotLifecyclePolicy(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodPut, req.Method)
+		require.Equal(t, "/_slm/policy/nightly", req.URL.Path)
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"name":"<nightly-{now/d}>","schedule":"0 30 1 * * ?","repository":"backups","config":{"indices":["*"]}}`, string(body))
+		return NewMockResponse(200, req, `{"acknowledged":true}`)
+	})
+	err := testClient.UpsertSnapshotLifecyclePolicy(context.Background(), "nightly", SnapshotLifecyclePolicy{
+		Name:       "<nightly-{now/d}>",
+		Schedule:   "0 30 1 * * ?",
+		Repository: "backups",
+		Config:     map[string]interface{}{"indices": []string{"*"}},
+	})
+	require.NoError(t, err)
+}
+
+func TestClient_SnapshotLifecyclePoliciesNotSupportedInEs6x(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("6.8.0"), func(req *http.Request) *http.Response {
+		t.Fatalf("unexpected request to %s", req.URL.Path)
+		return nil
+	})
+	_, err := testClient.GetSnapshotLifecyclePolicies(context.Background())
+	require.ErrorIs(t, err, errNotSupportedInEs6x)
+}
+
+func TestClient_GetSnapshots(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodGet, req.Method)
+		require.Equal(t, "/_snapshot/backups/nightly-*", req.URL.Path)
+		return NewMockResponse(200, req, `{"snapshots":[{"snapshot":"nightly-2022.01.01","uuid":"dKb54xw67gvdRctLCxSket","state":"SUCCESS","indices":["logs"],"start_time_in_millis":1640995200000}]}`)
+	})
+	snapshots, err := testClient.GetSnapshots(context.Background(), "backups", "nightly-*")
+	require.NoError(t, err)
+	require.Equal(t, Snapshots{Snapshots: []Snapshot{
+		{Snapshot: "nightly-2022.01.01", State: SnapshotSuccessState, Indices: []string{"logs"}, StartTimeInMillis: 1640995200000},
+	}}, snapshots)
+}
+
+func TestClient_RestoreSnapshot(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodPost, req.Method)
+		require.Equal(t, "/_snapshot/backups/nightly-2022.01.01/_restore", req.URL.Path)
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"indices":"logs","include_global_state":false}`, string(body))
+		return NewMockResponse(200, req, `{"accepted":true}`)
+	})
+	err := testClient.RestoreSnapshot(context.Background(), "backups", "nightly-2022.01.01", map[string]interface{}{
+		"indices":              "logs",
+		"include_global_state": false,
+	})
+	require.NoError(t, err)
+}
+
+func TestClient_GetRecoveries(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodGet, req.Method)
+		require.Equal(t, "/_recovery", req.URL.Path)
+		return NewMockResponse(200, req, `{"logs":{"shards":[{"id":0,"type":"SNAPSHOT","stage":"INDEX","primary":true,"source":{"repository":"backups","snapshot":"nightly-2022.01.01","version":"7.16.0","index":"logs"}}]}}`)
+	})
+	recoveries, err := testClient.GetRecoveries(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, Recoveries{"logs": {Shards: []ShardRecovery{
+		{ID: 0, Type: SnapshotRecoveryType, Stage: "INDEX", Source: ShardRecoverySource{Repository: "backups", Snapshot: "nightly-2022.01.01"}},
+	}}}, recoveries)
+}
@@ -0,0 +1,117 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+type IndexTemplateClient interface {
+	// GetComponentTemplates returns the component templates of the cluster, with flat settings.
+	// Introduced in: Elasticsearch 7.8.0
+	GetComponentTemplates(ctx context.Context) (ComponentTemplates, error)
+	// UpsertComponentTemplate creates or updates a component template.
+	// Introduced in: Elasticsearch 7.8.0
+	UpsertComponentTemplate(ctx context.Context, name string, template ComponentTemplate) error
+	// DeleteComponentTemplate deletes a component template. Elasticsearch rejects the deletion of component templates
+	// still in use by index templates.
+	// Introduced in: Elasticsearch 7.8.0
+	DeleteComponentTemplate(ctx context.Context, name string) error
+	// GetIndexTemplates returns the composable index templates of the cluster, with flat settings.
+	// Introduced in: Elasticsearch 7.8.0
+	GetIndexTemplates(ctx context.Context) (IndexTemplates, error)
+	// UpsertIndexTemplate creates or updates a composable index template.
+	// Introduced in: Elasticsearch 7.8.0
+	UpsertIndexTemplate(ctx context.Context, name string, template IndexTemplate) error
+	// DeleteIndexTemplate deletes a composable index template.
+	// Introduced in: Elasticsearch 7.8.0
+	DeleteIndexTemplate(ctx context.Context, name string) error
+}
+
+// ComponentTemplates maps the name of the component templates to their definition.
+type ComponentTemplates map[string]ComponentTemplate
+
+// ComponentTemplate models a component template as returned and expected by the component template API.
+type ComponentTemplate struct {
+	Template map[string]interface{} `json:"template"`
+	Meta     map[string]interface{} `json:"_meta,omitempty"`
+}
+
+// IndexTemplates maps the name of the composable index templates to their definition.
+type IndexTemplates map[string]IndexTemplate
+
+// IndexTemplate models a composable index template as returned and expected by the index template API.
+type IndexTemplate struct {
+	IndexPatterns []string                 `json:"index_patterns"`
+	ComposedOf    []string                 `json:"composed_of,omitempty"`
+	Priority      *int64                   `json:"priority,omitempty"`
+	Template      map[string]interface{}   `json:"template,omitempty"`
+	DataStream    *IndexTemplateDataStream `json:"data_stream,omitempty"`
+	Meta          map[string]interface{}   `json:"_meta,omitempty"`
+}
+
+// IndexTemplateDataStream makes an index template create data streams instead of regular indices. Its options are left
+// to their default values.
+type IndexTemplateDataStream struct{}
+
+type componentTemplatesResponse struct {
+	ComponentTemplates []struct {
+		Name              string            `json:"name"`
+		ComponentTemplate ComponentTemplate `json:"component_template"`
+	} `json:"component_templates"`
+}
+
+type indexTemplatesResponse struct {
+	IndexTemplates []struct {
+		Name          string        `json:"name"`
+		IndexTemplate IndexTemplate `json:"index_template"`
+	} `json:"index_templates"`
+}
+
+func (c *clientV7) GetComponentTemplates(ctx context.Context) (ComponentTemplates, error) {
+	var response componentTemplatesResponse
+	if err := c.get(ctx, "/_component_template?flat_settings=true", &response); err != nil {
+		return nil, err
+	}
+	templates := make(ComponentTemplates, len(response.ComponentTemplates))
+	for _, template := range response.ComponentTemplates {
+		templates[template.Name] = template.ComponentTemplate
+	}
+	return templates, nil
+}
+
+func (c *clientV7) UpsertComponentTemplate(ctx context.Context, name string, template ComponentTemplate) error {
+	path := fmt.Sprintf("/_component_template/%s", url.PathEscape(name))
+	return c.put(ctx, path, template, nil)
+}
+
+func (c *clientV7) DeleteComponentTemplate(ctx context.Context, name string) error {
+	path := fmt.Sprintf("/_component_template/%s", url.PathEscape(name))
+	return c.delete(ctx, path)
+}
+
+func (c *clientV7) GetIndexTemplates(ctx context.Context) (IndexTemplates, error) {
+	var response indexTemplatesResponse
+	if err := c.get(ctx, "/_index_template?flat_settings=true", &response); err != nil {
+		return nil, err
+	}
+	templates := make(IndexTemplates, len(response.IndexTemplates))
+	for _, template := range response.IndexTemplates {
+		templates[template.Name] = template.IndexTemplate
+	}
+	return templates, nil
+}
+
+func (c *clientV7) UpsertIndexTemplate(ctx context.Context, name string, template IndexTemplate) error {
+	path := fmt.Sprintf("/_index_template/%s", url.PathEscape(name))
+	return c.put(ctx, path, template, nil)
+}
+
+func (c *clientV7) DeleteIndexTemplate(ctx context.Context, name string) error {
+	path := fmt.Sprintf("/_index_template/%s", url.PathEscape(name))
+	return c.delete(ctx, path)
+}
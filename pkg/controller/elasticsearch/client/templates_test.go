@@ -0,0 +1,104 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+)
+
+func TestClient_GetComponentTemplates(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodGet, req.Method)
+		require.Equal(t, "/_component_template", req.URL.Path)
+		require.Equal(t, "true", req.URL.Query().Get("flat_settings"))
+		return NewMockResponse(200, req, `{"component_templates":[{"name":"logs-settings","component_template":{"template":{"settings":{"index.number_of_shards":"1"}},"_meta":{"managed_by":"eck"}}}]}`)
+	})
+	templates, err := testClient.GetComponentTemplates(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, ComponentTemplates{
+		"logs-settings": {
+			Template: map[string]interface{}{"settings": map[string]interface{}{"index.number_of_shards": "1"}},
+			Meta:     map[string]interface{}{"managed_by": "eck"},
+		},
+	}, templates)
+}
+
+func TestClient_UpsertComponentTemplate(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodPut, req.Method)
+		require.Equal(t, "/_component_template/logs-mappings", req.URL.Path)
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"template":{"mappings":{"properties":{"message":{"type":"text"}}}}}`, string(body))
+		return NewMockResponse(200, req, `{"acknowledged":true}`)
+	})
+	err := testClient.UpsertComponentTemplate(context.Background(), "logs-mappings", ComponentTemplate{
+		Template: map[string]interface{}{"mappings": map[string]interface{}{"properties": map[string]interface{}{"message": map[string]interface{}{"type": "text"}}}},
+	})
+	require.NoError(t, err)
+}
+
+func TestClient_GetIndexTemplates(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodGet, req.Method)
+		require.Equal(t, "/_index_template", req.URL.Path)
+		require.Equal(t, "true", req.URL.Query().Get("flat_settings"))
+		return NewMockResponse(200, req, `{"index_templates":[{"name":"logs","index_template":{"index_patterns":["logs-*"],"composed_of":["logs-settings"],"priority":200,"data_stream":{"hidden":false}}}]}`)
+	})
+	templates, err := testClient.GetIndexTemplates(context.Background())
+	require.NoError(t, err)
+	priority := int64(200)
+	require.Equal(t, IndexTemplates{
+		"logs": {
+			IndexPatterns: []string{"logs-*"},
+			ComposedOf:    []string{"logs-settings"},
+			Priority:      &priority,
+			DataStream:    &IndexTemplateDataStream{},
+		},
+	}, templates)
+}
+
+func TestClient_UpsertIndexTemplate(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodPut, req.Method)
+		require.Equal(t, "/_index_template/logs", req.URL.Path)
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"index_patterns":["logs-*"],"composed_of":["logs-settings"],"data_stream":{},"_meta":{"managed_by":"eck"}}`, string(body))
+		return NewMockResponse(200, req, `{"acknowledged":true}`)
+	})
+	err := testClient.UpsertIndexTemplate(context.Background(), "logs", IndexTemplate{
+		IndexPatterns: []string{"logs-*"},
+		ComposedOf:    []string{"logs-settings"},
+		DataStream:    &IndexTemplateDataStream{},
+		Meta:          map[string]interface{}{"managed_by": "eck"},
+	})
+	require.NoError(t, err)
+}
+
+func TestClient_DeleteIndexTemplate(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("7.16.0"), func(req *http.Request) *http.Response {
+		require.Equal(t, http.MethodDelete, req.Method)
+		require.Equal(t, "/_index_template/logs", req.URL.Path)
+		return NewMockResponse(200, req, `{"acknowledged":true}`)
+	})
+	require.NoError(t, testClient.DeleteIndexTemplate(context.Background(), "logs"))
+}
+
+func TestClient_IndexTemplatesNotSupportedInEs6x(t *testing.T) {
+	testClient := NewMockClient(version.MustParse("6.8.0"), func(req *http.Request) *http.Response {
+		t.Fatalf("unexpected request to %s", req.URL.Path)
+		return nil
+	})
+	_, err := testClient.GetIndexTemplates(context.Background())
+	require.ErrorIs(t, err, errNotSupportedInEs6x)
+}
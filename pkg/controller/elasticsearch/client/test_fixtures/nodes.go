@@ -724,6 +724,45 @@ const (
 		}
 	}
 	  `
+	NodesPluginsSample = `
+{
+	"_nodes": {
+		"total": 1,
+		"successful": 1,
+		"failed": 0
+	},
+	"cluster_name": "elasticsearch-sample",
+	"nodes": {
+		"Rt-o5-ZBQaq-Nkhhy0p7JA": {
+			"name": "elasticsearch-sample-es-default-0",
+			"version": "7.16.0",
+			"roles": ["data", "ingest", "master"],
+			"plugins": [
+				{
+					"name": "analysis-icu",
+					"version": "7.16.0",
+					"elasticsearch_version": "7.16.0",
+					"java_version": "1.8",
+					"description": "The ICU Analysis plugin integrates the Lucene ICU module into Elasticsearch, adding ICU-related analysis components.",
+					"classname": "org.elasticsearch.plugin.analysis.icu.AnalysisICUPlugin",
+					"extended_plugins": [],
+					"has_native_controller": false
+				},
+				{
+					"name": "repository-gcs",
+					"version": "7.16.0",
+					"elasticsearch_version": "7.16.0",
+					"java_version": "1.8",
+					"description": "The GCS repository plugin adds Google Cloud Storage support for repositories.",
+					"classname": "org.elasticsearch.repositories.gcs.GoogleCloudStoragePlugin",
+					"extended_plugins": [],
+					"has_native_controller": false
+				}
+			]
+		}
+	}
+}
+`
 )
 
 func MasterNodeForVersion(version string) string {
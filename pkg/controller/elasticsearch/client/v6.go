@@ -115,6 +115,12 @@ func (c *clientV6) GetNodes(ctx context.Context) (Nodes, error) {
 	return nodes, err
 }
 
+func (c *clientV6) GetNodesPlugins(ctx context.Context) (Nodes, error) {
+	var nodes Nodes
+	err := c.get(ctx, "/_nodes/_all/plugins", &nodes)
+	return nodes, err
+}
+
 func (c *clientV6) GetNodesStats(ctx context.Context) (NodesStats, error) {
 	var nodesStats NodesStats
 	// restrict call to basic node info only
@@ -164,6 +170,46 @@ func (c *clientV6) DeleteVotingConfigExclusions(ctx context.Context, waitForRemo
 	return errNotSupportedInEs6x
 }
 
+func (c *clientV6) GetSnapshotLifecyclePolicies(_ context.Context) (SnapshotLifecyclePolicies, error) {
+	return nil, errNotSupportedInEs6x
+}
+
+func (c *clientV6) UpsertSnapshotLifecyclePolicy(_ context.Context, _ string, _ SnapshotLifecyclePolicy) error {
+	return errNotSupportedInEs6x
+}
+
+func (c *clientV6) DeleteSnapshotLifecyclePolicy(_ context.Context, _ string) error {
+	return errNotSupportedInEs6x
+}
+
+func (c *clientV6) CreateDataStream(_ context.Context, _ string) error {
+	return errNotSupportedInEs6x
+}
+
+func (c *clientV6) GetComponentTemplates(_ context.Context) (ComponentTemplates, error) {
+	return nil, errNotSupportedInEs6x
+}
+
+func (c *clientV6) UpsertComponentTemplate(_ context.Context, _ string, _ ComponentTemplate) error {
+	return errNotSupportedInEs6x
+}
+
+func (c *clientV6) DeleteComponentTemplate(_ context.Context, _ string) error {
+	return errNotSupportedInEs6x
+}
+
+func (c *clientV6) GetIndexTemplates(_ context.Context) (IndexTemplates, error) {
+	return nil, errNotSupportedInEs6x
+}
+
+func (c *clientV6) UpsertIndexTemplate(_ context.Context, _ string, _ IndexTemplate) error {
+	return errNotSupportedInEs6x
+}
+
+func (c *clientV6) DeleteIndexTemplate(_ context.Context, _ string) error {
+	return errNotSupportedInEs6x
+}
+
 func (c *clientV6) DeleteAutoscalingPolicies(_ context.Context) error {
 	return errNotSupportedInEs6x
 }
@@ -46,11 +46,12 @@ func ReconcileScriptsConfigMap(ctx context.Context, c k8s.Client, es esv1.Elasti
 	scriptsConfigMap := NewConfigMapWithData(
 		types.NamespacedName{Namespace: es.Namespace, Name: esv1.ScriptsConfigMap(es.Name)},
 		map[string]string{
-			nodespec.ReadinessProbeScriptConfigKey: nodespec.ReadinessProbeScript,
-			nodespec.PreStopHookScriptConfigKey:    nodespec.PreStopHookScript,
-			initcontainer.PrepareFsScriptConfigKey: fsScript,
-			initcontainer.SuspendScriptConfigKey:   initcontainer.SuspendScript,
-			initcontainer.SuspendedHostsFile:       initcontainer.RenderSuspendConfiguration(es),
+			nodespec.ReadinessProbeScriptConfigKey:  nodespec.ReadinessProbeScript,
+			nodespec.PreStopHookScriptConfigKey:     nodespec.PreStopHookScript,
+			nodespec.KeystoreUpdaterScriptConfigKey: nodespec.KeystoreUpdaterScript,
+			initcontainer.PrepareFsScriptConfigKey:  fsScript,
+			initcontainer.SuspendScriptConfigKey:    initcontainer.SuspendScript,
+			initcontainer.SuspendedHostsFile:        initcontainer.RenderSuspendConfiguration(es),
 		},
 	)
 
@@ -33,14 +33,17 @@ import (
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/cleanup"
 	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/configmap"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/ilm"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/initcontainer"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/label"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/license"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/observer"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/realms"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/reconcile"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/remotecluster"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/services"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/settings"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/snapshot"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/stackmon"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/user"
 	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
@@ -280,6 +283,35 @@ func (d *defaultDriver) Reconcile(ctx context.Context) *reconciler.Results {
 		}
 	}
 
+	// reconcile the resources managed through the Elasticsearch API: auto-follow patterns relying on the remote clusters,
+	// snapshot repositories, then the snapshot and index lifecycle policies relying on them
+	if esReachable {
+		for _, managedResources := range []struct {
+			description string
+			reconcile   func() error
+		}{
+			{description: "auto-follow patterns", reconcile: func() error {
+				return remotecluster.ReconcileAutoFollowPatterns(ctx, d.Client, &d.ES, esClient, d.LicenseChecker, d.ReconcileState)
+			}},
+			{description: "snapshot repositories", reconcile: func() error {
+				return snapshot.ReconcileRepositories(ctx, d.Client, &d.ES, esClient, d.ReconcileState)
+			}},
+			{description: "snapshot lifecycle policies", reconcile: func() error {
+				return snapshot.ReconcilePolicies(ctx, d.Client, &d.ES, esClient, d.ReconcileState)
+			}},
+			{description: "index lifecycle policies", reconcile: func() error {
+				return ilm.ReconcilePolicies(ctx, d.Client, &d.ES, esClient, d.ReconcileState)
+			}},
+		} {
+			if err := managedResources.reconcile(); err != nil {
+				msg := fmt.Sprintf("Could not reconcile %s, re-queuing", managedResources.description)
+				log.Info(msg, "err", err, "namespace", d.ES.Namespace, "es_name", d.ES.Name)
+				d.ReconcileState.AddEvent(corev1.EventTypeWarning, events.EventReasonUnexpected, fmt.Sprintf("%s: %s", msg, err.Error()))
+				results.WithReconciliationState(defaultRequeue.WithReason(msg))
+			}
+		}
+	}
+
 	// Compute seed hosts based on current masters with a podIP
 	if err := settings.UpdateSeedHostsConfigMap(ctx, d.Client, d.ES, resourcesState.AllPods); err != nil {
 		return results.WithError(err)
@@ -297,6 +329,11 @@ func (d *defaultDriver) Reconcile(ctx context.Context) *reconciler.Results {
 		return results.WithError(err)
 	}
 
+	// watch the secrets referenced by the realms, the nodes are restarted when they change
+	if err := realms.WatchSecrets(d.ES, d.DynamicWatches()); err != nil {
+		return results.WithError(err)
+	}
+
 	// set an annotation with the ClusterUUID, if bootstrapped
 	requeue, err := bootstrap.ReconcileClusterUUID(ctx, d.Client, &d.ES, esClient, esReachable)
 	if err != nil {
@@ -306,6 +343,22 @@ func (d *defaultDriver) Reconcile(ctx context.Context) *reconciler.Results {
 		results = results.WithReconciliationState(defaultRequeue.WithReason("Elasticsearch cluster UUID is not reconciled"))
 	}
 
+	// reload the secure settings updated in place in the keystore of the Pods
+	requeue, err = reloadSecureSettings(ctx, d.Client, &d.ES, esClient, esReachable, keystoreResources, time.Now())
+	if err != nil {
+		return results.WithError(err)
+	}
+	if requeue {
+		results = results.WithReconciliationState(defaultRequeue.WithReason("Secure settings are being reloaded"))
+	}
+
+	// report the plugins installed on the nodes
+	if err := updatePluginsStatus(ctx, d.ES, esClient, esReachable, d.ReconcileState); err != nil {
+		msg := "Could not retrieve the installed plugins, re-queuing"
+		log.Info(msg, "err", err, "namespace", d.ES.Namespace, "es_name", d.ES.Name)
+		results.WithReconciliationState(defaultRequeue.WithReason(msg))
+	}
+
 	// reconcile beats config secrets if Stack Monitoring is defined
 	err = stackmon.ReconcileConfigSecrets(d.Client, d.ES)
 	if err != nil {
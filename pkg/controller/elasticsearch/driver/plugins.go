@@ -0,0 +1,77 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package driver
+
+import (
+	"context"
+	"sort"
+
+	"go.elastic.co/apm"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/tracing"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/reconcile"
+)
+
+// updatePluginsStatus reports in the status the plugins installed on the Elasticsearch nodes, along with their version.
+// Only the plugins specified by the user are reported, the modules shipped with Elasticsearch are not.
+func updatePluginsStatus(
+	ctx context.Context,
+	es esv1.Elasticsearch,
+	esClient esclient.Client,
+	esReachable bool,
+	state *reconcile.State,
+) error {
+	if len(es.Spec.Plugins) == 0 {
+		state.UpdatePlugins(nil)
+		return nil
+	}
+	if !esReachable {
+		// keep the last observed plugins
+		return nil
+	}
+
+	span, ctx := apm.StartSpan(ctx, "update_plugins_status", tracing.SpanTypeApp)
+	defer span.End()
+
+	nodes, err := esClient.GetNodesPlugins(ctx)
+	if err != nil {
+		return err
+	}
+	state.UpdatePlugins(installedPlugins(es.Spec.Plugins, nodes))
+	return nil
+}
+
+// installedPlugins returns the distinct specified plugins installed on the given nodes, sorted by name and version.
+// A plugin can be reported with several versions while the nodes are being upgraded.
+func installedPlugins(specified []esv1.Plugin, nodes esclient.Nodes) []esv1.PluginStatus {
+	names := make(map[string]struct{}, len(specified))
+	for _, plugin := range specified {
+		names[plugin.Name] = struct{}{}
+	}
+	seen := make(map[esv1.PluginStatus]struct{})
+	var installed []esv1.PluginStatus
+	for _, node := range nodes.Nodes {
+		for _, plugin := range node.Plugins {
+			if _, isSpecified := names[plugin.Name]; !isSpecified {
+				continue
+			}
+			status := esv1.PluginStatus{Name: plugin.Name, Version: plugin.Version}
+			if _, exists := seen[status]; exists {
+				continue
+			}
+			seen[status] = struct{}{}
+			installed = append(installed, status)
+		}
+	}
+	sort.Slice(installed, func(i, j int) bool {
+		if installed[i].Name != installed[j].Name {
+			return installed[i].Name < installed[j].Name
+		}
+		return installed[i].Version < installed[j].Version
+	})
+	return installed
+}
@@ -0,0 +1,79 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/reconcile"
+)
+
+type pluginsESClient struct {
+	esclient.Client
+	nodes esclient.Nodes
+}
+
+func (f *pluginsESClient) GetNodesPlugins(_ context.Context) (esclient.Nodes, error) {
+	return f.nodes, nil
+}
+
+func Test_updatePluginsStatus(t *testing.T) {
+	nodes := esclient.Nodes{Nodes: map[string]esclient.Node{
+		"a": {Plugins: []esclient.NodePlugin{{Name: "repository-gcs", Version: "7.16.0"}, {Name: "analysis-icu", Version: "7.16.0"}}},
+		"b": {Plugins: []esclient.NodePlugin{{Name: "analysis-icu", Version: "7.16.0"}, {Name: "unspecified", Version: "1.0.0"}}},
+		"c": {Plugins: []esclient.NodePlugin{{Name: "analysis-icu", Version: "7.15.2"}}},
+	}}
+	previous := []esv1.PluginStatus{{Name: "analysis-icu", Version: "7.15.2"}}
+	tests := []struct {
+		name        string
+		plugins     []esv1.Plugin
+		esReachable bool
+		want        []esv1.PluginStatus
+	}{
+		{
+			name:        "no plugins specified",
+			esReachable: true,
+			want:        nil,
+		},
+		{
+			name:        "Elasticsearch not reachable: keep the previous status",
+			plugins:     []esv1.Plugin{{Name: "analysis-icu"}},
+			esReachable: false,
+			want:        previous,
+		},
+		{
+			name:        "report the distinct specified plugins",
+			plugins:     []esv1.Plugin{{Name: "analysis-icu"}, {Name: "repository-gcs"}},
+			esReachable: true,
+			want: []esv1.PluginStatus{
+				{Name: "analysis-icu", Version: "7.15.2"},
+				{Name: "analysis-icu", Version: "7.16.0"},
+				{Name: "repository-gcs", Version: "7.16.0"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := esv1.Elasticsearch{
+				Spec:   esv1.ElasticsearchSpec{Version: "7.16.0", Plugins: tt.plugins},
+				Status: esv1.ElasticsearchStatus{Plugins: previous},
+			}
+			state := reconcile.MustNewState(es)
+			err := updatePluginsStatus(context.Background(), es, &pluginsESClient{nodes: nodes}, tt.esReachable, state)
+			require.NoError(t, err)
+			_, updated := state.Apply()
+			actual := es.Status.Plugins
+			if updated != nil {
+				actual = updated.Status.Plugins
+			}
+			require.Equal(t, tt.want, actual)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.elastic.co/apm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/keystore"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/tracing"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+const (
+	// SecureSettingsAnnotationName is used to store on the Elasticsearch resource the version of the secure settings
+	// last observed by the operator, and when it was observed.
+	SecureSettingsAnnotationName = "elasticsearch.k8s.elastic.co/secure-settings"
+	// secureSettingsReloadPeriod is the period during which the secure settings are reloaded after they changed. It
+	// covers the propagation of the secure settings secret to the Pods by the kubelet, and the update of their keystore
+	// by the keystore updater container.
+	secureSettingsReloadPeriod = 3 * time.Minute
+)
+
+// secureSettingsState is the content of the secure settings annotation.
+type secureSettingsState struct {
+	Version    string      `json:"version"`
+	ObservedAt metav1.Time `json:"observedAt"`
+}
+
+// reloadSecureSettings reloads the secure settings of the Elasticsearch nodes for a while after they changed, for
+// Elasticsearch to pick up the reloadable secure settings updated in place in the keystore of the Pods. Changes to
+// non-reloadable secure settings rotate the Pods instead. Nothing is done unless the reload of the secure settings is
+// enabled with the ReloadSecureSettings annotation.
+// It returns a boolean indicating whether the reconciliation should be re-queued to reload the secure settings again.
+func reloadSecureSettings(
+	ctx context.Context,
+	c k8s.Client,
+	es *esv1.Elasticsearch,
+	esClient esclient.Client,
+	esReachable bool,
+	keystoreResources *keystore.Resources,
+	now time.Time,
+) (bool, error) {
+	if keystoreResources == nil || !es.IsSecureSettingsReloadEnabled() {
+		// no secure settings to reload
+		return false, nil
+	}
+
+	span, ctx := apm.StartSpan(ctx, "reload_secure_settings", tracing.SpanTypeApp)
+	defer span.End()
+
+	state, err := observedSecureSettings(*es)
+	if err != nil {
+		return false, err
+	}
+	if state == nil {
+		// first observation, the keystore of the Pods has been created with these secure settings
+		return false, annotateWithSecureSettings(ctx, c, es, secureSettingsState{Version: keystoreResources.Version})
+	}
+	if state.Version != keystoreResources.Version {
+		state = &secureSettingsState{Version: keystoreResources.Version, ObservedAt: metav1.NewTime(now)}
+		if err := annotateWithSecureSettings(ctx, c, es, *state); err != nil {
+			return false, err
+		}
+	}
+
+	if now.Sub(state.ObservedAt.Time) > secureSettingsReloadPeriod {
+		// keystores are expected to be up-to-date and reloaded
+		return false, nil
+	}
+	if !esReachable {
+		// retry later
+		return true, nil
+	}
+	if err := esClient.ReloadSecureSettings(ctx); err != nil {
+		// not critical, the secure settings are reloaded again until the end of the reload period
+		log.Info(
+			"Recoverable error while reloading secure settings",
+			"namespace", es.Namespace,
+			"es_name", es.Name,
+			"error", err,
+		)
+	}
+	return true, nil
+}
+
+// observedSecureSettings returns the secure settings state stored in the annotation of the Elasticsearch resource,
+// or nil if there is none.
+func observedSecureSettings(es esv1.Elasticsearch) (*secureSettingsState, error) {
+	value, exists := es.Annotations[SecureSettingsAnnotationName]
+	if !exists {
+		return nil, nil
+	}
+	var state secureSettingsState
+	if err := json.Unmarshal([]byte(value), &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// annotateWithSecureSettings stores the given secure settings state in the annotation of the Elasticsearch resource,
+// patching only that annotation.
+func annotateWithSecureSettings(ctx context.Context, c k8s.Client, es *esv1.Elasticsearch, state secureSettingsState) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	mergePatch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{SecureSettingsAnnotationName: string(value)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return c.Patch(ctx, es, client.RawPatch(types.MergePatchType, mergePatch))
+}
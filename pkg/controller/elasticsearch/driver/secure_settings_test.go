@@ -0,0 +1,146 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/keystore"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+type reloadSecureSettingsESClient struct {
+	esclient.Client
+	reloadCount int
+	err         error
+}
+
+func (f *reloadSecureSettingsESClient) ReloadSecureSettings(_ context.Context) error {
+	f.reloadCount++
+	return f.err
+}
+
+func Test_reloadSecureSettings(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	esWithState := func(state *secureSettingsState) esv1.Elasticsearch {
+		es := esv1.Elasticsearch{ObjectMeta: metav1.ObjectMeta{
+			Name:        "es",
+			Namespace:   "ns",
+			Annotations: map[string]string{esv1.ReloadSecureSettingsAnnotation: "true"},
+		}}
+		if state != nil {
+			value, err := json.Marshal(state)
+			require.NoError(t, err)
+			es.Annotations[SecureSettingsAnnotationName] = string(value)
+		}
+		return es
+	}
+	tests := []struct {
+		name              string
+		es                esv1.Elasticsearch
+		keystoreResources *keystore.Resources
+		esReachable       bool
+		reloadErr         error
+		wantRequeue       bool
+		wantReloads       int
+		wantState         *secureSettingsState
+	}{
+		{
+			name:        "no secure settings",
+			es:          esWithState(nil),
+			esReachable: true,
+		},
+		{
+			name:              "reload of the secure settings not enabled",
+			es:                esv1.Elasticsearch{ObjectMeta: metav1.ObjectMeta{Name: "es", Namespace: "ns"}},
+			keystoreResources: &keystore.Resources{Version: "1"},
+			esReachable:       true,
+		},
+		{
+			name:              "first observation of the secure settings: no reload",
+			es:                esWithState(nil),
+			keystoreResources: &keystore.Resources{Version: "1"},
+			esReachable:       true,
+			wantState:         &secureSettingsState{Version: "1"},
+		},
+		{
+			name:              "secure settings changed: reload",
+			es:                esWithState(&secureSettingsState{Version: "1"}),
+			keystoreResources: &keystore.Resources{Version: "2"},
+			esReachable:       true,
+			wantRequeue:       true,
+			wantReloads:       1,
+			wantState:         &secureSettingsState{Version: "2", ObservedAt: metav1.NewTime(now)},
+		},
+		{
+			name:              "secure settings changed recently: reload again",
+			es:                esWithState(&secureSettingsState{Version: "2", ObservedAt: metav1.NewTime(now.Add(-time.Minute))}),
+			keystoreResources: &keystore.Resources{Version: "2"},
+			esReachable:       true,
+			wantRequeue:       true,
+			wantReloads:       1,
+			wantState:         &secureSettingsState{Version: "2", ObservedAt: metav1.NewTime(now.Add(-time.Minute))},
+		},
+		{
+			name:              "secure settings changed recently but the reload failed: requeue",
+			es:                esWithState(&secureSettingsState{Version: "2", ObservedAt: metav1.NewTime(now.Add(-time.Minute))}),
+			keystoreResources: &keystore.Resources{Version: "2"},
+			esReachable:       true,
+			reloadErr:         errors.New("timeout"),
+			wantRequeue:       true,
+			wantReloads:       1,
+			wantState:         &secureSettingsState{Version: "2", ObservedAt: metav1.NewTime(now.Add(-time.Minute))},
+		},
+		{
+			name:              "secure settings changed recently but Elasticsearch is not reachable: requeue",
+			es:                esWithState(&secureSettingsState{Version: "2", ObservedAt: metav1.NewTime(now.Add(-time.Minute))}),
+			keystoreResources: &keystore.Resources{Version: "2"},
+			esReachable:       false,
+			wantRequeue:       true,
+			wantState:         &secureSettingsState{Version: "2", ObservedAt: metav1.NewTime(now.Add(-time.Minute))},
+		},
+		{
+			name:              "reload period is over: nothing to do",
+			es:                esWithState(&secureSettingsState{Version: "2", ObservedAt: metav1.NewTime(now.Add(-time.Hour))}),
+			keystoreResources: &keystore.Resources{Version: "2"},
+			esReachable:       true,
+			wantState:         &secureSettingsState{Version: "2", ObservedAt: metav1.NewTime(now.Add(-time.Hour))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := tt.es
+			c := k8s.NewFakeClient(&es)
+			esClient := &reloadSecureSettingsESClient{err: tt.reloadErr}
+			requeue, err := reloadSecureSettings(context.Background(), c, &es, esClient, tt.esReachable, tt.keystoreResources, now)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantRequeue, requeue)
+			require.Equal(t, tt.wantReloads, esClient.reloadCount)
+
+			var updated esv1.Elasticsearch
+			require.NoError(t, c.Get(context.Background(), k8s.ExtractNamespacedName(&es), &updated))
+			// other annotations are left untouched
+			require.Equal(t, tt.es.Annotations[esv1.ReloadSecureSettingsAnnotation], updated.Annotations[esv1.ReloadSecureSettingsAnnotation])
+			state, err := observedSecureSettings(updated)
+			require.NoError(t, err)
+			if tt.wantState == nil {
+				require.Nil(t, state)
+				return
+			}
+			require.NotNil(t, state)
+			require.Equal(t, tt.wantState.Version, state.Version)
+			require.True(t, tt.wantState.ObservedAt.Equal(&state.ObservedAt))
+		})
+	}
+}
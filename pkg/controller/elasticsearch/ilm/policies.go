@@ -0,0 +1,166 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package ilm
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/managed"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/reconcile"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+const (
+	// ManagedIndexLifecyclePoliciesAnnotationName holds the list of the index lifecycle policies created by the operator.
+	ManagedIndexLifecyclePoliciesAnnotationName = "elasticsearch.k8s.elastic.co/managed-index-lifecycle-policies"
+
+	// defaultMinAge is the minimum age Elasticsearch sets on the phases which do not specify one.
+	defaultMinAge = "0ms"
+)
+
+// ReconcilePolicies creates in Elasticsearch the index lifecycle management policies declared in the Elasticsearch
+// specification, updates the ones which differ from their declaration, including because of changes made through the
+// Elasticsearch API, and deletes the ones previously created by the operator which are not declared anymore.
+// Policies created by the user directly in Elasticsearch are left untouched, even if a policy with the same name is
+// declared in the specification. The outcome is reported in the IndexLifecyclePoliciesApplied condition.
+func ReconcilePolicies(
+	ctx context.Context,
+	c k8s.Client,
+	es *esv1.Elasticsearch,
+	esClient esclient.Client,
+	state *reconcile.State,
+) error {
+	outcome, err := managed.Reconcile(ctx, c, es, policies(*es, esClient), state)
+	if err != nil {
+		return err
+	}
+	return outcome.Report(state)
+}
+
+// policies gives access to the index lifecycle policies declared in the given Elasticsearch specification.
+func policies(es esv1.Elasticsearch, esClient esclient.Client) managed.Resources {
+	declared := make(map[string]esclient.IndexLifecyclePolicy, len(es.Spec.IndexLifecyclePolicies))
+	names := make([]string, 0, len(es.Spec.IndexLifecyclePolicies))
+	for _, policy := range es.Spec.IndexLifecyclePolicies {
+		declared[policy.Name] = toPolicy(policy)
+		names = append(names, policy.Name)
+	}
+	return managed.Resources{
+		AnnotationName: ManagedIndexLifecyclePoliciesAnnotationName,
+		Kind:           "index lifecycle policy",
+		Description:    "index lifecycle policies",
+		ConditionType:  esv1.IndexLifecyclePoliciesApplied,
+		SuccessMsg:     "%d index lifecycle policies applied",
+		Expected:       names,
+		Get: func(ctx context.Context) (map[string]interface{}, error) {
+			inEs, err := esClient.GetIndexLifecyclePolicies(ctx)
+			if err != nil {
+				return nil, err
+			}
+			result := make(map[string]interface{}, len(inEs))
+			for name, policy := range inEs {
+				result[name] = policy.Policy
+			}
+			return result, nil
+		},
+		Equal: func(name string, actual interface{}) (bool, error) {
+			return phasesEqual(declared[name].Phases, actual.(esclient.IndexLifecyclePolicy).Phases)
+		},
+		Upsert: func(ctx context.Context, name string, _ interface{}) error {
+			return esClient.UpsertIndexLifecyclePolicy(ctx, name, declared[name])
+		},
+		Delete: esClient.DeleteIndexLifecyclePolicy,
+	}
+}
+
+// toPolicy returns the definition of the given policy expected by Elasticsearch.
+func toPolicy(policy esv1.IndexLifecyclePolicy) esclient.IndexLifecyclePolicy {
+	expected := esclient.IndexLifecyclePolicy{Phases: map[string]interface{}{}}
+	if policy.Phases != nil {
+		expected.Phases = policy.Phases.Data
+	}
+	return expected
+}
+
+// phasesEqual compares the phases of a policy declared in the specification with the ones returned by Elasticsearch.
+// Elasticsearch completes the policies with default values, such as the minimum age of the phases or some settings
+// of the actions: phases and actions must match, while the settings of each action returned by Elasticsearch only
+// have to include the declared ones.
+func phasesEqual(expected, actual map[string]interface{}) (bool, error) {
+	// compare through the JSON representation, regardless of the Go types of the declared values
+	bytes, err := json.Marshal(expected)
+	if err != nil {
+		return false, err
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(bytes, &normalized); err != nil {
+		return false, err
+	}
+
+	if !sameKeys(normalized, actual) {
+		return false, nil
+	}
+	for name, phase := range normalized {
+		expectedPhase := asMap(phase)
+		actualPhase := asMap(actual[name])
+		minAge, hasMinAge := expectedPhase["min_age"]
+		if !hasMinAge {
+			minAge = defaultMinAge
+		}
+		if !reflect.DeepEqual(minAge, actualPhase["min_age"]) {
+			return false, nil
+		}
+		expectedActions := asMap(expectedPhase["actions"])
+		actualActions := asMap(actualPhase["actions"])
+		if !sameKeys(expectedActions, actualActions) {
+			return false, nil
+		}
+		for action, settings := range expectedActions {
+			if !includes(actualActions[action], settings) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// includes returns true if actual holds all the values of expected, ignoring the additional keys of objects.
+func includes(actual, expected interface{}) bool {
+	expectedMap, isMap := expected.(map[string]interface{})
+	if !isMap {
+		return reflect.DeepEqual(actual, expected)
+	}
+	actualMap, isMap := actual.(map[string]interface{})
+	if !isMap {
+		return false
+	}
+	for key, value := range expectedMap {
+		if !includes(actualMap[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameKeys(a, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key := range a {
+		if _, exists := b[key]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+func asMap(value interface{}) map[string]interface{} {
+	m, _ := value.(map[string]interface{})
+	return m
+}
@@ -0,0 +1,157 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package ilm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/reconcile"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+type fakePoliciesESClient struct {
+	esclient.Client
+	policies esclient.IndexLifecyclePolicies
+	upserted []string
+	deleted  []string
+}
+
+func (f *fakePoliciesESClient) GetIndexLifecyclePolicies(_ context.Context) (esclient.IndexLifecyclePolicies, error) {
+	return f.policies, nil
+}
+
+func (f *fakePoliciesESClient) UpsertIndexLifecyclePolicy(_ context.Context, name string, policy esclient.IndexLifecyclePolicy) error {
+	f.upserted = append(f.upserted, name)
+	f.policies[name] = esclient.IndexLifecyclePolicyDefinition{Policy: policy}
+	return nil
+}
+
+func (f *fakePoliciesESClient) DeleteIndexLifecyclePolicy(_ context.Context, name string) error {
+	f.deleted = append(f.deleted, name)
+	delete(f.policies, name)
+	return nil
+}
+
+func TestReconcilePolicies(t *testing.T) {
+	logs := esv1.IndexLifecyclePolicy{
+		Name: "logs-retention",
+		Phases: &commonv1.Config{Data: map[string]interface{}{
+			"hot":    map[string]interface{}{"actions": map[string]interface{}{"rollover": map[string]interface{}{"max_age": "1d", "max_docs": 1000000}}},
+			"delete": map[string]interface{}{"min_age": "30d", "actions": map[string]interface{}{"delete": map[string]interface{}{}}},
+		}},
+	}
+	// as returned by Elasticsearch, with default values
+	logsInEs := esclient.IndexLifecyclePolicyDefinition{
+		Version: 1,
+		Policy: esclient.IndexLifecyclePolicy{Phases: map[string]interface{}{
+			"hot":    map[string]interface{}{"min_age": "0ms", "actions": map[string]interface{}{"rollover": map[string]interface{}{"max_age": "1d", "max_docs": float64(1000000)}}},
+			"delete": map[string]interface{}{"min_age": "30d", "actions": map[string]interface{}{"delete": map[string]interface{}{"delete_searchable_snapshot": true}}},
+		}},
+	}
+	manuallyChanged := esclient.IndexLifecyclePolicyDefinition{
+		Version: 2,
+		Policy: esclient.IndexLifecyclePolicy{Phases: map[string]interface{}{
+			"hot":    map[string]interface{}{"min_age": "0ms", "actions": map[string]interface{}{"rollover": map[string]interface{}{"max_age": "7d", "max_docs": float64(1000000)}}},
+			"delete": map[string]interface{}{"min_age": "30d", "actions": map[string]interface{}{"delete": map[string]interface{}{"delete_searchable_snapshot": true}}},
+		}},
+	}
+
+	tests := []struct {
+		name           string
+		policies       []esv1.IndexLifecyclePolicy
+		annotation     string
+		inEs           esclient.IndexLifecyclePolicies
+		wantUpserted   []string
+		wantDeleted    []string
+		wantAnnotation string
+		wantCondition  corev1.ConditionStatus
+	}{
+		{
+			name: "no policies",
+			inEs: esclient.IndexLifecyclePolicies{},
+		},
+		{
+			name:           "create a new policy",
+			policies:       []esv1.IndexLifecyclePolicy{logs},
+			inEs:           esclient.IndexLifecyclePolicies{},
+			wantUpserted:   []string{"logs-retention"},
+			wantAnnotation: "logs-retention",
+			wantCondition:  corev1.ConditionTrue,
+		},
+		{
+			name:           "policy already up to date, ignoring the default values set by Elasticsearch",
+			policies:       []esv1.IndexLifecyclePolicy{logs},
+			annotation:     "logs-retention",
+			inEs:           esclient.IndexLifecyclePolicies{"logs-retention": logsInEs},
+			wantAnnotation: "logs-retention",
+			wantCondition:  corev1.ConditionTrue,
+		},
+		{
+			name:           "revert a manual change",
+			policies:       []esv1.IndexLifecyclePolicy{logs},
+			annotation:     "logs-retention",
+			inEs:           esclient.IndexLifecyclePolicies{"logs-retention": manuallyChanged},
+			wantUpserted:   []string{"logs-retention"},
+			wantAnnotation: "logs-retention",
+			wantCondition:  corev1.ConditionTrue,
+		},
+		{
+			name:          "do not overwrite a policy created by the user",
+			policies:      []esv1.IndexLifecyclePolicy{logs},
+			inEs:          esclient.IndexLifecyclePolicies{"logs-retention": manuallyChanged},
+			wantCondition: corev1.ConditionFalse,
+		},
+		{
+			name:       "delete a policy removed from the spec but not the ones created by the user",
+			annotation: "logs-retention",
+			inEs: esclient.IndexLifecyclePolicies{
+				"logs-retention": logsInEs,
+				"user":           manuallyChanged,
+			},
+			wantDeleted:   []string{"logs-retention"},
+			wantCondition: corev1.ConditionTrue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := esv1.Elasticsearch{
+				ObjectMeta: metav1.ObjectMeta{Name: "es", Namespace: "ns"},
+				Spec:       esv1.ElasticsearchSpec{IndexLifecyclePolicies: tt.policies},
+			}
+			if tt.annotation != "" {
+				es.Annotations = map[string]string{ManagedIndexLifecyclePoliciesAnnotationName: tt.annotation}
+			}
+			c := k8s.NewFakeClient(&es)
+			esClient := &fakePoliciesESClient{policies: tt.inEs}
+			state := reconcile.MustNewState(es)
+
+			require.NoError(t, ReconcilePolicies(context.Background(), c, &es, esClient, state))
+			require.Equal(t, tt.wantUpserted, esClient.upserted)
+			require.Equal(t, tt.wantDeleted, esClient.deleted)
+
+			var updated esv1.Elasticsearch
+			require.NoError(t, c.Get(context.Background(), k8s.ExtractNamespacedName(&es), &updated))
+			require.Equal(t, tt.wantAnnotation, updated.Annotations[ManagedIndexLifecyclePoliciesAnnotationName])
+
+			_, withStatus := state.Apply()
+			require.NotNil(t, withStatus)
+			index := withStatus.Status.Conditions.Index(esv1.IndexLifecyclePoliciesApplied)
+			if tt.wantCondition == "" {
+				require.Equal(t, -1, index)
+				return
+			}
+			require.NotEqual(t, -1, index)
+			require.Equal(t, tt.wantCondition, withStatus.Status.Conditions[index].Status)
+		})
+	}
+}
@@ -7,6 +7,7 @@ package initcontainer
 import (
 	corev1 "k8s.io/api/core/v1"
 
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/common/keystore"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/common/volume"
 )
@@ -16,6 +17,8 @@ const (
 	PrepareFilesystemContainerName = "elastic-internal-init-filesystem"
 	// SuspendContainerName is the name of the container that is used to suspend Elasticsearch if requested by the user.
 	SuspendContainerName = "elastic-internal-suspend"
+	// InstallPluginsContainerName is the name of the container installing the plugins specified by the user.
+	InstallPluginsContainerName = "elastic-internal-install-plugins"
 )
 
 // NewInitContainers creates init containers according to the given parameters
@@ -23,6 +26,7 @@ func NewInitContainers(
 	transportCertificatesVolume volume.SecretVolume,
 	keystoreResources *keystore.Resources,
 	nodeLabelsAsAnnotations []string,
+	plugins []esv1.Plugin,
 ) ([]corev1.Container, error) {
 	var containers []corev1.Container
 	prepareFsContainer, err := NewPrepareFSInitContainer(transportCertificatesVolume, nodeLabelsAsAnnotations)
@@ -31,6 +35,14 @@ func NewInitContainers(
 	}
 	containers = append(containers, prepareFsContainer)
 
+	if len(plugins) > 0 {
+		installPluginsContainer, err := NewInstallPluginsInitContainer(plugins)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, installPluginsContainer)
+	}
+
 	if keystoreResources != nil {
 		containers = append(containers, keystoreResources.InitContainer)
 	}
@@ -0,0 +1,121 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package initcontainer
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/volume"
+)
+
+const (
+	PluginBinPath = "/usr/share/elasticsearch/bin/elasticsearch-plugin"
+
+	pluginBundlesVolumeNamePrefix = "elastic-internal-plugin-bundle-"
+	pluginBundlesMountPath        = "/mnt/elastic-internal/plugin-bundles"
+)
+
+// installPluginsScript installs the plugins that are not installed yet, the init container may be restarted.
+// Plugins are installed in the plugins/ directory persisted by the prepare-fs init container for the ES container.
+const installPluginsScript = `#!/usr/bin/env bash
+
+set -eu
+
+installed=$({{ .PluginBinPath }} list)
+{{ range .Plugins }}
+if grep -qx {{ quote .Name }} <<< "${installed}"; then
+	echo "Plugin" {{ quote .Name }} "already installed."
+else
+	echo "Installing plugin" {{ quote .Name }}
+	{{ $.PluginBinPath }} install --batch {{ quote .Source }}
+fi
+{{ end }}
+echo "Plugins installation successful."
+`
+
+var installPluginsScriptTemplate = template.Must(template.New("").Funcs(template.FuncMap{"quote": shellQuote}).Parse(installPluginsScript))
+
+// shellQuote single-quotes the given string for it to be used as a single bash word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+type pluginInstall struct {
+	Name   string
+	Source string
+}
+
+// PluginBundleVolumes returns the volumes holding the archives of the plugins installed from a bundle.
+func PluginBundleVolumes(plugins []esv1.Plugin) []volume.ConfigMapVolume {
+	var volumes []volume.ConfigMapVolume
+	for i, plugin := range plugins {
+		if plugin.Bundle != nil {
+			volumes = append(volumes, pluginBundleVolume(i, *plugin.Bundle))
+		}
+	}
+	return volumes
+}
+
+func pluginBundleVolume(index int, bundle esv1.PluginBundle) volume.ConfigMapVolume {
+	return volume.NewConfigMapVolume(
+		bundle.ConfigMapName,
+		pluginBundlesVolumeNamePrefix+strconv.Itoa(index),
+		path.Join(pluginBundlesMountPath, strconv.Itoa(index)),
+	)
+}
+
+// pluginSource returns what to pass to the plugin installation tool to install the given plugin.
+func pluginSource(index int, plugin esv1.Plugin) string {
+	switch {
+	case plugin.Bundle != nil:
+		return fmt.Sprintf("file://%s", path.Join(pluginBundleVolume(index, *plugin.Bundle).VolumeMount().MountPath, plugin.Bundle.Key))
+	case plugin.URL != "":
+		return plugin.URL
+	default:
+		return plugin.Name
+	}
+}
+
+// NewInstallPluginsInitContainer creates an init container to install the given plugins.
+// This container does not need to be privileged.
+func NewInstallPluginsInitContainer(plugins []esv1.Plugin) (corev1.Container, error) {
+	installs := make([]pluginInstall, len(plugins))
+	for i, plugin := range plugins {
+		installs[i] = pluginInstall{Name: plugin.Name, Source: pluginSource(i, plugin)}
+	}
+	var script bytes.Buffer
+	if err := installPluginsScriptTemplate.Execute(&script, map[string]interface{}{
+		"PluginBinPath": PluginBinPath,
+		"Plugins":       installs,
+	}); err != nil {
+		return corev1.Container{}, err
+	}
+
+	// we will also inherit all volume mounts and the resources from the main container in the pod template builder
+	bundleVolumes := PluginBundleVolumes(plugins)
+	volumeMounts := make([]corev1.VolumeMount, 0, len(bundleVolumes))
+	for _, v := range bundleVolumes {
+		volumeMounts = append(volumeMounts, v.VolumeMount())
+	}
+
+	privileged := false
+	return corev1.Container{
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Name:            InstallPluginsContainerName,
+		SecurityContext: &corev1.SecurityContext{
+			Privileged: &privileged,
+		},
+		Command:      []string{"/usr/bin/env", "bash", "-c", script.String()},
+		VolumeMounts: volumeMounts,
+	}, nil
+}
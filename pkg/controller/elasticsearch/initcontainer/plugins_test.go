@@ -0,0 +1,50 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package initcontainer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+)
+
+func TestNewInstallPluginsInitContainer(t *testing.T) {
+	plugins := []esv1.Plugin{
+		{Name: "analysis-icu"},
+		{Name: "custom", URL: "https://example.com/custom-1.0.0.zip"},
+		{Name: "offline", Bundle: &esv1.PluginBundle{ConfigMapName: "plugins", Key: "offline.zip"}},
+		{Name: "it's", URL: "https://example.com/it's.zip"},
+	}
+	container, err := NewInstallPluginsInitContainer(plugins)
+	require.NoError(t, err)
+	assert.Equal(t, InstallPluginsContainerName, container.Name)
+	require.Len(t, container.Command, 4)
+	script := container.Command[3]
+	assert.Contains(t, script, "install --batch 'analysis-icu'")
+	assert.Contains(t, script, "install --batch 'https://example.com/custom-1.0.0.zip'")
+	assert.Contains(t, script, "install --batch 'file:///mnt/elastic-internal/plugin-bundles/2/offline.zip'")
+	assert.Contains(t, script, `install --batch 'https://example.com/it'"'"'s.zip'`)
+	assert.Equal(t, []corev1.VolumeMount{{
+		Name:      "elastic-internal-plugin-bundle-2",
+		MountPath: "/mnt/elastic-internal/plugin-bundles/2",
+		ReadOnly:  true,
+	}}, container.VolumeMounts)
+}
+
+func TestPluginBundleVolumes(t *testing.T) {
+	assert.Empty(t, PluginBundleVolumes(nil))
+	assert.Empty(t, PluginBundleVolumes([]esv1.Plugin{{Name: "analysis-icu"}}))
+	volumes := PluginBundleVolumes([]esv1.Plugin{
+		{Name: "analysis-icu"},
+		{Name: "offline", Bundle: &esv1.PluginBundle{ConfigMapName: "plugins", Key: "offline.zip"}},
+	})
+	require.Len(t, volumes, 1)
+	assert.Equal(t, "elastic-internal-plugin-bundle-1", volumes[0].Volume().Name)
+	assert.Equal(t, "plugins", volumes[0].Volume().ConfigMap.Name)
+}
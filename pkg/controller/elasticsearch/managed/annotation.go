@@ -0,0 +1,56 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package managed reconciles the resources created by the operator through the Elasticsearch API, such as snapshot
+// repositories or lifecycle policies, and keeps track of them so that they can be deleted once removed from the
+// specification without touching the ones created directly by users.
+package managed
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+// FromAnnotation returns the set of the resources managed by the operator listed in the given annotation.
+func FromAnnotation(es esv1.Elasticsearch, annotationName string) map[string]struct{} {
+	managed := make(map[string]struct{})
+	serialized, ok := es.Annotations[annotationName]
+	if !ok || strings.TrimSpace(serialized) == "" {
+		return managed
+	}
+	for _, name := range strings.Split(serialized, ",") {
+		managed[name] = struct{}{}
+	}
+	return managed
+}
+
+// Annotate updates the given annotation listing the resources managed by the operator, if it changed.
+func Annotate(ctx context.Context, c k8s.Client, es *esv1.Elasticsearch, annotationName string, managed map[string]struct{}) error {
+	names := make([]string, 0, len(managed))
+	for name := range managed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	serialized := strings.Join(names, ",")
+
+	current, exists := es.Annotations[annotationName]
+	switch {
+	case len(names) == 0 && !exists:
+		return nil
+	case len(names) == 0:
+		delete(es.Annotations, annotationName)
+	case exists && current == serialized:
+		return nil
+	default:
+		if es.Annotations == nil {
+			es.Annotations = make(map[string]string)
+		}
+		es.Annotations[annotationName] = serialized
+	}
+	return c.Update(ctx, es)
+}
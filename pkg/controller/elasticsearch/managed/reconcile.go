@@ -0,0 +1,181 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package managed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.elastic.co/apm"
+	corev1 "k8s.io/api/core/v1"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/tracing"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/reconcile"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+	ulog "github.com/elastic/cloud-on-k8s/pkg/utils/log"
+)
+
+var log = ulog.Log.WithName("managed")
+
+// Resources gives access to a kind of resources declared in the Elasticsearch specification, and to the matching API of
+// Elasticsearch.
+type Resources struct {
+	// AnnotationName is the name of the annotation listing the resources created by the operator.
+	AnnotationName string
+	// Kind is the name of a resource in the logs, such as "snapshot repository".
+	Kind string
+	// Description is the name of the resources in the condition messages, such as "snapshot repositories".
+	Description string
+	// ConditionType is the type of the condition reporting the outcome of the reconciliation.
+	ConditionType esv1.ConditionType
+	// SuccessMsg is the format of the condition message reporting a successful reconciliation, given the number of
+	// declared resources.
+	SuccessMsg string
+	// TakeOver is true if the resources created by the user with the name of a declared resource are taken over by the
+	// operator. They are otherwise left untouched and reported as conflicts.
+	TakeOver bool
+	// Expected are the names of the declared resources, in the order they are created or updated.
+	Expected []string
+
+	// Get returns the resources existing in Elasticsearch, indexed by name.
+	Get func(ctx context.Context) (map[string]interface{}, error)
+	// Equal returns true if the given resource as returned by Elasticsearch matches its declaration.
+	Equal func(name string, actual interface{}) (bool, error)
+	// Upsert creates or updates the declared resource with the given name. Actual is the resource as returned by
+	// Elasticsearch, or nil if it does not exist yet.
+	Upsert func(ctx context.Context, name string, actual interface{}) error
+	// Delete deletes the resource with the given name from Elasticsearch.
+	Delete func(ctx context.Context, name string) error
+}
+
+// IsEmpty returns true if no resources are declared nor were created by the operator, in which case there is nothing
+// to reconcile.
+func (r Resources) IsEmpty(es esv1.Elasticsearch) bool {
+	return len(r.Expected) == 0 && len(FromAnnotation(es, r.AnnotationName)) == 0
+}
+
+// Outcome is the outcome of the reconciliation of a kind of resources, to report in the status.
+type Outcome struct {
+	resources *Resources
+	failures  []string
+	conflicts []string
+}
+
+// Reconcile creates in Elasticsearch the given declared resources, updates the ones which differ from their
+// declaration, and deletes the ones previously created by the operator which are not declared anymore. Resources
+// created by the user directly in Elasticsearch are left untouched. The resources created by the operator are tracked
+// in an annotation of the Elasticsearch resource, updated before creating them to not lose track of them if the
+// annotation update fails.
+// Errors of Elasticsearch on individual resources do not interrupt the reconciliation and are part of the outcome.
+func Reconcile(
+	ctx context.Context,
+	c k8s.Client,
+	es *esv1.Elasticsearch,
+	resources Resources,
+	state *reconcile.State,
+) (Outcome, error) {
+	if resources.IsEmpty(*es) {
+		// nothing to do, skip
+		return Outcome{}, nil
+	}
+
+	spanName := "reconcile_" + strings.NewReplacer(" ", "_", "-", "_").Replace(resources.Description)
+	span, ctx := apm.StartSpan(ctx, spanName, tracing.SpanTypeApp)
+	defer span.End()
+
+	inEs, err := resources.Get(ctx)
+	if err != nil {
+		state.ReportCondition(resources.ConditionType, corev1.ConditionUnknown, fmt.Sprintf("Cannot retrieve the %s: %s", resources.Description, err.Error()))
+		return Outcome{}, err
+	}
+
+	outcome := Outcome{resources: &resources}
+	inAnnotation := FromAnnotation(*es, resources.AnnotationName)
+	expected := make(map[string]struct{}, len(resources.Expected))
+	for _, name := range resources.Expected {
+		expected[name] = struct{}{}
+		_, exists := inEs[name]
+		if _, isManaged := inAnnotation[name]; exists && !isManaged && !resources.TakeOver {
+			// created by the user, do not take it over
+			outcome.conflicts = append(outcome.conflicts, name)
+			continue
+		}
+		inAnnotation[name] = struct{}{}
+	}
+	if err := Annotate(ctx, c, es, resources.AnnotationName, inAnnotation); err != nil {
+		return Outcome{}, err
+	}
+
+	for _, name := range resources.Expected {
+		if _, isManaged := inAnnotation[name]; !isManaged {
+			continue
+		}
+		actual, exists := inEs[name]
+		if exists {
+			equal, err := resources.Equal(name, actual)
+			if err != nil {
+				return Outcome{}, err
+			}
+			if equal {
+				continue
+			}
+			log.Info("Updating "+resources.Kind, "namespace", es.Namespace, "es_name", es.Name, "name", name)
+		} else {
+			log.Info("Creating "+resources.Kind, "namespace", es.Namespace, "es_name", es.Name, "name", name)
+		}
+		if err := resources.Upsert(ctx, name, actual); err != nil {
+			outcome.failures = append(outcome.failures, fmt.Sprintf("%s: %s", name, err.Error()))
+		}
+	}
+
+	for name := range inAnnotation {
+		if _, isExpected := expected[name]; isExpected {
+			continue
+		}
+		if _, exists := inEs[name]; exists {
+			log.Info("Deleting "+resources.Kind, "namespace", es.Namespace, "es_name", es.Name, "name", name)
+			if err := resources.Delete(ctx, name); err != nil {
+				outcome.failures = append(outcome.failures, fmt.Sprintf("%s: %s", name, err.Error()))
+				continue
+			}
+		}
+		delete(inAnnotation, name)
+	}
+	if err := Annotate(ctx, c, es, resources.AnnotationName, inAnnotation); err != nil {
+		return Outcome{}, err
+	}
+	return outcome, nil
+}
+
+// Report reports the outcome in the condition of the reconciled resources. It returns an error if some resources could
+// not be reconciled, to retry later. Conflicts are not worth retrying, since the user has to rename or delete the
+// conflicting resources.
+func (o Outcome) Report(state *reconcile.State) error {
+	if o.resources == nil {
+		// nothing was reconciled
+		return nil
+	}
+	r := o.resources
+	if len(o.failures) > 0 {
+		sort.Strings(o.failures)
+		msg := fmt.Sprintf("Failed to reconcile %s: %s", r.Description, strings.Join(o.failures, ", "))
+		state.ReportCondition(r.ConditionType, corev1.ConditionFalse, msg)
+		return errors.New(msg)
+	}
+	if len(o.conflicts) > 0 {
+		sort.Strings(o.conflicts)
+		state.ReportCondition(r.ConditionType, corev1.ConditionFalse, fmt.Sprintf(
+			"%s not created by the operator already exist in Elasticsearch: %s",
+			strings.ToUpper(r.Description[:1])+r.Description[1:], strings.Join(o.conflicts, ", "),
+		))
+		return nil
+	}
+	state.ReportCondition(r.ConditionType, corev1.ConditionTrue, fmt.Sprintf(r.SuccessMsg, len(r.Expected)))
+	return nil
+}
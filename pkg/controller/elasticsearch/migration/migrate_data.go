@@ -6,6 +6,7 @@ package migration
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
@@ -41,41 +42,51 @@ func (sm *ShardMigration) ReconcileShutdowns(ctx context.Context, leavingNodes [
 }
 
 // ShutdownStatus returns the current shutdown status for a given Pod mimicking the node shutdown API to create a common
-// interface. "Complete" is returned if shard migration for the given Pod is finished.
+// interface. "Complete" is returned if shard migration for the given Pod is finished, otherwise the explanation reports
+// the progress of the migration.
 func (sm *ShardMigration) ShutdownStatus(ctx context.Context, podName string) (shutdown.NodeShutdownStatus, error) {
-	migrating, err := nodeMayHaveShard(ctx, sm.es, sm.s, podName)
+	migrating, explanation, err := nodeMayHaveShard(ctx, sm.es, sm.s, podName)
 	if err != nil {
 		return shutdown.NodeShutdownStatus{}, err
 	}
 	if migrating {
-		return shutdown.NodeShutdownStatus{Status: esclient.ShutdownInProgress}, nil
+		return shutdown.NodeShutdownStatus{Status: esclient.ShutdownInProgress, Explanation: explanation}, nil
 	}
 	return shutdown.NodeShutdownStatus{Status: esclient.ShutdownComplete}, nil
 }
 
-// nodeMayHaveShard returns true if one of those conditions is met:
+// nodeMayHaveShard returns true, along with an explanation to surface in the status, if one of those conditions is met:
 // - the given ES Pod is holding at least one shard (primary or replica)
 // - some shards in the cluster don't have a node assigned, in which case we can't be sure about the 1st condition
 //   this may happen if the node was just restarted: the shards it is holding appear unassigned
-func nodeMayHaveShard(ctx context.Context, es esv1.Elasticsearch, shardLister esclient.ShardLister, podName string) (bool, error) {
+func nodeMayHaveShard(
+	ctx context.Context,
+	es esv1.Elasticsearch,
+	shardLister esclient.ShardLister,
+	podName string,
+) (bool, string, error) {
 	shards, err := shardLister.GetShards(ctx)
 	if err != nil {
-		return false, err
+		return false, "", err
 	}
+	remaining := 0
 	for _, shard := range shards {
-		// shard still on the node
-		if shard.NodeName == podName {
-			return true, nil
-		}
 		// shard node undefined (likely unassigned)
 		if shard.NodeName == "" {
 			log.Info("Found orphan shard, preventing data migration",
 				"namespace", es.Namespace, "es_name", es.Name,
 				"index", shard.Index, "shard", shard.Shard, "shard_state", shard.State)
-			return true, nil
+			return true, fmt.Sprintf("shard %s of index %s is not assigned to any node", shard.Shard, shard.Index), nil
 		}
+		// shard still on the node
+		if shard.NodeName == podName {
+			remaining++
+		}
+	}
+	if remaining > 0 {
+		return true, fmt.Sprintf("%d shard(s) remaining on the node", remaining), nil
 	}
-	return false, nil
+	return false, "", nil
 }
 
 // migrateData sets allocation filters for the given nodes.
@@ -22,10 +22,11 @@ func TestNodeMayHaveShard(t *testing.T) {
 		podName     string
 	}
 	tests := []struct {
-		name    string
-		args    args
-		want    bool
-		wantErr bool
+		name            string
+		args            args
+		want            bool
+		wantExplanation string
+		wantErr         bool
 	}{
 		{
 			name: "Error while getting shards",
@@ -48,7 +49,21 @@ func TestNodeMayHaveShard(t *testing.T) {
 					{Index: "index-1", Shard: "0", NodeName: "C"},
 				}),
 			},
-			want: true,
+			want:            true,
+			wantExplanation: "1 shard(s) remaining on the node",
+		},
+		{
+			name: "Node has several shards",
+			args: args{
+				podName: "A",
+				shardLister: NewFakeShardLister([]client.Shard{
+					{Index: "index-1", Shard: "0", NodeName: "A"},
+					{Index: "index-1", Shard: "1", NodeName: "A"},
+					{Index: "index-1", Shard: "0", NodeName: "B"},
+				}),
+			},
+			want:            true,
+			wantExplanation: "2 shard(s) remaining on the node",
 		},
 		{
 			name: "No shard on the node",
@@ -70,12 +85,13 @@ func TestNodeMayHaveShard(t *testing.T) {
 					{Index: "index-1", Shard: "0", NodeName: "C"},
 				}),
 			},
-			want: true,
+			want:            true,
+			wantExplanation: "shard 0 of index index-1 is not assigned to any node",
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := nodeMayHaveShard(context.Background(), esv1.Elasticsearch{}, tt.args.shardLister, tt.args.podName)
+			got, explanation, err := nodeMayHaveShard(context.Background(), esv1.Elasticsearch{}, tt.args.shardLister, tt.args.podName)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("nodeMayHaveShard() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -83,6 +99,7 @@ func TestNodeMayHaveShard(t *testing.T) {
 			if got != tt.want {
 				t.Errorf("nodeMayHaveShard() = %v, want %v", got, tt.want)
 			}
+			assert.Equal(t, tt.wantExplanation, explanation)
 		})
 	}
 }
@@ -5,6 +5,7 @@
 package nodespec
 
 import (
+	"fmt"
 	"path"
 
 	corev1 "k8s.io/api/core/v1"
@@ -12,6 +13,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/common/annotation"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/common/defaults"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/label"
@@ -72,6 +74,24 @@ func DefaultEnvVars(httpCfg commonv1.HTTPConfig, headlessServiceName string) []c
 	)
 }
 
+// zoneAwarenessEnvVars returns the environment variable holding the zone of the k8s node the Pod is scheduled on,
+// if zone awareness is enabled. Its value is read from the Pod annotation copied from the corresponding node label.
+func zoneAwarenessEnvVars(es esv1.Elasticsearch) []corev1.EnvVar {
+	if es.Spec.ZoneAwareness == nil {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{
+			Name: settings.EnvZone,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: fmt.Sprintf("metadata.annotations['%s']", es.Spec.ZoneAwareness.TopologyKeyOrDefault()),
+				},
+			},
+		},
+	}
+}
+
 // DefaultAffinity returns the default affinity for pods in a cluster.
 func DefaultAffinity(esName string) *corev1.Affinity {
 	return &corev1.Affinity{
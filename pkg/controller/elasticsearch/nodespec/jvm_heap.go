@@ -0,0 +1,74 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package nodespec
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/defaults"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/settings"
+)
+
+const (
+	// maxHeapSizeMi keeps the heap below the threshold above which the JVM stops using compressed ordinary object
+	// pointers, which is close to 32GiB depending on the JVM.
+	maxHeapSizeMi = 31 * 1024
+	// heapSizeMemoryLimitRatio is the ratio of the memory limit dedicated to the heap, the rest of the memory is left
+	// to the off-heap memory of the JVM and to the file system cache.
+	heapSizeMemoryLimitRatio = 2
+)
+
+// withJVMHeapFromMemoryLimit sets the minimum and maximum JVM heap size in the environment variable `ES_JAVA_OPTS` of
+// the Elasticsearch container from its memory limit, if requested in the Elasticsearch specification. Heap sizes
+// already set by the user are left untouched.
+func withJVMHeapFromMemoryLimit(builder *defaults.PodTemplateBuilder, es esv1.Elasticsearch) {
+	if es.Spec.JVMHeap == nil || !es.Spec.JVMHeap.FromMemoryLimit {
+		return
+	}
+	for c, esContainer := range builder.PodTemplate.Spec.Containers {
+		if esContainer.Name != esv1.ElasticsearchContainerName {
+			continue
+		}
+		heapSizeParams := heapSizeJavaOpts(esContainer.Resources)
+		if heapSizeParams == "" {
+			// no memory limit
+			return
+		}
+		for e, envVar := range esContainer.Env {
+			if envVar.Name != settings.EnvEsJavaOpts {
+				continue
+			}
+			if !strings.Contains(envVar.Value, "-Xms") && !strings.Contains(envVar.Value, "-Xmx") {
+				builder.PodTemplate.Spec.Containers[c].Env[e].Value = strings.TrimSpace(heapSizeParams + " " + envVar.Value)
+			}
+			return
+		}
+		builder.PodTemplate.Spec.Containers[c].Env = append(
+			builder.PodTemplate.Spec.Containers[c].Env,
+			corev1.EnvVar{Name: settings.EnvEsJavaOpts, Value: heapSizeParams},
+		)
+	}
+}
+
+// heapSizeJavaOpts returns the JVM parameters setting the heap size from the memory limit of the given resources, or
+// an empty string if there is no memory limit.
+func heapSizeJavaOpts(resources corev1.ResourceRequirements) string {
+	memoryLimit, exists := resources.Limits[corev1.ResourceMemory]
+	if !exists || memoryLimit.IsZero() {
+		return ""
+	}
+	heapSizeMi := memoryLimit.Value() / heapSizeMemoryLimitRatio / (1024 * 1024)
+	if heapSizeMi > maxHeapSizeMi {
+		heapSizeMi = maxHeapSizeMi
+	}
+	if heapSizeMi <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("-Xms%dm -Xmx%dm", heapSizeMi, heapSizeMi)
+}
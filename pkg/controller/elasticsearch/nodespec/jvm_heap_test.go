@@ -0,0 +1,106 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package nodespec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/defaults"
+)
+
+func Test_heapSizeJavaOpts(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources corev1.ResourceRequirements
+		want      string
+	}{
+		{
+			name: "no memory limit",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+			},
+			want: "",
+		},
+		{
+			name: "half of the memory limit",
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+			},
+			want: "-Xms2048m -Xmx2048m",
+		},
+		{
+			name: "half of a memory limit in decimal units",
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("3G")},
+			},
+			want: "-Xms1430m -Xmx1430m",
+		},
+		{
+			name: "capped below the compressed oops threshold",
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Gi")},
+			},
+			want: "-Xms31744m -Xmx31744m",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, heapSizeJavaOpts(tt.resources))
+		})
+	}
+}
+
+func Test_withJVMHeapFromMemoryLimit(t *testing.T) {
+	limits := corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+	}
+	tests := []struct {
+		name    string
+		jvmHeap *esv1.JVMHeap
+		userEnv []corev1.EnvVar
+		want    []corev1.EnvVar
+	}{
+		{
+			name:    "not requested",
+			jvmHeap: nil,
+			want:    nil,
+		},
+		{
+			name:    "heap size set from the memory limit",
+			jvmHeap: &esv1.JVMHeap{FromMemoryLimit: true},
+			userEnv: []corev1.EnvVar{{Name: "YO", Value: "LO"}},
+			want:    []corev1.EnvVar{{Name: "YO", Value: "LO"}, {Name: "ES_JAVA_OPTS", Value: "-Xms2048m -Xmx2048m"}},
+		},
+		{
+			name:    "merged with user-provided JVM parameters",
+			jvmHeap: &esv1.JVMHeap{FromMemoryLimit: true},
+			userEnv: []corev1.EnvVar{{Name: "ES_JAVA_OPTS", Value: "-XX:+UseG1GC"}},
+			want:    []corev1.EnvVar{{Name: "ES_JAVA_OPTS", Value: "-Xms2048m -Xmx2048m -XX:+UseG1GC"}},
+		},
+		{
+			name:    "user-provided heap size is not overridden",
+			jvmHeap: &esv1.JVMHeap{FromMemoryLimit: true},
+			userEnv: []corev1.EnvVar{{Name: "ES_JAVA_OPTS", Value: "-Xms1g -Xmx1g"}},
+			want:    []corev1.EnvVar{{Name: "ES_JAVA_OPTS", Value: "-Xms1g -Xmx1g"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := esv1.Elasticsearch{Spec: esv1.ElasticsearchSpec{JVMHeap: tt.jvmHeap}}
+			builder := defaults.NewPodTemplateBuilder(corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: esv1.ElasticsearchContainerName, Env: tt.userEnv, Resources: limits}},
+				},
+			}, esv1.ElasticsearchContainerName)
+			withJVMHeapFromMemoryLimit(builder, es)
+			require.Equal(t, tt.want, builder.PodTemplate.Spec.Containers[0].Env)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package nodespec
+
+import (
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/defaults"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/keystore"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/initcontainer"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/volume"
+)
+
+// KeystoreUpdaterContainerName is the name of the sidecar container updating the keystore on secure settings changes.
+const KeystoreUpdaterContainerName = "elastic-internal-keystore-updater"
+
+const KeystoreUpdaterScriptConfigKey = "keystore-updater-script.sh"
+
+// KeystoreUpdaterScript updates the keystore in place when the content of the secure settings volume changes. The
+// kubelet updates a secret volume atomically by switching its ..data symlink to a new directory, which is used here to
+// detect changes. Elasticsearch is not restarted: the operator then calls the reload secure settings API.
+var KeystoreUpdaterScript = fmt.Sprintf(`#!/usr/bin/env bash
+
+set -uo pipefail
+
+secure_settings=%s
+keystore=%s
+
+function keys {
+	for filename in ${secure_settings}/*; do
+		[[ -e "$filename" ]] || continue # glob does not match
+		basename "$filename"
+	done
+}
+
+function update_keystore {
+	for key in $(keys); do
+		echo "Adding ${key} to the keystore."
+		${keystore} add-file --force "$key" "${secure_settings}/${key}" || return 1
+	done
+	for key in ${previous_keys}; do
+		[[ -e "${secure_settings}/${key}" ]] && continue
+		echo "Removing ${key} from the keystore."
+		${keystore} remove "$key" || return 1
+	done
+}
+
+current=$(readlink "${secure_settings}/..data")
+previous_keys=$(keys)
+
+while true; do
+	sleep 10
+	latest=$(readlink "${secure_settings}/..data")
+	[[ "$latest" == "$current" ]] && continue
+
+	echo "Secure settings changed, updating the keystore."
+	if update_keystore; then
+		current=${latest}
+		previous_keys=$(keys)
+		echo "Keystore update successful."
+	else
+		echo "Keystore update failed, retrying."
+	fi
+done
+`, keystore.SecureSettingsVolumeMountPath, initcontainer.KeystoreBinPath)
+
+// keystoreUpdaterContainer returns a sidecar container running the keystore updater script, with the same image and
+// volumes as the Elasticsearch container so that the keystore it updates is the one loaded by Elasticsearch.
+func keystoreUpdaterContainer(
+	builder *defaults.PodTemplateBuilder,
+	keystoreResources *keystore.Resources,
+	volumeMounts []corev1.VolumeMount,
+) corev1.Container {
+	var image string
+	for _, c := range builder.PodTemplate.Spec.Containers {
+		if c.Name == esv1.ElasticsearchContainerName {
+			image = c.Image
+		}
+	}
+	mounts := make([]corev1.VolumeMount, 0, len(volumeMounts)+len(keystoreResources.InitContainer.VolumeMounts))
+	mounts = append(mounts, volumeMounts...)
+	// access secure settings
+	mounts = append(mounts, keystoreResources.InitContainer.VolumeMounts...)
+
+	privileged := false
+	return corev1.Container{
+		Name:            KeystoreUpdaterContainerName,
+		Image:           image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		SecurityContext: &corev1.SecurityContext{
+			Privileged: &privileged,
+		},
+		Command:      []string{"bash", "-c", path.Join(volume.ScriptsVolumeMountPath, KeystoreUpdaterScriptConfigKey)},
+		VolumeMounts: mounts,
+		Resources:    initcontainer.KeystoreParams.Resources,
+	}
+}
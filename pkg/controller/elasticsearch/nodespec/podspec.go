@@ -5,6 +5,7 @@
 package nodespec
 
 import (
+	"context"
 	"fmt"
 	"hash/fnv"
 	"strings"
@@ -21,6 +22,7 @@ import (
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/initcontainer"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/label"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/network"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/realms"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/settings"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/stackmon"
 	esvolume "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/volume"
@@ -46,6 +48,7 @@ var minDefaultSecurityContextVersion = version.MinFor(8, 0, 0)
 
 // BuildPodTemplateSpec builds a new PodTemplateSpec for an Elasticsearch node.
 func BuildPodTemplateSpec(
+	ctx context.Context,
 	client k8s.Client,
 	es esv1.Elasticsearch,
 	nodeSet esv1.NodeSet,
@@ -55,6 +58,18 @@ func BuildPodTemplateSpec(
 ) (corev1.PodTemplateSpec, error) {
 	downwardAPIVolume := volume.DownwardAPI{}.WithAnnotations(es.HasDownwardNodeLabels())
 	volumes, volumeMounts := buildVolumes(es.Name, nodeSet, keystoreResources, downwardAPIVolume)
+	// plugin bundles are only mounted in the init container installing the plugins
+	for _, v := range initcontainer.PluginBundleVolumes(es.Spec.Plugins) {
+		volumes = append(volumes, v.Volume())
+	}
+	for _, v := range realms.Volumes(es) {
+		volumes = append(volumes, v.Volume())
+		volumeMounts = append(volumeMounts, v.VolumeMount())
+	}
+	realmSecretsHash, err := realms.SecretsHash(ctx, client, es)
+	if err != nil {
+		return corev1.PodTemplateSpec{}, err
+	}
 
 	labels, err := buildLabels(es, cfg, nodeSet)
 	if err != nil {
@@ -68,6 +83,7 @@ func BuildPodTemplateSpec(
 		transportCertificatesVolume(esv1.StatefulSet(es.Name, nodeSet.Name)),
 		keystoreResources,
 		es.DownwardNodeLabels(),
+		es.Spec.Plugins,
 	)
 	if err != nil {
 		return corev1.PodTemplateSpec{}, err
@@ -87,7 +103,7 @@ func BuildPodTemplateSpec(
 
 	headlessServiceName := HeadlessServiceName(esv1.StatefulSet(es.Name, nodeSet.Name))
 
-	annotations := buildAnnotations(es, cfg, keystoreResources)
+	annotations := buildAnnotations(es, cfg, keystoreResources, realmSecretsHash)
 
 	// build the podTemplate until we have the effective resources configured
 	builder = builder.
@@ -100,17 +116,25 @@ func BuildPodTemplateSpec(
 		WithReadinessProbe(*NewReadinessProbe()).
 		WithAffinity(DefaultAffinity(es.Name)).
 		WithEnv(DefaultEnvVars(es.Spec.HTTP, headlessServiceName)...).
+		WithEnv(zoneAwarenessEnvVars(es)...).
 		WithVolumes(volumes...).
 		WithVolumeMounts(volumeMounts...).
 		WithInitContainers(initContainers...).
 		WithInitContainerDefaults(corev1.EnvVar{Name: settings.HeadlessServiceName, Value: headlessServiceName}).
 		WithPreStopHook(*NewPreStopHook())
 
+	if keystoreResources != nil && es.IsSecureSettingsReloadEnabled() {
+		builder = builder.WithContainers(keystoreUpdaterContainer(builder, keystoreResources, volumeMounts))
+	}
+
 	builder, err = stackmon.WithMonitoring(client, builder, es)
 	if err != nil {
 		return corev1.PodTemplateSpec{}, err
 	}
 
+	// size the JVM heap from the effective memory limit of the Elasticsearch container
+	withJVMHeapFromMemoryLimit(builder, es)
+
 	if ver.LT(version.From(7, 2, 0)) {
 		// mitigate CVE-2021-44228
 		enableLog4JFormatMsgNoLookups(builder)
@@ -164,6 +188,7 @@ func buildAnnotations(
 	es esv1.Elasticsearch,
 	cfg settings.CanonicalConfig,
 	keystoreResources *keystore.Resources,
+	realmSecretsHash string,
 ) map[string]string {
 	// start from our defaults
 	annotations := DefaultAnnotations
@@ -177,11 +202,22 @@ func buildAnnotations(
 		_, _ = configHash.Write([]byte(es.Annotations[esv1.DownwardNodeLabelsAnnotation]))
 	}
 
-	if keystoreResources != nil {
+	if keystoreResources != nil && es.IsSecureSettingsReloadEnabled() {
+		// secure settings that cannot be reloaded to rotate the pod on their change,
+		// reloadable secure settings are updated in place by the keystore updater container
+		if nonReloadable := settings.NonReloadableSecureSettings(keystoreResources.SettingHashes); len(nonReloadable) > 0 {
+			hash.WriteHashObject(configHash, nonReloadable)
+		}
+	} else if keystoreResources != nil {
 		// resource version of the secure settings secret to rotate the pod on secure settings change
 		_, _ = configHash.Write([]byte(keystoreResources.Version))
 	}
 
+	if realmSecretsHash != "" {
+		// content of the secrets referenced by the realms, which are not reloaded by Elasticsearch
+		_, _ = configHash.Write([]byte(realmSecretsHash))
+	}
+
 	// set the annotation in place
 	annotations[configHashAnnotationName] = fmt.Sprint(configHash.Sum32())
 
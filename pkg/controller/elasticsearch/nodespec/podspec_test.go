@@ -5,6 +5,7 @@
 package nodespec
 
 import (
+	"context"
 	"sort"
 	"testing"
 
@@ -204,25 +205,70 @@ func TestBuildPodTemplateSpecWithDefaultSecurityContext(t *testing.T) {
 			es.Spec.Version = tt.version.String()
 			es.Spec.NodeSets[0].PodTemplate.Spec.SecurityContext = tt.userSecurityContext
 
-			cfg, err := settings.NewMergedESConfig(es.Name, tt.version, corev1.IPv4Protocol, es.Spec.HTTP, *es.Spec.NodeSets[0].Config)
+			cfg, err := settings.NewMergedESConfig(es.Name, tt.version, corev1.IPv4Protocol, es.Spec.HTTP, *es.Spec.NodeSets[0].Config, false)
 			require.NoError(t, err)
 
-			actual, err := BuildPodTemplateSpec(k8s.NewFakeClient(), es, es.Spec.NodeSets[0], cfg, nil, tt.setDefaultFSGroup)
+			actual, err := BuildPodTemplateSpec(context.Background(), k8s.NewFakeClient(), es, es.Spec.NodeSets[0], cfg, nil, tt.setDefaultFSGroup)
 			require.NoError(t, err)
 			require.Equal(t, tt.wantSecurityContext, actual.Spec.SecurityContext)
 		})
 	}
 }
 
+func TestBuildPodTemplateSpecWithKeystoreUpdater(t *testing.T) {
+	es := newEsSampleBuilder().addEsAnnotations(map[string]string{esv1.ReloadSecureSettingsAnnotation: "true"}).build()
+	ver, err := version.Parse(es.Spec.Version)
+	require.NoError(t, err)
+	cfg, err := settings.NewMergedESConfig(es.Name, ver, corev1.IPv4Protocol, es.Spec.HTTP, *es.Spec.NodeSets[0].Config, false)
+	require.NoError(t, err)
+
+	secureSettings := volume.NewSecretVolumeWithMountPath("secure-settings", keystore.SecureSettingsVolumeName, keystore.SecureSettingsVolumeMountPath)
+	keystoreResources := &keystore.Resources{
+		Volume:        secureSettings.Volume(),
+		InitContainer: corev1.Container{Name: keystore.InitContainerName, VolumeMounts: []corev1.VolumeMount{secureSettings.VolumeMount()}},
+	}
+
+	withoutKeystore, err := BuildPodTemplateSpec(context.Background(), k8s.NewFakeClient(), es, es.Spec.NodeSets[0], cfg, nil, false)
+	require.NoError(t, err)
+	for _, c := range withoutKeystore.Spec.Containers {
+		require.NotEqual(t, KeystoreUpdaterContainerName, c.Name)
+	}
+
+	withKeystore, err := BuildPodTemplateSpec(context.Background(), k8s.NewFakeClient(), es, es.Spec.NodeSets[0], cfg, keystoreResources, false)
+	require.NoError(t, err)
+	var updater, elasticsearch *corev1.Container
+	for i, c := range withKeystore.Spec.Containers {
+		switch c.Name {
+		case KeystoreUpdaterContainerName:
+			updater = &withKeystore.Spec.Containers[i]
+		case esv1.ElasticsearchContainerName:
+			elasticsearch = &withKeystore.Spec.Containers[i]
+		}
+	}
+	require.NotNil(t, updater)
+	require.NotNil(t, elasticsearch)
+	// the keystore updater runs the Elasticsearch image with access to the secure settings
+	require.Equal(t, elasticsearch.Image, updater.Image)
+	require.Contains(t, updater.VolumeMounts, secureSettings.VolumeMount())
+
+	// the keystore updater is only added once the reload of the secure settings is enabled
+	withoutReload := newEsSampleBuilder().build()
+	withKeystore, err = BuildPodTemplateSpec(context.Background(), k8s.NewFakeClient(), withoutReload, withoutReload.Spec.NodeSets[0], cfg, keystoreResources, false)
+	require.NoError(t, err)
+	for _, c := range withKeystore.Spec.Containers {
+		require.NotEqual(t, KeystoreUpdaterContainerName, c.Name)
+	}
+}
+
 func TestBuildPodTemplateSpec(t *testing.T) {
 	sampleES := newEsSampleBuilder().build()
 	nodeSet := sampleES.Spec.NodeSets[0]
 	ver, err := version.Parse(sampleES.Spec.Version)
 	require.NoError(t, err)
-	cfg, err := settings.NewMergedESConfig(sampleES.Name, ver, corev1.IPv4Protocol, sampleES.Spec.HTTP, *nodeSet.Config)
+	cfg, err := settings.NewMergedESConfig(sampleES.Name, ver, corev1.IPv4Protocol, sampleES.Spec.HTTP, *nodeSet.Config, false)
 	require.NoError(t, err)
 
-	actual, err := BuildPodTemplateSpec(k8s.NewFakeClient(), sampleES, sampleES.Spec.NodeSets[0], cfg, nil, false)
+	actual, err := BuildPodTemplateSpec(context.Background(), k8s.NewFakeClient(), sampleES, sampleES.Spec.NodeSets[0], cfg, nil, false)
 	require.NoError(t, err)
 
 	// build expected PodTemplateSpec
@@ -235,7 +281,7 @@ func TestBuildPodTemplateSpec(t *testing.T) {
 	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
 	sort.Slice(volumeMounts, func(i, j int) bool { return volumeMounts[i].Name < volumeMounts[j].Name })
 
-	initContainers, err := initcontainer.NewInitContainers(transportCertificatesVolume(sampleES.Name), nil, nil)
+	initContainers, err := initcontainer.NewInitContainers(transportCertificatesVolume(sampleES.Name), nil, nil, nil)
 	require.NoError(t, err)
 	// init containers should be patched with volume and inherited env vars and image
 	headlessSvcEnvVar := corev1.EnvVar{Name: "HEADLESS_SERVICE_NAME", Value: "name-es-nodeset-1"}
@@ -374,7 +420,8 @@ func Test_buildAnnotations(t *testing.T) {
 			name: "With keystore",
 			args: args{
 				keystoreResources: &keystore.Resources{
-					Version: "42",
+					Version:       "42",
+					SettingHashes: map[string]string{"s3.client.default.access_key": "1"},
 				},
 			},
 			expectedAnnotations: map[string]string{
@@ -385,22 +432,49 @@ func Test_buildAnnotations(t *testing.T) {
 			name: "With another keystore version",
 			args: args{
 				keystoreResources: &keystore.Resources{
-					Version: "43",
+					Version:       "43",
+					SettingHashes: map[string]string{"s3.client.default.access_key": "2"},
 				},
 			},
 			expectedAnnotations: map[string]string{
 				"elasticsearch.k8s.elastic.co/config-hash": "2580943768",
 			},
 		},
+		{
+			name: "With keystore, reloadable secure settings do not change the hash",
+			args: args{
+				esAnnotations: map[string]string{esv1.ReloadSecureSettingsAnnotation: "true"},
+				keystoreResources: &keystore.Resources{
+					Version:       "42",
+					SettingHashes: map[string]string{"s3.client.default.access_key": "1"},
+				},
+			},
+			expectedAnnotations: map[string]string{
+				"elasticsearch.k8s.elastic.co/config-hash": "1382203021",
+			},
+		},
+		{
+			name: "With another keystore version, reloadable secure settings do not change the hash",
+			args: args{
+				esAnnotations: map[string]string{esv1.ReloadSecureSettingsAnnotation: "true"},
+				keystoreResources: &keystore.Resources{
+					Version:       "43",
+					SettingHashes: map[string]string{"s3.client.default.access_key": "2"},
+				},
+			},
+			expectedAnnotations: map[string]string{
+				"elasticsearch.k8s.elastic.co/config-hash": "1382203021",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			es := newEsSampleBuilder().withKeystoreResources(tt.args.keystoreResources).withUserConfig(tt.args.cfg).addEsAnnotations(tt.args.esAnnotations).build()
 			ver, err := version.Parse(sampleES.Spec.Version)
 			require.NoError(t, err)
-			cfg, err := settings.NewMergedESConfig(es.Name, ver, corev1.IPv4Protocol, es.Spec.HTTP, *es.Spec.NodeSets[0].Config)
+			cfg, err := settings.NewMergedESConfig(es.Name, ver, corev1.IPv4Protocol, es.Spec.HTTP, *es.Spec.NodeSets[0].Config, false)
 			require.NoError(t, err)
-			got := buildAnnotations(es, cfg, tt.args.keystoreResources)
+			got := buildAnnotations(es, cfg, tt.args.keystoreResources, "")
 
 			for expectedAnnotation, expectedValue := range tt.expectedAnnotations {
 				actualValue, exists := got[expectedAnnotation]
@@ -411,6 +485,44 @@ func Test_buildAnnotations(t *testing.T) {
 	}
 }
 
+func Test_buildAnnotations_nonReloadableSecureSettings(t *testing.T) {
+	es := newEsSampleBuilder().addEsAnnotations(map[string]string{esv1.ReloadSecureSettingsAnnotation: "true"}).build()
+	ver, err := version.Parse(es.Spec.Version)
+	require.NoError(t, err)
+	cfg, err := settings.NewMergedESConfig(es.Name, ver, corev1.IPv4Protocol, es.Spec.HTTP, *es.Spec.NodeSets[0].Config, false)
+	require.NoError(t, err)
+	configHash := func(settingHashes map[string]string) string {
+		keystoreResources := &keystore.Resources{SettingHashes: settingHashes}
+		return buildAnnotations(es, cfg, keystoreResources, "")[configHashAnnotationName]
+	}
+
+	withoutKeystore := buildAnnotations(es, cfg, nil, "")[configHashAnnotationName]
+	nonReloadable := configHash(map[string]string{"xpack.security.authc.realms.ldap.ldap1.secure_bind_password": "1"})
+	// a non-reloadable secure setting rotates the Pods
+	require.NotEqual(t, withoutKeystore, nonReloadable)
+	// so does a change of its value
+	require.NotEqual(t, nonReloadable, configHash(map[string]string{"xpack.security.authc.realms.ldap.ldap1.secure_bind_password": "2"}))
+	// but not a change of a reloadable secure setting
+	require.Equal(t, nonReloadable, configHash(map[string]string{
+		"xpack.security.authc.realms.ldap.ldap1.secure_bind_password": "1",
+		"s3.client.default.access_key":                                "1",
+	}))
+}
+
+func Test_buildAnnotations_realmSecrets(t *testing.T) {
+	es := newEsSampleBuilder().build()
+	ver, err := version.Parse(es.Spec.Version)
+	require.NoError(t, err)
+	cfg, err := settings.NewMergedESConfig(es.Name, ver, corev1.IPv4Protocol, es.Spec.HTTP, *es.Spec.NodeSets[0].Config, false)
+	require.NoError(t, err)
+
+	withoutRealms := buildAnnotations(es, cfg, nil, "")[configHashAnnotationName]
+	withRealms := buildAnnotations(es, cfg, nil, "1")[configHashAnnotationName]
+	// the content of the secrets referenced by the realms rotates the Pods
+	require.NotEqual(t, withoutRealms, withRealms)
+	require.NotEqual(t, withRealms, buildAnnotations(es, cfg, nil, "2")[configHashAnnotationName])
+}
+
 func Test_getDefaultContainerPorts(t *testing.T) {
 	tt := []struct {
 		name string
@@ -495,9 +607,9 @@ func Test_enableLog4JFormatMsgNoLookups(t *testing.T) {
 
 			ver, err := version.Parse(sampleES.Spec.Version)
 			require.NoError(t, err)
-			cfg, err := settings.NewMergedESConfig(sampleES.Name, ver, corev1.IPv4Protocol, sampleES.Spec.HTTP, *sampleES.Spec.NodeSets[0].Config)
+			cfg, err := settings.NewMergedESConfig(sampleES.Name, ver, corev1.IPv4Protocol, sampleES.Spec.HTTP, *sampleES.Spec.NodeSets[0].Config, false)
 			require.NoError(t, err)
-			actual, err := BuildPodTemplateSpec(k8s.NewFakeClient(), sampleES, sampleES.Spec.NodeSets[0], cfg, nil, false)
+			actual, err := BuildPodTemplateSpec(context.Background(), k8s.NewFakeClient(), sampleES, sampleES.Spec.NodeSets[0], cfg, nil, false)
 			require.NoError(t, err)
 
 			env := actual.Spec.Containers[1].Env
@@ -5,6 +5,7 @@
 package nodespec
 
 import (
+	"context"
 	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -15,6 +16,7 @@ import (
 	"github.com/elastic/cloud-on-k8s/pkg/controller/common/keystore"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/label"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/realms"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/settings"
 	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/sset"
 	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
@@ -47,6 +49,7 @@ func (l ResourcesList) StatefulSets() sset.StatefulSetList {
 }
 
 func BuildExpectedResources(
+	ctx context.Context,
 	client k8s.Client,
 	es esv1.Elasticsearch,
 	keystoreResources *keystore.Resources,
@@ -61,19 +64,30 @@ func BuildExpectedResources(
 		return nil, err
 	}
 
+	realmsCfg, err := realms.Config(ver, es)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, nodeSpec := range es.Spec.NodeSets {
 		// build es config
 		userCfg := commonv1.Config{}
 		if nodeSpec.Config != nil {
 			userCfg = *nodeSpec.Config
 		}
-		cfg, err := settings.NewMergedESConfig(es.Name, ver, ipFamily, es.Spec.HTTP, userCfg)
+		cfg, err := settings.NewMergedESConfig(es.Name, ver, ipFamily, es.Spec.HTTP, userCfg, es.Spec.ZoneAwareness != nil)
 		if err != nil {
 			return nil, err
 		}
+		if err := cfg.ApplyDataTier(nodeSpec.DataTier); err != nil {
+			return nil, err
+		}
+		if err := cfg.MergeWith(realmsCfg); err != nil {
+			return nil, err
+		}
 
 		// build stateful set and associated headless service
-		statefulSet, err := BuildStatefulSet(client, es, nodeSpec, cfg, keystoreResources, existingStatefulSets, setDefaultSecurityContext)
+		statefulSet, err := BuildStatefulSet(ctx, client, es, nodeSpec, cfg, keystoreResources, existingStatefulSets, setDefaultSecurityContext)
 		if err != nil {
 			return nil, err
 		}
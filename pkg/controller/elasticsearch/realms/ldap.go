@@ -0,0 +1,78 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package realms
+
+import (
+	"strconv"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/certificates"
+	common "github.com/elastic/cloud-on-k8s/pkg/controller/common/settings"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+)
+
+// ldapMountsType identifies the secrets referenced by the LDAP and Active Directory realms, which are declared in the
+// same list of the specification.
+const ldapMountsType = "ldap"
+
+// newLDAPCAMount returns the secret holding the certificate authorities of the LDAP realm at the given index of the
+// specification, or nil if there is none.
+func newLDAPCAMount(index int, realm esv1.LDAPRealm) *secretMount {
+	if realm.CertificateAuthoritiesSecretName == "" {
+		return nil
+	}
+	return &secretMount{secretName: realm.CertificateAuthoritiesSecretName, name: strconv.Itoa(index) + "-ca", realmType: ldapMountsType}
+}
+
+func ldapSecretMounts(realms []esv1.LDAPRealm) []secretMount {
+	var mounts []secretMount
+	for i, realm := range realms {
+		if mount := newLDAPCAMount(i, realm); mount != nil {
+			mounts = append(mounts, *mount)
+		}
+	}
+	return mounts
+}
+
+// ldapConfig returns the settings of the LDAP and Active Directory realms. Their bind passwords are not part of it, as
+// they are stored in the keystore along with the other secure settings.
+func ldapConfig(ver version.Version, realms []esv1.LDAPRealm) (*common.CanonicalConfig, error) {
+	cfg := common.NewCanonicalConfig()
+	for i, realm := range realms {
+		settings := map[string]interface{}{}
+		if len(realm.URLs) > 0 {
+			settings["url"] = realm.URLs
+		}
+		if realm.TypeOrDefault() == esv1.ActiveDirectoryType {
+			settings["domain_name"] = realm.DomainName
+		}
+		if realm.BindDN != "" {
+			settings["bind_dn"] = realm.BindDN
+		}
+		if realm.UserSearch != nil {
+			settings["user_search.base_dn"] = realm.UserSearch.BaseDN
+			if realm.UserSearch.Filter != "" {
+				settings["user_search.filter"] = realm.UserSearch.Filter
+			}
+		}
+		if len(realm.UserDNTemplates) > 0 {
+			settings["user_dn_templates"] = realm.UserDNTemplates
+		}
+		if realm.GroupSearch != nil {
+			settings["group_search.base_dn"] = realm.GroupSearch.BaseDN
+		}
+		if mount := newLDAPCAMount(i, realm); mount != nil {
+			settings["ssl.certificate_authorities"] = []string{mount.file(certificates.CAFileName)}
+		}
+		realmCfg, err := realmConfig(ver, string(realm.TypeOrDefault()), realm.Name, realm.Order, settings, realm.Config)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.MergeWith(realmCfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
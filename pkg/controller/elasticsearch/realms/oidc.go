@@ -0,0 +1,61 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package realms
+
+import (
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	common "github.com/elastic/cloud-on-k8s/pkg/controller/common/settings"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+)
+
+const (
+	oidcRealmType = "oidc"
+	// defaultOIDCResponseType selects the authorization code flow
+	defaultOIDCResponseType = "code"
+)
+
+// oidcConfig returns the settings of the OpenID Connect realms. Their client secrets are not part of it, as they are
+// stored in the keystore along with the other secure settings.
+func oidcConfig(ver version.Version, realms []esv1.OIDCRealm) (*common.CanonicalConfig, error) {
+	cfg := common.NewCanonicalConfig()
+	for _, realm := range realms {
+		responseType := realm.RP.ResponseType
+		if responseType == "" {
+			responseType = defaultOIDCResponseType
+		}
+		settings := map[string]interface{}{
+			"op.issuer":                 realm.OP.Issuer,
+			"op.authorization_endpoint": realm.OP.AuthorizationEndpoint,
+			"op.token_endpoint":         realm.OP.TokenEndpoint,
+			"op.jwkset_path":            realm.OP.JWKSetURL,
+			"rp.client_id":              realm.RP.ClientID,
+			"rp.redirect_uri":           realm.RP.RedirectURI,
+			"rp.response_type":          responseType,
+			"claims.principal":          realm.Claims.Principal,
+		}
+		optional := map[string]string{
+			"op.userinfo_endpoint":        realm.OP.UserInfoEndpoint,
+			"op.endsession_endpoint":      realm.OP.EndSessionEndpoint,
+			"rp.post_logout_redirect_uri": realm.RP.PostLogoutRedirectURI,
+			"claims.groups":               realm.Claims.Groups,
+			"claims.name":                 realm.Claims.Name,
+			"claims.mail":                 realm.Claims.Mail,
+			"claims.dn":                   realm.Claims.DN,
+		}
+		for key, value := range optional {
+			if value != "" {
+				settings[key] = value
+			}
+		}
+		realmCfg, err := realmConfig(ver, oidcRealmType, realm.Name, realm.Order, settings, realm.Config)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.MergeWith(realmCfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,164 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Package realms renders the configuration of the authentication realms declared in the Elasticsearch specification,
+// and mounts the secrets they reference in the Elasticsearch containers.
+package realms
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/hash"
+	common "github.com/elastic/cloud-on-k8s/pkg/controller/common/settings"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/volume"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/watches"
+	esvolume "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/volume"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+// volumeNamePrefix prefixes the names of the volumes of the secrets referenced by the realms.
+const volumeNamePrefix = "elastic-internal-realm-"
+
+// realmsMountPath is where the secrets referenced by the realms are mounted. Elasticsearch can only read files from
+// its configuration directory.
+var realmsMountPath = path.Join(esvolume.ConfigVolumeMountPath, "realms")
+
+// secretMount is a secret referenced by a realm, mounted in the Elasticsearch containers.
+type secretMount struct {
+	secretName string
+	// name identifies the secret among the ones referenced by the realms of the same type
+	name string
+	// realmType is the type of the realm referencing the secret
+	realmType string
+}
+
+func (m secretMount) volume() volume.SecretVolume {
+	return volume.NewSecretVolumeWithMountPath(m.secretName, volumeNamePrefix+m.realmType+"-"+m.name, m.mountPath())
+}
+
+func (m secretMount) mountPath() string {
+	return path.Join(realmsMountPath, m.realmType, m.name)
+}
+
+// file returns the path of the given entry of the secret.
+func (m secretMount) file(key string) string {
+	return path.Join(m.mountPath(), key)
+}
+
+// secretMounts returns the secrets referenced by the realms declared in the specification.
+func secretMounts(es esv1.Elasticsearch) []secretMount {
+	return append(samlSecretMounts(es.Spec.Auth.SAML), ldapSecretMounts(es.Spec.Auth.LDAP)...)
+}
+
+// Config returns the settings of the realms declared in the specification, to merge into the Elasticsearch
+// configuration of all the nodes.
+func Config(ver version.Version, es esv1.Elasticsearch) (*common.CanonicalConfig, error) {
+	samlCfg, err := samlConfig(ver, es.Spec.Auth.SAML)
+	if err != nil {
+		return nil, err
+	}
+	oidcCfg, err := oidcConfig(ver, es.Spec.Auth.OIDC)
+	if err != nil {
+		return nil, err
+	}
+	ldapCfg, err := ldapConfig(ver, es.Spec.Auth.LDAP)
+	if err != nil {
+		return nil, err
+	}
+	cfg := common.NewCanonicalConfig()
+	return cfg, cfg.MergeWith(samlCfg, oidcCfg, ldapCfg)
+}
+
+// Volumes returns the volumes of the secrets referenced by the realms declared in the specification.
+func Volumes(es esv1.Elasticsearch) []volume.SecretVolume {
+	mounts := secretMounts(es)
+	volumes := make([]volume.SecretVolume, 0, len(mounts))
+	for _, m := range mounts {
+		volumes = append(volumes, m.volume())
+	}
+	return volumes
+}
+
+// WatchName returns the name of the watch of the secrets referenced by the realms of the given cluster.
+func WatchName(es types.NamespacedName) string {
+	return fmt.Sprintf("%s-%s-realm-secrets", es.Namespace, es.Name)
+}
+
+// WatchSecrets ensures the secrets referenced by the realms are watched, to trigger a reconciliation on their change.
+func WatchSecrets(es esv1.Elasticsearch, watched watches.DynamicWatches) error {
+	mounts := secretMounts(es)
+	secretNames := make([]string, 0, len(mounts))
+	for _, m := range mounts {
+		secretNames = append(secretNames, m.secretName)
+	}
+	return watches.WatchUserProvidedSecrets(k8s.ExtractNamespacedName(&es), watched, WatchName(k8s.ExtractNamespacedName(&es)), secretNames)
+}
+
+// SecretsHash returns a hash of the content of the secrets referenced by the realms, to restart the nodes when it
+// changes, or an empty string if there is no such secret.
+func SecretsHash(ctx context.Context, c k8s.Client, es esv1.Elasticsearch) (string, error) {
+	mounts := secretMounts(es)
+	if len(mounts) == 0 {
+		return "", nil
+	}
+	secretsHash := fnv.New32a()
+	for _, m := range mounts {
+		var secret corev1.Secret
+		if err := c.Get(ctx, types.NamespacedName{Namespace: es.Namespace, Name: m.secretName}, &secret); err != nil {
+			return "", fmt.Errorf("while getting the secret %s referenced by a %s realm: %w", m.secretName, m.realmType, err)
+		}
+		hash.WriteHashObject(secretsHash, secret.Data)
+	}
+	return fmt.Sprint(secretsHash.Sum32()), nil
+}
+
+// realmConfig returns the settings of a realm, prefixed with the realm type and name as expected by the given version
+// of Elasticsearch. The generated settings take precedence over the additional settings set by the user.
+func realmConfig(
+	ver version.Version,
+	realmType string,
+	name string,
+	order int32,
+	generated map[string]interface{},
+	additional *commonv1.Config,
+) (*common.CanonicalConfig, error) {
+	prefix := esv1.RealmSettingsPrefix(ver, realmType, name)
+	settings := map[string]interface{}{"order": order}
+	if ver.LT(version.From(7, 0, 0)) {
+		// the realm type is not part of the prefix before Elasticsearch 7.0.0
+		settings["type"] = realmType
+	}
+	for key, value := range generated {
+		settings[key] = value
+	}
+
+	cfg := common.NewCanonicalConfig()
+	if additional != nil {
+		additionalCfg, err := common.NewCanonicalConfigFrom(map[string]interface{}{prefix: additional.Data})
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.MergeWith(additionalCfg); err != nil {
+			return nil, err
+		}
+	}
+	prefixed := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		prefixed[prefix+"."+key] = value
+	}
+	generatedCfg, err := common.NewCanonicalConfigFrom(prefixed)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, cfg.MergeWith(generatedCfg)
+}
@@ -0,0 +1,254 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package realms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	common "github.com/elastic/cloud-on-k8s/pkg/controller/common/settings"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+var samlRealm = esv1.SAMLRealm{
+	Name:  "saml1",
+	Order: 2,
+	IdP: esv1.SAMLIdentityProvider{
+		EntityID:           "https://idp.example.com",
+		MetadataSecretName: "idp-metadata",
+	},
+	SP: esv1.SAMLServiceProvider{
+		EntityID: "https://kibana.example.com",
+		ACS:      "https://kibana.example.com/api/security/saml/callback",
+	},
+	Attributes:        esv1.SAMLAttributes{Principal: "nameid", Groups: "groups"},
+	SigningSecretName: "saml-signing",
+	Config:            &commonv1.Config{Data: map[string]interface{}{"nameid_format": "urn:oasis:names:tc:SAML:2.0:nameid-format:transient", "order": 10}},
+}
+
+var oidcRealm = esv1.OIDCRealm{
+	Name:  "oidc1",
+	Order: 3,
+	OP: esv1.OIDCProvider{
+		Issuer:                "https://op.example.com",
+		AuthorizationEndpoint: "https://op.example.com/oauth2/authorize",
+		TokenEndpoint:         "https://op.example.com/oauth2/token",
+		JWKSetURL:             "https://op.example.com/oauth2/jwks",
+	},
+	RP: esv1.OIDCRelyingParty{
+		ClientID:         "kibana",
+		ClientSecretName: "oidc-client-secret",
+		RedirectURI:      "https://kibana.example.com/api/security/oidc/callback",
+	},
+	Claims: esv1.OIDCClaims{Principal: "sub", Mail: "email"},
+	Config: &commonv1.Config{Data: map[string]interface{}{"rp.requested_scopes": []interface{}{"openid", "email"}}},
+}
+
+var ldapRealm = esv1.LDAPRealm{
+	Name:                             "ldap1",
+	Order:                            4,
+	URLs:                             []string{"ldaps://ldap.example.com:636"},
+	BindDN:                           "cn=es,dc=example,dc=com",
+	BindPasswordSecretName:           "ldap-bind",
+	UserSearch:                       &esv1.LDAPUserSearch{BaseDN: "ou=users,dc=example,dc=com", Filter: "(uid={0})"},
+	GroupSearch:                      &esv1.LDAPGroupSearch{BaseDN: "ou=groups,dc=example,dc=com"},
+	CertificateAuthoritiesSecretName: "ldap-ca",
+	Config:                           &commonv1.Config{Data: map[string]interface{}{"unmapped_groups_as_roles": true}},
+}
+
+func newES(realms ...esv1.SAMLRealm) esv1.Elasticsearch {
+	return esv1.Elasticsearch{
+		ObjectMeta: metav1.ObjectMeta{Name: "es", Namespace: "ns"},
+		Spec:       esv1.ElasticsearchSpec{Auth: esv1.Auth{SAML: realms}},
+	}
+}
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		auth    esv1.Auth
+		want    map[string]interface{}
+	}{
+		{
+			name:    "no realms",
+			version: "7.16.0",
+			want:    map[string]interface{}{},
+		},
+		{
+			name:    "SAML realm",
+			version: "7.16.0",
+			auth:    esv1.Auth{SAML: []esv1.SAMLRealm{samlRealm}},
+			want: map[string]interface{}{
+				"xpack.security.authc.realms.saml.saml1.order":                2,
+				"xpack.security.authc.realms.saml.saml1.nameid_format":        "urn:oasis:names:tc:SAML:2.0:nameid-format:transient",
+				"xpack.security.authc.realms.saml.saml1.idp.metadata.path":    "/usr/share/elasticsearch/config/realms/saml/0-idp/metadata.xml",
+				"xpack.security.authc.realms.saml.saml1.idp.entity_id":        "https://idp.example.com",
+				"xpack.security.authc.realms.saml.saml1.sp.entity_id":         "https://kibana.example.com",
+				"xpack.security.authc.realms.saml.saml1.sp.acs":               "https://kibana.example.com/api/security/saml/callback",
+				"xpack.security.authc.realms.saml.saml1.attributes.principal": "nameid",
+				"xpack.security.authc.realms.saml.saml1.attributes.groups":    "groups",
+				"xpack.security.authc.realms.saml.saml1.signing.certificate":  "/usr/share/elasticsearch/config/realms/saml/0-signing/tls.crt",
+				"xpack.security.authc.realms.saml.saml1.signing.key":          "/usr/share/elasticsearch/config/realms/saml/0-signing/tls.key",
+			},
+		},
+		{
+			name:    "SAML realm before 7.0.0",
+			version: "6.8.0",
+			auth:    esv1.Auth{SAML: []esv1.SAMLRealm{{Name: "saml1", IdP: samlRealm.IdP, SP: samlRealm.SP, Attributes: esv1.SAMLAttributes{Principal: "nameid"}}}},
+			want: map[string]interface{}{
+				"xpack.security.authc.realms.saml1.type":                 "saml",
+				"xpack.security.authc.realms.saml1.order":                0,
+				"xpack.security.authc.realms.saml1.idp.metadata.path":    "/usr/share/elasticsearch/config/realms/saml/0-idp/metadata.xml",
+				"xpack.security.authc.realms.saml1.idp.entity_id":        "https://idp.example.com",
+				"xpack.security.authc.realms.saml1.sp.entity_id":         "https://kibana.example.com",
+				"xpack.security.authc.realms.saml1.sp.acs":               "https://kibana.example.com/api/security/saml/callback",
+				"xpack.security.authc.realms.saml1.attributes.principal": "nameid",
+			},
+		},
+		{
+			name:    "OIDC realm",
+			version: "7.16.0",
+			auth:    esv1.Auth{OIDC: []esv1.OIDCRealm{oidcRealm}},
+			want: map[string]interface{}{
+				"xpack.security.authc.realms.oidc.oidc1.order":                     3,
+				"xpack.security.authc.realms.oidc.oidc1.rp.requested_scopes":       []interface{}{"openid", "email"},
+				"xpack.security.authc.realms.oidc.oidc1.op.issuer":                 "https://op.example.com",
+				"xpack.security.authc.realms.oidc.oidc1.op.authorization_endpoint": "https://op.example.com/oauth2/authorize",
+				"xpack.security.authc.realms.oidc.oidc1.op.token_endpoint":         "https://op.example.com/oauth2/token",
+				"xpack.security.authc.realms.oidc.oidc1.op.jwkset_path":            "https://op.example.com/oauth2/jwks",
+				"xpack.security.authc.realms.oidc.oidc1.rp.client_id":              "kibana",
+				"xpack.security.authc.realms.oidc.oidc1.rp.redirect_uri":           "https://kibana.example.com/api/security/oidc/callback",
+				"xpack.security.authc.realms.oidc.oidc1.rp.response_type":          "code",
+				"xpack.security.authc.realms.oidc.oidc1.claims.principal":          "sub",
+				"xpack.security.authc.realms.oidc.oidc1.claims.mail":               "email",
+			},
+		},
+		{
+			name:    "LDAP realm",
+			version: "7.16.0",
+			auth:    esv1.Auth{LDAP: []esv1.LDAPRealm{ldapRealm}},
+			want: map[string]interface{}{
+				"xpack.security.authc.realms.ldap.ldap1.order":                       4,
+				"xpack.security.authc.realms.ldap.ldap1.unmapped_groups_as_roles":    true,
+				"xpack.security.authc.realms.ldap.ldap1.url":                         []string{"ldaps://ldap.example.com:636"},
+				"xpack.security.authc.realms.ldap.ldap1.bind_dn":                     "cn=es,dc=example,dc=com",
+				"xpack.security.authc.realms.ldap.ldap1.user_search.base_dn":         "ou=users,dc=example,dc=com",
+				"xpack.security.authc.realms.ldap.ldap1.user_search.filter":          "(uid={0})",
+				"xpack.security.authc.realms.ldap.ldap1.group_search.base_dn":        "ou=groups,dc=example,dc=com",
+				"xpack.security.authc.realms.ldap.ldap1.ssl.certificate_authorities": []string{"/usr/share/elasticsearch/config/realms/ldap/0-ca/ca.crt"},
+			},
+		},
+		{
+			name:    "Active Directory realm before 7.0.0",
+			version: "6.8.0",
+			auth: esv1.Auth{LDAP: []esv1.LDAPRealm{{
+				Name:       "ad1",
+				Order:      5,
+				Type:       esv1.ActiveDirectoryType,
+				DomainName: "example.com",
+			}}},
+			want: map[string]interface{}{
+				"xpack.security.authc.realms.ad1.type":        "active_directory",
+				"xpack.security.authc.realms.ad1.order":       5,
+				"xpack.security.authc.realms.ad1.domain_name": "example.com",
+			},
+		},
+		{
+			name:    "SAML and OIDC realms",
+			version: "7.16.0",
+			auth: esv1.Auth{
+				SAML: []esv1.SAMLRealm{{Name: "saml1", Order: 2, IdP: samlRealm.IdP, SP: samlRealm.SP, Attributes: esv1.SAMLAttributes{Principal: "nameid"}}},
+				OIDC: []esv1.OIDCRealm{{Name: "oidc1", Order: 3, OP: oidcRealm.OP, RP: oidcRealm.RP, Claims: esv1.OIDCClaims{Principal: "sub"}}},
+			},
+			want: map[string]interface{}{
+				"xpack.security.authc.realms.saml.saml1.order":                     2,
+				"xpack.security.authc.realms.saml.saml1.idp.metadata.path":         "/usr/share/elasticsearch/config/realms/saml/0-idp/metadata.xml",
+				"xpack.security.authc.realms.saml.saml1.idp.entity_id":             "https://idp.example.com",
+				"xpack.security.authc.realms.saml.saml1.sp.entity_id":              "https://kibana.example.com",
+				"xpack.security.authc.realms.saml.saml1.sp.acs":                    "https://kibana.example.com/api/security/saml/callback",
+				"xpack.security.authc.realms.saml.saml1.attributes.principal":      "nameid",
+				"xpack.security.authc.realms.oidc.oidc1.order":                     3,
+				"xpack.security.authc.realms.oidc.oidc1.op.issuer":                 "https://op.example.com",
+				"xpack.security.authc.realms.oidc.oidc1.op.authorization_endpoint": "https://op.example.com/oauth2/authorize",
+				"xpack.security.authc.realms.oidc.oidc1.op.token_endpoint":         "https://op.example.com/oauth2/token",
+				"xpack.security.authc.realms.oidc.oidc1.op.jwkset_path":            "https://op.example.com/oauth2/jwks",
+				"xpack.security.authc.realms.oidc.oidc1.rp.client_id":              "kibana",
+				"xpack.security.authc.realms.oidc.oidc1.rp.redirect_uri":           "https://kibana.example.com/api/security/oidc/callback",
+				"xpack.security.authc.realms.oidc.oidc1.rp.response_type":          "code",
+				"xpack.security.authc.realms.oidc.oidc1.claims.principal":          "sub",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := esv1.Elasticsearch{Spec: esv1.ElasticsearchSpec{Auth: tt.auth}}
+			cfg, err := Config(version.MustParse(tt.version), es)
+			require.NoError(t, err)
+			require.Empty(t, common.MustCanonicalConfig(tt.want).Diff(cfg, nil))
+		})
+	}
+}
+
+func TestVolumes(t *testing.T) {
+	require.Empty(t, Volumes(newES()))
+	volumes := Volumes(newES(samlRealm))
+	require.Len(t, volumes, 2)
+	require.Equal(t, "idp-metadata", volumes[0].Volume().Secret.SecretName)
+	require.Equal(t, "elastic-internal-realm-saml-0-idp", volumes[0].Volume().Name)
+	require.Equal(t, "/usr/share/elasticsearch/config/realms/saml/0-idp", volumes[0].VolumeMount().MountPath)
+	require.Equal(t, "saml-signing", volumes[1].Volume().Secret.SecretName)
+	require.Equal(t, "elastic-internal-realm-saml-0-signing", volumes[1].Volume().Name)
+}
+
+func TestSecretsHash(t *testing.T) {
+	secret := func(name string, data string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+			Data:       map[string][]byte{"key": []byte(data)},
+		}
+	}
+
+	hash, err := SecretsHash(context.Background(), k8s.NewFakeClient(), newES())
+	require.NoError(t, err)
+	require.Empty(t, hash)
+
+	// a missing secret is an error
+	_, err = SecretsHash(context.Background(), k8s.NewFakeClient(secret("idp-metadata", "a")), newES(samlRealm))
+	require.Error(t, err)
+
+	hash, err = SecretsHash(context.Background(), k8s.NewFakeClient(secret("idp-metadata", "a"), secret("saml-signing", "b")), newES(samlRealm))
+	require.NoError(t, err)
+	require.NotEmpty(t, hash)
+	updated, err := SecretsHash(context.Background(), k8s.NewFakeClient(secret("idp-metadata", "a"), secret("saml-signing", "c")), newES(samlRealm))
+	require.NoError(t, err)
+	require.NotEqual(t, hash, updated)
+}
+
+func TestVolumes_OIDC(t *testing.T) {
+	// the client secret of an OIDC realm is stored in the keystore, it is not mounted
+	es := esv1.Elasticsearch{Spec: esv1.ElasticsearchSpec{Auth: esv1.Auth{OIDC: []esv1.OIDCRealm{oidcRealm}}}}
+	require.Empty(t, Volumes(es))
+}
+
+func TestVolumes_LDAP(t *testing.T) {
+	// the bind password of an LDAP realm is stored in the keystore, only the certificate authorities are mounted
+	es := esv1.Elasticsearch{Spec: esv1.ElasticsearchSpec{Auth: esv1.Auth{
+		SAML: []esv1.SAMLRealm{samlRealm},
+		LDAP: []esv1.LDAPRealm{{Name: "ad1", Type: esv1.ActiveDirectoryType, DomainName: "example.com"}, ldapRealm},
+	}}}
+	volumes := Volumes(es)
+	require.Len(t, volumes, 3)
+	require.Equal(t, "ldap-ca", volumes[2].Volume().Secret.SecretName)
+	require.Equal(t, "elastic-internal-realm-ldap-1-ca", volumes[2].Volume().Name)
+	require.Equal(t, "/usr/share/elasticsearch/config/realms/ldap/1-ca", volumes[2].VolumeMount().MountPath)
+}
@@ -0,0 +1,101 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package realms
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	common "github.com/elastic/cloud-on-k8s/pkg/controller/common/settings"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+)
+
+const samlRealmType = "saml"
+
+// samlMounts are the secrets referenced by a SAML realm.
+type samlMounts struct {
+	idp        secretMount
+	signing    *secretMount
+	encryption *secretMount
+}
+
+func (m samlMounts) list() []secretMount {
+	mounts := []secretMount{m.idp}
+	if m.signing != nil {
+		mounts = append(mounts, *m.signing)
+	}
+	if m.encryption != nil {
+		mounts = append(mounts, *m.encryption)
+	}
+	return mounts
+}
+
+// newSAMLMounts returns the secrets referenced by the SAML realm at the given index of the specification. They are
+// identified by the index of the realm, as the name of a realm is not necessarily a valid volume name.
+func newSAMLMounts(index int, realm esv1.SAMLRealm) samlMounts {
+	id := strconv.Itoa(index)
+	mounts := samlMounts{
+		idp: secretMount{secretName: realm.IdP.MetadataSecretName, name: id + "-idp", realmType: samlRealmType},
+	}
+	if realm.SigningSecretName != "" {
+		mounts.signing = &secretMount{secretName: realm.SigningSecretName, name: id + "-signing", realmType: samlRealmType}
+	}
+	if realm.EncryptionSecretName != "" {
+		mounts.encryption = &secretMount{secretName: realm.EncryptionSecretName, name: id + "-encryption", realmType: samlRealmType}
+	}
+	return mounts
+}
+
+func samlSecretMounts(realms []esv1.SAMLRealm) []secretMount {
+	var mounts []secretMount
+	for i, realm := range realms {
+		mounts = append(mounts, newSAMLMounts(i, realm).list()...)
+	}
+	return mounts
+}
+
+func samlConfig(ver version.Version, realms []esv1.SAMLRealm) (*common.CanonicalConfig, error) {
+	cfg := common.NewCanonicalConfig()
+	for i, realm := range realms {
+		mounts := newSAMLMounts(i, realm)
+		settings := map[string]interface{}{
+			"idp.metadata.path":    mounts.idp.file(esv1.SAMLMetadataKey),
+			"idp.entity_id":        realm.IdP.EntityID,
+			"sp.entity_id":         realm.SP.EntityID,
+			"sp.acs":               realm.SP.ACS,
+			"attributes.principal": realm.Attributes.Principal,
+		}
+		optional := map[string]string{
+			"sp.logout":         realm.SP.Logout,
+			"attributes.groups": realm.Attributes.Groups,
+			"attributes.name":   realm.Attributes.Name,
+			"attributes.mail":   realm.Attributes.Mail,
+			"attributes.dn":     realm.Attributes.DN,
+		}
+		for key, value := range optional {
+			if value != "" {
+				settings[key] = value
+			}
+		}
+		if mounts.signing != nil {
+			settings["signing.certificate"] = mounts.signing.file(corev1.TLSCertKey)
+			settings["signing.key"] = mounts.signing.file(corev1.TLSPrivateKeyKey)
+		}
+		if mounts.encryption != nil {
+			settings["encryption.certificate"] = mounts.encryption.file(corev1.TLSCertKey)
+			settings["encryption.key"] = mounts.encryption.file(corev1.TLSPrivateKeyKey)
+		}
+		realmCfg, err := realmConfig(ver, samlRealmType, realm.Name, realm.Order, settings, realm.Config)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.MergeWith(realmCfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
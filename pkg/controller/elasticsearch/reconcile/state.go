@@ -112,6 +112,16 @@ func (s *State) UpdateAvailableNodes(
 	return s
 }
 
+func (s *State) UpdatePlugins(plugins []esv1.PluginStatus) *State {
+	s.status.Plugins = plugins
+	return s
+}
+
+func (s *State) UpdateCrossClusterReplication(replication []esv1.CrossClusterReplicationStatus) *State {
+	s.status.CrossClusterReplication = replication
+	return s
+}
+
 func (s *State) UpdateMinRunningVersion(
 	resourcesState ResourcesState,
 ) *State {
@@ -0,0 +1,192 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package remotecluster
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/license"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/managed"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/reconcile"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+const (
+	// ManagedAutoFollowPatternsAnnotationName holds the list of the auto-follow patterns created by the operator.
+	ManagedAutoFollowPatternsAnnotationName = "elasticsearch.k8s.elastic.co/managed-auto-follow-patterns"
+
+	// defaultFollowIndexPattern is the name given by Elasticsearch to the follower indices if no pattern is specified.
+	defaultFollowIndexPattern = "{{leader_index}}"
+)
+
+// ReconcileAutoFollowPatterns creates in Elasticsearch the auto-follow patterns declared on the remote clusters of the
+// Elasticsearch specification, updates the ones which differ from their declaration, and deletes the ones previously
+// created by the operator which are not declared anymore. Patterns created by the user directly in Elasticsearch are
+// left untouched. The outcome is reported in the AutoFollowPatternsApplied condition, and the replication of the
+// follower indices of each remote cluster with auto-follow patterns is summarized in the status.
+// It is expected to be called once the remote clusters are configured in the Elasticsearch settings.
+func ReconcileAutoFollowPatterns(
+	ctx context.Context,
+	c k8s.Client,
+	es *esv1.Elasticsearch,
+	esClient esclient.Client,
+	licenseChecker license.Checker,
+	state *reconcile.State,
+) error {
+	resources := autoFollowPatterns(*es, esClient)
+	if resources.IsEmpty(*es) {
+		// nothing to do, skip
+		state.UpdateCrossClusterReplication(nil)
+		return nil
+	}
+
+	enabled, err := licenseChecker.EnterpriseFeaturesEnabled()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		// the event is already emitted when updating the remote clusters settings
+		state.ReportCondition(esv1.AutoFollowPatternsApplied, corev1.ConditionFalse, enterpriseFeaturesDisabledMsg)
+		return nil
+	}
+
+	outcome, err := managed.Reconcile(ctx, c, es, resources, state)
+	if err != nil {
+		return err
+	}
+	if err := updateReplicationStatus(ctx, *es, esClient, state); err != nil {
+		return err
+	}
+	return outcome.Report(state)
+}
+
+// autoFollowPatterns gives access to the auto-follow patterns declared on the remote clusters of the given
+// Elasticsearch specification.
+func autoFollowPatterns(es esv1.Elasticsearch, esClient esclient.Client) managed.Resources {
+	declared := expectedAutoFollowPatterns(es)
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return managed.Resources{
+		AnnotationName: ManagedAutoFollowPatternsAnnotationName,
+		Kind:           "auto-follow pattern",
+		Description:    "auto-follow patterns",
+		ConditionType:  esv1.AutoFollowPatternsApplied,
+		SuccessMsg:     "%d auto-follow patterns applied",
+		Expected:       names,
+		Get: func(ctx context.Context) (map[string]interface{}, error) {
+			inEs, err := esClient.GetAutoFollowPatterns(ctx)
+			if err != nil {
+				return nil, err
+			}
+			result := make(map[string]interface{}, len(inEs))
+			for name, pattern := range inEs {
+				result[name] = pattern
+			}
+			return result, nil
+		},
+		Equal: func(name string, actual interface{}) (bool, error) {
+			return autoFollowPatternEqual(declared[name], actual.(esclient.AutoFollowPattern)), nil
+		},
+		Upsert: func(ctx context.Context, name string, actual interface{}) error {
+			// the remote cluster of an existing pattern cannot be changed, Elasticsearch expects it to be recreated
+			if actual, exists := actual.(esclient.AutoFollowPattern); exists && actual.RemoteCluster != declared[name].RemoteCluster {
+				log.Info("Deleting auto-follow pattern to change its remote cluster", "namespace", es.Namespace, "es_name", es.Name, "pattern", name)
+				if err := esClient.DeleteAutoFollowPattern(ctx, name); err != nil {
+					return err
+				}
+			}
+			return esClient.UpsertAutoFollowPattern(ctx, name, declared[name])
+		},
+		Delete: esClient.DeleteAutoFollowPattern,
+	}
+}
+
+// expectedAutoFollowPatterns returns the auto-follow patterns declared on the remote clusters of the specification,
+// indexed by name.
+func expectedAutoFollowPatterns(es esv1.Elasticsearch) map[string]esclient.AutoFollowPattern {
+	expected := make(map[string]esclient.AutoFollowPattern)
+	for _, remoteCluster := range es.Spec.RemoteClusters {
+		for _, pattern := range remoteCluster.AutoFollowPatterns {
+			expected[pattern.Name] = esclient.AutoFollowPattern{
+				RemoteCluster:       remoteCluster.Name,
+				LeaderIndexPatterns: pattern.LeaderIndexPatterns,
+				FollowIndexPattern:  pattern.FollowIndexPattern,
+			}
+		}
+	}
+	return expected
+}
+
+// autoFollowPatternEqual returns true if the given auto-follow pattern as returned by Elasticsearch matches the expected
+// one, taking into account the default follow index pattern.
+func autoFollowPatternEqual(expected, actual esclient.AutoFollowPattern) bool {
+	followIndexPattern := expected.FollowIndexPattern
+	if followIndexPattern == "" {
+		followIndexPattern = defaultFollowIndexPattern
+	}
+	actualFollowIndexPattern := actual.FollowIndexPattern
+	if actualFollowIndexPattern == "" {
+		actualFollowIndexPattern = defaultFollowIndexPattern
+	}
+	return expected.RemoteCluster == actual.RemoteCluster &&
+		reflect.DeepEqual(expected.LeaderIndexPatterns, actual.LeaderIndexPatterns) &&
+		followIndexPattern == actualFollowIndexPattern
+}
+
+// updateReplicationStatus reports in the status the number of follower indices of each remote cluster with auto-follow
+// patterns, along with the number of operations they still have to replicate.
+func updateReplicationStatus(ctx context.Context, es esv1.Elasticsearch, esClient esclient.Client, state *reconcile.State) error {
+	stats, err := esClient.GetCrossClusterReplicationStats(ctx)
+	if err != nil {
+		return err
+	}
+	state.UpdateCrossClusterReplication(replicationStatus(es.Spec.RemoteClusters, stats))
+	return nil
+}
+
+// replicationStatus summarizes the given replication statistics for the remote clusters with auto-follow patterns,
+// sorted by name. The lag of a shard is the difference between the global checkpoints of the leader and follower shards.
+func replicationStatus(remoteClusters []esv1.RemoteCluster, stats esclient.CrossClusterReplicationStats) []esv1.CrossClusterReplicationStatus {
+	byRemoteCluster := make(map[string]*esv1.CrossClusterReplicationStatus)
+	for _, remoteCluster := range remoteClusters {
+		if len(remoteCluster.AutoFollowPatterns) == 0 {
+			continue
+		}
+		byRemoteCluster[remoteCluster.Name] = &esv1.CrossClusterReplicationStatus{RemoteCluster: remoteCluster.Name}
+	}
+	for _, index := range stats.FollowStats.Indices {
+		counted := make(map[string]struct{})
+		for _, shard := range index.Shards {
+			status, exists := byRemoteCluster[shard.RemoteCluster]
+			if !exists {
+				continue
+			}
+			if _, isCounted := counted[shard.RemoteCluster]; !isCounted {
+				counted[shard.RemoteCluster] = struct{}{}
+				status.FollowerIndices++
+			}
+			if lag := shard.LeaderGlobalCheckpoint - shard.FollowerGlobalCheckpoint; lag > 0 {
+				status.OperationsBehind += lag
+			}
+		}
+	}
+	result := make([]esv1.CrossClusterReplicationStatus, 0, len(byRemoteCluster))
+	for _, status := range byRemoteCluster {
+		result = append(result, *status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].RemoteCluster < result[j].RemoteCluster
+	})
+	return result
+}
@@ -0,0 +1,183 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package remotecluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/license"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/reconcile"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+type fakeCCRESClient struct {
+	esclient.Client
+	patterns esclient.AutoFollowPatterns
+	stats    esclient.CrossClusterReplicationStats
+	calls    []string
+}
+
+func (f *fakeCCRESClient) GetAutoFollowPatterns(_ context.Context) (esclient.AutoFollowPatterns, error) {
+	return f.patterns, nil
+}
+
+func (f *fakeCCRESClient) UpsertAutoFollowPattern(_ context.Context, name string, pattern esclient.AutoFollowPattern) error {
+	f.calls = append(f.calls, "upsert "+name)
+	f.patterns[name] = pattern
+	return nil
+}
+
+func (f *fakeCCRESClient) DeleteAutoFollowPattern(_ context.Context, name string) error {
+	f.calls = append(f.calls, "delete "+name)
+	delete(f.patterns, name)
+	return nil
+}
+
+func (f *fakeCCRESClient) GetCrossClusterReplicationStats(_ context.Context) (esclient.CrossClusterReplicationStats, error) {
+	return f.stats, nil
+}
+
+func TestReconcileAutoFollowPatterns(t *testing.T) {
+	leader := esv1.RemoteCluster{
+		Name: "leader",
+		AutoFollowPatterns: []esv1.AutoFollowPattern{
+			{Name: "logs", LeaderIndexPatterns: []string{"logs-*"}},
+		},
+	}
+	// as returned by Elasticsearch, with the default follow index pattern
+	logsInEs := esclient.AutoFollowPattern{RemoteCluster: "leader", LeaderIndexPatterns: []string{"logs-*"}, FollowIndexPattern: "{{leader_index}}"}
+	logsChanged := esclient.AutoFollowPattern{RemoteCluster: "leader", LeaderIndexPatterns: []string{"logs-*", "metrics-*"}}
+
+	tests := []struct {
+		name               string
+		remoteClusters     []esv1.RemoteCluster
+		annotation         string
+		patternsInEs       esclient.AutoFollowPatterns
+		enterpriseDisabled bool
+		wantCalls          []string
+		wantAnnotation     string
+		wantCondition      corev1.ConditionStatus
+	}{
+		{
+			name:         "no auto-follow patterns",
+			patternsInEs: esclient.AutoFollowPatterns{},
+		},
+		{
+			name:           "create a pattern",
+			remoteClusters: []esv1.RemoteCluster{leader},
+			patternsInEs:   esclient.AutoFollowPatterns{},
+			wantCalls:      []string{"upsert logs"},
+			wantAnnotation: "logs",
+			wantCondition:  corev1.ConditionTrue,
+		},
+		{
+			name:           "pattern already up to date",
+			remoteClusters: []esv1.RemoteCluster{leader},
+			annotation:     "logs",
+			patternsInEs:   esclient.AutoFollowPatterns{"logs": logsInEs},
+			wantAnnotation: "logs",
+			wantCondition:  corev1.ConditionTrue,
+		},
+		{
+			name:           "revert manual changes",
+			remoteClusters: []esv1.RemoteCluster{leader},
+			annotation:     "logs",
+			patternsInEs:   esclient.AutoFollowPatterns{"logs": logsChanged},
+			wantCalls:      []string{"upsert logs"},
+			wantAnnotation: "logs",
+			wantCondition:  corev1.ConditionTrue,
+		},
+		{
+			name:           "do not overwrite a pattern created by the user",
+			remoteClusters: []esv1.RemoteCluster{leader},
+			patternsInEs:   esclient.AutoFollowPatterns{"logs": logsChanged},
+			wantCondition:  corev1.ConditionFalse,
+		},
+		{
+			name:          "delete a pattern removed from the spec, but not the ones created by the user",
+			annotation:    "logs",
+			patternsInEs:  esclient.AutoFollowPatterns{"logs": logsInEs, "user": logsChanged},
+			wantCalls:     []string{"delete logs"},
+			wantCondition: corev1.ConditionTrue,
+		},
+		{
+			name:               "enterprise features disabled",
+			remoteClusters:     []esv1.RemoteCluster{leader},
+			patternsInEs:       esclient.AutoFollowPatterns{},
+			enterpriseDisabled: true,
+			wantCondition:      corev1.ConditionFalse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := esv1.Elasticsearch{
+				ObjectMeta: metav1.ObjectMeta{Name: "es", Namespace: "ns", Annotations: map[string]string{}},
+				Spec:       esv1.ElasticsearchSpec{RemoteClusters: tt.remoteClusters},
+			}
+			if tt.annotation != "" {
+				es.Annotations[ManagedAutoFollowPatternsAnnotationName] = tt.annotation
+			}
+			c := k8s.NewFakeClient(&es)
+			esClient := &fakeCCRESClient{patterns: tt.patternsInEs}
+			state := reconcile.MustNewState(es)
+			licenseChecker := license.MockLicenseChecker{EnterpriseEnabled: !tt.enterpriseDisabled}
+
+			require.NoError(t, ReconcileAutoFollowPatterns(context.Background(), c, &es, esClient, licenseChecker, state))
+			require.Equal(t, tt.wantCalls, esClient.calls)
+
+			var updated esv1.Elasticsearch
+			require.NoError(t, c.Get(context.Background(), k8s.ExtractNamespacedName(&es), &updated))
+			require.Equal(t, tt.wantAnnotation, updated.Annotations[ManagedAutoFollowPatternsAnnotationName])
+
+			_, withStatus := state.Apply()
+			require.NotNil(t, withStatus)
+			index := withStatus.Status.Conditions.Index(esv1.AutoFollowPatternsApplied)
+			if tt.wantCondition == "" {
+				require.Equal(t, -1, index)
+				return
+			}
+			require.NotEqual(t, -1, index)
+			require.Equal(t, tt.wantCondition, withStatus.Status.Conditions[index].Status)
+		})
+	}
+}
+
+func Test_replicationStatus(t *testing.T) {
+	remoteClusters := []esv1.RemoteCluster{
+		{Name: "other", AutoFollowPatterns: []esv1.AutoFollowPattern{{Name: "metrics", LeaderIndexPatterns: []string{"metrics-*"}}}},
+		{Name: "leader", AutoFollowPatterns: []esv1.AutoFollowPattern{{Name: "logs", LeaderIndexPatterns: []string{"logs-*"}}}},
+		{Name: "no-patterns"},
+	}
+	var stats esclient.CrossClusterReplicationStats
+	stats.FollowStats.Indices = []esclient.FollowerIndexStats{
+		{
+			Index: "logs-1",
+			Shards: []esclient.FollowerShardStats{
+				{RemoteCluster: "leader", LeaderGlobalCheckpoint: 100, FollowerGlobalCheckpoint: 90},
+				{RemoteCluster: "leader", LeaderGlobalCheckpoint: 50, FollowerGlobalCheckpoint: 50},
+			},
+		},
+		{
+			Index: "logs-2",
+			// the follower checkpoint can be ahead of the last leader checkpoint fetched
+			Shards: []esclient.FollowerShardStats{{RemoteCluster: "leader", LeaderGlobalCheckpoint: 10, FollowerGlobalCheckpoint: 12}},
+		},
+		{
+			Index:  "unmanaged",
+			Shards: []esclient.FollowerShardStats{{RemoteCluster: "unknown", LeaderGlobalCheckpoint: 10, FollowerGlobalCheckpoint: 0}},
+		},
+	}
+	require.Equal(t, []esv1.CrossClusterReplicationStatus{
+		{RemoteCluster: "leader", FollowerIndices: 2, OperationsBehind: 10},
+		{RemoteCluster: "other", FollowerIndices: 0, OperationsBehind: 0},
+	}, replicationStatus(remoteClusters, stats))
+}
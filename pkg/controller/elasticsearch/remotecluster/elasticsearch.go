@@ -115,8 +115,10 @@ func updateSettingsInternal(
 	for name, remoteCluster := range remoteClustersInSpec {
 		remoteClustersToUpdate = append(remoteClustersToUpdate, name)
 		// Declare remote cluster in ES
-		seedHosts := []string{services.ExternalTransportServiceHost(remoteCluster.ElasticsearchRef.NamespacedName())}
-		remoteClustersToApply[name] = esclient.RemoteCluster{Seeds: seedHosts}
+		remoteClustersToApply[name] = esclient.RemoteCluster{
+			Seeds:           seedHosts(remoteCluster),
+			SkipUnavailable: remoteCluster.SkipUnavailable,
+		}
 		// Ensure this cluster is tracked in the annotation
 		remoteClustersInAnnotation[name] = struct{}{}
 	}
@@ -167,15 +169,26 @@ func getRemoteClustersInElasticsearch(esClient esclient.Client) (map[string]stru
 func getRemoteClustersInSpec(es esv1.Elasticsearch) map[string]esv1.RemoteCluster {
 	remoteClusters := make(map[string]esv1.RemoteCluster)
 	for _, remoteCluster := range es.Spec.RemoteClusters {
-		if !remoteCluster.ElasticsearchRef.IsDefined() {
+		if !remoteCluster.ElasticsearchRef.IsDefined() && len(remoteCluster.Seeds) == 0 {
 			continue
 		}
-		remoteCluster.ElasticsearchRef = remoteCluster.ElasticsearchRef.WithDefaultNamespace(es.Namespace)
+		if remoteCluster.ElasticsearchRef.IsDefined() {
+			remoteCluster.ElasticsearchRef = remoteCluster.ElasticsearchRef.WithDefaultNamespace(es.Namespace)
+		}
 		remoteClusters[remoteCluster.Name] = remoteCluster
 	}
 	return remoteClusters
 }
 
+// seedHosts returns the transport addresses of a remote cluster: the transport service of the referenced
+// Elasticsearch cluster, or the seeds of a remote cluster not managed by the operator.
+func seedHosts(remoteCluster esv1.RemoteCluster) []string {
+	if !remoteCluster.ElasticsearchRef.IsDefined() {
+		return remoteCluster.Seeds
+	}
+	return []string{services.ExternalTransportServiceHost(remoteCluster.ElasticsearchRef.NamespacedName())}
+}
+
 // updateSettings makes a call to an Elasticsearch cluster to apply a persistent setting.
 func updateSettings(esClient esclient.Client, remoteClusters map[string]esclient.RemoteCluster) error {
 	return esClient.UpdateRemoteClusterSettings(context.Background(), esclient.RemoteClustersSettings{
@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 
 	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
 	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
@@ -200,6 +201,63 @@ func TestUpdateSettings(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Create a new remote cluster not managed by the operator",
+			args: args{
+				esClient:       &fakeESClient{existingSettings: emptySettings},
+				licenseChecker: &license.MockLicenseChecker{EnterpriseEnabled: true},
+				es: newEsWithRemoteClusters(
+					"ns1",
+					"es1",
+					nil,
+					esv1.RemoteCluster{
+						Name:  "external",
+						Seeds: []string{"10.0.0.1:9300", "10.0.0.2:9300"},
+					},
+				),
+			},
+			wantAnnotation:                        "external",
+			wantGetRemoteClusterSettingsCalled:    true,
+			wantUpdateRemoteClusterSettingsCalled: true,
+			wantSettings: esclient.RemoteClustersSettings{
+				PersistentSettings: &esclient.SettingsGroup{
+					Cluster: esclient.RemoteClusters{
+						RemoteClusters: map[string]esclient.RemoteCluster{
+							"external": {Seeds: []string{"10.0.0.1:9300", "10.0.0.2:9300"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "Create a new remote cluster skipped by cross-cluster searches when unavailable",
+			args: args{
+				esClient:       &fakeESClient{existingSettings: emptySettings},
+				licenseChecker: &license.MockLicenseChecker{EnterpriseEnabled: true},
+				es: newEsWithRemoteClusters(
+					"ns1",
+					"es1",
+					nil,
+					esv1.RemoteCluster{
+						Name:             "ns2-es2",
+						ElasticsearchRef: commonv1.ObjectSelector{Name: "es2", Namespace: "ns2"},
+						SkipUnavailable:  pointer.BoolPtr(true),
+					},
+				),
+			},
+			wantAnnotation:                        "ns2-es2",
+			wantGetRemoteClusterSettingsCalled:    true,
+			wantUpdateRemoteClusterSettingsCalled: true,
+			wantSettings: esclient.RemoteClustersSettings{
+				PersistentSettings: &esclient.SettingsGroup{
+					Cluster: esclient.RemoteClusters{
+						RemoteClusters: map[string]esclient.RemoteCluster{
+							"ns2-es2": {Seeds: []string{"es2-es-transport.ns2.svc:9300"}, SkipUnavailable: pointer.BoolPtr(true)},
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "Create a new remote cluster with no namespace",
 			args: args{
@@ -19,4 +19,6 @@ const (
 	EnvPodIP     = "POD_IP"
 	EnvNodeName  = "NODE_NAME"
 	EnvNamespace = "NAMESPACE"
+	// EnvZone is only injected if zone awareness is enabled
+	EnvZone = "ZONE"
 )
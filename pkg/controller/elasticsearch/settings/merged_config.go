@@ -23,7 +23,13 @@ import (
 // the name of the ES attribute indicating the pod's current k8s node
 const nodeAttrK8sNodeName = "k8s_node_name"
 
-var nodeAttrNodeName = fmt.Sprintf("%s.%s", esv1.NodeAttr, nodeAttrK8sNodeName)
+// the name of the ES attribute indicating the zone of the pod's current k8s node, if zone awareness is enabled
+const nodeAttrZoneName = "zone"
+
+var (
+	nodeAttrNodeName = fmt.Sprintf("%s.%s", esv1.NodeAttr, nodeAttrK8sNodeName)
+	nodeAttrZone     = fmt.Sprintf("%s.%s", esv1.NodeAttr, nodeAttrZoneName)
+)
 
 // NewMergedESConfig merges user provided Elasticsearch configuration with configuration derived from the given
 // parameters. The user provided config overrides have precedence over the ECK config.
@@ -33,12 +39,13 @@ func NewMergedESConfig(
 	ipFamily corev1.IPFamily,
 	httpConfig commonv1.HTTPConfig,
 	userConfig commonv1.Config,
+	zoneAwareness bool,
 ) (CanonicalConfig, error) {
 	userCfg, err := common.NewCanonicalConfigFrom(userConfig.Data)
 	if err != nil {
 		return CanonicalConfig{}, err
 	}
-	config := baseConfig(clusterName, ver, ipFamily).CanonicalConfig
+	config := baseConfig(clusterName, ver, ipFamily, zoneAwareness).CanonicalConfig
 	err = config.MergeWith(
 		xpackConfig(ver, httpConfig).CanonicalConfig,
 		userCfg,
@@ -49,8 +56,31 @@ func NewMergedESConfig(
 	return CanonicalConfig{config}, nil
 }
 
+// ApplyDataTier adds the roles of the given data tier to the node.roles setting, and sets the node.attr.data attribute
+// to the name of the tier unless it is already set. The configuration is left unchanged if the data tier is empty.
+func (c CanonicalConfig) ApplyDataTier(tier esv1.DataTier) error {
+	if tier == "" {
+		return nil
+	}
+	cfg := esv1.ElasticsearchSettings{}
+	if err := c.CanonicalConfig.Unpack(&cfg); err != nil {
+		return err
+	}
+	var roles []string
+	if cfg.Node != nil {
+		roles = cfg.Node.Roles
+	}
+	tierCfg := map[string]interface{}{
+		esv1.NodeRoles: tier.WithRoles(roles),
+	}
+	if len(c.HasKeys([]string{esv1.NodeAttrData})) == 0 {
+		tierCfg[esv1.NodeAttrData] = string(tier)
+	}
+	return c.MergeWith(common.MustCanonicalConfig(tierCfg))
+}
+
 // baseConfig returns the base ES configuration to apply for the given cluster
-func baseConfig(clusterName string, ver version.Version, ipFamily corev1.IPFamily) *CanonicalConfig {
+func baseConfig(clusterName string, ver version.Version, ipFamily corev1.IPFamily, zoneAwareness bool) *CanonicalConfig {
 	cfg := map[string]interface{}{
 		// derive node name dynamically from the pod name, injected as env var
 		esv1.NodeName:    "${" + EnvPodName + "}",
@@ -69,6 +99,12 @@ func baseConfig(clusterName string, ver version.Version, ipFamily corev1.IPFamil
 		esv1.PathLogs: volume.ElasticsearchLogsMountPath,
 	}
 
+	if zoneAwareness {
+		// also allocate the copies of a shard to pods running in different zones, derived from the k8s node labels
+		cfg[esv1.ShardAwarenessAttributes] = nodeAttrK8sNodeName + "," + nodeAttrZoneName
+		cfg[nodeAttrZone] = "${" + EnvZone + "}"
+	}
+
 	// seed hosts setting name changed starting ES 7.X
 	fileProvider := "file"
 	if ver.Major < 7 {
@@ -36,12 +36,31 @@ func TestNewMergedESConfig(t *testing.T) {
 		} `yaml:"network"`
 	}
 
+	// awarenessCfg captures the allocation awareness settings
+	type awarenessCfg struct {
+		Cluster struct {
+			Routing struct {
+				Allocation struct {
+					Awareness struct {
+						Attributes string `config:"attributes"`
+					} `config:"awareness"`
+				} `config:"allocation"`
+			} `config:"routing"`
+		} `config:"cluster"`
+		Node struct {
+			Attr struct {
+				Zone string `config:"zone"`
+			} `config:"attr"`
+		} `config:"node"`
+	}
+
 	tests := []struct {
-		name     string
-		version  string
-		ipFamily corev1.IPFamily
-		cfgData  map[string]interface{}
-		assert   func(cfg CanonicalConfig)
+		name          string
+		version       string
+		ipFamily      corev1.IPFamily
+		cfgData       map[string]interface{}
+		zoneAwareness bool
+		assert        func(cfg CanonicalConfig)
 	}{
 		{
 			name:     "in 6.x, empty config should have the default file and native realm settings configured",
@@ -209,6 +228,31 @@ func TestNewMergedESConfig(t *testing.T) {
 				require.Equal(t, "[${POD_IP}]", esCfg.Network.PublishHost)
 			},
 		},
+		{
+			name:          "zone awareness sets the zone attribute and adds it to the awareness attributes",
+			version:       "7.16.0",
+			ipFamily:      corev1.IPv4Protocol,
+			cfgData:       map[string]interface{}{},
+			zoneAwareness: true,
+			assert: func(cfg CanonicalConfig) {
+				esCfg := awarenessCfg{}
+				require.NoError(t, cfg.Unpack(&esCfg))
+				require.Equal(t, "${ZONE}", esCfg.Node.Attr.Zone)
+				require.Equal(t, "k8s_node_name,zone", esCfg.Cluster.Routing.Allocation.Awareness.Attributes)
+			},
+		},
+		{
+			name:          "user provided awareness attributes take precedence over zone awareness",
+			version:       "7.16.0",
+			ipFamily:      corev1.IPv4Protocol,
+			cfgData:       map[string]interface{}{esv1.ShardAwarenessAttributes: "rack"},
+			zoneAwareness: true,
+			assert: func(cfg CanonicalConfig) {
+				esCfg := awarenessCfg{}
+				require.NoError(t, cfg.Unpack(&esCfg))
+				require.Equal(t, "rack", esCfg.Cluster.Routing.Allocation.Awareness.Attributes)
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -220,9 +264,74 @@ func TestNewMergedESConfig(t *testing.T) {
 				tt.ipFamily,
 				commonv1.HTTPConfig{},
 				commonv1.Config{Data: tt.cfgData},
+				tt.zoneAwareness,
 			)
 			require.NoError(t, err)
 			tt.assert(cfg)
 		})
 	}
 }
+
+func TestCanonicalConfig_ApplyDataTier(t *testing.T) {
+	tests := []struct {
+		name      string
+		tier      esv1.DataTier
+		cfgData   map[string]interface{}
+		wantRoles []string
+		wantAttr  string
+	}{
+		{
+			name:    "no data tier",
+			cfgData: map[string]interface{}{esv1.NodeRoles: []string{"master"}},
+			// node.roles is left as is and the attribute is not set
+			wantRoles: []string{"master"},
+		},
+		{
+			name:      "data tier without node.roles",
+			tier:      esv1.HotTier,
+			cfgData:   map[string]interface{}{},
+			wantRoles: []string{"master", "ingest", "ml", "transform", "remote_cluster_client", "data_hot", "data_content"},
+			wantAttr:  "hot",
+		},
+		{
+			name:      "data tier with node.roles",
+			tier:      esv1.WarmTier,
+			cfgData:   map[string]interface{}{"node": map[string]interface{}{"roles": []string{"ingest"}}},
+			wantRoles: []string{"ingest", "data_warm"},
+			wantAttr:  "warm",
+		},
+		{
+			name:      "node.attr.data set by the user",
+			tier:      esv1.ColdTier,
+			cfgData:   map[string]interface{}{esv1.NodeAttrData: "archive"},
+			wantRoles: []string{"master", "ingest", "ml", "transform", "remote_cluster_client", "data_cold"},
+			wantAttr:  "archive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := NewMergedESConfig(
+				"clusterName",
+				version.MustParse("7.16.0"),
+				corev1.IPv4Protocol,
+				commonv1.HTTPConfig{},
+				commonv1.Config{Data: tt.cfgData},
+				false,
+			)
+			require.NoError(t, err)
+			require.NoError(t, cfg.ApplyDataTier(tt.tier))
+
+			var esCfg struct {
+				Node struct {
+					Roles []string `config:"roles"`
+					Attr  struct {
+						Data string `config:"data"`
+					} `config:"attr"`
+				} `config:"node"`
+			}
+			require.NoError(t, cfg.CanonicalConfig.Unpack(&esCfg))
+			require.Equal(t, tt.wantRoles, esCfg.Node.Roles)
+			require.Equal(t, tt.wantAttr, esCfg.Node.Attr.Data)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package settings
+
+import "regexp"
+
+// reloadableSecureSettings are the secure settings Elasticsearch can reload from the keystore without a restart
+// through the reload secure settings API.
+// See https://www.elastic.co/guide/en/elasticsearch/reference/current/secure-settings.html#reloadable-secure-settings
+var reloadableSecureSettings = []*regexp.Regexp{
+	regexp.MustCompile(`^azure\.client\.[^.]+\.(account|key|sas_token)$`),
+	regexp.MustCompile(`^discovery\.ec2\.(access_key|secret_key|session_token)$`),
+	regexp.MustCompile(`^gcs\.client\.[^.]+\.credentials_file$`),
+	regexp.MustCompile(`^s3\.client\.[^.]+\.(access_key|secret_key|session_token)$`),
+	regexp.MustCompile(`^xpack\.monitoring\.exporters\.[^.]+\.auth\.secure_password$`),
+	regexp.MustCompile(`^xpack\.notification\.(email|jira|pagerduty|slack)\.account\.[^.]+\.(smtp\.)?secure_[a-z_]+$`),
+}
+
+// IsReloadableSecureSetting returns true if the given secure setting can be reloaded without restarting Elasticsearch.
+func IsReloadableSecureSetting(setting string) bool {
+	for _, reloadable := range reloadableSecureSettings {
+		if reloadable.MatchString(setting) {
+			return true
+		}
+	}
+	return false
+}
+
+// NonReloadableSecureSettings returns the subset of the given secure settings that cannot be reloaded without
+// restarting Elasticsearch.
+func NonReloadableSecureSettings(settings map[string]string) map[string]string {
+	nonReloadable := make(map[string]string)
+	for setting, value := range settings {
+		if !IsReloadableSecureSetting(setting) {
+			nonReloadable[setting] = value
+		}
+	}
+	return nonReloadable
+}
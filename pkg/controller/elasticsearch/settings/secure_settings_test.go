@@ -0,0 +1,49 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package settings
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsReloadableSecureSetting(t *testing.T) {
+	tests := []struct {
+		setting string
+		want    bool
+	}{
+		{setting: "s3.client.default.access_key", want: true},
+		{setting: "s3.client.backup.secret_key", want: true},
+		{setting: "gcs.client.default.credentials_file", want: true},
+		{setting: "azure.client.secondary.sas_token", want: true},
+		{setting: "discovery.ec2.session_token", want: true},
+		{setting: "xpack.monitoring.exporters.remote.auth.secure_password", want: true},
+		{setting: "xpack.notification.email.account.work.smtp.secure_password", want: true},
+		{setting: "xpack.notification.slack.account.monitoring.secure_url", want: true},
+		{setting: "s3.client.default.endpoint", want: false},
+		{setting: "xpack.security.authc.realms.ldap.ldap1.secure_bind_password", want: false},
+		{setting: "xpack.security.transport.ssl.keystore.secure_password", want: false},
+		{setting: "bootstrap.password", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.setting, func(t *testing.T) {
+			require.Equal(t, tt.want, IsReloadableSecureSetting(tt.setting))
+		})
+	}
+}
+
+func TestNonReloadableSecureSettings(t *testing.T) {
+	settings := map[string]string{
+		"s3.client.default.access_key":                                "hash1",
+		"s3.client.default.secret_key":                                "hash2",
+		"xpack.security.authc.realms.ldap.ldap1.secure_bind_password": "hash3",
+	}
+	require.Equal(t,
+		map[string]string{"xpack.security.authc.realms.ldap.ldap1.secure_bind_password": "hash3"},
+		NonReloadableSecureSettings(settings),
+	)
+	require.Empty(t, NonReloadableSecureSettings(nil))
+}
@@ -0,0 +1,13 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package snapshot
+
+const (
+	// ManagedSnapshotRepositoriesAnnotationName holds the list of the snapshot repositories registered by the operator.
+	ManagedSnapshotRepositoriesAnnotationName = "elasticsearch.k8s.elastic.co/managed-snapshot-repositories"
+	// ManagedSnapshotLifecyclePoliciesAnnotationName holds the list of the snapshot lifecycle policies created by the
+	// operator.
+	ManagedSnapshotLifecyclePoliciesAnnotationName = "elasticsearch.k8s.elastic.co/managed-snapshot-lifecycle-policies"
+)
@@ -0,0 +1,117 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/managed"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/reconcile"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+// ReconcilePolicies creates in Elasticsearch the snapshot lifecycle management policies declared in the Elasticsearch
+// specification, updates the ones which differ from their declaration, including because of changes made through the
+// Elasticsearch API, and deletes the ones previously created by the operator which are not declared anymore. Policies
+// created by the user directly in Elasticsearch are left untouched. The outcome is reported in the
+// SnapshotLifecyclePoliciesApplied condition.
+func ReconcilePolicies(
+	ctx context.Context,
+	c k8s.Client,
+	es *esv1.Elasticsearch,
+	esClient esclient.Client,
+	state *reconcile.State,
+) error {
+	outcome, err := managed.Reconcile(ctx, c, es, policies(*es, esClient), state)
+	if err != nil {
+		return err
+	}
+	return outcome.Report(state)
+}
+
+// policies gives access to the snapshot lifecycle policies declared in the given Elasticsearch specification.
+func policies(es esv1.Elasticsearch, esClient esclient.Client) managed.Resources {
+	declared := make(map[string]esclient.SnapshotLifecyclePolicy, len(es.Spec.SnapshotLifecyclePolicies))
+	names := make([]string, 0, len(es.Spec.SnapshotLifecyclePolicies))
+	for _, policy := range es.Spec.SnapshotLifecyclePolicies {
+		declared[policy.Name] = toPolicy(policy)
+		names = append(names, policy.Name)
+	}
+	return managed.Resources{
+		AnnotationName: ManagedSnapshotLifecyclePoliciesAnnotationName,
+		Kind:           "snapshot lifecycle policy",
+		Description:    "snapshot lifecycle policies",
+		ConditionType:  esv1.SnapshotLifecyclePoliciesApplied,
+		SuccessMsg:     "%d snapshot lifecycle policies applied",
+		TakeOver:       true,
+		Expected:       names,
+		Get: func(ctx context.Context) (map[string]interface{}, error) {
+			inEs, err := esClient.GetSnapshotLifecyclePolicies(ctx)
+			if err != nil {
+				return nil, err
+			}
+			result := make(map[string]interface{}, len(inEs))
+			for name, policy := range inEs {
+				result[name] = policy.Policy
+			}
+			return result, nil
+		},
+		Equal: func(name string, actual interface{}) (bool, error) {
+			return policyEqual(declared[name], actual.(esclient.SnapshotLifecyclePolicy))
+		},
+		Upsert: func(ctx context.Context, name string, _ interface{}) error {
+			return esClient.UpsertSnapshotLifecyclePolicy(ctx, name, declared[name])
+		},
+		Delete: esClient.DeleteSnapshotLifecyclePolicy,
+	}
+}
+
+// toPolicy returns the definition of the given policy expected by Elasticsearch.
+func toPolicy(policy esv1.SnapshotLifecyclePolicy) esclient.SnapshotLifecyclePolicy {
+	expected := esclient.SnapshotLifecyclePolicy{
+		Name:       policy.SnapshotNameOrDefault(),
+		Schedule:   policy.Schedule,
+		Repository: policy.Repository,
+	}
+	if policy.Config != nil {
+		expected.Config = policy.Config.Data
+	}
+	if policy.Retention != nil {
+		expected.Retention = &esclient.SnapshotLifecycleRetention{
+			ExpireAfter: policy.Retention.ExpireAfter,
+			MinCount:    policy.Retention.MinCount,
+			MaxCount:    policy.Retention.MaxCount,
+		}
+	}
+	return expected
+}
+
+// policyEqual compares the expected definition of a policy with the one returned by Elasticsearch, through their
+// JSON representation to compare the untyped snapshot configuration regardless of the Go types of its values.
+func policyEqual(expected, actual esclient.SnapshotLifecyclePolicy) (bool, error) {
+	normalizedExpected, err := normalize(expected)
+	if err != nil {
+		return false, err
+	}
+	normalizedActual, err := normalize(actual)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(normalizedExpected, normalizedActual), nil
+}
+
+func normalize(policy esclient.SnapshotLifecyclePolicy) (map[string]interface{}, error) {
+	bytes, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]interface{}
+	err = json.Unmarshal(bytes, &normalized)
+	return normalized, err
+}
@@ -0,0 +1,149 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package snapshot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/reconcile"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+type fakePoliciesESClient struct {
+	esclient.Client
+	policies esclient.SnapshotLifecyclePolicies
+	upserted []string
+	deleted  []string
+}
+
+func (f *fakePoliciesESClient) GetSnapshotLifecyclePolicies(_ context.Context) (esclient.SnapshotLifecyclePolicies, error) {
+	return f.policies, nil
+}
+
+func (f *fakePoliciesESClient) UpsertSnapshotLifecyclePolicy(_ context.Context, id string, policy esclient.SnapshotLifecyclePolicy) error {
+	f.upserted = append(f.upserted, id)
+	f.policies[id] = esclient.SnapshotLifecyclePolicyDefinition{Policy: policy}
+	return nil
+}
+
+func (f *fakePoliciesESClient) DeleteSnapshotLifecyclePolicy(_ context.Context, id string) error {
+	f.deleted = append(f.deleted, id)
+	delete(f.policies, id)
+	return nil
+}
+
+func TestReconcilePolicies(t *testing.T) {
+	minCount := int32(5)
+	nightly := esv1.SnapshotLifecyclePolicy{
+		Name:       "nightly",
+		Schedule:   "0 30 1 * * ?",
+		Repository: "backups",
+		Config:     &commonv1.Config{Data: map[string]interface{}{"indices": []interface{}{"*"}, "include_global_state": true}},
+		Retention:  &esv1.SnapshotRetention{ExpireAfter: "30d", MinCount: &minCount},
+	}
+	nightlyInEs := esclient.SnapshotLifecyclePolicyDefinition{
+		Version: 1,
+		Policy: esclient.SnapshotLifecyclePolicy{
+			Name:       "<nightly-{now/d}>",
+			Schedule:   "0 30 1 * * ?",
+			Repository: "backups",
+			Config:     map[string]interface{}{"indices": []interface{}{"*"}, "include_global_state": true},
+			Retention:  &esclient.SnapshotLifecycleRetention{ExpireAfter: "30d", MinCount: &minCount},
+		},
+	}
+	manuallyChanged := nightlyInEs
+	manuallyChanged.Policy.Schedule = "0 0 * * * ?"
+
+	tests := []struct {
+		name           string
+		policies       []esv1.SnapshotLifecyclePolicy
+		annotation     string
+		inEs           esclient.SnapshotLifecyclePolicies
+		wantUpserted   []string
+		wantDeleted    []string
+		wantAnnotation string
+		wantCondition  bool
+	}{
+		{
+			name: "no policies",
+			inEs: esclient.SnapshotLifecyclePolicies{},
+		},
+		{
+			name:           "create a new policy",
+			policies:       []esv1.SnapshotLifecyclePolicy{nightly},
+			inEs:           esclient.SnapshotLifecyclePolicies{},
+			wantUpserted:   []string{"nightly"},
+			wantAnnotation: "nightly",
+			wantCondition:  true,
+		},
+		{
+			name:           "policy already up to date",
+			policies:       []esv1.SnapshotLifecyclePolicy{nightly},
+			annotation:     "nightly",
+			inEs:           esclient.SnapshotLifecyclePolicies{"nightly": nightlyInEs},
+			wantAnnotation: "nightly",
+			wantCondition:  true,
+		},
+		{
+			name:           "revert a manual change",
+			policies:       []esv1.SnapshotLifecyclePolicy{nightly},
+			annotation:     "nightly",
+			inEs:           esclient.SnapshotLifecyclePolicies{"nightly": manuallyChanged},
+			wantUpserted:   []string{"nightly"},
+			wantAnnotation: "nightly",
+			wantCondition:  true,
+		},
+		{
+			name:       "delete a policy removed from the spec but not the ones created by the user",
+			annotation: "nightly",
+			inEs: esclient.SnapshotLifecyclePolicies{
+				"nightly": nightlyInEs,
+				"user":    manuallyChanged,
+			},
+			wantDeleted:   []string{"nightly"},
+			wantCondition: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := esv1.Elasticsearch{
+				ObjectMeta: metav1.ObjectMeta{Name: "es", Namespace: "ns"},
+				Spec:       esv1.ElasticsearchSpec{SnapshotLifecyclePolicies: tt.policies},
+			}
+			if tt.annotation != "" {
+				es.Annotations = map[string]string{ManagedSnapshotLifecyclePoliciesAnnotationName: tt.annotation}
+			}
+			c := k8s.NewFakeClient(&es)
+			esClient := &fakePoliciesESClient{policies: tt.inEs}
+			state := reconcile.MustNewState(es)
+
+			require.NoError(t, ReconcilePolicies(context.Background(), c, &es, esClient, state))
+			require.Equal(t, tt.wantUpserted, esClient.upserted)
+			require.Equal(t, tt.wantDeleted, esClient.deleted)
+
+			var updated esv1.Elasticsearch
+			require.NoError(t, c.Get(context.Background(), k8s.ExtractNamespacedName(&es), &updated))
+			require.Equal(t, tt.wantAnnotation, updated.Annotations[ManagedSnapshotLifecyclePoliciesAnnotationName])
+
+			_, withStatus := state.Apply()
+			require.NotNil(t, withStatus)
+			index := withStatus.Status.Conditions.Index(esv1.SnapshotLifecyclePoliciesApplied)
+			if !tt.wantCondition {
+				require.Equal(t, -1, index)
+				return
+			}
+			require.NotEqual(t, -1, index)
+			require.Equal(t, corev1.ConditionTrue, withStatus.Status.Conditions[index].Status)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/managed"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/reconcile"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+// ReconcileRepositories registers in Elasticsearch the snapshot repositories declared in the Elasticsearch
+// specification, updates the ones whose definition changed, and unregisters the ones previously registered by the
+// operator which are not declared anymore. Repositories registered by the user directly in Elasticsearch are left
+// untouched. The outcome is reported in the SnapshotRepositoriesRegistered condition.
+func ReconcileRepositories(
+	ctx context.Context,
+	c k8s.Client,
+	es *esv1.Elasticsearch,
+	esClient esclient.Client,
+	state *reconcile.State,
+) error {
+	outcome, err := managed.Reconcile(ctx, c, es, repositories(*es, esClient), state)
+	if err != nil {
+		return err
+	}
+	return outcome.Report(state)
+}
+
+// repositories gives access to the snapshot repositories declared in the given Elasticsearch specification.
+func repositories(es esv1.Elasticsearch, esClient esclient.Client) managed.Resources {
+	declared := make(map[string]esclient.SnapshotRepository, len(es.Spec.SnapshotRepositories))
+	names := make([]string, 0, len(es.Spec.SnapshotRepositories))
+	for _, repository := range es.Spec.SnapshotRepositories {
+		declared[repository.Name] = toRepository(repository)
+		names = append(names, repository.Name)
+	}
+	return managed.Resources{
+		AnnotationName: ManagedSnapshotRepositoriesAnnotationName,
+		Kind:           "snapshot repository",
+		Description:    "snapshot repositories",
+		ConditionType:  esv1.SnapshotRepositoriesRegistered,
+		SuccessMsg:     "%d snapshot repositories registered",
+		TakeOver:       true,
+		Expected:       names,
+		Get: func(ctx context.Context) (map[string]interface{}, error) {
+			inEs, err := esClient.GetSnapshotRepositories(ctx)
+			if err != nil {
+				return nil, err
+			}
+			result := make(map[string]interface{}, len(inEs))
+			for name, repository := range inEs {
+				result[name] = repository
+			}
+			return result, nil
+		},
+		Equal: func(name string, actual interface{}) (bool, error) {
+			return repositoryEqual(declared[name], actual.(esclient.SnapshotRepository)), nil
+		},
+		Upsert: func(ctx context.Context, name string, _ interface{}) error {
+			return esClient.UpsertSnapshotRepository(ctx, name, declared[name])
+		},
+		Delete: esClient.DeleteSnapshotRepository,
+	}
+}
+
+// toRepository returns the definition of the given repository expected by Elasticsearch.
+func toRepository(repository esv1.SnapshotRepository) esclient.SnapshotRepository {
+	settings := map[string]interface{}{}
+	if repository.Settings != nil && repository.Settings.Data != nil {
+		settings = repository.Settings.Data
+	}
+	return esclient.SnapshotRepository{Type: string(repository.Type), Settings: settings}
+}
+
+// repositoryEqual compares the expected definition of a repository with the one returned by Elasticsearch, which
+// returns the settings values as strings, possibly nested.
+func repositoryEqual(expected, actual esclient.SnapshotRepository) bool {
+	if expected.Type != actual.Type {
+		return false
+	}
+	expectedSettings := flatten("", expected.Settings, map[string]string{})
+	actualSettings := flatten("", actual.Settings, map[string]string{})
+	if len(expectedSettings) != len(actualSettings) {
+		return false
+	}
+	for k, v := range expectedSettings {
+		if actualSettings[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// flatten collects the values of the given settings with their dotted keys.
+func flatten(prefix string, settings map[string]interface{}, into map[string]string) map[string]string {
+	for k, v := range settings {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, isMap := v.(map[string]interface{}); isMap {
+			flatten(key, nested, into)
+			continue
+		}
+		into[key] = fmt.Sprint(v)
+	}
+	return into
+}
@@ -0,0 +1,157 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/reconcile"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+type fakeESClient struct {
+	esclient.Client
+	repositories esclient.SnapshotRepositories
+	upserted     []string
+	deleted      []string
+	upsertErr    error
+}
+
+func (f *fakeESClient) GetSnapshotRepositories(_ context.Context) (esclient.SnapshotRepositories, error) {
+	return f.repositories, nil
+}
+
+func (f *fakeESClient) UpsertSnapshotRepository(_ context.Context, name string, repository esclient.SnapshotRepository) error {
+	if f.upsertErr != nil {
+		return f.upsertErr
+	}
+	f.upserted = append(f.upserted, name)
+	f.repositories[name] = repository
+	return nil
+}
+
+func (f *fakeESClient) DeleteSnapshotRepository(_ context.Context, name string) error {
+	f.deleted = append(f.deleted, name)
+	delete(f.repositories, name)
+	return nil
+}
+
+func TestReconcileRepositories(t *testing.T) {
+	gcsRepository := esv1.SnapshotRepository{
+		Name:     "gcs-backups",
+		Type:     esv1.GCSSnapshotRepository,
+		Settings: &commonv1.Config{Data: map[string]interface{}{"bucket": "my-bucket", "compress": true}},
+	}
+	tests := []struct {
+		name             string
+		repositories     []esv1.SnapshotRepository
+		annotation       string
+		inEs             esclient.SnapshotRepositories
+		upsertErr        error
+		wantErr          bool
+		wantUpserted     []string
+		wantDeleted      []string
+		wantAnnotation   string
+		wantCondition    corev1.ConditionStatus
+		wantNoConditions bool
+	}{
+		{
+			name:             "no repositories",
+			inEs:             esclient.SnapshotRepositories{},
+			wantNoConditions: true,
+		},
+		{
+			name:           "register a new repository",
+			repositories:   []esv1.SnapshotRepository{gcsRepository},
+			inEs:           esclient.SnapshotRepositories{},
+			wantUpserted:   []string{"gcs-backups"},
+			wantAnnotation: "gcs-backups",
+			wantCondition:  corev1.ConditionTrue,
+		},
+		{
+			name:         "repository already registered with the same settings returned as strings",
+			repositories: []esv1.SnapshotRepository{gcsRepository},
+			annotation:   "gcs-backups",
+			inEs: esclient.SnapshotRepositories{
+				"gcs-backups": {Type: "gcs", Settings: map[string]interface{}{"bucket": "my-bucket", "compress": "true"}},
+			},
+			wantAnnotation: "gcs-backups",
+			wantCondition:  corev1.ConditionTrue,
+		},
+		{
+			name:         "update a repository with different settings",
+			repositories: []esv1.SnapshotRepository{gcsRepository},
+			annotation:   "gcs-backups",
+			inEs: esclient.SnapshotRepositories{
+				"gcs-backups": {Type: "gcs", Settings: map[string]interface{}{"bucket": "other-bucket", "compress": "true"}},
+			},
+			wantUpserted:   []string{"gcs-backups"},
+			wantAnnotation: "gcs-backups",
+			wantCondition:  corev1.ConditionTrue,
+		},
+		{
+			name:       "unregister a repository removed from the spec but not the ones created by the user",
+			annotation: "gcs-backups",
+			inEs: esclient.SnapshotRepositories{
+				"gcs-backups":  {Type: "gcs", Settings: map[string]interface{}{"bucket": "my-bucket"}},
+				"user-backups": {Type: "fs", Settings: map[string]interface{}{"location": "/mnt/backups"}},
+			},
+			wantDeleted:    []string{"gcs-backups"},
+			wantAnnotation: "",
+			wantCondition:  corev1.ConditionTrue,
+		},
+		{
+			name:           "report registration failures",
+			repositories:   []esv1.SnapshotRepository{gcsRepository},
+			inEs:           esclient.SnapshotRepositories{},
+			upsertErr:      errors.New("repository verification exception"),
+			wantErr:        true,
+			wantAnnotation: "gcs-backups",
+			wantCondition:  corev1.ConditionFalse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := esv1.Elasticsearch{
+				ObjectMeta: metav1.ObjectMeta{Name: "es", Namespace: "ns"},
+				Spec:       esv1.ElasticsearchSpec{SnapshotRepositories: tt.repositories},
+			}
+			if tt.annotation != "" {
+				es.Annotations = map[string]string{ManagedSnapshotRepositoriesAnnotationName: tt.annotation}
+			}
+			c := k8s.NewFakeClient(&es)
+			esClient := &fakeESClient{repositories: tt.inEs, upsertErr: tt.upsertErr}
+			state := reconcile.MustNewState(es)
+
+			err := ReconcileRepositories(context.Background(), c, &es, esClient, state)
+			require.Equal(t, tt.wantErr, err != nil)
+			require.Equal(t, tt.wantUpserted, esClient.upserted)
+			require.Equal(t, tt.wantDeleted, esClient.deleted)
+
+			var updated esv1.Elasticsearch
+			require.NoError(t, c.Get(context.Background(), k8s.ExtractNamespacedName(&es), &updated))
+			require.Equal(t, tt.wantAnnotation, updated.Annotations[ManagedSnapshotRepositoriesAnnotationName])
+
+			_, withStatus := state.Apply()
+			require.NotNil(t, withStatus)
+			index := withStatus.Status.Conditions.Index(esv1.SnapshotRepositoriesRegistered)
+			if tt.wantNoConditions {
+				require.Equal(t, -1, index)
+				return
+			}
+			require.NotEqual(t, -1, index)
+			require.Equal(t, tt.wantCondition, withStatus.Status.Conditions[index].Status)
+		})
+	}
+}
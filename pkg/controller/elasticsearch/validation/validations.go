@@ -7,6 +7,7 @@ package validation
 import (
 	"fmt"
 	"net"
+	"regexp"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -19,24 +20,45 @@ import (
 	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
 	ulog "github.com/elastic/cloud-on-k8s/pkg/utils/log"
 	netutil "github.com/elastic/cloud-on-k8s/pkg/utils/net"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/stringsutil"
 )
 
 var log = ulog.Log.WithName("es-validation")
 
 const (
+	adDomainNameMsg          = "domainName is required for the active_directory type, and not allowed for the ldap type"
+	adUserDNTemplatesMsg     = "userDNTemplates are not allowed for the active_directory type"
 	autoscalingVersionMsg    = "autoscaling is not available in this version of Elasticsearch"
 	cfgInvalidMsg            = "Configuration invalid"
+	dataTierInOldVersionMsg  = "dataTier is not available in this version of Elasticsearch"
+	dataTierRoleConflictMsg  = "dataTier cannot be combined with the %s role in node.roles"
+	duplicateAutoFollowMsg   = "Auto-follow pattern names must be unique across remote clusters"
+	duplicateILMPoliciesMsg  = "Index lifecycle policy names must be unique"
 	duplicateNodeSets        = "NodeSet names must be unique"
+	duplicatePluginsMsg      = "Plugin names must be unique"
+	duplicateRealmMsg        = "Realm names must be unique, and differ from the file1 and native1 realms configured by the operator"
+	duplicateRealmOrderMsg   = "Realm orders must be unique, and differ from the -100 and -99 orders of the realms configured by the operator"
+	duplicateRepositoriesMsg = "Snapshot repository names must be unique"
+	duplicateSLMPoliciesMsg  = "Snapshot lifecycle policy names must be unique"
+	hotTierRequiredMsg       = "Elasticsearch needs to have at least one hot tier node when data tiers are declared"
 	invalidNamesErrMsg       = "Elasticsearch configuration would generate resources with invalid names"
+	invalidRealmNameMsg      = "Realm names can only contain alphanumeric characters, hyphens and underscores"
 	invalidSanIPErrMsg       = "Invalid SAN IP address. Must be a valid IPv4 address"
+	ldapBindCredentialsMsg   = "bindDN and bindPasswordSecretName must be set together"
+	ldapURLsRequiredMsg      = "At least one URL is required for the ldap type"
+	ldapUserSearchMsg        = "Exactly one of userSearch and userDNTemplates must be set for the ldap type"
 	masterRequiredMsg        = "Elasticsearch needs to have at least one master node"
 	mixedRoleConfigMsg       = "Detected a combination of node.roles and %s. Use only node.roles"
 	noDowngradesMsg          = "Downgrades are not supported"
 	nodeRolesInOldVersionMsg = "node.roles setting is not available in this version of Elasticsearch"
+	oidcInOldVersionMsg      = "OpenID Connect realms are not available in this version of Elasticsearch"
 	parseStoredVersionErrMsg = "Cannot parse current Elasticsearch version. String format must be {major}.{minor}.{patch}[-{label}]"
 	parseVersionErrMsg       = "Cannot parse Elasticsearch version. String format must be {major}.{minor}.{patch}[-{label}]"
+	pluginSourceConflictMsg  = "A plugin can be installed either from a URL or from a bundle, not both"
 	pvcImmutableErrMsg       = "volume claim templates can only have their storage requests increased, if the storage class allows volume expansion. Any other change is forbidden"
 	pvcNotMountedErrMsg      = "volume claim declared but volume not mounted in any container. Note that the Elasticsearch data volume should be named 'elasticsearch-data'"
+	remoteClusterSeedsMsg    = "A remote cluster is defined either by an elasticsearchRef or by seeds, not both"
+	slmInOldVersionMsg       = "snapshot lifecycle policies are not available in this version of Elasticsearch"
 	unsupportedConfigErrMsg  = "Configuration setting is reserved for internal use. User-configured use is unsupported"
 	unsupportedUpgradeMsg    = "Unsupported version upgrade path. Check the Elasticsearch documentation for supported upgrade paths."
 	unsupportedVersionMsg    = "Unsupported version"
@@ -67,8 +89,16 @@ func validations(exposedNodeLabels NodeLabels) []validation {
 		noUnknownFields,
 		validName,
 		hasCorrectNodeRoles,
+		validDataTiers,
 		supportedVersion,
 		validSanIP,
+		validPlugins,
+		validSnapshotRepositories,
+		validSnapshotLifecyclePolicies,
+		validIndexLifecyclePolicies,
+		validRemoteClusters,
+		validRealms,
+		validLDAPRealms,
 		validAutoscalingConfiguration,
 		validPVCNaming,
 		validMonitoring,
@@ -81,10 +111,14 @@ func validNodeLabels(proposed esv1.Elasticsearch, exposedNodeLabels NodeLabels)
 		if exposedNodeLabels.IsAllowed(nodeLabel) {
 			continue
 		}
+		path := field.NewPath("metadata").Child("annotations", esv1.DownwardNodeLabelsAnnotation)
+		if proposed.Spec.ZoneAwareness != nil && proposed.Spec.ZoneAwareness.TopologyKeyOrDefault() == nodeLabel {
+			path = field.NewPath("spec").Child("zoneAwareness", "topologyKey")
+		}
 		errs = append(
 			errs,
 			field.Invalid(
-				field.NewPath("metadata").Child("annotations", esv1.DownwardNodeLabelsAnnotation),
+				path,
 				nodeLabel,
 				notAllowedNodesLabelMsg,
 			),
@@ -150,7 +184,7 @@ func hasCorrectNodeRoles(es esv1.Elasticsearch) field.ErrorList {
 
 	for i, ns := range es.Spec.NodeSets {
 		cfg := esv1.ElasticsearchSettings{}
-		if err := esv1.UnpackConfig(ns.Config, v, &cfg); err != nil {
+		if err := ns.UnpackConfig(v, &cfg); err != nil {
 			errs = append(errs, field.Invalid(confField(i), ns.Config, cfgInvalidMsg))
 
 			continue
@@ -180,6 +214,53 @@ func hasCorrectNodeRoles(es esv1.Elasticsearch) field.ErrorList {
 	return errs
 }
 
+// validDataTiers checks that data tiers are only declared on Elasticsearch 7.10.0 and above, that they do not conflict
+// with the data roles set in node.roles, and that there is a hot tier if any NodeSet declares a data tier.
+func validDataTiers(es esv1.Elasticsearch) field.ErrorList {
+	v, err := version.Parse(es.Spec.Version)
+	if err != nil {
+		return field.ErrorList{field.Invalid(field.NewPath("spec").Child("version"), es.Spec.Version, parseVersionErrMsg)}
+	}
+
+	var errs field.ErrorList
+	seenTier, seenHot := false, false
+	for i, ns := range es.Spec.NodeSets {
+		tierField := field.NewPath("spec").Child("nodeSets").Index(i).Child("dataTier")
+		cfg := esv1.ElasticsearchSettings{}
+		if err := esv1.UnpackConfig(ns.Config, v, &cfg); err != nil {
+			// already reported by hasCorrectNodeRoles
+			continue
+		}
+
+		if ns.DataTier == "" {
+			// nodes without node.roles hold all the data roles
+			seenHot = seenHot || cfg.Node.HasRole(esv1.DataHotRole)
+			continue
+		}
+		seenTier = true
+		seenHot = seenHot || ns.DataTier == esv1.HotTier
+
+		if !v.GTE(version.From(7, 10, 0)) {
+			errs = append(errs, field.Invalid(tierField, ns.DataTier, dataTierInOldVersionMsg))
+			continue
+		}
+		if cfg.Node == nil {
+			continue
+		}
+		for _, role := range esv1.DataRoles {
+			if stringsutil.StringInSlice(string(role), cfg.Node.Roles) {
+				errs = append(errs, field.Forbidden(tierField, fmt.Sprintf(dataTierRoleConflictMsg, role)))
+			}
+		}
+	}
+
+	if seenTier && !seenHot {
+		errs = append(errs, field.Required(field.NewPath("spec").Child("nodeSets"), hotTierRequiredMsg))
+	}
+
+	return errs
+}
+
 func getNodeRoleAttrs(cfg esv1.ElasticsearchSettings) []string {
 	var nodeRoleAttrs []string
 
@@ -233,6 +314,177 @@ func validSanIP(es esv1.Elasticsearch) field.ErrorList {
 	return errs
 }
 
+// validPlugins checks that each plugin is declared once and from a single source.
+func validPlugins(es esv1.Elasticsearch) field.ErrorList {
+	var errs field.ErrorList
+	names := make(map[string]struct{}, len(es.Spec.Plugins))
+	for i, plugin := range es.Spec.Plugins {
+		pluginField := field.NewPath("spec").Child("plugins").Index(i)
+		if _, found := names[plugin.Name]; found {
+			errs = append(errs, field.Invalid(pluginField.Child("name"), plugin.Name, duplicatePluginsMsg))
+		}
+		names[plugin.Name] = struct{}{}
+		if plugin.URL != "" && plugin.Bundle != nil {
+			errs = append(errs, field.Invalid(pluginField, plugin.Name, pluginSourceConflictMsg))
+		}
+	}
+	return errs
+}
+
+// validSnapshotRepositories checks that each snapshot repository is declared once.
+func validSnapshotRepositories(es esv1.Elasticsearch) field.ErrorList {
+	var errs field.ErrorList
+	names := make(map[string]struct{}, len(es.Spec.SnapshotRepositories))
+	for i, repository := range es.Spec.SnapshotRepositories {
+		if _, found := names[repository.Name]; found {
+			errs = append(errs, field.Invalid(field.NewPath("spec").Child("snapshotRepositories").Index(i).Child("name"), repository.Name, duplicateRepositoriesMsg))
+		}
+		names[repository.Name] = struct{}{}
+	}
+	return errs
+}
+
+// validSnapshotLifecyclePolicies checks that each snapshot lifecycle policy is declared once, in a version of
+// Elasticsearch supporting them.
+func validSnapshotLifecyclePolicies(es esv1.Elasticsearch) field.ErrorList {
+	if len(es.Spec.SnapshotLifecyclePolicies) == 0 {
+		return nil
+	}
+	policiesField := field.NewPath("spec").Child("snapshotLifecyclePolicies")
+	v, err := version.Parse(es.Spec.Version)
+	if err != nil {
+		return field.ErrorList{field.Invalid(field.NewPath("spec").Child("version"), es.Spec.Version, parseVersionErrMsg)}
+	}
+	if !v.GTE(version.From(7, 4, 0)) {
+		return field.ErrorList{field.Forbidden(policiesField, slmInOldVersionMsg)}
+	}
+	var errs field.ErrorList
+	names := make(map[string]struct{}, len(es.Spec.SnapshotLifecyclePolicies))
+	for i, policy := range es.Spec.SnapshotLifecyclePolicies {
+		if _, found := names[policy.Name]; found {
+			errs = append(errs, field.Invalid(policiesField.Index(i).Child("name"), policy.Name, duplicateSLMPoliciesMsg))
+		}
+		names[policy.Name] = struct{}{}
+	}
+	return errs
+}
+
+// validIndexLifecyclePolicies checks that each index lifecycle policy is declared once.
+func validIndexLifecyclePolicies(es esv1.Elasticsearch) field.ErrorList {
+	var errs field.ErrorList
+	names := make(map[string]struct{}, len(es.Spec.IndexLifecyclePolicies))
+	for i, policy := range es.Spec.IndexLifecyclePolicies {
+		if _, found := names[policy.Name]; found {
+			errs = append(errs, field.Invalid(field.NewPath("spec").Child("indexLifecyclePolicies").Index(i).Child("name"), policy.Name, duplicateILMPoliciesMsg))
+		}
+		names[policy.Name] = struct{}{}
+	}
+	return errs
+}
+
+func validRemoteClusters(es esv1.Elasticsearch) field.ErrorList {
+	remoteClustersField := field.NewPath("spec").Child("remoteClusters")
+	var errs field.ErrorList
+	patterns := make(map[string]struct{})
+	for i, remoteCluster := range es.Spec.RemoteClusters {
+		if remoteCluster.ElasticsearchRef.IsDefined() && len(remoteCluster.Seeds) > 0 {
+			errs = append(errs, field.Forbidden(remoteClustersField.Index(i).Child("seeds"), remoteClusterSeedsMsg))
+		}
+		for j, pattern := range remoteCluster.AutoFollowPatterns {
+			if _, found := patterns[pattern.Name]; found {
+				errs = append(errs, field.Invalid(remoteClustersField.Index(i).Child("autoFollowPatterns").Index(j).Child("name"), pattern.Name, duplicateAutoFollowMsg))
+			}
+			patterns[pattern.Name] = struct{}{}
+		}
+	}
+	return errs
+}
+
+// declaredRealm is a realm declared in the auth section of the specification.
+type declaredRealm struct {
+	path  *field.Path
+	name  string
+	order int32
+}
+
+func declaredRealms(es esv1.Elasticsearch) []declaredRealm {
+	authField := field.NewPath("spec").Child("auth")
+	realms := make([]declaredRealm, 0, len(es.Spec.Auth.SAML)+len(es.Spec.Auth.OIDC)+len(es.Spec.Auth.LDAP))
+	for i, realm := range es.Spec.Auth.SAML {
+		realms = append(realms, declaredRealm{path: authField.Child("saml").Index(i), name: realm.Name, order: realm.Order})
+	}
+	for i, realm := range es.Spec.Auth.OIDC {
+		realms = append(realms, declaredRealm{path: authField.Child("oidc").Index(i), name: realm.Name, order: realm.Order})
+	}
+	for i, realm := range es.Spec.Auth.LDAP {
+		realms = append(realms, declaredRealm{path: authField.Child("ldap").Index(i), name: realm.Name, order: realm.Order})
+	}
+	return realms
+}
+
+var realmNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+func validRealms(es esv1.Elasticsearch) field.ErrorList {
+	var errs field.ErrorList
+	if len(es.Spec.Auth.OIDC) > 0 {
+		v, err := version.Parse(es.Spec.Version)
+		if err != nil {
+			return field.ErrorList{field.Invalid(field.NewPath("spec").Child("version"), es.Spec.Version, parseVersionErrMsg)}
+		}
+		if !v.GTE(version.From(7, 2, 0)) {
+			errs = append(errs, field.Forbidden(field.NewPath("spec").Child("auth", "oidc"), oidcInOldVersionMsg))
+		}
+	}
+	// the file and native realms are always configured by the operator
+	names := map[string]struct{}{"file1": {}, "native1": {}}
+	orders := map[int32]struct{}{-100: {}, -99: {}}
+	for _, realm := range declaredRealms(es) {
+		if !realmNameRegexp.MatchString(realm.name) {
+			errs = append(errs, field.Invalid(realm.path.Child("name"), realm.name, invalidRealmNameMsg))
+		}
+		if _, found := names[realm.name]; found {
+			errs = append(errs, field.Invalid(realm.path.Child("name"), realm.name, duplicateRealmMsg))
+		}
+		names[realm.name] = struct{}{}
+		if _, found := orders[realm.order]; found {
+			errs = append(errs, field.Invalid(realm.path.Child("order"), realm.order, duplicateRealmOrderMsg))
+		}
+		orders[realm.order] = struct{}{}
+	}
+	return errs
+}
+
+// validLDAPRealms checks that the LDAP realms have the fields required by their type.
+func validLDAPRealms(es esv1.Elasticsearch) field.ErrorList {
+	var errs field.ErrorList
+	for i, realm := range es.Spec.Auth.LDAP {
+		realmField := field.NewPath("spec").Child("auth", "ldap").Index(i)
+		if (realm.BindDN == "") != (realm.BindPasswordSecretName == "") {
+			errs = append(errs, field.Invalid(realmField.Child("bindDN"), realm.BindDN, ldapBindCredentialsMsg))
+		}
+		switch realm.TypeOrDefault() {
+		case esv1.LDAPDirectoryType:
+			if len(realm.URLs) == 0 {
+				errs = append(errs, field.Required(realmField.Child("urls"), ldapURLsRequiredMsg))
+			}
+			if (realm.UserSearch == nil) == (len(realm.UserDNTemplates) == 0) {
+				errs = append(errs, field.Invalid(realmField.Child("userSearch"), realm.UserSearch, ldapUserSearchMsg))
+			}
+			if realm.DomainName != "" {
+				errs = append(errs, field.Invalid(realmField.Child("domainName"), realm.DomainName, adDomainNameMsg))
+			}
+		case esv1.ActiveDirectoryType:
+			if realm.DomainName == "" {
+				errs = append(errs, field.Required(realmField.Child("domainName"), adDomainNameMsg))
+			}
+			if len(realm.UserDNTemplates) > 0 {
+				errs = append(errs, field.Forbidden(realmField.Child("userDNTemplates"), adUserDNTemplatesMsg))
+			}
+		}
+	}
+	return errs
+}
+
 func checkNodeSetNameUniqueness(es esv1.Elasticsearch) field.ErrorList {
 	var errs field.ErrorList
 	nodeSets := es.Spec.NodeSets
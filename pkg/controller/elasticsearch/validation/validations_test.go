@@ -15,6 +15,375 @@ import (
 	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
 )
 
+func Test_validPlugins(t *testing.T) {
+	tests := []struct {
+		name         string
+		plugins      []esv1.Plugin
+		expectErrors bool
+	}{
+		{
+			name:         "no plugins",
+			expectErrors: false,
+		},
+		{
+			name: "valid plugins",
+			plugins: []esv1.Plugin{
+				{Name: "analysis-icu"},
+				{Name: "custom", URL: "https://example.com/custom.zip"},
+				{Name: "offline", Bundle: &esv1.PluginBundle{ConfigMapName: "plugins", Key: "offline.zip"}},
+			},
+			expectErrors: false,
+		},
+		{
+			name:         "duplicate plugins",
+			plugins:      []esv1.Plugin{{Name: "analysis-icu"}, {Name: "analysis-icu"}},
+			expectErrors: true,
+		},
+		{
+			name: "both url and bundle",
+			plugins: []esv1.Plugin{
+				{Name: "custom", URL: "https://example.com/custom.zip", Bundle: &esv1.PluginBundle{ConfigMapName: "plugins", Key: "custom.zip"}},
+			},
+			expectErrors: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := esv1.Elasticsearch{Spec: esv1.ElasticsearchSpec{Version: "7.16.0", Plugins: tt.plugins}}
+			actual := validPlugins(es)
+			actualErrors := len(actual) > 0
+			if tt.expectErrors != actualErrors {
+				t.Errorf("failed validPlugins(). Name: %v, actual %v, wanted: %v, value: %v", tt.name, actual, tt.expectErrors, tt.plugins)
+			}
+		})
+	}
+}
+
+func Test_validSnapshotRepositories(t *testing.T) {
+	tests := []struct {
+		name         string
+		repositories []esv1.SnapshotRepository
+		expectErrors bool
+	}{
+		{
+			name:         "no repositories",
+			expectErrors: false,
+		},
+		{
+			name:         "distinct repositories",
+			repositories: []esv1.SnapshotRepository{{Name: "a", Type: esv1.GCSSnapshotRepository}, {Name: "b", Type: esv1.S3SnapshotRepository}},
+			expectErrors: false,
+		},
+		{
+			name:         "duplicate repositories",
+			repositories: []esv1.SnapshotRepository{{Name: "a", Type: esv1.GCSSnapshotRepository}, {Name: "a", Type: esv1.S3SnapshotRepository}},
+			expectErrors: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := esv1.Elasticsearch{Spec: esv1.ElasticsearchSpec{Version: "7.16.0", SnapshotRepositories: tt.repositories}}
+			actual := validSnapshotRepositories(es)
+			actualErrors := len(actual) > 0
+			if tt.expectErrors != actualErrors {
+				t.Errorf("failed validSnapshotRepositories(). Name: %v, actual %v, wanted: %v, value: %v", tt.name, actual, tt.expectErrors, tt.repositories)
+			}
+		})
+	}
+}
+
+func Test_validSnapshotLifecyclePolicies(t *testing.T) {
+	nightly := esv1.SnapshotLifecyclePolicy{Name: "nightly", Schedule: "0 30 1 * * ?", Repository: "backups"}
+	tests := []struct {
+		name         string
+		version      string
+		policies     []esv1.SnapshotLifecyclePolicy
+		expectErrors bool
+	}{
+		{
+			name:         "no policies",
+			version:      "6.8.0",
+			expectErrors: false,
+		},
+		{
+			name:         "valid policies",
+			version:      "7.16.0",
+			policies:     []esv1.SnapshotLifecyclePolicy{nightly, {Name: "hourly", Schedule: "0 0 * * * ?", Repository: "backups"}},
+			expectErrors: false,
+		},
+		{
+			name:         "duplicate policies",
+			version:      "7.16.0",
+			policies:     []esv1.SnapshotLifecyclePolicy{nightly, nightly},
+			expectErrors: true,
+		},
+		{
+			name:         "not available before 7.4.0",
+			version:      "7.3.2",
+			policies:     []esv1.SnapshotLifecyclePolicy{nightly},
+			expectErrors: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := esv1.Elasticsearch{Spec: esv1.ElasticsearchSpec{Version: tt.version, SnapshotLifecyclePolicies: tt.policies}}
+			actual := validSnapshotLifecyclePolicies(es)
+			actualErrors := len(actual) > 0
+			if tt.expectErrors != actualErrors {
+				t.Errorf("failed validSnapshotLifecyclePolicies(). Name: %v, actual %v, wanted: %v, value: %v", tt.name, actual, tt.expectErrors, tt.policies)
+			}
+		})
+	}
+}
+
+func Test_validIndexLifecyclePolicies(t *testing.T) {
+	logs := esv1.IndexLifecyclePolicy{Name: "logs", Phases: &commonv1.Config{Data: map[string]interface{}{"delete": map[string]interface{}{"min_age": "30d"}}}}
+	tests := []struct {
+		name         string
+		policies     []esv1.IndexLifecyclePolicy
+		expectErrors bool
+	}{
+		{
+			name:         "no policies",
+			expectErrors: false,
+		},
+		{
+			name:         "valid policies",
+			policies:     []esv1.IndexLifecyclePolicy{logs, {Name: "metrics", Phases: logs.Phases}},
+			expectErrors: false,
+		},
+		{
+			name:         "duplicate policies",
+			policies:     []esv1.IndexLifecyclePolicy{logs, logs},
+			expectErrors: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := esv1.Elasticsearch{Spec: esv1.ElasticsearchSpec{Version: "7.16.0", IndexLifecyclePolicies: tt.policies}}
+			actual := validIndexLifecyclePolicies(es)
+			actualErrors := len(actual) > 0
+			if tt.expectErrors != actualErrors {
+				t.Errorf("failed validIndexLifecyclePolicies(). Name: %v, actual %v, wanted: %v, value: %v", tt.name, actual, tt.expectErrors, tt.policies)
+			}
+		})
+	}
+}
+
+func Test_validRemoteClusters(t *testing.T) {
+	logs := esv1.AutoFollowPattern{Name: "logs", LeaderIndexPatterns: []string{"logs-*"}}
+	tests := []struct {
+		name           string
+		remoteClusters []esv1.RemoteCluster
+		expectErrors   bool
+	}{
+		{
+			name:         "no remote clusters",
+			expectErrors: false,
+		},
+		{
+			name: "managed and external remote clusters with auto-follow patterns",
+			remoteClusters: []esv1.RemoteCluster{
+				{Name: "managed", ElasticsearchRef: commonv1.ObjectSelector{Name: "leader"}, AutoFollowPatterns: []esv1.AutoFollowPattern{logs}},
+				{Name: "external", Seeds: []string{"leader.example.com:9300"}},
+			},
+			expectErrors: false,
+		},
+		{
+			name: "both elasticsearchRef and seeds",
+			remoteClusters: []esv1.RemoteCluster{
+				{Name: "leader", ElasticsearchRef: commonv1.ObjectSelector{Name: "leader"}, Seeds: []string{"leader.example.com:9300"}},
+			},
+			expectErrors: true,
+		},
+		{
+			name: "duplicate auto-follow patterns across remote clusters",
+			remoteClusters: []esv1.RemoteCluster{
+				{Name: "leader", ElasticsearchRef: commonv1.ObjectSelector{Name: "leader"}, AutoFollowPatterns: []esv1.AutoFollowPattern{logs}},
+				{Name: "other", ElasticsearchRef: commonv1.ObjectSelector{Name: "other"}, AutoFollowPatterns: []esv1.AutoFollowPattern{logs}},
+			},
+			expectErrors: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := esv1.Elasticsearch{Spec: esv1.ElasticsearchSpec{Version: "7.16.0", RemoteClusters: tt.remoteClusters}}
+			actual := validRemoteClusters(es)
+			actualErrors := len(actual) > 0
+			if tt.expectErrors != actualErrors {
+				t.Errorf("failed validRemoteClusters(). Name: %v, actual %v, wanted: %v, value: %v", tt.name, actual, tt.expectErrors, tt.remoteClusters)
+			}
+		})
+	}
+}
+
+func Test_validRealms(t *testing.T) {
+	saml := func(name string, order int32) esv1.SAMLRealm {
+		return esv1.SAMLRealm{Name: name, Order: order}
+	}
+	oidc := func(name string, order int32) esv1.OIDCRealm {
+		return esv1.OIDCRealm{Name: name, Order: order}
+	}
+	tests := []struct {
+		name         string
+		version      string
+		auth         esv1.Auth
+		expectErrors bool
+	}{
+		{
+			name:         "no realms",
+			expectErrors: false,
+		},
+		{
+			name:         "valid realms",
+			auth:         esv1.Auth{SAML: []esv1.SAMLRealm{saml("saml1", 2), saml("saml_2", 3)}, OIDC: []esv1.OIDCRealm{oidc("oidc1", 4)}},
+			expectErrors: false,
+		},
+		{
+			name:         "invalid name",
+			auth:         esv1.Auth{SAML: []esv1.SAMLRealm{saml("saml.1", 2)}},
+			expectErrors: true,
+		},
+		{
+			name:         "duplicate names",
+			auth:         esv1.Auth{SAML: []esv1.SAMLRealm{saml("saml1", 2), saml("saml1", 3)}},
+			expectErrors: true,
+		},
+		{
+			name:         "duplicate names across realm types",
+			auth:         esv1.Auth{SAML: []esv1.SAMLRealm{saml("sso", 2)}, OIDC: []esv1.OIDCRealm{oidc("sso", 3)}},
+			expectErrors: true,
+		},
+		{
+			name:         "name of a realm configured by the operator",
+			auth:         esv1.Auth{SAML: []esv1.SAMLRealm{saml("native1", 2)}},
+			expectErrors: true,
+		},
+		{
+			name:         "duplicate orders",
+			auth:         esv1.Auth{SAML: []esv1.SAMLRealm{saml("saml1", 2), saml("saml2", 2)}},
+			expectErrors: true,
+		},
+		{
+			name:         "duplicate names across OIDC and LDAP realms",
+			auth:         esv1.Auth{OIDC: []esv1.OIDCRealm{oidc("corp", 2)}, LDAP: []esv1.LDAPRealm{{Name: "corp", Order: 3}}},
+			expectErrors: true,
+		},
+		{
+			name:         "duplicate orders across realm types",
+			auth:         esv1.Auth{SAML: []esv1.SAMLRealm{saml("saml1", 2)}, OIDC: []esv1.OIDCRealm{oidc("oidc1", 2)}},
+			expectErrors: true,
+		},
+		{
+			name:         "order of a realm configured by the operator",
+			auth:         esv1.Auth{SAML: []esv1.SAMLRealm{saml("saml1", -100)}},
+			expectErrors: true,
+		},
+		{
+			name:         "OIDC realm before 7.2.0",
+			version:      "7.1.1",
+			auth:         esv1.Auth{OIDC: []esv1.OIDCRealm{oidc("oidc1", 2)}},
+			expectErrors: true,
+		},
+		{
+			name:         "SAML realm before 7.2.0",
+			version:      "6.8.0",
+			auth:         esv1.Auth{SAML: []esv1.SAMLRealm{saml("saml1", 2)}},
+			expectErrors: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ver := tt.version
+			if ver == "" {
+				ver = "7.16.0"
+			}
+			es := esv1.Elasticsearch{Spec: esv1.ElasticsearchSpec{Version: ver, Auth: tt.auth}}
+			actual := validRealms(es)
+			actualErrors := len(actual) > 0
+			if tt.expectErrors != actualErrors {
+				t.Errorf("failed validRealms(). Name: %v, actual %v, wanted: %v, value: %v", tt.name, actual, tt.expectErrors, tt.auth)
+			}
+		})
+	}
+}
+
+func Test_validLDAPRealms(t *testing.T) {
+	userSearch := &esv1.LDAPUserSearch{BaseDN: "ou=users,dc=example,dc=com"}
+	tests := []struct {
+		name         string
+		realm        esv1.LDAPRealm
+		expectErrors bool
+	}{
+		{
+			name:         "valid LDAP realm with a user search",
+			realm:        esv1.LDAPRealm{URLs: []string{"ldaps://ldap.example.com:636"}, BindDN: "cn=es,dc=example,dc=com", BindPasswordSecretName: "ldap-bind", UserSearch: userSearch},
+			expectErrors: false,
+		},
+		{
+			name:         "valid LDAP realm with user DN templates",
+			realm:        esv1.LDAPRealm{Type: esv1.LDAPDirectoryType, URLs: []string{"ldaps://ldap.example.com:636"}, UserDNTemplates: []string{"cn={0},ou=users,dc=example,dc=com"}},
+			expectErrors: false,
+		},
+		{
+			name:         "LDAP realm without URL",
+			realm:        esv1.LDAPRealm{UserSearch: userSearch},
+			expectErrors: true,
+		},
+		{
+			name:         "LDAP realm without user search nor user DN templates",
+			realm:        esv1.LDAPRealm{URLs: []string{"ldaps://ldap.example.com:636"}},
+			expectErrors: true,
+		},
+		{
+			name:         "LDAP realm with both user search and user DN templates",
+			realm:        esv1.LDAPRealm{URLs: []string{"ldaps://ldap.example.com:636"}, UserSearch: userSearch, UserDNTemplates: []string{"cn={0},ou=users,dc=example,dc=com"}},
+			expectErrors: true,
+		},
+		{
+			name:         "LDAP realm with a domain name",
+			realm:        esv1.LDAPRealm{URLs: []string{"ldaps://ldap.example.com:636"}, UserSearch: userSearch, DomainName: "example.com"},
+			expectErrors: true,
+		},
+		{
+			name:         "bind DN without bind password",
+			realm:        esv1.LDAPRealm{URLs: []string{"ldaps://ldap.example.com:636"}, UserSearch: userSearch, BindDN: "cn=es,dc=example,dc=com"},
+			expectErrors: true,
+		},
+		{
+			name:         "bind password without bind DN",
+			realm:        esv1.LDAPRealm{URLs: []string{"ldaps://ldap.example.com:636"}, UserSearch: userSearch, BindPasswordSecretName: "ldap-bind"},
+			expectErrors: true,
+		},
+		{
+			name:         "valid Active Directory realm",
+			realm:        esv1.LDAPRealm{Type: esv1.ActiveDirectoryType, DomainName: "example.com"},
+			expectErrors: false,
+		},
+		{
+			name:         "Active Directory realm without domain name",
+			realm:        esv1.LDAPRealm{Type: esv1.ActiveDirectoryType, URLs: []string{"ldaps://dc.example.com:636"}},
+			expectErrors: true,
+		},
+		{
+			name:         "Active Directory realm with user DN templates",
+			realm:        esv1.LDAPRealm{Type: esv1.ActiveDirectoryType, DomainName: "example.com", UserDNTemplates: []string{"cn={0},ou=users,dc=example,dc=com"}},
+			expectErrors: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.realm.Name = "ldap1"
+			es := esv1.Elasticsearch{Spec: esv1.ElasticsearchSpec{Version: "7.16.0", Auth: esv1.Auth{LDAP: []esv1.LDAPRealm{tt.realm}}}}
+			actual := validLDAPRealms(es)
+			actualErrors := len(actual) > 0
+			if tt.expectErrors != actualErrors {
+				t.Errorf("failed validLDAPRealms(). Name: %v, actual %v, wanted: %v, value: %v", tt.name, actual, tt.expectErrors, tt.realm)
+			}
+		})
+	}
+}
+
 func Test_checkNodeSetNameUniqueness(t *testing.T) {
 	type args struct {
 		name         string
@@ -157,6 +526,14 @@ func Test_hasCorrectNodeRoles(t *testing.T) {
 			name: "valid configuration (node roles)",
 			es:   esWithRoles("7.9.0", 4, m{esv1.NodeRoles: []esv1.NodeRole{esv1.MasterRole, esv1.DataRole}}, m{esv1.NodeRoles: []esv1.NodeRole{esv1.DataRole}}, m{esv1.NodeRoles: []esv1.NodeRole{esv1.RemoteClusterClientRole}}),
 		},
+		{
+			name: "single data tier without node.roles",
+			es: func() esv1.Elasticsearch {
+				x := esWithRoles("7.16.0", 3, nil)
+				x.Spec.NodeSets[0].DataTier = esv1.HotTier
+				return x
+			}(),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -169,6 +546,73 @@ func Test_hasCorrectNodeRoles(t *testing.T) {
 	}
 }
 
+func Test_validDataTiers(t *testing.T) {
+	withNodeSets := func(version string, nodeSets ...esv1.NodeSet) esv1.Elasticsearch {
+		x := es(version)
+		x.Spec.NodeSets = nodeSets
+		return x
+	}
+	roles := func(roles ...esv1.NodeRole) *commonv1.Config {
+		return &commonv1.Config{Data: map[string]interface{}{esv1.NodeRoles: roles}}
+	}
+
+	tests := []struct {
+		name         string
+		es           esv1.Elasticsearch
+		expectErrors bool
+	}{
+		{
+			name: "no data tier",
+			es:   withNodeSets("7.16.0", esv1.NodeSet{Config: roles(esv1.MasterRole, esv1.DataWarmRole)}),
+		},
+		{
+			name: "valid data tiers",
+			es: withNodeSets("7.16.0",
+				esv1.NodeSet{Config: roles(esv1.MasterRole)},
+				esv1.NodeSet{DataTier: esv1.HotTier},
+				esv1.NodeSet{DataTier: esv1.WarmTier, Config: roles(esv1.IngestRole)},
+			),
+		},
+		{
+			name: "hot tier declared with node.roles",
+			es: withNodeSets("7.16.0",
+				esv1.NodeSet{Config: roles(esv1.MasterRole, esv1.DataHotRole, esv1.DataContentRole)},
+				esv1.NodeSet{DataTier: esv1.ColdTier},
+			),
+		},
+		{
+			name: "no hot tier",
+			es: withNodeSets("7.16.0",
+				esv1.NodeSet{Config: roles(esv1.MasterRole)},
+				esv1.NodeSet{DataTier: esv1.WarmTier},
+			),
+			expectErrors: true,
+		},
+		{
+			name: "data role conflicting with the data tier",
+			es: withNodeSets("7.16.0",
+				esv1.NodeSet{Config: roles(esv1.MasterRole)},
+				esv1.NodeSet{DataTier: esv1.HotTier, Config: roles(esv1.DataWarmRole)},
+			),
+			expectErrors: true,
+		},
+		{
+			name:         "data tier on older version",
+			es:           withNodeSets("7.9.0", esv1.NodeSet{DataTier: esv1.HotTier}),
+			expectErrors: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := validDataTiers(tt.es)
+			hasErrors := len(result) > 0
+			if tt.expectErrors != hasErrors {
+				t.Errorf("expectedErrors=%t hasErrors=%t result=%+v", tt.expectErrors, hasErrors, result)
+			}
+		})
+	}
+}
+
 func Test_supportedVersion(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -536,6 +980,29 @@ func Test_validNodeLabels(t *testing.T) {
 				exposedNodeLabels: []string{"topology.kubernetes.io/*", "failure-domain.beta.kubernetes.io/*"},
 			},
 		},
+		{
+			name: "Invalid zone awareness topology key",
+			args: args{
+				proposed: esv1.Elasticsearch{
+					Spec: esv1.ElasticsearchSpec{
+						ZoneAwareness: &esv1.ZoneAwareness{TopologyKey: "failure-domain.beta.kubernetes.io/zone"},
+					},
+				},
+				exposedNodeLabels: []string{"topology.kubernetes.io/*"},
+			},
+			expectErrors: true,
+		},
+		{
+			name: "Valid default zone awareness topology key",
+			args: args{
+				proposed: esv1.Elasticsearch{
+					Spec: esv1.ElasticsearchSpec{
+						ZoneAwareness: &esv1.ZoneAwareness{},
+					},
+				},
+				exposedNodeLabels: []string{"topology.kubernetes.io/*"},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,128 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package indextemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+)
+
+const indexSettingsPrefix = "index."
+
+// componentTemplateEqual compares the component template expected for a resource with the one returned by
+// Elasticsearch.
+func componentTemplateEqual(expected, actual esclient.ComponentTemplate) (bool, error) {
+	return templateIncluded(expected.Template, actual.Template)
+}
+
+// indexTemplateEqual compares the index template expected for a resource with the one returned by Elasticsearch.
+func indexTemplateEqual(expected, actual esclient.IndexTemplate) (bool, error) {
+	if !stringsEqual(expected.IndexPatterns, actual.IndexPatterns) ||
+		!stringsEqual(expected.ComposedOf, actual.ComposedOf) ||
+		priority(expected.Priority) != priority(actual.Priority) ||
+		(expected.DataStream == nil) != (actual.DataStream == nil) {
+		return false, nil
+	}
+	return templateIncluded(expected.Template, actual.Template)
+}
+
+// templateIncluded returns true if the settings, mappings and aliases returned by Elasticsearch include the declared
+// ones. Elasticsearch returns the settings flattened, prefixed with "index." and with string values: the declared
+// settings are normalized the same way before being compared.
+func templateIncluded(expected, actual map[string]interface{}) (bool, error) {
+	// compare through the JSON representation, regardless of the Go types of the declared values
+	bytes, err := json.Marshal(expected)
+	if err != nil {
+		return false, err
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(bytes, &normalized); err != nil {
+		return false, err
+	}
+	if settings, exists := normalized["settings"]; exists {
+		flat := make(map[string]interface{})
+		flattenSettings("", settings, flat)
+		normalized["settings"] = flat
+	}
+	return includes(actual, normalized), nil
+}
+
+// flattenSettings stores in flat the given settings under their dotted name, prefixed with "index.", with their
+// values converted to strings.
+func flattenSettings(prefix string, settings interface{}, flat map[string]interface{}) {
+	if nested, isMap := settings.(map[string]interface{}); isMap {
+		for key, value := range nested {
+			flattenSettings(prefix+key+".", value, flat)
+		}
+		return
+	}
+	name := strings.TrimSuffix(prefix, ".")
+	if !strings.HasPrefix(name, indexSettingsPrefix) {
+		name = indexSettingsPrefix + name
+	}
+	flat[name] = settingValue(settings)
+}
+
+// settingValue converts a setting value to the string representation returned by Elasticsearch.
+func settingValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i := range v {
+			values[i] = settingValue(v[i])
+		}
+		return values
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// includes returns true if actual holds all the values of expected, ignoring the additional keys of objects.
+func includes(actual, expected interface{}) bool {
+	expectedMap, isMap := expected.(map[string]interface{})
+	if !isMap {
+		return reflect.DeepEqual(actual, expected)
+	}
+	actualMap, isMap := actual.(map[string]interface{})
+	if !isMap {
+		return false
+	}
+	for key, value := range expectedMap {
+		if !includes(actualMap[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func priority(p *int64) int64 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
@@ -0,0 +1,52 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package indextemplate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_templateIncluded(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected map[string]interface{}
+		actual   map[string]interface{}
+		want     bool
+	}{
+		{
+			name:     "nested settings with non-string values",
+			expected: map[string]interface{}{"settings": map[string]interface{}{"number_of_replicas": 0, "index": map[string]interface{}{"hidden": true}}},
+			actual:   map[string]interface{}{"settings": map[string]interface{}{"index.number_of_replicas": "0", "index.hidden": "true"}},
+			want:     true,
+		},
+		{
+			name:     "different setting value",
+			expected: map[string]interface{}{"settings": map[string]interface{}{"number_of_replicas": 1}},
+			actual:   map[string]interface{}{"settings": map[string]interface{}{"index.number_of_replicas": "0"}},
+			want:     false,
+		},
+		{
+			name:     "missing mapping",
+			expected: map[string]interface{}{"mappings": map[string]interface{}{"properties": map[string]interface{}{"message": map[string]interface{}{"type": "text"}}}},
+			actual:   map[string]interface{}{"mappings": map[string]interface{}{"properties": map[string]interface{}{}}},
+			want:     false,
+		},
+		{
+			name:     "no declared template",
+			expected: nil,
+			actual:   map[string]interface{}{"aliases": map[string]interface{}{"logs": map[string]interface{}{}}},
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := templateIncluded(tt.expected, tt.actual)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
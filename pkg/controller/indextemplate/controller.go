@@ -0,0 +1,286 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package indextemplate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esv1alpha1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1alpha1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/operator"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/tracing"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/version"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/user"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+	ulog "github.com/elastic/cloud-on-k8s/pkg/utils/log"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/net"
+)
+
+var (
+	// pendingRequeue is used while waiting for the Elasticsearch cluster to be available.
+	pendingRequeue = reconcile.Result{Requeue: true, RequeueAfter: 30 * time.Second}
+	// retryRequeue is used for the templates rejected by Elasticsearch or in conflict with existing ones, which may be
+	// applied once the component templates they are composed of exist, or once the conflicting templates are deleted.
+	retryRequeue = reconcile.Result{Requeue: true, RequeueAfter: time.Minute}
+
+	// minVersion is the first version of Elasticsearch supporting component and composable index templates.
+	minVersion = version.From(7, 8, 0)
+)
+
+type EsClientProvider func(ctx context.Context, c k8s.Client, dialer net.Dialer, es esv1.Elasticsearch) (esclient.Client, error)
+
+// AddComponentTemplate creates a new ComponentTemplate Controller and adds it to the Manager with default RBAC. The
+// Manager will set fields on the Controller and Start it when the Manager is Started.
+func AddComponentTemplate(mgr manager.Manager, params operator.Parameters) error {
+	return add(mgr, params, componentTemplates)
+}
+
+// AddIndexTemplate creates a new IndexTemplate Controller and adds it to the Manager with default RBAC. The Manager
+// will set fields on the Controller and Start it when the Manager is Started.
+func AddIndexTemplate(mgr manager.Manager, params operator.Parameters) error {
+	return add(mgr, params, indexTemplates)
+}
+
+func add(mgr manager.Manager, params operator.Parameters, kind templateKind) error {
+	r := newReconciler(mgr, params, kind)
+	c, err := common.NewController(mgr, kind.controllerName, r, params)
+	if err != nil {
+		return err
+	}
+	// Watch for changes to the templates of this kind
+	return c.Watch(&source.Kind{Type: kind.newResource()}, &handler.EnqueueRequestForObject{})
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager, params operator.Parameters, kind templateKind) *ReconcileTemplates {
+	return &ReconcileTemplates{
+		Client:           mgr.GetClient(),
+		Parameters:       params,
+		kind:             kind,
+		esClientProvider: user.NewElasticsearchClient,
+		log:              ulog.Log.WithName(kind.controllerName),
+	}
+}
+
+var _ reconcile.Reconciler = &ReconcileTemplates{}
+
+// ReconcileTemplates applies in Elasticsearch the templates specified by the resources of a kind of template.
+type ReconcileTemplates struct {
+	k8s.Client
+	operator.Parameters
+	kind             templateKind
+	esClientProvider EsClientProvider
+	log              logr.Logger
+	// iteration is the number of times this controller has run its Reconcile method
+	iteration uint64
+}
+
+// Reconcile creates or updates in the referenced Elasticsearch cluster the template specified in a resource, reverting
+// the changes made to it through the Elasticsearch API. A template with the same name which was not created from the
+// resource is never overwritten. The templates created from the resource under another name or in another cluster of
+// the namespace, including once the resource is deleted, are deleted.
+func (r *ReconcileTemplates) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	defer common.LogReconciliationRun(r.log, request, "template_name", &r.iteration)()
+	tx, ctx := tracing.NewTransaction(ctx, r.Tracer, request.NamespacedName, r.kind.transactionType)
+	defer tracing.EndTransaction(tx)
+
+	resource := r.kind.newResource()
+	if err := r.Client.Get(ctx, request.NamespacedName, resource); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, tracing.CaptureError(ctx, r.deleteOrphans(ctx, request.NamespacedName, ""))
+		}
+		return reconcile.Result{}, tracing.CaptureError(ctx, err)
+	}
+
+	if common.IsUnmanaged(resource) {
+		r.log.Info("Object is currently not managed by this controller. Skipping reconciliation", "namespace", resource.GetNamespace(), "template_name", resource.GetName())
+		return reconcile.Result{}, nil
+	}
+
+	status := resource.GetTemplateStatus()
+	status.ObservedGeneration = resource.GetGeneration()
+	results, err := r.doReconcile(ctx, resource, &status)
+	if err != nil {
+		return reconcile.Result{}, tracing.CaptureError(ctx, err)
+	}
+	if err := r.updateStatus(ctx, resource, status); err != nil {
+		if apierrors.IsConflict(err) {
+			return reconcile.Result{Requeue: true}, nil
+		}
+		return reconcile.Result{}, tracing.CaptureError(ctx, err)
+	}
+	// the template may have been created in another cluster before the reference to the cluster changed
+	if err := r.deleteOrphans(ctx, request.NamespacedName, resource.ElasticsearchKey().Name); err != nil {
+		return reconcile.Result{}, tracing.CaptureError(ctx, err)
+	}
+	return results, nil
+}
+
+func (r *ReconcileTemplates) doReconcile(
+	ctx context.Context,
+	resource templateResource,
+	status *esv1alpha1.TemplateStatus,
+) (reconcile.Result, error) {
+	var es esv1.Elasticsearch
+	if err := r.Client.Get(ctx, resource.ElasticsearchKey(), &es); err != nil {
+		if apierrors.IsNotFound(err) {
+			setPhase(status, esv1alpha1.TemplatePendingPhase, fmt.Sprintf("Elasticsearch cluster %s does not exist", resource.ElasticsearchKey().Name))
+			return pendingRequeue, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if es.Status.Phase != esv1.ElasticsearchReadyPhase {
+		setPhase(status, esv1alpha1.TemplatePendingPhase, fmt.Sprintf("Waiting for Elasticsearch cluster %s to be ready", es.Name))
+		return pendingRequeue, nil
+	}
+	supported, err := supportsTemplates(es)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !supported {
+		setPhase(status, esv1alpha1.TemplateFailedPhase, fmt.Sprintf("Composable templates are not available in version %s of Elasticsearch", es.Spec.Version))
+		return retryRequeue, nil
+	}
+
+	esClient, err := r.esClientProvider(ctx, r.Client, r.Dialer, es)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	defer esClient.Close()
+
+	templates, err := r.kind.fetch(ctx, esClient)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	owner := k8s.ExtractNamespacedName(resource).String()
+	name := resource.TemplateName()
+	// the resource may have specified another name before
+	if err := r.deleteOwned(ctx, es, esClient, templates, owner, name); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	expected := r.kind.expected(resource)
+	if actual, exists := templates[name]; exists {
+		if actual.owner() != owner {
+			setPhase(status, esv1alpha1.TemplateConflictPhase,
+				fmt.Sprintf("Template %s already exists in Elasticsearch cluster %s and was not created from this resource", name, es.Name))
+			return retryRequeue, nil
+		}
+		upToDate, err := actual.matches(expected)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if upToDate {
+			setPhase(status, esv1alpha1.TemplateAppliedPhase, "")
+			return reconcile.Result{}, nil
+		}
+		r.log.Info("Updating template", "namespace", es.Namespace, "es_name", es.Name, "template", name)
+	} else {
+		r.log.Info("Creating template", "namespace", es.Namespace, "es_name", es.Name, "template", name)
+	}
+	if err := r.kind.upsert(ctx, esClient, name, expected); err != nil {
+		if !esclient.Is4xx(err) {
+			return reconcile.Result{}, err
+		}
+		setPhase(status, esv1alpha1.TemplateFailedPhase, fmt.Sprintf("Template %s rejected by Elasticsearch: %s", name, err.Error()))
+		return retryRequeue, nil
+	}
+	setPhase(status, esv1alpha1.TemplateAppliedPhase, "")
+	return reconcile.Result{}, nil
+}
+
+// deleteOrphans deletes the templates created from the given resource in the ready Elasticsearch clusters of its
+// namespace, except in the cluster with the given name.
+func (r *ReconcileTemplates) deleteOrphans(ctx context.Context, resource types.NamespacedName, except string) error {
+	var clusters esv1.ElasticsearchList
+	if err := r.Client.List(ctx, &clusters, client.InNamespace(resource.Namespace)); err != nil {
+		return err
+	}
+	var errs []error
+	for _, es := range clusters.Items {
+		if es.Name == except || es.Status.Phase != esv1.ElasticsearchReadyPhase {
+			continue
+		}
+		if supported, err := supportsTemplates(es); err != nil || !supported {
+			continue
+		}
+		if err := r.deleteOrphansIn(ctx, es, resource.String()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (r *ReconcileTemplates) deleteOrphansIn(ctx context.Context, es esv1.Elasticsearch, owner string) error {
+	esClient, err := r.esClientProvider(ctx, r.Client, r.Dialer, es)
+	if err != nil {
+		return err
+	}
+	defer esClient.Close()
+	templates, err := r.kind.fetch(ctx, esClient)
+	if err != nil {
+		return err
+	}
+	return r.deleteOwned(ctx, es, esClient, templates, owner, "")
+}
+
+// deleteOwned deletes the given templates created from the given resource, except the one with the given name.
+func (r *ReconcileTemplates) deleteOwned(
+	ctx context.Context,
+	es esv1.Elasticsearch,
+	esClient esclient.Client,
+	templates map[string]template,
+	owner string,
+	except string,
+) error {
+	for name, t := range templates {
+		if name == except || t.owner() != owner {
+			continue
+		}
+		r.log.Info("Deleting template", "namespace", es.Namespace, "es_name", es.Name, "template", name)
+		if err := r.kind.delete(ctx, esClient, name); err != nil && !esclient.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateStatus updates the status of the given resource, which is kept up-to-date with the updated resource.
+func (r *ReconcileTemplates) updateStatus(ctx context.Context, resource templateResource, status esv1alpha1.TemplateStatus) error {
+	if reflect.DeepEqual(resource.GetTemplateStatus(), status) {
+		return nil
+	}
+	resource.SetTemplateStatus(status)
+	return r.Client.Status().Update(ctx, resource)
+}
+
+func setPhase(status *esv1alpha1.TemplateStatus, phase esv1alpha1.TemplatePhase, msg string) {
+	status.Phase = phase
+	status.Message = msg
+}
+
+// supportsTemplates returns true if the given Elasticsearch cluster supports component and composable index templates.
+func supportsTemplates(es esv1.Elasticsearch) (bool, error) {
+	v, err := version.Parse(es.Spec.Version)
+	if err != nil {
+		return false, err
+	}
+	return v.GTE(minVersion), nil
+}
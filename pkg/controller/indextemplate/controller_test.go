@@ -0,0 +1,323 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package indextemplate
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esv1alpha1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1alpha1"
+	controllerscheme "github.com/elastic/cloud-on-k8s/pkg/controller/common/scheme"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+	ulog "github.com/elastic/cloud-on-k8s/pkg/utils/log"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/net"
+)
+
+type fakeESClient struct {
+	esclient.Client
+	components     esclient.ComponentTemplates
+	indexTemplates esclient.IndexTemplates
+	upsertErr      error
+	calls          []string
+}
+
+func (f *fakeESClient) GetComponentTemplates(_ context.Context) (esclient.ComponentTemplates, error) {
+	return f.components, nil
+}
+
+func (f *fakeESClient) UpsertComponentTemplate(_ context.Context, name string, template esclient.ComponentTemplate) error {
+	f.calls = append(f.calls, "upsert "+name)
+	if f.upsertErr != nil {
+		return f.upsertErr
+	}
+	f.components[name] = template
+	return nil
+}
+
+func (f *fakeESClient) DeleteComponentTemplate(_ context.Context, name string) error {
+	f.calls = append(f.calls, "delete "+name)
+	delete(f.components, name)
+	return nil
+}
+
+func (f *fakeESClient) GetIndexTemplates(_ context.Context) (esclient.IndexTemplates, error) {
+	return f.indexTemplates, nil
+}
+
+func (f *fakeESClient) UpsertIndexTemplate(_ context.Context, name string, template esclient.IndexTemplate) error {
+	f.calls = append(f.calls, "upsert "+name)
+	if f.upsertErr != nil {
+		return f.upsertErr
+	}
+	f.indexTemplates[name] = template
+	return nil
+}
+
+func (f *fakeESClient) DeleteIndexTemplate(_ context.Context, name string) error {
+	f.calls = append(f.calls, "delete "+name)
+	delete(f.indexTemplates, name)
+	return nil
+}
+
+func (f *fakeESClient) Close() {}
+
+// fakeESClients returns the fake client of each Elasticsearch cluster, by name.
+type fakeESClients map[string]*fakeESClient
+
+func (f fakeESClients) provider(_ context.Context, _ k8s.Client, _ net.Dialer, es esv1.Elasticsearch) (esclient.Client, error) {
+	return f[es.Name], nil
+}
+
+func elasticsearch(name string, version string, phase esv1.ElasticsearchOrchestrationPhase) *esv1.Elasticsearch {
+	return &esv1.Elasticsearch{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name},
+		Spec:       esv1.ElasticsearchSpec{Version: version},
+		Status:     esv1.ElasticsearchStatus{Phase: phase},
+	}
+}
+
+func newComponentTemplate(name string, esName string) *esv1alpha1.ComponentTemplate {
+	return &esv1alpha1.ComponentTemplate{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "logs-settings", Generation: 2},
+		Spec: esv1alpha1.ComponentTemplateSpec{
+			ElasticsearchRef: esv1alpha1.ElasticsearchRef{Name: esName},
+			Name:             name,
+			Template: &commonv1.Config{Data: map[string]interface{}{
+				"settings": map[string]interface{}{"number_of_shards": 1, "index": map[string]interface{}{"lifecycle.name": "logs"}},
+			}},
+		},
+	}
+}
+
+func TestReconcileTemplates_Reconcile(t *testing.T) {
+	controllerscheme.SetupScheme()
+	owned := map[string]interface{}{managedByMetaKey: managedByMetaValue, resourceMetaKey: "ns/logs-settings"}
+	// as returned by Elasticsearch, with flat settings
+	settingsInEs := esclient.ComponentTemplate{
+		Template: map[string]interface{}{"settings": map[string]interface{}{"index.number_of_shards": "1", "index.lifecycle.name": "logs"}},
+		Meta:     owned,
+	}
+	settingsChanged := esclient.ComponentTemplate{
+		Template: map[string]interface{}{"settings": map[string]interface{}{"index.number_of_shards": "3", "index.lifecycle.name": "logs"}},
+		Meta:     owned,
+	}
+	createdByUser := esclient.ComponentTemplate{Template: settingsInEs.Template}
+	logs := &esv1alpha1.IndexTemplate{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "logs", Generation: 2},
+		Spec: esv1alpha1.IndexTemplateSpec{
+			ElasticsearchRef: esv1alpha1.ElasticsearchRef{Name: "es"},
+			IndexPatterns:    []string{"logs-*"},
+			ComposedOf:       []string{"logs-settings"},
+			DataStream:       true,
+		},
+	}
+	rejected := &esclient.APIError{Status: "400 Bad Request", StatusCode: http.StatusBadRequest}
+
+	tests := []struct {
+		name       string
+		kind       templateKind
+		resource   templateResource
+		objects    []runtime.Object
+		esClients  fakeESClients
+		wantResult reconcile.Result
+		wantStatus esv1alpha1.TemplateStatus
+		wantCalls  map[string][]string
+	}{
+		{
+			name:       "Elasticsearch cluster does not exist",
+			kind:       componentTemplates,
+			resource:   newComponentTemplate("", "es"),
+			esClients:  fakeESClients{},
+			wantResult: pendingRequeue,
+			wantStatus: esv1alpha1.TemplateStatus{
+				Phase:              esv1alpha1.TemplatePendingPhase,
+				Message:            "Elasticsearch cluster es does not exist",
+				ObservedGeneration: 2,
+			},
+		},
+		{
+			name:       "Elasticsearch cluster not ready",
+			kind:       componentTemplates,
+			resource:   newComponentTemplate("", "es"),
+			objects:    []runtime.Object{elasticsearch("es", "7.16.0", esv1.ElasticsearchApplyingChangesPhase)},
+			esClients:  fakeESClients{"es": {components: esclient.ComponentTemplates{}}},
+			wantResult: pendingRequeue,
+			wantStatus: esv1alpha1.TemplateStatus{
+				Phase:              esv1alpha1.TemplatePendingPhase,
+				Message:            "Waiting for Elasticsearch cluster es to be ready",
+				ObservedGeneration: 2,
+			},
+		},
+		{
+			name:       "Composable templates not available",
+			kind:       componentTemplates,
+			resource:   newComponentTemplate("", "es"),
+			objects:    []runtime.Object{elasticsearch("es", "7.7.1", esv1.ElasticsearchReadyPhase)},
+			esClients:  fakeESClients{"es": {components: esclient.ComponentTemplates{}}},
+			wantResult: retryRequeue,
+			wantStatus: esv1alpha1.TemplateStatus{
+				Phase:              esv1alpha1.TemplateFailedPhase,
+				Message:            "Composable templates are not available in version 7.7.1 of Elasticsearch",
+				ObservedGeneration: 2,
+			},
+		},
+		{
+			name:       "Create the component template",
+			kind:       componentTemplates,
+			resource:   newComponentTemplate("", "es"),
+			objects:    []runtime.Object{elasticsearch("es", "7.16.0", esv1.ElasticsearchReadyPhase)},
+			esClients:  fakeESClients{"es": {components: esclient.ComponentTemplates{}}},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.TemplateStatus{Phase: esv1alpha1.TemplateAppliedPhase, ObservedGeneration: 2},
+			wantCalls:  map[string][]string{"es": {"upsert logs-settings"}},
+		},
+		{
+			name:       "Component template up to date, ignoring the normalization of the settings",
+			kind:       componentTemplates,
+			resource:   newComponentTemplate("", "es"),
+			objects:    []runtime.Object{elasticsearch("es", "7.16.0", esv1.ElasticsearchReadyPhase)},
+			esClients:  fakeESClients{"es": {components: esclient.ComponentTemplates{"logs-settings": settingsInEs}}},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.TemplateStatus{Phase: esv1alpha1.TemplateAppliedPhase, ObservedGeneration: 2},
+		},
+		{
+			name:       "Revert manual changes",
+			kind:       componentTemplates,
+			resource:   newComponentTemplate("", "es"),
+			objects:    []runtime.Object{elasticsearch("es", "7.16.0", esv1.ElasticsearchReadyPhase)},
+			esClients:  fakeESClients{"es": {components: esclient.ComponentTemplates{"logs-settings": settingsChanged}}},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.TemplateStatus{Phase: esv1alpha1.TemplateAppliedPhase, ObservedGeneration: 2},
+			wantCalls:  map[string][]string{"es": {"upsert logs-settings"}},
+		},
+		{
+			name:       "Do not overwrite a template created by the user",
+			kind:       componentTemplates,
+			resource:   newComponentTemplate("", "es"),
+			objects:    []runtime.Object{elasticsearch("es", "7.16.0", esv1.ElasticsearchReadyPhase)},
+			esClients:  fakeESClients{"es": {components: esclient.ComponentTemplates{"logs-settings": createdByUser}}},
+			wantResult: retryRequeue,
+			wantStatus: esv1alpha1.TemplateStatus{
+				Phase:              esv1alpha1.TemplateConflictPhase,
+				Message:            "Template logs-settings already exists in Elasticsearch cluster es and was not created from this resource",
+				ObservedGeneration: 2,
+			},
+		},
+		{
+			name:       "Template rejected by Elasticsearch",
+			kind:       componentTemplates,
+			resource:   newComponentTemplate("", "es"),
+			objects:    []runtime.Object{elasticsearch("es", "7.16.0", esv1.ElasticsearchReadyPhase)},
+			esClients:  fakeESClients{"es": {components: esclient.ComponentTemplates{}, upsertErr: rejected}},
+			wantResult: retryRequeue,
+			wantStatus: esv1alpha1.TemplateStatus{
+				Phase:              esv1alpha1.TemplateFailedPhase,
+				Message:            "Template logs-settings rejected by Elasticsearch: " + rejected.Error(),
+				ObservedGeneration: 2,
+			},
+			wantCalls: map[string][]string{"es": {"upsert logs-settings"}},
+		},
+		{
+			name:     "Delete the template created under the previous name, but not the ones created by the user",
+			kind:     componentTemplates,
+			resource: newComponentTemplate("logs-settings-v2", "es"),
+			objects:  []runtime.Object{elasticsearch("es", "7.16.0", esv1.ElasticsearchReadyPhase)},
+			esClients: fakeESClients{"es": {components: esclient.ComponentTemplates{
+				"logs-settings": settingsInEs,
+				"user":          createdByUser,
+			}}},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.TemplateStatus{Phase: esv1alpha1.TemplateAppliedPhase, ObservedGeneration: 2},
+			wantCalls:  map[string][]string{"es": {"delete logs-settings", "upsert logs-settings-v2"}},
+		},
+		{
+			name:     "Delete the template created in the previously referenced cluster",
+			kind:     componentTemplates,
+			resource: newComponentTemplate("", "es"),
+			objects: []runtime.Object{
+				elasticsearch("es", "7.16.0", esv1.ElasticsearchReadyPhase),
+				elasticsearch("previous", "7.16.0", esv1.ElasticsearchReadyPhase),
+				elasticsearch("old", "6.8.0", esv1.ElasticsearchReadyPhase),
+			},
+			esClients: fakeESClients{
+				"es":       {components: esclient.ComponentTemplates{}},
+				"previous": {components: esclient.ComponentTemplates{"logs-settings": settingsInEs}},
+			},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.TemplateStatus{Phase: esv1alpha1.TemplateAppliedPhase, ObservedGeneration: 2},
+			wantCalls:  map[string][]string{"es": {"upsert logs-settings"}, "previous": {"delete logs-settings"}},
+		},
+		{
+			name: "Delete the template once the resource is deleted",
+			kind: componentTemplates,
+			objects: []runtime.Object{
+				elasticsearch("es", "7.16.0", esv1.ElasticsearchReadyPhase),
+				elasticsearch("not-ready", "7.16.0", esv1.ElasticsearchApplyingChangesPhase),
+			},
+			esClients: fakeESClients{"es": {components: esclient.ComponentTemplates{
+				"logs-settings": settingsInEs,
+				"user":          createdByUser,
+			}}},
+			wantResult: reconcile.Result{},
+			wantCalls:  map[string][]string{"es": {"delete logs-settings"}},
+		},
+		{
+			name:       "Create the index template",
+			kind:       indexTemplates,
+			resource:   logs,
+			objects:    []runtime.Object{elasticsearch("es", "7.16.0", esv1.ElasticsearchReadyPhase)},
+			esClients:  fakeESClients{"es": {indexTemplates: esclient.IndexTemplates{}}},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.TemplateStatus{Phase: esv1alpha1.TemplateAppliedPhase, ObservedGeneration: 2},
+			wantCalls:  map[string][]string{"es": {"upsert logs"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := tt.objects
+			key := types.NamespacedName{Namespace: "ns", Name: "logs-settings"}
+			if tt.resource != nil {
+				objects = append(objects, tt.resource)
+				key = k8s.ExtractNamespacedName(tt.resource)
+			}
+			c := k8s.NewFakeClient(objects...)
+			r := &ReconcileTemplates{
+				Client:           c,
+				kind:             tt.kind,
+				esClientProvider: tt.esClients.provider,
+				log:              ulog.Log,
+			}
+			result, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+			require.NoError(t, err)
+			require.Equal(t, tt.wantResult, result)
+			for name, esClient := range tt.esClients {
+				require.Equal(t, tt.wantCalls[name], esClient.calls, name)
+			}
+			if tt.resource == nil {
+				return
+			}
+
+			resource := tt.kind.newResource()
+			require.NoError(t, c.Get(context.Background(), key, resource))
+			require.Equal(t, tt.wantStatus, resource.GetTemplateStatus())
+			if tt.wantStatus.Phase == esv1alpha1.TemplateAppliedPhase {
+				// the template is recorded as created from the resource
+				templates, err := tt.kind.fetch(context.Background(), tt.esClients["es"])
+				require.NoError(t, err)
+				require.Equal(t, key.String(), templates[resource.TemplateName()].owner())
+			}
+		})
+	}
+}
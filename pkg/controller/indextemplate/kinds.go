@@ -0,0 +1,172 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package indextemplate
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	esv1alpha1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1alpha1"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+const (
+	// managedByMetaKey is set to managedByMetaValue in the _meta field of the templates created by the operator.
+	managedByMetaKey   = "managed_by"
+	managedByMetaValue = "eck"
+	// resourceMetaKey holds in the _meta field of a template the namespaced name of the resource it was created from.
+	resourceMetaKey = "resource"
+)
+
+// templateResource is a ComponentTemplate or an IndexTemplate resource.
+type templateResource interface {
+	client.Object
+	ElasticsearchKey() types.NamespacedName
+	TemplateName() string
+	GetTemplateStatus() esv1alpha1.TemplateStatus
+	SetTemplateStatus(status esv1alpha1.TemplateStatus)
+}
+
+// template is a component or an index template, as returned and expected by Elasticsearch.
+type template interface {
+	// owner returns the namespaced name of the resource the template was created from, or an empty string if it was
+	// not created by the operator.
+	owner() string
+	// matches returns true if the template holds the definition of the expected one.
+	matches(expected template) (bool, error)
+}
+
+// templateKind gives access to the resources of a kind of template, and to the matching API of Elasticsearch.
+type templateKind struct {
+	// controllerName is the name of the controller of the resources of this kind.
+	controllerName string
+	// transactionType is the type of the APM transactions of the controller.
+	transactionType string
+	// newResource returns an empty resource of this kind.
+	newResource func() templateResource
+	// expected returns the template expected in Elasticsearch for the given resource.
+	expected func(resource templateResource) template
+	// fetch returns the templates of this kind in Elasticsearch, indexed by name.
+	fetch func(ctx context.Context, esClient esclient.Client) (map[string]template, error)
+	// upsert creates or updates a template of this kind in Elasticsearch.
+	upsert func(ctx context.Context, esClient esclient.Client, name string, t template) error
+	// delete deletes a template of this kind from Elasticsearch.
+	delete func(ctx context.Context, esClient esclient.Client, name string) error
+}
+
+var componentTemplates = templateKind{
+	controllerName:  "componenttemplate-controller",
+	transactionType: "componenttemplate",
+	newResource: func() templateResource {
+		return &esv1alpha1.ComponentTemplate{}
+	},
+	expected: func(resource templateResource) template {
+		spec := resource.(*esv1alpha1.ComponentTemplate).Spec
+		expected := esclient.ComponentTemplate{Template: map[string]interface{}{}, Meta: ownerMeta(resource)}
+		if spec.Template != nil {
+			expected.Template = spec.Template.Data
+		}
+		return componentTemplate(expected)
+	},
+	fetch: func(ctx context.Context, esClient esclient.Client) (map[string]template, error) {
+		templates, err := esClient.GetComponentTemplates(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result := make(map[string]template, len(templates))
+		for name, t := range templates {
+			result[name] = componentTemplate(t)
+		}
+		return result, nil
+	},
+	upsert: func(ctx context.Context, esClient esclient.Client, name string, t template) error {
+		return esClient.UpsertComponentTemplate(ctx, name, esclient.ComponentTemplate(t.(componentTemplate)))
+	},
+	delete: func(ctx context.Context, esClient esclient.Client, name string) error {
+		return esClient.DeleteComponentTemplate(ctx, name)
+	},
+}
+
+var indexTemplates = templateKind{
+	controllerName:  "indextemplate-controller",
+	transactionType: "indextemplate",
+	newResource: func() templateResource {
+		return &esv1alpha1.IndexTemplate{}
+	},
+	expected: func(resource templateResource) template {
+		spec := resource.(*esv1alpha1.IndexTemplate).Spec
+		expected := esclient.IndexTemplate{
+			IndexPatterns: spec.IndexPatterns,
+			ComposedOf:    spec.ComposedOf,
+			Priority:      spec.Priority,
+			Meta:          ownerMeta(resource),
+		}
+		if spec.Template != nil {
+			expected.Template = spec.Template.Data
+		}
+		if spec.DataStream {
+			expected.DataStream = &esclient.IndexTemplateDataStream{}
+		}
+		return indexTemplate(expected)
+	},
+	fetch: func(ctx context.Context, esClient esclient.Client) (map[string]template, error) {
+		templates, err := esClient.GetIndexTemplates(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result := make(map[string]template, len(templates))
+		for name, t := range templates {
+			result[name] = indexTemplate(t)
+		}
+		return result, nil
+	},
+	upsert: func(ctx context.Context, esClient esclient.Client, name string, t template) error {
+		return esClient.UpsertIndexTemplate(ctx, name, esclient.IndexTemplate(t.(indexTemplate)))
+	},
+	delete: func(ctx context.Context, esClient esclient.Client, name string) error {
+		return esClient.DeleteIndexTemplate(ctx, name)
+	},
+}
+
+type componentTemplate esclient.ComponentTemplate
+
+func (t componentTemplate) owner() string {
+	return ownerOf(t.Meta)
+}
+
+func (t componentTemplate) matches(expected template) (bool, error) {
+	return componentTemplateEqual(esclient.ComponentTemplate(expected.(componentTemplate)), esclient.ComponentTemplate(t))
+}
+
+type indexTemplate esclient.IndexTemplate
+
+func (t indexTemplate) owner() string {
+	return ownerOf(t.Meta)
+}
+
+func (t indexTemplate) matches(expected template) (bool, error) {
+	return indexTemplateEqual(esclient.IndexTemplate(expected.(indexTemplate)), esclient.IndexTemplate(t))
+}
+
+// ownerMeta returns the metadata identifying the given resource as the owner of a template.
+func ownerMeta(resource templateResource) map[string]interface{} {
+	return map[string]interface{}{
+		managedByMetaKey: managedByMetaValue,
+		resourceMetaKey:  k8s.ExtractNamespacedName(resource).String(),
+	}
+}
+
+// ownerOf returns the namespaced name of the resource recorded in the given metadata of a template, or an empty string
+// if the template was not created by the operator.
+func ownerOf(meta map[string]interface{}) string {
+	if meta[managedByMetaKey] != managedByMetaValue {
+		return ""
+	}
+	resource, _ := meta[resourceMetaKey].(string)
+	return resource
+}
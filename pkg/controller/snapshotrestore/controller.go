@@ -0,0 +1,289 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package snapshotrestore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esv1alpha1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1alpha1"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/events"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/operator"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/common/tracing"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/user"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+	ulog "github.com/elastic/cloud-on-k8s/pkg/utils/log"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/net"
+)
+
+const (
+	controllerName = "snapshotrestore-controller"
+)
+
+var (
+	log = ulog.Log.WithName(controllerName)
+
+	// pendingRequeue is used while waiting for the Elasticsearch cluster or the snapshot to be available.
+	pendingRequeue = reconcile.Result{Requeue: true, RequeueAfter: 30 * time.Second}
+	// progressRequeue is used to poll the progress of an ongoing restore.
+	progressRequeue = reconcile.Result{Requeue: true, RequeueAfter: 10 * time.Second}
+	// noRecoveryGracePeriod is the time after the start of a restore past which no recovery from the snapshot means
+	// that there is no shard to restore, or that the recoveries are not reported anymore once completed, for example
+	// after the restored shards were relocated.
+	noRecoveryGracePeriod = time.Minute
+)
+
+type EsClientProvider func(ctx context.Context, c k8s.Client, dialer net.Dialer, es esv1.Elasticsearch) (esclient.Client, error)
+
+// Add creates a new SnapshotRestore Controller and adds it to the Manager with default RBAC. The Manager will set fields
+// on the Controller and Start it when the Manager is Started.
+func Add(mgr manager.Manager, params operator.Parameters) error {
+	r := newReconciler(mgr, params)
+	c, err := common.NewController(mgr, controllerName, r, params)
+	if err != nil {
+		return err
+	}
+	// Watch for changes to SnapshotRestore
+	return c.Watch(&source.Kind{Type: &esv1alpha1.SnapshotRestore{}}, &handler.EnqueueRequestForObject{})
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager, params operator.Parameters) *ReconcileSnapshotRestore {
+	return &ReconcileSnapshotRestore{
+		Client:           mgr.GetClient(),
+		Parameters:       params,
+		esClientProvider: user.NewElasticsearchClient,
+		recorder:         mgr.GetEventRecorderFor(controllerName),
+	}
+}
+
+var _ reconcile.Reconciler = &ReconcileSnapshotRestore{}
+
+// ReconcileSnapshotRestore restores Elasticsearch snapshots as specified by SnapshotRestore resources.
+type ReconcileSnapshotRestore struct {
+	k8s.Client
+	operator.Parameters
+	esClientProvider EsClientProvider
+	recorder         record.EventRecorder
+	// iteration is the number of times this controller has run its Reconcile method
+	iteration uint64
+}
+
+// Reconcile starts the restore of the snapshot specified in a SnapshotRestore once the referenced Elasticsearch cluster
+// is ready, then tracks the recovery of the restored shards until completion. A restore is performed at most once:
+// the resource is left untouched once it reached the Completed or Failed phase.
+func (r *ReconcileSnapshotRestore) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	defer common.LogReconciliationRun(log, request, "restore_name", &r.iteration)()
+	tx, ctx := tracing.NewTransaction(ctx, r.Tracer, request.NamespacedName, "snapshotrestore")
+	defer tracing.EndTransaction(tx)
+
+	var restore esv1alpha1.SnapshotRestore
+	if err := r.Client.Get(ctx, request.NamespacedName, &restore); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, tracing.CaptureError(ctx, err)
+	}
+
+	if common.IsUnmanaged(&restore) {
+		log.Info("Object is currently not managed by this controller. Skipping reconciliation", "namespace", restore.Namespace, "restore_name", restore.Name)
+		return reconcile.Result{}, nil
+	}
+
+	if restore.Status.IsFinished() {
+		return reconcile.Result{}, nil
+	}
+
+	status := restore.Status.DeepCopy()
+	results, err := r.doReconcile(ctx, &restore, status)
+	if err != nil {
+		if apierrors.IsConflict(err) {
+			return reconcile.Result{Requeue: true}, nil
+		}
+		return reconcile.Result{}, tracing.CaptureError(ctx, err)
+	}
+	if err := r.updateStatus(ctx, &restore, *status); err != nil {
+		if apierrors.IsConflict(err) {
+			return reconcile.Result{Requeue: true}, nil
+		}
+		return reconcile.Result{}, tracing.CaptureError(ctx, err)
+	}
+	return results, nil
+}
+
+func (r *ReconcileSnapshotRestore) doReconcile(
+	ctx context.Context,
+	restore *esv1alpha1.SnapshotRestore,
+	status *esv1alpha1.SnapshotRestoreStatus,
+) (reconcile.Result, error) {
+	var es esv1.Elasticsearch
+	if err := r.Client.Get(ctx, restore.ElasticsearchKey(), &es); err != nil {
+		if apierrors.IsNotFound(err) {
+			setPending(status, fmt.Sprintf("Elasticsearch cluster %s does not exist", restore.Spec.ElasticsearchRef.Name))
+			return pendingRequeue, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if es.Status.Phase != esv1.ElasticsearchReadyPhase {
+		setPending(status, fmt.Sprintf("Waiting for Elasticsearch cluster %s to be ready", es.Name))
+		return pendingRequeue, nil
+	}
+
+	esClient, err := r.esClientProvider(ctx, r.Client, r.Dialer, es)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	defer esClient.Close()
+
+	if status.Phase == esv1alpha1.SnapshotRestoreInProgressPhase && status.StartTime != nil {
+		return r.updateProgress(ctx, esClient, *restore, status)
+	}
+	return r.startRestore(ctx, esClient, restore, status)
+}
+
+// startRestore resolves the snapshot to restore and submits the restore request to Elasticsearch. The InProgress phase
+// is persisted before the submission, so that a restore accepted by Elasticsearch is never submitted again if the
+// status cannot be updated afterwards: a restore in progress without start time is only submitted again if there is
+// no recovery from its snapshot.
+func (r *ReconcileSnapshotRestore) startRestore(
+	ctx context.Context,
+	esClient esclient.Client,
+	restore *esv1alpha1.SnapshotRestore,
+	status *esv1alpha1.SnapshotRestoreStatus,
+) (reconcile.Result, error) {
+	if status.Phase == esv1alpha1.SnapshotRestoreInProgressPhase {
+		// the restore may have been accepted by Elasticsearch before the status was updated
+		recoveries, err := esClient.GetRecoveries(ctx)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if total, _ := restoreProgress(recoveries, restore.Spec.Repository, status.Snapshot); total > 0 {
+			now := metav1.Now()
+			status.Message = ""
+			status.StartTime = &now
+			return progressRequeue, nil
+		}
+		return r.submitRestore(ctx, esClient, *restore, status)
+	}
+
+	snapshot := restore.Spec.Snapshot
+	if restore.Spec.IsPattern() {
+		latest, err := latestSuccessfulSnapshot(ctx, esClient, restore.Spec.Repository, restore.Spec.Snapshot)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if latest == "" {
+			setPending(status, fmt.Sprintf("No successful snapshot matching %s in repository %s", restore.Spec.Snapshot, restore.Spec.Repository))
+			return pendingRequeue, nil
+		}
+		snapshot = latest
+	}
+
+	status.Phase = esv1alpha1.SnapshotRestoreInProgressPhase
+	status.Snapshot = snapshot
+	status.Message = fmt.Sprintf("Submitting the restore of snapshot %s", snapshot)
+	if err := r.updateStatus(ctx, restore, *status); err != nil {
+		return reconcile.Result{}, err
+	}
+	return r.submitRestore(ctx, esClient, *restore, status)
+}
+
+// submitRestore submits the request to restore the snapshot of the status to Elasticsearch.
+func (r *ReconcileSnapshotRestore) submitRestore(
+	ctx context.Context,
+	esClient esclient.Client,
+	restore esv1alpha1.SnapshotRestore,
+	status *esv1alpha1.SnapshotRestoreStatus,
+) (reconcile.Result, error) {
+	snapshot := status.Snapshot
+	log.Info("Starting snapshot restore",
+		"namespace", restore.Namespace, "restore_name", restore.Name,
+		"repository", restore.Spec.Repository, "snapshot", snapshot)
+	err := esClient.RestoreSnapshot(ctx, restore.Spec.Repository, snapshot, restoreRequest(restore.Spec))
+	now := metav1.Now()
+	if err != nil {
+		if !esclient.Is4xx(err) {
+			return reconcile.Result{}, err
+		}
+		// the restore was rejected by Elasticsearch, retrying with the same specification would not help
+		msg := fmt.Sprintf("Restore of snapshot %s rejected by Elasticsearch: %s", snapshot, err.Error())
+		r.recorder.Event(&restore, corev1.EventTypeWarning, events.EventReasonRestoreFailed, msg)
+		status.Phase = esv1alpha1.SnapshotRestoreFailedPhase
+		status.Message = msg
+		status.CompletionTime = &now
+		return reconcile.Result{}, nil
+	}
+
+	r.recorder.Eventf(&restore, corev1.EventTypeNormal, events.EventReasonRestoreStarted, "Started restore of snapshot %s", snapshot)
+	status.Message = ""
+	status.StartTime = &now
+	return progressRequeue, nil
+}
+
+// updateProgress updates the status with the progress of the shard recoveries from the snapshot being restored.
+func (r *ReconcileSnapshotRestore) updateProgress(
+	ctx context.Context,
+	esClient esclient.Client,
+	restore esv1alpha1.SnapshotRestore,
+	status *esv1alpha1.SnapshotRestoreStatus,
+) (reconcile.Result, error) {
+	recoveries, err := esClient.GetRecoveries(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	total, restored := restoreProgress(recoveries, restore.Spec.Repository, status.Snapshot)
+	if total == 0 && time.Since(status.StartTime.Time) < noRecoveryGracePeriod {
+		return progressRequeue, nil
+	}
+	if total > 0 {
+		// keep the last known progress once the recoveries are not reported anymore
+		status.TotalShards = total
+		status.RestoredShards = restored
+	}
+	if restored < total {
+		return progressRequeue, nil
+	}
+
+	log.Info("Snapshot restore completed",
+		"namespace", restore.Namespace, "restore_name", restore.Name,
+		"repository", restore.Spec.Repository, "snapshot", status.Snapshot)
+	r.recorder.Eventf(&restore, corev1.EventTypeNormal, events.EventReasonRestoreCompleted, "Restored %d shards from snapshot %s", status.RestoredShards, status.Snapshot)
+	now := metav1.Now()
+	status.Phase = esv1alpha1.SnapshotRestoreCompletedPhase
+	status.CompletionTime = &now
+	return reconcile.Result{}, nil
+}
+
+// updateStatus updates the status of the given restore, which is kept up-to-date with the updated resource.
+func (r *ReconcileSnapshotRestore) updateStatus(
+	ctx context.Context,
+	restore *esv1alpha1.SnapshotRestore,
+	status esv1alpha1.SnapshotRestoreStatus,
+) error {
+	if reflect.DeepEqual(restore.Status, status) {
+		return nil
+	}
+	restore.Status = *status.DeepCopy()
+	return r.Client.Status().Update(ctx, restore)
+}
+
+func setPending(status *esv1alpha1.SnapshotRestoreStatus, msg string) {
+	status.Phase = esv1alpha1.SnapshotRestorePendingPhase
+	status.Message = msg
+}
@@ -0,0 +1,328 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package snapshotrestore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	commonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
+	esv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
+	esv1alpha1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1alpha1"
+	controllerscheme "github.com/elastic/cloud-on-k8s/pkg/controller/common/scheme"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+	"github.com/elastic/cloud-on-k8s/pkg/utils/net"
+)
+
+type fakeESClient struct {
+	esclient.Client
+	snapshots  esclient.Snapshots
+	restoreErr error
+	recoveries esclient.Recoveries
+
+	restored map[string]map[string]interface{}
+}
+
+func (f *fakeESClient) GetSnapshots(_ context.Context, _ string, _ string) (esclient.Snapshots, error) {
+	return f.snapshots, nil
+}
+
+func (f *fakeESClient) RestoreSnapshot(_ context.Context, _ string, snapshot string, request map[string]interface{}) error {
+	if f.restoreErr != nil {
+		return f.restoreErr
+	}
+	if f.restored == nil {
+		f.restored = map[string]map[string]interface{}{}
+	}
+	f.restored[snapshot] = request
+	return nil
+}
+
+func (f *fakeESClient) GetRecoveries(_ context.Context) (esclient.Recoveries, error) {
+	return f.recoveries, nil
+}
+
+func (f *fakeESClient) Close() {}
+
+func (f *fakeESClient) provider(_ context.Context, _ k8s.Client, _ net.Dialer, _ esv1.Elasticsearch) (esclient.Client, error) {
+	return f, nil
+}
+
+func elasticsearch(phase esv1.ElasticsearchOrchestrationPhase) *esv1.Elasticsearch {
+	return &esv1.Elasticsearch{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "es"},
+		Status:     esv1.ElasticsearchStatus{Phase: phase},
+	}
+}
+
+func snapshotRestore(snapshot string, status esv1alpha1.SnapshotRestoreStatus) *esv1alpha1.SnapshotRestore {
+	return &esv1alpha1.SnapshotRestore{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "restore"},
+		Spec: esv1alpha1.SnapshotRestoreSpec{
+			ElasticsearchRef: esv1alpha1.ElasticsearchRef{Name: "es"},
+			Repository:       "backups",
+			Snapshot:         snapshot,
+			Indices:          []string{"logs", "metrics"},
+			Config:           &commonv1.Config{Data: map[string]interface{}{"include_global_state": false}},
+		},
+		Status: status,
+	}
+}
+
+func snapshotRecovery(snapshot string, stage string) esclient.ShardRecovery {
+	return esclient.ShardRecovery{
+		Type:   esclient.SnapshotRecoveryType,
+		Stage:  stage,
+		Source: esclient.ShardRecoverySource{Repository: "backups", Snapshot: snapshot},
+	}
+}
+
+func TestReconcileSnapshotRestore_Reconcile(t *testing.T) {
+	controllerscheme.SetupScheme()
+	now := metav1.Now()
+	inProgress := esv1alpha1.SnapshotRestoreStatus{
+		Phase:     esv1alpha1.SnapshotRestoreInProgressPhase,
+		Snapshot:  "nightly-2",
+		StartTime: &now,
+	}
+	startedLongAgo := metav1.NewTime(now.Add(-time.Hour))
+	submitted := esv1alpha1.SnapshotRestoreStatus{
+		Phase:    esv1alpha1.SnapshotRestoreInProgressPhase,
+		Snapshot: "nightly-2",
+		Message:  "Submitting the restore of snapshot nightly-2",
+	}
+	completed := esv1alpha1.SnapshotRestoreStatus{
+		Phase:          esv1alpha1.SnapshotRestoreCompletedPhase,
+		Snapshot:       "nightly-1",
+		StartTime:      &now,
+		CompletionTime: &now,
+	}
+	rejected := &esclient.APIError{Status: "400 Bad Request", StatusCode: http.StatusBadRequest}
+	tests := []struct {
+		name         string
+		objects      []runtime.Object
+		esClient     *fakeESClient
+		wantResult   reconcile.Result
+		wantStatus   esv1alpha1.SnapshotRestoreStatus
+		wantRestored map[string]map[string]interface{}
+	}{
+		{
+			name:       "Elasticsearch cluster does not exist",
+			objects:    []runtime.Object{snapshotRestore("nightly-2", esv1alpha1.SnapshotRestoreStatus{})},
+			esClient:   &fakeESClient{},
+			wantResult: pendingRequeue,
+			wantStatus: esv1alpha1.SnapshotRestoreStatus{
+				Phase:   esv1alpha1.SnapshotRestorePendingPhase,
+				Message: "Elasticsearch cluster es does not exist",
+			},
+		},
+		{
+			name: "Elasticsearch cluster not ready",
+			objects: []runtime.Object{
+				elasticsearch(esv1.ElasticsearchApplyingChangesPhase),
+				snapshotRestore("nightly-2", esv1alpha1.SnapshotRestoreStatus{}),
+			},
+			esClient:   &fakeESClient{},
+			wantResult: pendingRequeue,
+			wantStatus: esv1alpha1.SnapshotRestoreStatus{
+				Phase:   esv1alpha1.SnapshotRestorePendingPhase,
+				Message: "Waiting for Elasticsearch cluster es to be ready",
+			},
+		},
+		{
+			name: "No snapshot matching the pattern",
+			objects: []runtime.Object{
+				elasticsearch(esv1.ElasticsearchReadyPhase),
+				snapshotRestore("nightly-*", esv1alpha1.SnapshotRestoreStatus{}),
+			},
+			esClient: &fakeESClient{snapshots: esclient.Snapshots{Snapshots: []esclient.Snapshot{
+				{Snapshot: "nightly-1", State: "FAILED", StartTimeInMillis: 1},
+			}}},
+			wantResult: pendingRequeue,
+			wantStatus: esv1alpha1.SnapshotRestoreStatus{
+				Phase:   esv1alpha1.SnapshotRestorePendingPhase,
+				Message: "No successful snapshot matching nightly-* in repository backups",
+			},
+		},
+		{
+			name: "Restore the latest successful snapshot matching the pattern",
+			objects: []runtime.Object{
+				elasticsearch(esv1.ElasticsearchReadyPhase),
+				snapshotRestore("nightly-*", esv1alpha1.SnapshotRestoreStatus{}),
+			},
+			esClient: &fakeESClient{snapshots: esclient.Snapshots{Snapshots: []esclient.Snapshot{
+				{Snapshot: "nightly-1", State: esclient.SnapshotSuccessState, StartTimeInMillis: 1},
+				{Snapshot: "nightly-2", State: esclient.SnapshotSuccessState, StartTimeInMillis: 2},
+				{Snapshot: "nightly-3", State: "IN_PROGRESS", StartTimeInMillis: 3},
+			}}},
+			wantResult: progressRequeue,
+			wantStatus: esv1alpha1.SnapshotRestoreStatus{
+				Phase:    esv1alpha1.SnapshotRestoreInProgressPhase,
+				Snapshot: "nightly-2",
+			},
+			wantRestored: map[string]map[string]interface{}{
+				"nightly-2": {"indices": "logs,metrics", "include_global_state": false},
+			},
+		},
+		{
+			name: "Restore rejected by Elasticsearch",
+			objects: []runtime.Object{
+				elasticsearch(esv1.ElasticsearchReadyPhase),
+				snapshotRestore("nightly-2", esv1alpha1.SnapshotRestoreStatus{}),
+			},
+			esClient:   &fakeESClient{restoreErr: rejected},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.SnapshotRestoreStatus{
+				Phase:    esv1alpha1.SnapshotRestoreFailedPhase,
+				Snapshot: "nightly-2",
+				Message:  "Restore of snapshot nightly-2 rejected by Elasticsearch: " + rejected.Error(),
+			},
+		},
+		{
+			name: "Restore accepted before the status update failed: not submitted again",
+			objects: []runtime.Object{
+				elasticsearch(esv1.ElasticsearchReadyPhase),
+				snapshotRestore("nightly-*", submitted),
+			},
+			esClient: &fakeESClient{recoveries: esclient.Recoveries{
+				"logs": {Shards: []esclient.ShardRecovery{snapshotRecovery("nightly-2", "INDEX")}},
+			}},
+			wantResult: progressRequeue,
+			wantStatus: esv1alpha1.SnapshotRestoreStatus{
+				Phase:    esv1alpha1.SnapshotRestoreInProgressPhase,
+				Snapshot: "nightly-2",
+			},
+		},
+		{
+			name: "Restore submission interrupted: submitted again",
+			objects: []runtime.Object{
+				elasticsearch(esv1.ElasticsearchReadyPhase),
+				snapshotRestore("nightly-*", submitted),
+			},
+			esClient:   &fakeESClient{},
+			wantResult: progressRequeue,
+			wantStatus: esv1alpha1.SnapshotRestoreStatus{
+				Phase:    esv1alpha1.SnapshotRestoreInProgressPhase,
+				Snapshot: "nightly-2",
+			},
+			wantRestored: map[string]map[string]interface{}{
+				"nightly-2": {"indices": "logs,metrics", "include_global_state": false},
+			},
+		},
+		{
+			name: "No recovery from the snapshot yet",
+			objects: []runtime.Object{
+				elasticsearch(esv1.ElasticsearchReadyPhase),
+				snapshotRestore("nightly-*", inProgress),
+			},
+			esClient:   &fakeESClient{},
+			wantResult: progressRequeue,
+			wantStatus: esv1alpha1.SnapshotRestoreStatus{
+				Phase:    esv1alpha1.SnapshotRestoreInProgressPhase,
+				Snapshot: "nightly-2",
+			},
+		},
+		{
+			name: "No recovery from the snapshot long after the start: completed",
+			objects: []runtime.Object{
+				elasticsearch(esv1.ElasticsearchReadyPhase),
+				snapshotRestore("nightly-*", esv1alpha1.SnapshotRestoreStatus{
+					Phase:     esv1alpha1.SnapshotRestoreInProgressPhase,
+					Snapshot:  "nightly-2",
+					StartTime: &startedLongAgo,
+				}),
+			},
+			esClient:   &fakeESClient{},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.SnapshotRestoreStatus{
+				Phase:    esv1alpha1.SnapshotRestoreCompletedPhase,
+				Snapshot: "nightly-2",
+			},
+		},
+		{
+			name: "Restore in progress",
+			objects: []runtime.Object{
+				elasticsearch(esv1.ElasticsearchReadyPhase),
+				snapshotRestore("nightly-*", inProgress),
+			},
+			esClient: &fakeESClient{recoveries: esclient.Recoveries{
+				"logs":    {Shards: []esclient.ShardRecovery{snapshotRecovery("nightly-2", esclient.RecoveryDoneStage)}},
+				"metrics": {Shards: []esclient.ShardRecovery{snapshotRecovery("nightly-2", "INDEX")}},
+				"other":   {Shards: []esclient.ShardRecovery{snapshotRecovery("nightly-1", "INDEX")}},
+			}},
+			wantResult: progressRequeue,
+			wantStatus: esv1alpha1.SnapshotRestoreStatus{
+				Phase:          esv1alpha1.SnapshotRestoreInProgressPhase,
+				Snapshot:       "nightly-2",
+				TotalShards:    2,
+				RestoredShards: 1,
+			},
+		},
+		{
+			name: "Restore completed",
+			objects: []runtime.Object{
+				elasticsearch(esv1.ElasticsearchReadyPhase),
+				snapshotRestore("nightly-*", inProgress),
+			},
+			esClient: &fakeESClient{recoveries: esclient.Recoveries{
+				"logs":    {Shards: []esclient.ShardRecovery{snapshotRecovery("nightly-2", esclient.RecoveryDoneStage)}},
+				"metrics": {Shards: []esclient.ShardRecovery{snapshotRecovery("nightly-2", esclient.RecoveryDoneStage)}},
+			}},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.SnapshotRestoreStatus{
+				Phase:          esv1alpha1.SnapshotRestoreCompletedPhase,
+				Snapshot:       "nightly-2",
+				TotalShards:    2,
+				RestoredShards: 2,
+			},
+		},
+		{
+			name: "Finished restores are not reconciled again",
+			objects: []runtime.Object{
+				elasticsearch(esv1.ElasticsearchReadyPhase),
+				snapshotRestore("nightly-*", completed),
+			},
+			esClient:   &fakeESClient{},
+			wantResult: reconcile.Result{},
+			wantStatus: esv1alpha1.SnapshotRestoreStatus{Phase: esv1alpha1.SnapshotRestoreCompletedPhase, Snapshot: "nightly-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := k8s.NewFakeClient(tt.objects...)
+			r := &ReconcileSnapshotRestore{
+				Client:           c,
+				esClientProvider: tt.esClient.provider,
+				recorder:         record.NewFakeRecorder(10),
+			}
+			key := types.NamespacedName{Namespace: "ns", Name: "restore"}
+			result, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+			require.NoError(t, err)
+			require.Equal(t, tt.wantResult, result)
+
+			var restore esv1alpha1.SnapshotRestore
+			require.NoError(t, c.Get(context.Background(), key, &restore))
+			// times are not predictable, only check they are set when expected
+			started := restore.Status.Phase == esv1alpha1.SnapshotRestoreInProgressPhase ||
+				restore.Status.Phase == esv1alpha1.SnapshotRestoreCompletedPhase
+			require.Equal(t, started, restore.Status.StartTime != nil)
+			require.Equal(t, restore.Status.IsFinished(), restore.Status.CompletionTime != nil)
+			restore.Status.StartTime = nil
+			restore.Status.CompletionTime = nil
+			require.Equal(t, tt.wantStatus, restore.Status)
+			require.Equal(t, tt.wantRestored, tt.esClient.restored)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package snapshotrestore
+
+import (
+	"context"
+	"strings"
+
+	esv1alpha1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1alpha1"
+	esclient "github.com/elastic/cloud-on-k8s/pkg/controller/elasticsearch/client"
+)
+
+// restoreRequest builds the body of the restore request from the additional parameters in the spec. Indices specified
+// in the dedicated field take precedence over the ones specified in the config.
+func restoreRequest(spec esv1alpha1.SnapshotRestoreSpec) map[string]interface{} {
+	request := map[string]interface{}{}
+	if spec.Config != nil {
+		for k, v := range spec.Config.DeepCopy().Data {
+			request[k] = v
+		}
+	}
+	if len(spec.Indices) > 0 {
+		request["indices"] = strings.Join(spec.Indices, ",")
+	}
+	return request
+}
+
+// latestSuccessfulSnapshot returns the name of the most recent successful snapshot matching the given pattern, or an
+// empty string if there is none.
+func latestSuccessfulSnapshot(ctx context.Context, esClient esclient.Client, repository string, pattern string) (string, error) {
+	snapshots, err := esClient.GetSnapshots(ctx, repository, pattern)
+	if err != nil {
+		if esclient.IsNotFound(err) {
+			// the repository may not be registered yet
+			return "", nil
+		}
+		return "", err
+	}
+	var latest *esclient.Snapshot
+	for i, snapshot := range snapshots.Snapshots {
+		if snapshot.State != esclient.SnapshotSuccessState {
+			continue
+		}
+		if latest == nil || snapshot.StartTimeInMillis > latest.StartTimeInMillis {
+			latest = &snapshots.Snapshots[i]
+		}
+	}
+	if latest == nil {
+		return "", nil
+	}
+	return latest.Snapshot, nil
+}
+
+// restoreProgress returns the total number of shards recovering from the given snapshot and the number of those that
+// are fully recovered.
+func restoreProgress(recoveries esclient.Recoveries, repository string, snapshot string) (total int32, restored int32) {
+	for _, index := range recoveries {
+		for _, shard := range index.Shards {
+			if shard.Type != esclient.SnapshotRecoveryType ||
+				shard.Source.Repository != repository ||
+				shard.Source.Snapshot != snapshot {
+				continue
+			}
+			total++
+			if shard.Stage == esclient.RecoveryDoneStage {
+				restored++
+			}
+		}
+	}
+	return total, restored
+}
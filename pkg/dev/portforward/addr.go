@@ -0,0 +1,239 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+const (
+	// syntheticDNSSegment is the DNS segment identifying pod addresses, as in {name}.{namespace}.pod
+	syntheticDNSSegment = "pod"
+	// serviceDNSSegment is the DNS segment identifying service addresses, as in {name}.{namespace}.svc
+	serviceDNSSegment = "svc"
+)
+
+// addrKind is the kind of resource targeted by an address
+type addrKind string
+
+const (
+	podAddrKind     addrKind = "pod"
+	serviceAddrKind addrKind = "service"
+)
+
+// expected address formats per kind, as reported by AddrFormatError
+var expectedAddrFormats = map[addrKind]string{
+	podAddrKind: "{name}.{namespace}[.pod[.{cluster domain}]], {dashed pod IP}.{namespace}.pod[.{cluster domain}], " +
+		"{name}.{subdomain}.{namespace}[.svc[.{cluster domain}]] or a pod IP address",
+	serviceAddrKind: "{name}.{namespace}.svc[.{cluster domain}]",
+}
+
+// AddrFormatError is returned when an address does not match the supported formats.
+type AddrFormatError struct {
+	// Addr is the offending address.
+	Addr string
+	// Kind is the kind of resource targeted by the address, pod or service.
+	Kind string
+	// Expected describes the supported formats for that kind of resource.
+	Expected string
+}
+
+// newAddrFormatError returns an AddrFormatError for an address targeting the given kind of resource.
+func newAddrFormatError(kind addrKind, addr string) *AddrFormatError {
+	return &AddrFormatError{Addr: addr, Kind: string(kind), Expected: expectedAddrFormats[kind]}
+}
+
+func (e *AddrFormatError) Error() string {
+	return fmt.Sprintf("unsupported %s address format: %s", e.Kind, e.Addr)
+}
+
+// parsedAddr is the resource targeted by an address.
+type parsedAddr struct {
+	Kind      addrKind
+	Name      string
+	Namespace string
+	Port      string
+}
+
+// NamespacedName returns the namespace and name of the targeted resource.
+func (a parsedAddr) NamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: a.Namespace, Name: a.Name}
+}
+
+// podDNSRegex matches pods FQDN such as {name}.{namespace}.pod
+var podDNSRegex = regexp.MustCompile(`^.+\..+$`)
+
+// podIPRegex matches any ipv4 address.
+var podIPv4Regex = regexp.MustCompile(`^\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}$`)
+
+// parseAddr parses the kind, name, namespace and port of the resource targeted by an address. Supported formats are:
+//   - {name}.{namespace}.svc[.{cluster domain}] for services
+//   - {name}.{namespace}[.pod[.{cluster domain}]] or {name}.{subdomain}.{namespace}[...] for pods
+//   - {name}.{headless service}.{namespace}.svc[.{cluster domain}] for pods of headless services
+//   - {dashed pod IP}.{namespace}.pod[.{cluster domain}] for pods A records, such as 10-0-0-2.ns.pod, which requires
+//     clientSet to look the pod up
+//   - a pod IPv4 or IPv6 address, which requires clientSet to look the pod up
+//
+// If defaultNamespace is not empty, it is used for pod addresses that only specify the pod name, such as {name} or
+// {name}.pod.
+func parseAddr(
+	ctx context.Context,
+	addr string,
+	clientSet kubernetes.Interface,
+	defaultNamespace string,
+) (*parsedAddr, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if ip := net.ParseIP(host); podIPv4Regex.MatchString(host) || ip != nil {
+		if ip != nil && ip.To4() == nil {
+			// IPv6 addresses are reported in their canonical form in the pod status
+			host = ip.String()
+		}
+		// we got an IP address
+		// try to map it to a pod name and namespace
+		nsn, err := getPodWithIP(ctx, host, "", clientSet)
+		if err != nil {
+			return nil, err
+		}
+		return &parsedAddr{Kind: podAddrKind, Name: nsn.Name, Namespace: nsn.Namespace, Port: port}, nil
+	}
+
+	segments := strings.Split(host, ".")
+	for i, segment := range segments[1:] {
+		if segment != serviceDNSSegment {
+			continue
+		}
+		switch i + 1 {
+		case 2:
+			// svcname.ns.svc[.cluster.local]
+			return &parsedAddr{Kind: serviceAddrKind, Name: segments[0], Namespace: segments[1], Port: port}, nil
+		case 3:
+			// podname.headless-svcname.ns.svc[.cluster.local], the DNS name of a pod of a headless service
+			return &parsedAddr{Kind: podAddrKind, Name: segments[0], Namespace: segments[2], Port: port}, nil
+		default:
+			return nil, newAddrFormatError(serviceAddrKind, host)
+		}
+	}
+
+	parts := strings.SplitN(host, ".", 4)
+
+	if len(parts) >= 3 && parts[2] == syntheticDNSSegment {
+		if ip, ok := podIPFromDashedName(parts[0]); ok {
+			// pod-ip-with-dashes.ns.pod[.cluster.local], the A record of a pod
+			nsn, err := getPodWithIP(ctx, ip, parts[1], clientSet)
+			if err != nil {
+				return nil, err
+			}
+			return &parsedAddr{Kind: podAddrKind, Name: nsn.Name, Namespace: nsn.Namespace, Port: port}, nil
+		}
+	}
+
+	if defaultNamespace != "" {
+		if name := strings.TrimSuffix(host, "."+syntheticDNSSegment); !strings.Contains(name, ".") {
+			// podname[.pod] without namespace
+			return &parsedAddr{Kind: podAddrKind, Name: name, Namespace: defaultNamespace, Port: port}, nil
+		}
+	}
+	if podDNSRegex.MatchString(host) {
+		// retrieve pod name and namespace from addr
+		if len(parts) <= 1 {
+			return nil, newAddrFormatError(podAddrKind, host)
+		}
+		if len(parts) == 2 || parts[2] == syntheticDNSSegment {
+			// podname.ns[.pod] from service forwarder or direct call
+			return &parsedAddr{Kind: podAddrKind, Name: parts[0], Namespace: parts[1], Port: port}, nil
+		}
+		// podname.subdomain.ns
+		return &parsedAddr{Kind: podAddrKind, Name: parts[0], Namespace: parts[2], Port: port}, nil
+	}
+	return nil, newAddrFormatError(podAddrKind, host)
+}
+
+// podIPFromDashedName returns the pod IP encoded in the first segment of a pod A record, in which dashes replace the
+// dots of IPv4 addresses or the colons of IPv6 addresses.
+func podIPFromDashedName(name string) (string, bool) {
+	if ip := net.ParseIP(strings.ReplaceAll(name, "-", ".")); ip != nil {
+		return ip.String(), true
+	}
+	if ip := net.ParseIP(strings.ReplaceAll(name, "-", ":")); ip != nil {
+		return ip.String(), true
+	}
+	return "", false
+}
+
+// getPodWithIP requests the apiserver for pods with the given IP assigned, in the given namespace or in all namespaces
+// if empty.
+func getPodWithIP(
+	ctx context.Context,
+	ip, namespace string,
+	clientSet kubernetes.Interface,
+) (*types.NamespacedName, error) {
+	if clientSet == nil {
+		return nil, errors.New("a clientset is required to look up pods by IP")
+	}
+	pods, err := clientSet.CoreV1().
+		Pods(namespace).
+		List(ctx,
+			metav1.ListOptions{
+				FieldSelector: fmt.Sprintf("status.podIP=%s", ip),
+			})
+	if err != nil {
+		return nil, err
+	}
+	if pods == nil || len(pods.Items) == 0 {
+		return nil, fmt.Errorf("pod with IP %s not found", ip)
+	}
+	nsn := k8s.ExtractNamespacedName(&(pods.Items[0].ObjectMeta))
+	return &nsn, nil
+}
+
+// resolveContainerPort resolves a port specified as {container}/{port name} to the number of the named port of that
+// container in the given pod. Other ports are returned as is.
+func resolveContainerPort(
+	ctx context.Context,
+	clientSet kubernetes.Interface,
+	podNSN types.NamespacedName,
+	port string,
+) (string, error) {
+	parts := strings.SplitN(port, "/", 2)
+	if len(parts) != 2 {
+		return port, nil
+	}
+	containerName, portName := parts[0], parts[1]
+	if clientSet == nil {
+		return "", fmt.Errorf("a clientset is required to resolve port %s of pod %s", port, podNSN)
+	}
+	pod, err := clientSet.CoreV1().Pods(podNSN.Namespace).Get(ctx, podNSN.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name != containerName {
+			continue
+		}
+		for _, containerPort := range container.Ports {
+			if containerPort.Name == portName {
+				return strconv.Itoa(int(containerPort.ContainerPort)), nil
+			}
+		}
+		return "", fmt.Errorf("container %s of pod %s has no port named %s", containerName, podNSN, portName)
+	}
+	return "", fmt.Errorf("pod %s has no container named %s", podNSN, containerName)
+}
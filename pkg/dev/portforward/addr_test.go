@@ -0,0 +1,331 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_parseAddr(t *testing.T) {
+	type args struct {
+		addr             string
+		defaultNamespace string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    parsedAddr
+		wantErr error
+	}{
+		{
+			name: "pod DNS without subdomain",
+			args: args{addr: "foo.bar.pod:1234"},
+			want: parsedAddr{Kind: podAddrKind, Name: "foo", Namespace: "bar", Port: "1234"},
+		},
+		{
+			name: "pod DNS with pod and namespace only",
+			args: args{addr: "foopod.barnamespace:1234"},
+			want: parsedAddr{Kind: podAddrKind, Name: "foopod", Namespace: "barnamespace", Port: "1234"},
+		},
+		{
+			name: "pod DNS with pod, subdomain and namespace",
+			args: args{addr: "foopod.foosubdomain.barnamespace:1234"},
+			want: parsedAddr{Kind: podAddrKind, Name: "foopod", Namespace: "barnamespace", Port: "1234"},
+		},
+		{
+			name: "pod name only with default namespace",
+			args: args{addr: "foopod:1234", defaultNamespace: "defaultnamespace"},
+			want: parsedAddr{Kind: podAddrKind, Name: "foopod", Namespace: "defaultnamespace", Port: "1234"},
+		},
+		{
+			name: "pod name and pod segment with default namespace",
+			args: args{addr: "foopod.pod:1234", defaultNamespace: "defaultnamespace"},
+			want: parsedAddr{Kind: podAddrKind, Name: "foopod", Namespace: "defaultnamespace", Port: "1234"},
+		},
+		{
+			name: "explicit namespace overrides the default namespace",
+			args: args{addr: "foopod.barnamespace.pod:1234", defaultNamespace: "defaultnamespace"},
+			want: parsedAddr{Kind: podAddrKind, Name: "foopod", Namespace: "barnamespace", Port: "1234"},
+		},
+		{
+			name: "explicit namespace without pod segment overrides the default namespace",
+			args: args{addr: "foopod.barnamespace:1234", defaultNamespace: "defaultnamespace"},
+			want: parsedAddr{Kind: podAddrKind, Name: "foopod", Namespace: "barnamespace", Port: "1234"},
+		},
+		{
+			name: "service DNS",
+			args: args{addr: "foo.bar.svc:9200"},
+			want: parsedAddr{Kind: serviceAddrKind, Name: "foo", Namespace: "bar", Port: "9200"},
+		},
+		{
+			name: "service FQDN with cluster domain",
+			args: args{addr: "foo.bar.svc.cluster.local:9200"},
+			want: parsedAddr{Kind: serviceAddrKind, Name: "foo", Namespace: "bar", Port: "9200"},
+		},
+		{
+			name: "service DNS is not affected by the default namespace",
+			args: args{addr: "foo.bar.svc:9200", defaultNamespace: "defaultnamespace"},
+			want: parsedAddr{Kind: serviceAddrKind, Name: "foo", Namespace: "bar", Port: "9200"},
+		},
+		{
+			name: "headless service pod DNS",
+			args: args{addr: "es-0.es-headless.ns.svc:9200"},
+			want: parsedAddr{Kind: podAddrKind, Name: "es-0", Namespace: "ns", Port: "9200"},
+		},
+		{
+			name: "headless service pod FQDN with cluster domain",
+			args: args{addr: "es-0.es-headless.ns.svc.cluster.local:9200"},
+			want: parsedAddr{Kind: podAddrKind, Name: "es-0", Namespace: "ns", Port: "9200"},
+		},
+		{
+			name: "headless service DNS is a service",
+			args: args{addr: "es-headless.ns.svc.cluster.local:9200"},
+			want: parsedAddr{Kind: serviceAddrKind, Name: "es-headless", Namespace: "ns", Port: "9200"},
+		},
+		{
+			name:    "too many segments before the service segment",
+			args:    args{addr: "a.b.c.d.svc:9200"},
+			wantErr: newAddrFormatError(serviceAddrKind, "a.b.c.d.svc"),
+		},
+		{
+			name:    "service DNS without namespace",
+			args:    args{addr: "foo.svc:9200"},
+			wantErr: &AddrFormatError{Addr: "foo.svc", Kind: "service", Expected: "{name}.{namespace}.svc[.{cluster domain}]"},
+		},
+		{
+			name:    "invalid",
+			args:    args{addr: "foobar:1234"},
+			wantErr: newAddrFormatError(podAddrKind, "foobar"),
+		},
+		{
+			name:    "pod IP without clientset",
+			args:    args{addr: "10.0.0.2:1234"},
+			wantErr: errors.New("a clientset is required to look up pods by IP"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAddr(context.Background(), tt.args.addr, nil, tt.args.defaultNamespace)
+
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, *got)
+		})
+	}
+}
+
+func Test_parseAddr_missingPort(t *testing.T) {
+	_, err := parseAddr(context.Background(), "foo.bar.svc", nil, "")
+	require.Error(t, err)
+}
+
+func TestAddrFormatError(t *testing.T) {
+	_, err := parseAddr(context.Background(), "foo.svc:9200", nil, "")
+	var formatErr *AddrFormatError
+	require.ErrorAs(t, err, &formatErr)
+	require.Equal(t, "foo.svc", formatErr.Addr)
+	require.Equal(t, "service", formatErr.Kind)
+	require.EqualError(t, err, "unsupported service address format: foo.svc")
+
+	// a service address is not a valid pod address
+	_, err = NewPodForwarder(context.Background(), "tcp", "foo.bar.svc:9200", nil)
+	require.ErrorAs(t, err, &formatErr)
+	require.Equal(t, "pod", formatErr.Kind)
+	require.EqualError(t, err, "unsupported pod address format: foo.bar.svc:9200")
+}
+
+func Test_parseAddr_podIP(t *testing.T) {
+	newPod := func(namespace, name, ip string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Status:     corev1.PodStatus{PodIP: ip},
+		}
+	}
+	// the fake clientset ignores the field selector on the pod IP, each test only has the pods that may match
+	tests := []struct {
+		name string
+		addr string
+		pods []runtime.Object
+		want parsedAddr
+	}{
+		{
+			name: "IPv4 address",
+			addr: "10.0.0.2:9200",
+			pods: []runtime.Object{newPod("ns", "pod", "10.0.0.2")},
+			want: parsedAddr{Kind: podAddrKind, Name: "pod", Namespace: "ns", Port: "9200"},
+		},
+		{
+			name: "IPv6 address",
+			addr: "[fd00::2]:9200",
+			pods: []runtime.Object{newPod("ns", "pod", "fd00::2")},
+			want: parsedAddr{Kind: podAddrKind, Name: "pod", Namespace: "ns", Port: "9200"},
+		},
+		{
+			name: "dashed IPv4 pod DNS",
+			addr: "10-0-0-2.ns.pod:9200",
+			pods: []runtime.Object{newPod("ns", "pod", "10.0.0.2")},
+			want: parsedAddr{Kind: podAddrKind, Name: "pod", Namespace: "ns", Port: "9200"},
+		},
+		{
+			name: "dashed IPv6 pod FQDN with cluster domain",
+			addr: "fd00--2.ns.pod.cluster.local:9200",
+			pods: []runtime.Object{newPod("ns", "pod", "fd00::2")},
+			want: parsedAddr{Kind: podAddrKind, Name: "pod", Namespace: "ns", Port: "9200"},
+		},
+		{
+			name: "dashed pod DNS is looked up in its namespace",
+			addr: "10-0-0-2.other.pod:9200",
+			pods: []runtime.Object{newPod("ns", "pod", "10.0.0.2"), newPod("other", "other-pod", "10.0.0.2")},
+			want: parsedAddr{Kind: podAddrKind, Name: "other-pod", Namespace: "other", Port: "9200"},
+		},
+		{
+			name: "dashed name without pod segment is a pod name",
+			addr: "10-0-0-2.ns:9200",
+			want: parsedAddr{Kind: podAddrKind, Name: "10-0-0-2", Namespace: "ns", Port: "9200"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAddr(context.Background(), tt.addr, fake.NewSimpleClientset(tt.pods...), "")
+			require.NoError(t, err)
+			require.Equal(t, tt.want, *got)
+		})
+	}
+}
+
+func Test_podIPFromDashedName(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{name: "10-0-0-2", want: "10.0.0.2", wantOK: true},
+		{name: "fd00--2", want: "fd00::2", wantOK: true},
+		{name: "es-default-0", wantOK: false},
+		{name: "10-0-0", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := podIPFromDashedName(tt.name)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_podIPv4Regex(t *testing.T) {
+	tests := []struct {
+		name string
+		addr string
+		want bool
+	}{
+		{
+			name: "valid ipv4",
+			addr: "10.0.0.2",
+			want: true,
+		},
+		{
+			name: "invalid ipv4 still correctly parsed",
+			addr: "666.666.666.666",
+			want: true,
+		},
+		{
+			name: "empty string",
+			addr: "",
+			want: false,
+		},
+		{
+			name: "dns",
+			addr: "name.namespace.pod",
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, podIPv4Regex.MatchString(tt.addr))
+		})
+	}
+}
+
+func Test_resolveContainerPort(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pod"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "main",
+					Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+				},
+				{
+					Name:  "sidecar",
+					Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 9090}},
+				},
+			},
+		},
+	}
+	podNSN := types.NamespacedName{Namespace: "ns", Name: "pod"}
+	tests := []struct {
+		name    string
+		port    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "numeric port",
+			port: "9200",
+			want: "9200",
+		},
+		{
+			name: "named port of the main container",
+			port: "main/http",
+			want: "8080",
+		},
+		{
+			name: "named port of a sidecar container",
+			port: "sidecar/http",
+			want: "9090",
+		},
+		{
+			name:    "unknown container",
+			port:    "other/http",
+			wantErr: "pod ns/pod has no container named other",
+		},
+		{
+			name:    "unknown port",
+			port:    "main/metrics",
+			wantErr: "container main of pod ns/pod has no port named metrics",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveContainerPort(context.Background(), fake.NewSimpleClientset(pod), podNSN, tt.port)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_resolveContainerPort_withoutClientset(t *testing.T) {
+	podNSN := types.NamespacedName{Namespace: "ns", Name: "pod"}
+	_, err := resolveContainerPort(context.Background(), nil, podNSN, "main/http")
+	require.EqualError(t, err, "a clientset is required to resolve port main/http of pod ns/pod")
+}
@@ -0,0 +1,50 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCircuitOpen is returned when dialing a forwarder that stopped retrying after too many consecutive failures
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// circuitBreaker keeps track of consecutive port forwarding failures to decide when to stop retrying for a while.
+//
+// It is not safe for concurrent use.
+type circuitBreaker struct {
+	// threshold is the number of consecutive failures within window after which the circuit opens
+	threshold int
+	window    time.Duration
+	// cooldown is the time to wait after the circuit opens before trying again
+	cooldown time.Duration
+
+	// failures holds the times of the consecutive failures within window
+	failures []time.Time
+	// open is true once the circuit opened, until the next success
+	open bool
+}
+
+// recordSuccess closes the circuit.
+func (b *circuitBreaker) recordSuccess() {
+	b.failures = nil
+	b.open = false
+}
+
+// recordFailure records a failure at the given time and returns whether the circuit is open as a result.
+//
+// A failure while the circuit is open, which is the single attempt made after the cooldown period, re-opens it.
+func (b *circuitBreaker) recordFailure(now time.Time) bool {
+	b.failures = append(b.failures, now)
+	for len(b.failures) > 0 && now.Sub(b.failures[0]) > b.window {
+		b.failures = b.failures[1:]
+	}
+
+	if len(b.failures) >= b.threshold {
+		b.open = true
+	}
+	return b.open
+}
@@ -0,0 +1,140 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func Test_circuitBreaker(t *testing.T) {
+	now := time.Now()
+	b := &circuitBreaker{threshold: 3, window: time.Minute, cooldown: time.Minute}
+
+	require.False(t, b.recordFailure(now))
+	require.False(t, b.recordFailure(now.Add(10*time.Second)))
+	// the first failure is out of the window
+	require.False(t, b.recordFailure(now.Add(61*time.Second)))
+	require.True(t, b.recordFailure(now.Add(62*time.Second)))
+	// a failure of the attempt following the cooldown re-opens the circuit
+	require.True(t, b.recordFailure(now.Add(10*time.Minute)))
+
+	b.recordSuccess()
+	require.False(t, b.recordFailure(now.Add(11*time.Minute)))
+}
+
+func Test_podForwarder_Run_circuitBreaker(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithCircuitBreaker(3, time.Minute, time.Minute))
+	fwd.clock = fakeClock
+	fwd.reconnectDelay = 0
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.dialerFunc = (&capturingDialer{}).DialContext
+
+	var attempts, failing int32 = 0, 1
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		readyChan chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		atomic.AddInt32(&attempts, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			return nil, errors.New("upgrade failed")
+		}
+		close(readyChan)
+		return &stubPortForwarder{ctx: ctx}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(ctx)
+	}()
+
+	// dials fail fast once the circuit is open
+	_, err := fwd.DialContext(ctx)
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	// a single attempt is made after the cooldown
+	require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+	fakeClock.Step(time.Minute)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&attempts) == 4 && fakeClock.HasWaiters()
+	}, 5*time.Second, time.Millisecond)
+	_, err = fwd.DialContext(ctx)
+	require.ErrorIs(t, err, ErrCircuitOpen)
+
+	// recovery after the next cooldown
+	atomic.StoreInt32(&failing, 0)
+	fakeClock.Step(time.Minute)
+	require.Eventually(t, func() bool {
+		return fwd.State() == StateReady
+	}, 5*time.Second, time.Millisecond)
+	_, err = fwd.DialContext(ctx)
+	require.NoError(t, err)
+
+	cancel()
+	require.NoError(t, <-runErr)
+}
+
+func Test_podForwarder_Run_circuitBreakerRetryPolicy(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200",
+		WithCircuitBreaker(2, time.Minute, time.Minute),
+		WithRetryPolicy(4, time.Second, time.Second),
+	)
+	fwd.clock = fakeClock
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	forwardErr := errors.New("upgrade failed")
+	attempts := make(chan struct{}, 4)
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		_ chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		attempts <- struct{}{}
+		return nil, forwardErr
+	}
+
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(context.Background())
+	}()
+
+	// the first failure is retried after the backoff, the second one opens the circuit
+	<-attempts
+	require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+	fakeClock.Step(time.Second)
+	<-attempts
+	_, err := fwd.DialContext(context.Background())
+	require.ErrorIs(t, err, ErrCircuitOpen)
+
+	// the attempts made after the cooldown periods count towards the maximum number of attempts
+	for i := 0; i < 2; i++ {
+		require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+		fakeClock.Step(time.Minute)
+		<-attempts
+	}
+	require.ErrorIs(t, <-runErr, forwardErr)
+	require.Equal(t, StateFailed, fwd.State())
+}
@@ -6,34 +6,155 @@ package portforward
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"k8s.io/apimachinery/pkg/util/httpstream"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/portforward"
 	"k8s.io/client-go/transport/spdy"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 )
 
+// ForwardedPort is a pair of local and remote forwarded ports.
+type ForwardedPort = portforward.ForwardedPort
+
+// RoundTripperFactory returns the transport and upgrader used to establish the SPDY connection to the API server.
+type RoundTripperFactory func(cfg *rest.Config) (http.RoundTripper, spdy.Upgrader, error)
+
+// StreamProtocol is the protocol used to multiplex the forwarded streams over the connection to the API server.
+type StreamProtocol string
+
+// StreamProtocolSPDY upgrades the connection to the API server to SPDY, the only protocol supported for port forwarding
+// by the client library this package is built against.
+const StreamProtocolSPDY StreamProtocol = "spdy"
+
+// kubectlSettings holds the settings used by newKubectlPortForwarder
+type kubectlSettings struct {
+	// roundTripperFactory returns the transport and upgrader for the SPDY connection
+	roundTripperFactory RoundTripperFactory
+	// proxyURL is the proxy used to reach the API server, nil means the proxy of the rest config or environment
+	proxyURL *url.URL
+	// restConfig is the configuration used to reach the API server, nil means the ambient configuration
+	restConfig *rest.Config
+	// streamProtocol is the requested protocol for the forwarded streams
+	streamProtocol StreamProtocol
+	// clientSet is used to build the port forwarding URL, nil means a client built from the rest config
+	clientSet kubernetes.Interface
+	// transport and upgrader are used for the SPDY connection, nil means the ones returned by roundTripperFactory
+	transport http.RoundTripper
+	upgrader  spdy.Upgrader
+	// requestHook is called with the port forwarding request before it is sent, if not nil
+	requestHook func(req *http.Request)
+	// trace holds the hooks called when connecting to the API server, if not nil
+	trace *Trace
+	// insecureSkipTLSVerify disables the verification of the API server certificate
+	insecureSkipTLSVerify bool
+	// kubeconfigPath and kubeContext select the kube config file and context to load when there is no rest config,
+	// empty values mean the default ones
+	kubeconfigPath string
+	kubeContext    string
+	// impersonate is the identity impersonated when forwarding ports, none if the user name is empty
+	impersonate rest.ImpersonationConfig
+}
+
+// loadRestConfig returns the configuration used to reach the API server, with the settings applied.
+func (s kubectlSettings) loadRestConfig() (*rest.Config, error) {
+	var cfg *rest.Config
+	var err error
+	switch {
+	case s.restConfig != nil:
+		// copied since the settings below are applied to it, the caller's configuration must not change
+		cfg = rest.CopyConfig(s.restConfig)
+	case s.kubeconfigPath != "" || s.kubeContext != "":
+		cfg, err = loadKubeconfig(s.kubeconfigPath, s.kubeContext)
+	default:
+		cfg, err = config.GetConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("portforward: loading kube config: %w", err)
+	}
+	if s.proxyURL != nil {
+		// spdy.RoundTripperFor tunnels through cfg.Proxy with HTTP CONNECT, defaulting to the HTTPS_PROXY environment
+		cfg.Proxy = http.ProxyURL(s.proxyURL)
+	}
+	if s.insecureSkipTLSVerify {
+		log.Info("Warning: not verifying the API server certificate for port forwarding", "host", cfg.Host)
+		cfg.TLSClientConfig.Insecure = true
+		// root certificates cannot be specified along with the insecure flag
+		cfg.TLSClientConfig.CAFile = ""
+		cfg.TLSClientConfig.CAData = nil
+	}
+	if s.impersonate.UserName != "" {
+		cfg.Impersonate = s.impersonate
+	}
+	return cfg, nil
+}
+
+// loadKubeconfig loads the configuration of the given context from the given kube config file, following the default
+// loading rules for empty values.
+func loadKubeconfig(path, kubeContext string) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = path
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+}
+
+// defaultKubectlSettings returns the settings used outside of tests when no option is specified
+func defaultKubectlSettings() kubectlSettings {
+	return kubectlSettings{
+		roundTripperFactory: spdy.RoundTripperFor,
+		streamProtocol:      StreamProtocolSPDY,
+	}
+}
+
 // newKubectlPortForwarder creates a new PortForwarder using kubectl tooling
 func newKubectlPortForwarder(
 	ctx context.Context,
+	settings kubectlSettings,
 	namespace, podName string,
 	ports []string,
 	readyChan chan struct{},
-) (*portforward.PortForwarder, error) {
-	cfg, err := config.GetConfig()
-	if err != nil {
-		return nil, err
+	out, errOut io.Writer,
+) (*kubectlPortForwarder, error) {
+	// the client library does not take a context to build clients and transports, give up early instead
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("portforward: %w", err)
 	}
 
-	clientSet, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		return nil, err
+	clientSet, transport, upgrader := settings.clientSet, settings.transport, settings.upgrader
+	// the configuration is only needed for what is not shared between forwarders
+	if clientSet == nil || transport == nil || upgrader == nil {
+		cfg, err := settings.loadRestConfig()
+		if err != nil {
+			return nil, err
+		}
+		if clientSet == nil {
+			clientSet, err = kubernetes.NewForConfig(cfg)
+			if err != nil {
+				return nil, fmt.Errorf("portforward: building kube client: %w", err)
+			}
+		}
+		if transport == nil || upgrader == nil {
+			transport, upgrader, err = settings.roundTripperFactory(cfg)
+			if err != nil {
+				return nil, fmt.Errorf("portforward: building SPDY transport: %w", err)
+			}
+		}
 	}
 
-	req := clientSet.RESTClient().Post().
+	// the core API client builds the path from the server URL, which may include a prefix, for example behind an API
+	// gateway
+	req := clientSet.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Namespace(namespace).
 		Name(podName).
@@ -42,33 +163,283 @@ func newKubectlPortForwarder(
 	u := url.URL{
 		Scheme:   req.URL().Scheme,
 		Host:     req.URL().Host,
-		Path:     "/api/v1" + req.URL().Path,
+		Path:     req.URL().Path,
 		RawQuery: "timeout=32s",
 	}
 
-	transport, upgrader, err := spdy.RoundTripperFor(cfg)
+	if settings.requestHook != nil {
+		transport = &requestHookRoundTripper{hook: settings.requestHook, next: transport}
+	}
+	forbidden := &forbiddenRoundTripper{next: transport}
+	transport = forbidden
+
+	dialer, err := newStreamDialer(settings.streamProtocol, transport, upgrader, &u)
 	if err != nil {
 		return nil, err
 	}
+	if settings.trace != nil {
+		dialer = &tracingDialer{trace: settings.trace, next: dialer}
+	}
+
+	// do not dial the API server if the context was cancelled while building the clients
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("portforward: %w", err)
+	}
+	pf, err := portforward.New(dialer, ports, ctx.Done(), readyChan, out, errOut)
+	if err != nil {
+		return nil, err
+	}
+	return &kubectlPortForwarder{PortForwarder: pf, namespace: namespace, forbidden: forbidden}, nil
+}
+
+// ErrForbidden is returned when the API server refuses to forward the ports of a pod, usually because of missing RBAC
+// permissions.
+var ErrForbidden = errors.New("forbidden to forward the ports of the pod")
+
+// kubectlPortForwarder is a port forwarder of the client library that tells apart the API server refusing to forward
+// ports from other failures, which are only reported as text by the client library.
+type kubectlPortForwarder struct {
+	*portforward.PortForwarder
+
+	// namespace is the namespace of the pod
+	namespace string
+	// forbidden records whether the API server refused the port forwarding request
+	forbidden *forbiddenRoundTripper
+}
+
+// ForwardPorts forwards the ports, wrapping the error in ErrForbidden with the required permissions if the API server
+// refused the port forwarding request.
+func (pf *kubectlPortForwarder) ForwardPorts() error {
+	err := pf.PortForwarder.ForwardPorts()
+	if err != nil && pf.forbidden.refused() {
+		return fmt.Errorf(
+			"%w: the create verb on the pods/portforward resource is required in namespace %s: %s",
+			ErrForbidden, pf.namespace, err.Error(),
+		)
+	}
+	return err
+}
+
+// forbiddenRoundTripper records whether the API server responded to a request with 403 Forbidden.
+type forbiddenRoundTripper struct {
+	next http.RoundTripper
+	// forbidden is set to 1 once a request is forbidden
+	forbidden int32
+}
+
+func (rt *forbiddenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusForbidden {
+		atomic.StoreInt32(&rt.forbidden, 1)
+	}
+	return resp, err
+}
+
+// refused returns whether a request was forbidden.
+func (rt *forbiddenRoundTripper) refused() bool {
+	return atomic.LoadInt32(&rt.forbidden) == 1
+}
+
+// newStreamDialer returns the dialer upgrading the connection to the given URL to the requested stream protocol, or an
+// error if the protocol is not supported.
+func newStreamDialer(
+	protocol StreamProtocol,
+	transport http.RoundTripper,
+	upgrader spdy.Upgrader,
+	u *url.URL,
+) (httpstream.Dialer, error) {
+	if protocol != "" && protocol != StreamProtocolSPDY {
+		return nil, fmt.Errorf("portforward: unsupported stream protocol %q", protocol)
+	}
+	return spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, u), nil
+}
 
-	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, &u)
+// requestHookRoundTripper calls a hook with a copy of the requests it sends, to let callers customize them.
+type requestHookRoundTripper struct {
+	hook func(req *http.Request)
+	next http.RoundTripper
+}
 
-	// wrap stdout / stderr through logging
-	w := &logWriter{keysAndValues: []interface{}{
-		"namespace", namespace,
-		"pod", podName,
-		"ports", ports,
-	}}
-	return portforward.New(dialer, ports, ctx.Done(), readyChan, w, w)
+func (rt *requestHookRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// round trippers must not modify the original request
+	req = req.Clone(req.Context())
+	rt.hook(req)
+	return rt.next.RoundTrip(req)
 }
 
 // logWriter is a small utility that writes data from an io.Writer to a log
 type logWriter struct {
 	keysAndValues []interface{}
+
+	// output records the lines written to the log if not nil
+	output *outputBuffer
+
+	// dedup coalesces repeated identical lines if not nil
+	dedup *logDeduplicator
+
+	// next receives the data written instead of the log if not nil
+	next io.Writer
 }
 
 func (w *logWriter) Write(p []byte) (n int, err error) {
-	log.Info(strings.TrimSpace(string(p)), w.keysAndValues...)
+	line := strings.TrimSpace(string(p))
+	switch {
+	case w.next != nil:
+		if _, err := w.next.Write(p); err != nil {
+			return 0, err
+		}
+	case w.dedup == nil:
+		log.Info(line, w.keysAndValues...)
+	default:
+		logLine, previous, repeated := w.dedup.record(line)
+		if repeated > 0 {
+			log.Info(previous, append([]interface{}{"repeated", repeated}, w.keysAndValues...)...)
+		}
+		if logLine {
+			log.Info(line, w.keysAndValues...)
+		}
+	}
+
+	if w.output != nil {
+		w.output.write(p)
+	}
 
 	return len(p), nil
 }
+
+// logDeduplicator coalesces identical log lines written within a window into a single entry with a count, to keep
+// logs readable when a forwarder is flapping.
+type logDeduplicator struct {
+	window time.Duration
+	clock  clock
+
+	mu sync.Mutex
+	// last is the last line logged, at lastLogged
+	last       string
+	lastLogged time.Time
+	// suppressed is the number of lines identical to last that were not logged since
+	suppressed int
+}
+
+// newLogDeduplicator returns a logDeduplicator suppressing the lines identical to the last one logged within window.
+func newLogDeduplicator(window time.Duration, clock clock) *logDeduplicator {
+	return &logDeduplicator{window: window, clock: clock}
+}
+
+// record records a line to log. It returns whether the line must be logged, and the previous line with the number of
+// times it was suppressed if that count must be logged first.
+func (d *logDeduplicator) record(line string) (logLine bool, previous string, repeated int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.clock.Now()
+	if line == d.last && now.Sub(d.lastLogged) < d.window {
+		d.suppressed++
+		return false, "", 0
+	}
+
+	if line == d.last && d.suppressed > 0 {
+		// the suppressed lines are logged once with their count when the window is over
+		repeated = d.suppressed + 1
+		d.lastLogged, d.suppressed = now, 0
+		return false, line, repeated
+	}
+	if d.suppressed > 0 {
+		previous, repeated = d.last, d.suppressed
+	}
+	d.last, d.lastLogged, d.suppressed = line, now, 0
+	return true, previous, repeated
+}
+
+// maxOutputLines is the number of most recent output lines retained by a PodForwarder
+const maxOutputLines = 50
+
+// outputBuffer is a ring buffer of the most recent non-empty lines written by port forwarders.
+type outputBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	// next is the index of lines where the next line is written
+	next int
+	// full is true once lines has wrapped around
+	full bool
+}
+
+// newOutputBuffer returns an outputBuffer retaining up to size lines.
+func newOutputBuffer(size int) *outputBuffer {
+	return &outputBuffer{lines: make([]string, size)}
+}
+
+func (b *outputBuffer) write(p []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, line := range strings.Split(string(p), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		b.lines[b.next] = line
+		b.next = (b.next + 1) % len(b.lines)
+		if b.next == 0 {
+			b.full = true
+		}
+	}
+}
+
+// snapshot returns a copy of the retained lines, oldest first.
+func (b *outputBuffer) snapshot() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]string(nil), b.lines[:b.next]...)
+	}
+	return append(append([]string(nil), b.lines[b.next:]...), b.lines[:b.next]...)
+}
+
+// maxStderrLines is the number of most recent stderr lines retained by a stderrWriter
+const maxStderrLines = 5
+
+// lostConnectionMessage is written by the port forwarder when the connection to the pod is lost
+const lostConnectionMessage = "lost connection to pod"
+
+// stderrWriter is a logWriter that also retains the most recent lines written to it, so they can be surfaced in the
+// error returned when the port forwarding fails.
+type stderrWriter struct {
+	logWriter
+
+	// onLostConnection is called when the port forwarder reports that the connection to the pod was lost
+	onLostConnection func()
+
+	mu    sync.Mutex
+	lines []string
+}
+
+func (w *stderrWriter) Write(p []byte) (n int, err error) {
+	if w.onLostConnection != nil && strings.Contains(string(p), lostConnectionMessage) {
+		w.onLostConnection()
+	}
+
+	w.mu.Lock()
+	for _, line := range strings.Split(string(p), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			w.lines = append(w.lines, line)
+		}
+	}
+	if len(w.lines) > maxStderrLines {
+		w.lines = w.lines[len(w.lines)-maxStderrLines:]
+	}
+	w.mu.Unlock()
+
+	return w.logWriter.Write(p)
+}
+
+// wrapError annotates err with the most recent lines written to stderr, if any.
+func (w *stderrWriter) wrapError(err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err == nil || len(w.lines) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w (stderr: %s)", err, strings.Join(w.lines, "; "))
+}
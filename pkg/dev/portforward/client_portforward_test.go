@@ -0,0 +1,548 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport/spdy"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: %s
+  name: test
+contexts:
+- context:
+    cluster: test
+    user: test
+  name: test
+current-context: test
+users:
+- name: test
+  user:
+    token: test-token
+`
+
+// setTestKubeconfig points the default client configuration to a test kubeconfig.
+func setTestKubeconfig(t *testing.T) {
+	t.Helper()
+	setTestKubeconfigWithServer(t, "https://10.0.0.1:6443")
+}
+
+// setTestKubeconfigWithServer points the default client configuration to a test kubeconfig for the given server URL.
+func setTestKubeconfigWithServer(t *testing.T, server string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(testKubeconfig, server)), 0600))
+	t.Setenv("KUBECONFIG", path)
+}
+
+// capturingRoundTripper records the requests it receives and fails them.
+type capturingRoundTripper struct {
+	requests []*http.Request
+}
+
+func (c *capturingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests = append(c.requests, req)
+	return nil, errors.New("capturing round tripper")
+}
+
+type stubUpgrader struct{}
+
+func (u *stubUpgrader) NewConnection(_ *http.Response) (httpstream.Connection, error) {
+	return nil, errors.New("not implemented")
+}
+
+func capturingRoundTripperFactory(rt *capturingRoundTripper) RoundTripperFactory {
+	return func(_ *rest.Config) (http.RoundTripper, spdy.Upgrader, error) {
+		return rt, &stubUpgrader{}, nil
+	}
+}
+
+func Test_newKubectlPortForwarder(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  string
+		wantURL string
+	}{
+		{
+			name:    "server without path",
+			server:  "https://10.0.0.1:6443",
+			wantURL: "https://10.0.0.1:6443/api/v1/namespaces/ns/pods/pod/portforward?timeout=32s",
+		},
+		{
+			name:    "server behind a path prefix",
+			server:  "https://gateway.example.com/clusters/test",
+			wantURL: "https://gateway.example.com/clusters/test/api/v1/namespaces/ns/pods/pod/portforward?timeout=32s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestKubeconfigWithServer(t, tt.server)
+
+			rt := &capturingRoundTripper{}
+			settings := defaultKubectlSettings()
+			settings.roundTripperFactory = capturingRoundTripperFactory(rt)
+
+			fwd, err := newKubectlPortForwarder(
+				context.Background(), settings, "ns", "pod", []string{"0:9200"}, make(chan struct{}), nil, nil,
+			)
+			require.NoError(t, err)
+
+			err = fwd.ForwardPorts()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "capturing round tripper")
+
+			require.Len(t, rt.requests, 1)
+			req := rt.requests[0]
+			assert.Equal(t, http.MethodPost, req.Method)
+			assert.Equal(t, tt.wantURL, req.URL.String())
+		})
+	}
+}
+
+func Test_newKubectlPortForwarder_setupErrors(t *testing.T) {
+	t.Run("kube config", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+		_, err := newKubectlPortForwarder(
+			context.Background(), defaultKubectlSettings(), "ns", "pod", []string{"0:9200"}, make(chan struct{}), nil, nil,
+		)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "portforward: loading kube config: ")
+	})
+
+	t.Run("transport", func(t *testing.T) {
+		setTestKubeconfig(t)
+		transportErr := errors.New("invalid TLS configuration")
+		settings := defaultKubectlSettings()
+		settings.roundTripperFactory = func(_ *rest.Config) (http.RoundTripper, spdy.Upgrader, error) {
+			return nil, nil, transportErr
+		}
+		_, err := newKubectlPortForwarder(
+			context.Background(), settings, "ns", "pod", []string{"0:9200"}, make(chan struct{}), nil, nil,
+		)
+		require.ErrorIs(t, err, transportErr)
+		require.EqualError(t, err, "portforward: building SPDY transport: invalid TLS configuration")
+	})
+}
+
+func Test_newKubectlPortForwarder_cancelledContext(t *testing.T) {
+	setTestKubeconfig(t)
+	settings := defaultKubectlSettings()
+	settings.roundTripperFactory = func(_ *rest.Config) (http.RoundTripper, spdy.Upgrader, error) {
+		t.Error("transport should not be built with a cancelled context")
+		return nil, nil, errors.New("unexpected transport")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	_, err := newKubectlPortForwarder(ctx, settings, "ns", "pod", []string{"0:9200"}, make(chan struct{}), nil, nil)
+	require.ErrorIs(t, err, context.Canceled)
+	require.EqualError(t, err, "portforward: context canceled")
+	require.Less(t, time.Since(start), time.Second)
+}
+
+// statusRoundTripper responds to every request with the given status.
+type statusRoundTripper struct {
+	status int
+}
+
+func (rt *statusRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: rt.status,
+		Body:       io.NopCloser(strings.NewReader("pods \"pod\" is forbidden")),
+		Request:    req,
+	}, nil
+}
+
+func Test_newKubectlPortForwarder_forbidden(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantForbidden bool
+	}{
+		{
+			name:          "missing RBAC permissions",
+			status:        http.StatusForbidden,
+			wantForbidden: true,
+		},
+		{
+			name:          "other upgrade failure",
+			status:        http.StatusInternalServerError,
+			wantForbidden: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestKubeconfig(t)
+			settings := defaultKubectlSettings()
+			settings.roundTripperFactory = func(_ *rest.Config) (http.RoundTripper, spdy.Upgrader, error) {
+				return &statusRoundTripper{status: tt.status}, &stubUpgrader{}, nil
+			}
+
+			pf, err := newKubectlPortForwarder(
+				context.Background(), settings, "ns", "pod", []string{"0:9200"}, make(chan struct{}), nil, nil,
+			)
+			require.NoError(t, err)
+			err = pf.ForwardPorts()
+			require.Error(t, err)
+			if !tt.wantForbidden {
+				require.NotErrorIs(t, err, ErrForbidden)
+				return
+			}
+			require.ErrorIs(t, err, ErrForbidden)
+			assert.Contains(t, err.Error(), "the create verb on the pods/portforward resource is required in namespace ns")
+		})
+	}
+}
+
+func TestWithRoundTripperFactory(t *testing.T) {
+	rt := &capturingRoundTripper{}
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithRoundTripperFactory(capturingRoundTripperFactory(rt)))
+
+	transport, _, err := fwd.kubectl.roundTripperFactory(&rest.Config{})
+	require.NoError(t, err)
+	require.Equal(t, rt, transport)
+}
+
+func TestWithProxyURL(t *testing.T) {
+	setTestKubeconfig(t)
+
+	proxyURL, err := url.Parse("http://proxy.example.com:3128")
+	require.NoError(t, err)
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithProxyURL(proxyURL))
+
+	var gotProxyURL *url.URL
+	fwd.kubectl.roundTripperFactory = func(cfg *rest.Config) (http.RoundTripper, spdy.Upgrader, error) {
+		require.NotNil(t, cfg.Proxy)
+		gotProxyURL, err = cfg.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "10.0.0.1:6443"}})
+		require.NoError(t, err)
+		return &capturingRoundTripper{}, &stubUpgrader{}, nil
+	}
+
+	_, err = newKubectlPortForwarder(
+		context.Background(), fwd.kubectl, "bar", "foo", []string{"0:9200"}, make(chan struct{}), nil, nil,
+	)
+	require.NoError(t, err)
+	require.Equal(t, proxyURL, gotProxyURL)
+}
+
+func TestWithStreamProtocol(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol StreamProtocol
+		wantErr  string
+	}{
+		{
+			name:     "spdy",
+			protocol: StreamProtocolSPDY,
+		},
+		{
+			name:     "websocket is not supported",
+			protocol: "websocket",
+			wantErr:  `portforward: unsupported stream protocol "websocket"`,
+		},
+		{
+			name:     "unknown protocol",
+			protocol: "carrier-pigeon",
+			wantErr:  `portforward: unsupported stream protocol "carrier-pigeon"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestKubeconfig(t)
+
+			rt := &capturingRoundTripper{}
+			fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200",
+				WithStreamProtocol(tt.protocol),
+				WithRoundTripperFactory(capturingRoundTripperFactory(rt)),
+			)
+			pf, err := newKubectlPortForwarder(
+				context.Background(), fwd.kubectl, "bar", "foo", []string{"0:9200"}, make(chan struct{}), nil, nil,
+			)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Error(t, pf.ForwardPorts())
+			require.Len(t, rt.requests, 1)
+			assert.Equal(t, http.MethodPost, rt.requests[0].Method)
+		})
+	}
+}
+
+func TestWithSharedClientsetAndTransport(t *testing.T) {
+	// the ambient configuration is not needed when everything is shared
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: "https://10.0.0.3:6443"})
+	require.NoError(t, err)
+	rt := &capturingRoundTripper{}
+	opts := []PodForwarderOption{
+		WithSharedClientset(clientSet),
+		WithSharedTransport(rt, &stubUpgrader{}),
+		WithRoundTripperFactory(func(_ *rest.Config) (http.RoundTripper, spdy.Upgrader, error) {
+			t.Error("the round tripper factory should not be called with a shared transport")
+			return nil, nil, errors.New("unexpected call")
+		}),
+	}
+
+	for _, addr := range []string{"foo.bar.pod:9200", "baz.bar.pod:9200"} {
+		fwd := NewPodForwarderWithTest(t, "tcp", addr, opts...)
+		pf, err := newKubectlPortForwarder(
+			context.Background(), fwd.kubectl, fwd.podNSN.Namespace, fwd.podNSN.Name, []string{"0:9200"},
+			make(chan struct{}), nil, nil,
+		)
+		require.NoError(t, err)
+		require.Error(t, pf.ForwardPorts())
+	}
+
+	require.Len(t, rt.requests, 2)
+	assert.Equal(t, "https://10.0.0.3:6443/api/v1/namespaces/bar/pods/foo/portforward?timeout=32s", rt.requests[0].URL.String())
+	assert.Equal(t, "https://10.0.0.3:6443/api/v1/namespaces/bar/pods/baz/portforward?timeout=32s", rt.requests[1].URL.String())
+}
+
+func TestWithRequestHook(t *testing.T) {
+	setTestKubeconfig(t)
+
+	rt := &capturingRoundTripper{}
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200",
+		WithRoundTripperFactory(capturingRoundTripperFactory(rt)),
+		WithRequestHook(func(req *http.Request) {
+			req.Header.Set("X-Audit-Reason", "debugging")
+			q := req.URL.Query()
+			q.Set("feature", "enabled")
+			req.URL.RawQuery = q.Encode()
+		}),
+	)
+	pf, err := newKubectlPortForwarder(
+		context.Background(), fwd.kubectl, "bar", "foo", []string{"0:9200"}, make(chan struct{}), nil, nil,
+	)
+	require.NoError(t, err)
+	require.Error(t, pf.ForwardPorts())
+
+	require.Len(t, rt.requests, 1)
+	req := rt.requests[0]
+	assert.Equal(t, "debugging", req.Header.Get("X-Audit-Reason"))
+	assert.Equal(t, "https://10.0.0.1:6443/api/v1/namespaces/bar/pods/foo/portforward?feature=enabled&timeout=32s", req.URL.String())
+}
+
+func TestWithInsecureSkipTLSVerify(t *testing.T) {
+	cfg := &rest.Config{
+		Host:            "https://10.0.0.2:6443",
+		TLSClientConfig: rest.TLSClientConfig{CAData: []byte("ca")},
+	}
+	var transportCfg *rest.Config
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200",
+		WithRestConfig(cfg),
+		WithInsecureSkipTLSVerify(),
+		WithRoundTripperFactory(func(cfg *rest.Config) (http.RoundTripper, spdy.Upgrader, error) {
+			transportCfg = cfg
+			return &capturingRoundTripper{}, &stubUpgrader{}, nil
+		}),
+	)
+	_, err := newKubectlPortForwarder(
+		context.Background(), fwd.kubectl, "bar", "foo", []string{"0:9200"}, make(chan struct{}), nil, nil,
+	)
+	require.NoError(t, err)
+
+	require.NotNil(t, transportCfg)
+	assert.True(t, transportCfg.Insecure)
+	assert.Empty(t, transportCfg.CAData)
+	// the configuration of the caller is left untouched
+	assert.False(t, cfg.Insecure)
+	assert.Equal(t, []byte("ca"), cfg.CAData)
+}
+
+func TestWithRestConfig(t *testing.T) {
+	// the ambient configuration is not used
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+
+	rt := &capturingRoundTripper{}
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200",
+		WithRestConfig(&rest.Config{Host: "https://10.0.0.2:6443"}),
+		WithRoundTripperFactory(capturingRoundTripperFactory(rt)),
+	)
+	pf, err := newKubectlPortForwarder(
+		context.Background(), fwd.kubectl, "bar", "foo", []string{"0:9200"}, make(chan struct{}), nil, nil,
+	)
+	require.NoError(t, err)
+	require.Error(t, pf.ForwardPorts())
+	require.Len(t, rt.requests, 1)
+	assert.Equal(t, "https://10.0.0.2:6443/api/v1/namespaces/bar/pods/foo/portforward?timeout=32s", rt.requests[0].URL.String())
+}
+
+func TestWithKubeconfig(t *testing.T) {
+	// the ambient configuration targets another cluster
+	setTestKubeconfigWithServer(t, "https://10.0.0.1:6443")
+	path := filepath.Join(t.TempDir(), "other-kubeconfig")
+	require.NoError(t, os.WriteFile(path, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://10.0.0.2:6443
+  name: default
+- cluster:
+    server: https://10.0.0.3:6443
+  name: other
+contexts:
+- context:
+    cluster: default
+    user: test
+  name: default
+- context:
+    cluster: other
+    user: test
+  name: other
+current-context: default
+users:
+- name: test
+  user:
+    token: test-token
+`), 0600))
+
+	tests := []struct {
+		name        string
+		kubeContext string
+		wantHost    string
+	}{
+		{
+			name:     "current context",
+			wantHost: "https://10.0.0.2:6443",
+		},
+		{
+			name:        "other context",
+			kubeContext: "other",
+			wantHost:    "https://10.0.0.3:6443",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var transportCfg *rest.Config
+			fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200",
+				WithKubeconfig(path, tt.kubeContext),
+				WithImpersonation(rest.ImpersonationConfig{UserName: "developer", Groups: []string{"dev"}}),
+				WithRoundTripperFactory(func(cfg *rest.Config) (http.RoundTripper, spdy.Upgrader, error) {
+					transportCfg = cfg
+					return &capturingRoundTripper{}, &stubUpgrader{}, nil
+				}),
+			)
+			_, err := newKubectlPortForwarder(
+				context.Background(), fwd.kubectl, "bar", "foo", []string{"0:9200"}, make(chan struct{}), nil, nil,
+			)
+			require.NoError(t, err)
+
+			require.NotNil(t, transportCfg)
+			assert.Equal(t, tt.wantHost, transportCfg.Host)
+			assert.Equal(t, "developer", transportCfg.Impersonate.UserName)
+			assert.Equal(t, []string{"dev"}, transportCfg.Impersonate.Groups)
+		})
+	}
+}
+
+func TestPodForwarder_Validate(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "/version", r.URL.Path)
+		_, _ = w.Write([]byte(`{"major": "1", "minor": "23"}`))
+	}))
+	defer apiServer.Close()
+
+	t.Run("valid rest config", func(t *testing.T) {
+		fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200",
+			WithRestConfig(&rest.Config{Host: apiServer.URL, BearerToken: "valid"}))
+		require.NoError(t, fwd.Validate(context.Background()))
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200",
+			WithRestConfig(&rest.Config{Host: apiServer.URL, BearerToken: "invalid"}))
+		err := fwd.Validate(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "portforward: reaching the API server: ")
+	})
+
+	t.Run("missing kube config", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+		fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+		err := fwd.Validate(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "portforward: loading kube config: ")
+	})
+}
+
+func Test_logDeduplicator_record(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	d := newLogDeduplicator(time.Minute, fakeClock)
+
+	type result struct {
+		logLine  bool
+		previous string
+		repeated int
+	}
+	record := func(line string) result {
+		logLine, previous, repeated := d.record(line)
+		return result{logLine: logLine, previous: previous, repeated: repeated}
+	}
+
+	require.Equal(t, result{logLine: true}, record("lost connection to pod"))
+	// identical lines within the window are suppressed
+	require.Equal(t, result{}, record("lost connection to pod"))
+	require.Equal(t, result{}, record("lost connection to pod"))
+
+	// and logged once with their count when the window is over
+	fakeClock.Step(time.Minute)
+	require.Equal(t, result{previous: "lost connection to pod", repeated: 3}, record("lost connection to pod"))
+	require.Equal(t, result{}, record("lost connection to pod"))
+
+	// or before a different line
+	require.Equal(t, result{logLine: true, previous: "lost connection to pod", repeated: 1}, record("Forwarding from 127.0.0.1:12345"))
+	require.Equal(t, result{logLine: true}, record("lost connection to pod"))
+
+	// a line repeated after the window without being suppressed is logged as is
+	fakeClock.Step(time.Minute)
+	require.Equal(t, result{logLine: true}, record("lost connection to pod"))
+}
+
+func Test_outputBuffer(t *testing.T) {
+	b := newOutputBuffer(3)
+	require.Empty(t, b.snapshot())
+
+	b.write([]byte("line 1\n\n"))
+	b.write([]byte("  line 2  \nline 3\n"))
+	require.Equal(t, []string{"line 1", "line 2", "line 3"}, b.snapshot())
+
+	b.write([]byte("line 4\nline 5"))
+	require.Equal(t, []string{"line 3", "line 4", "line 5"}, b.snapshot())
+
+	// the snapshot is a copy
+	b.snapshot()[0] = "changed"
+	require.Equal(t, []string{"line 3", "line 4", "line 5"}, b.snapshot())
+}
@@ -0,0 +1,22 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"time"
+
+	utilclock "k8s.io/utils/clock"
+)
+
+// clock is used to measure and wait for time, so that time-based behavior can be tested deterministically with a
+// fake clock such as the one from k8s.io/utils/clock/testing.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) utilclock.Timer
+}
+
+// realClock is the clock used outside of tests
+var realClock clock = utilclock.RealClock{}
@@ -0,0 +1,134 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// trackedConn wraps the connections returned by a forwarder to keep track of their usage.
+//
+// Every net.Conn method that is not about usage tracking must be delegated as-is to the underlying connection, so
+// that wrapping does not change the connection behavior.
+type trackedConn struct {
+	net.Conn
+
+	closeOnce sync.Once
+	// onClose is called once when the connection is closed
+	onClose func()
+	// closed is closed once the connection is closed if not nil
+	closed chan struct{}
+	// bytesRead and bytesWritten are incremented with the bytes read from and written to the connection if not nil
+	bytesRead, bytesWritten *int64
+	// bytesReadMetric and bytesWrittenMetric are incremented with the bytes read and written if not nil
+	bytesReadMetric, bytesWrittenMetric prometheus.Counter
+}
+
+var _ net.Conn = &trackedConn{}
+
+// ErrHalfCloseNotSupported is returned when half-closing a connection whose underlying connection does not support it.
+var ErrHalfCloseNotSupported = errors.New("half-close is not supported by the underlying connection")
+
+// closeWriter is implemented by connections that can shut down their writing side, such as *net.TCPConn
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// closeReader is implemented by connections that can shut down their reading side, such as *net.TCPConn
+type closeReader interface {
+	CloseRead() error
+}
+
+// Read reads from the underlying connection, counting the bytes read.
+func (c *trackedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if c.bytesRead != nil {
+		atomic.AddInt64(c.bytesRead, int64(n))
+	}
+	if c.bytesReadMetric != nil {
+		c.bytesReadMetric.Add(float64(n))
+	}
+	return n, err
+}
+
+// Write writes to the underlying connection, counting the bytes written.
+func (c *trackedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if c.bytesWritten != nil {
+		atomic.AddInt64(c.bytesWritten, int64(n))
+	}
+	if c.bytesWrittenMetric != nil {
+		c.bytesWrittenMetric.Add(float64(n))
+	}
+	return n, err
+}
+
+// Close closes the underlying connection.
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		if c.onClose != nil {
+			c.onClose()
+		}
+		if c.closed != nil {
+			close(c.closed)
+		}
+	})
+	return err
+}
+
+// closeWhenDone closes the connection when ctx is done, unless the connection is closed first.
+func (c *trackedConn) closeWhenDone(ctx context.Context) {
+	if ctx.Done() == nil {
+		// the context is never done
+		return
+	}
+	c.closed = make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = c.Close()
+		case <-c.closed:
+		}
+	}()
+}
+
+// SetDeadline sets the read and write deadlines of the underlying connection.
+func (c *trackedConn) SetDeadline(t time.Time) error {
+	return c.Conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the underlying connection.
+func (c *trackedConn) SetReadDeadline(t time.Time) error {
+	return c.Conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying connection.
+func (c *trackedConn) SetWriteDeadline(t time.Time) error {
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// CloseWrite shuts down the writing side of the underlying connection if it supports it.
+func (c *trackedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(closeWriter); ok {
+		return cw.CloseWrite()
+	}
+	return ErrHalfCloseNotSupported
+}
+
+// CloseRead shuts down the reading side of the underlying connection if it supports it.
+func (c *trackedConn) CloseRead() error {
+	if cr, ok := c.Conn.(closeReader); ok {
+		return cr.CloseRead()
+	}
+	return ErrHalfCloseNotSupported
+}
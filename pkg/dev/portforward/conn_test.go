@@ -0,0 +1,322 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTrackedConn(t *testing.T) (*trackedConn, net.Conn) {
+	t.Helper()
+	local, remote := net.Pipe()
+	t.Cleanup(func() {
+		_ = local.Close()
+		_ = remote.Close()
+	})
+	return &trackedConn{Conn: local, onClose: func() {}}, remote
+}
+
+func Test_trackedConn_deadlines(t *testing.T) {
+	t.Run("read deadline", func(t *testing.T) {
+		conn, _ := newTestTrackedConn(t)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+		_, err := conn.Read(make([]byte, 1))
+		require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	})
+	t.Run("write deadline", func(t *testing.T) {
+		conn, _ := newTestTrackedConn(t)
+		require.NoError(t, conn.SetWriteDeadline(time.Now().Add(10*time.Millisecond)))
+		_, err := conn.Write([]byte("nobody is reading"))
+		require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	})
+	t.Run("read and write deadline", func(t *testing.T) {
+		conn, _ := newTestTrackedConn(t)
+		require.NoError(t, conn.SetDeadline(time.Now().Add(10*time.Millisecond)))
+		_, err := conn.Read(make([]byte, 1))
+		require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+		_, err = conn.Write([]byte("nobody is reading"))
+		require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	})
+	t.Run("deadline reset", func(t *testing.T) {
+		conn, remote := newTestTrackedConn(t)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(-time.Second)))
+		require.NoError(t, conn.SetReadDeadline(time.Time{}))
+		go func() {
+			_, _ = remote.Write([]byte("x"))
+		}()
+		n, err := conn.Read(make([]byte, 1))
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+	})
+}
+
+func Test_trackedConn_Close(t *testing.T) {
+	closed := 0
+	conn, _ := newTestTrackedConn(t)
+	conn.onClose = func() {
+		closed++
+	}
+	require.NoError(t, conn.Close())
+	require.NoError(t, conn.Close())
+	require.Equal(t, 1, closed)
+}
+
+func Test_trackedConn_CloseWrite(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, err := listener.Accept()
+		assert.NoError(t, err)
+		accepted <- conn
+	}()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	server := <-accepted
+	require.NotNil(t, server)
+	defer server.Close()
+
+	var conn net.Conn = &trackedConn{Conn: client, onClose: func() {}}
+	defer conn.Close()
+	halfCloser, ok := conn.(interface{ CloseWrite() error })
+	require.True(t, ok)
+	require.NoError(t, halfCloser.CloseWrite())
+
+	// the server sees the end of the stream while the client can still read
+	_, err = io.ReadAll(server)
+	require.NoError(t, err)
+	_, err = server.Write([]byte("response"))
+	require.NoError(t, err)
+	buf := make([]byte, len("response"))
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "response", string(buf))
+}
+
+func Test_trackedConn_halfCloseNotSupported(t *testing.T) {
+	conn, _ := newTestTrackedConn(t)
+	require.ErrorIs(t, conn.CloseWrite(), ErrHalfCloseNotSupported)
+	require.ErrorIs(t, conn.CloseRead(), ErrHalfCloseNotSupported)
+}
+
+func Test_podForwarder_ActiveConnections(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.viaAddr = "127.0.0.1:12345"
+	close(fwd.initChan)
+	fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+		local, _ := net.Pipe()
+		return local, nil
+	}
+
+	conn1, err := fwd.DialContext(context.Background())
+	require.NoError(t, err)
+	conn2, err := fwd.DialContext(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), fwd.ActiveConnections())
+
+	require.NoError(t, conn1.Close())
+	require.NoError(t, conn1.Close())
+	require.Equal(t, int64(1), fwd.ActiveConnections())
+	require.NoError(t, conn2.Close())
+	require.Equal(t, int64(0), fwd.ActiveConnections())
+}
+
+func Test_podForwarder_Stats(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	require.Equal(t, ForwarderStats{State: StateInitializing}, fwd.Stats())
+
+	fwd.setReady("127.0.0.1:12345", nil)
+	close(fwd.initChan)
+	remotes := make(chan net.Conn, 2)
+	fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+		local, remote := net.Pipe()
+		remotes <- remote
+		return local, nil
+	}
+
+	conn1, err := fwd.DialContext(context.Background())
+	require.NoError(t, err)
+	defer conn1.Close()
+	remote1 := <-remotes
+	conn2, err := fwd.DialContext(context.Background())
+	require.NoError(t, err)
+	<-remotes
+
+	go func() {
+		_, _ = remote1.Write([]byte("pong"))
+		_, _ = io.ReadFull(remote1, make([]byte, 7))
+	}()
+	_, err = io.ReadFull(conn1, make([]byte, 4))
+	require.NoError(t, err)
+	_, err = conn1.Write([]byte("ping me"))
+	require.NoError(t, err)
+	require.NoError(t, conn2.Close())
+
+	require.Equal(t, ForwarderStats{
+		State:             StateReady,
+		LocalAddr:         "127.0.0.1:12345",
+		ActiveConnections: 1,
+		TotalDials:        2,
+		BytesRead:         4,
+		BytesWritten:      7,
+	}, fwd.Stats())
+
+	fwd.setFailed(ErrLostConnection)
+	stats := fwd.Stats()
+	require.Equal(t, StateFailed, stats.State)
+	require.Empty(t, stats.LocalAddr)
+	require.Equal(t, ErrLostConnection, stats.LastError)
+	require.Equal(t, int64(2), stats.TotalDials)
+}
+
+func Test_podForwarder_Dial(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.viaAddr = "127.0.0.1:12345"
+	close(fwd.initChan)
+	dialer := &capturingDialer{}
+	fwd.dialerFunc = dialer.DialContext
+
+	_, err := fwd.Dial()
+	require.NoError(t, err)
+	require.Equal(t, []string{"127.0.0.1:12345"}, dialer.addresses)
+}
+
+func Test_podForwarder_MaxConcurrentDials(t *testing.T) {
+	newForwarder := func(failFast bool) *PodForwarder {
+		fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithMaxConcurrentDials(1, failFast))
+		fwd.viaAddr = "127.0.0.1:12345"
+		close(fwd.initChan)
+		fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+			local, _ := net.Pipe()
+			return local, nil
+		}
+		return fwd
+	}
+
+	t.Run("fail fast", func(t *testing.T) {
+		fwd := newForwarder(true)
+		conn, err := fwd.DialContext(context.Background())
+		require.NoError(t, err)
+		_, err = fwd.DialContext(context.Background())
+		require.ErrorIs(t, err, ErrTooManyConnections)
+
+		require.NoError(t, conn.Close())
+		conn, err = fwd.DialContext(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+	})
+
+	t.Run("block until a connection is closed", func(t *testing.T) {
+		fwd := newForwarder(false)
+		conn, err := fwd.DialContext(context.Background())
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = fwd.DialContext(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		dialed := make(chan error)
+		go func() {
+			conn, err := fwd.DialContext(context.Background())
+			if err == nil {
+				err = conn.Close()
+			}
+			dialed <- err
+		}()
+		require.NoError(t, conn.Close())
+		require.NoError(t, <-dialed)
+		require.Equal(t, int64(0), fwd.ActiveConnections())
+	})
+
+	t.Run("failed dials release their slot", func(t *testing.T) {
+		fwd := newForwarder(true)
+		fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+			return nil, errors.New("dial failed")
+		}
+		_, err := fwd.DialContext(context.Background())
+		require.EqualError(t, err, "dial failed")
+		_, err = fwd.DialContext(context.Background())
+		require.EqualError(t, err, "dial failed")
+	})
+}
+
+func Test_podForwarder_DialContext_contextScopedConns(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []PodForwarderOption
+		wantClosed bool
+	}{
+		{
+			name:       "connections outlive the dial context by default",
+			wantClosed: false,
+		},
+		{
+			name:       "context scoped connections are closed with the dial context",
+			opts:       []PodForwarderOption{WithContextScopedConns()},
+			wantClosed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", tt.opts...)
+			fwd.viaAddr = "127.0.0.1:12345"
+			close(fwd.initChan)
+			fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+				local, remote := net.Pipe()
+				t.Cleanup(func() {
+					_ = remote.Close()
+				})
+				return local, nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			conn, err := fwd.DialContext(ctx)
+			require.NoError(t, err)
+			defer conn.Close()
+			require.Equal(t, int64(1), fwd.ActiveConnections())
+
+			cancel()
+			if tt.wantClosed {
+				require.Eventually(t, func() bool {
+					return fwd.ActiveConnections() == 0
+				}, 5*time.Second, time.Millisecond)
+				_, err := conn.Write([]byte("foo"))
+				require.ErrorIs(t, err, io.ErrClosedPipe)
+				return
+			}
+			require.Never(t, func() bool {
+				return fwd.ActiveConnections() == 0
+			}, 50*time.Millisecond, time.Millisecond)
+		})
+	}
+}
+
+func Test_trackedConn_closeWhenDone(t *testing.T) {
+	conn, _ := newTestTrackedConn(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn.closeWhenDone(ctx)
+
+	// closing the connection first stops watching the context
+	require.NoError(t, conn.Close())
+	select {
+	case <-conn.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closed channel not closed with the connection")
+	}
+}
@@ -6,8 +6,8 @@ package portforward
 
 import (
 	"context"
+	"fmt"
 	"net"
-	"strings"
 	"sync"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -21,6 +21,9 @@ type ForwardingDialer struct {
 	initOnce sync.Once
 	client   client.Client
 
+	// scope is the context bounding the lifetime of the dialer, if any
+	scope context.Context
+
 	// forwarderFactory is used to inject a custom Forwarder during testing.
 	forwarderFactory ForwardingDialerForwarderFactory
 }
@@ -36,18 +39,35 @@ func NewForwardingDialer() *ForwardingDialer {
 	}
 }
 
+// NewScopedForwardingDialer creates a new, initialized ForwardingDialer that is closed when the given context is done,
+// which stops all the forwarders it created. Dialing fails once the context is done.
+func NewScopedForwardingDialer(ctx context.Context) *ForwardingDialer {
+	d := NewForwardingDialer()
+	d.scope = ctx
+	go func() {
+		<-ctx.Done()
+		d.Close()
+	}()
+	return d
+}
+
 // defaultForwarderFactory is the default podForwarder factory used outside of tests
 var defaultForwarderFactory = ForwardingDialerForwarderFactory(
 	func(ctx context.Context, client client.Client, network, addr string) (Forwarder, error) {
-		if strings.Contains(addr, ".svc:") || strings.Contains(addr, ".svc.") {
-			// it looks like a service url, so forward as a service
-			return NewServiceForwarder(client, network, addr)
+		clients, err := getDefaultSharedClients()
+		if err != nil {
+			return nil, err
 		}
-		clientset, err := newDefaultKubernetesClientset()
+		target, err := parseAddr(ctx, addr, clients.Clientset(), "")
 		if err != nil {
 			return nil, err
 		}
-		return NewPodForwarder(ctx, network, addr, clientset)
+		switch target.Kind {
+		case serviceAddrKind:
+			return NewServiceForwarder(client, network, addr)
+		default:
+			return clients.PodForwarderFactory(defaultPodForwarderOptions()...)(ctx, network, addr)
+		}
 	},
 )
 
@@ -57,17 +77,15 @@ type Forwarder interface {
 	Run(ctx context.Context) error
 	// DialContext creates a connection to the forwarded address
 	DialContext(ctx context.Context) (net.Conn, error)
+	// Draining returns true once the forwarder stopped accepting new connections while it keeps running until the
+	// active ones are closed
+	Draining() bool
 }
 
 // initIfRequired initializes the dialer once if required.
 func (d *ForwardingDialer) initIfRequired() {
 	d.initOnce.Do(func() {
-		restConfig, err := config.GetConfig()
-		if err != nil {
-			panic(err)
-		}
-
-		client, err := client.New(restConfig, client.Options{})
+		client, err := newDefaultClient()
 		if err != nil {
 			panic(err)
 		}
@@ -76,19 +94,47 @@ func (d *ForwardingDialer) initIfRequired() {
 	})
 }
 
-// DialContext uses a cached internal podForwarder to redirect connections.
-//
-// There is no garbage collection involved, so the redirect and podForwarder will live for the duration of
-// the process.
+// newDefaultClient creates a new client using the default configuration
+func newDefaultClient() (client.Client, error) {
+	restConfig, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("portforward: loading kube config: %w", err)
+	}
+	c, err := client.New(restConfig, client.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("portforward: building kube client: %w", err)
+	}
+	return c, nil
+}
+
+// DialContext dials addr through a forwarder shared with the other connections to the same target. The forwarder keeps
+// running once all the connections dialed through it are closed, to be reused by the next dials, until it is idle for
+// 5 minutes or it is the least recently used one above 100 forwarders.
 func (d *ForwardingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.scope != nil && d.scope.Err() != nil {
+		return nil, ErrStoreClosed
+	}
+
 	d.initIfRequired()
 
-	fwd, err := d.store.GetOrCreateForwarder(network, addr, d.newForwarder)
+	fwd, release, err := d.store.AcquireForwarder(network, addr, d.newForwarder)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := fwd.DialContext(ctx)
 	if err != nil {
+		release()
 		return nil, err
 	}
+	// release the forwarder once the connection is closed
+	return &trackedConn{Conn: conn, onClose: release}, nil
+}
 
-	return fwd.DialContext(ctx)
+// Close stops all the forwarders created by this dialer and waits for them to return. Dialing fails with
+// ErrStoreClosed afterwards.
+func (d *ForwardingDialer) Close() {
+	d.store.Close()
 }
 
 // newForwarder adapts our internal forwarder factory to the forwarderStore one.
@@ -8,9 +8,13 @@ import (
 	"context"
 	"errors"
 	"net"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	testingclock "k8s.io/utils/clock/testing"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -28,6 +32,10 @@ func (f *stubForwarder) Run(ctx context.Context) error {
 	return nil
 }
 
+func (f *stubForwarder) Draining() bool {
+	return false
+}
+
 func (f *stubForwarder) DialContext(ctx context.Context) (net.Conn, error) {
 	if f.onDialContext != nil {
 		return f.onDialContext(ctx)
@@ -52,3 +60,115 @@ func TestForwardingDialer_DialContext(t *testing.T) {
 	_, err := d.DialContext(context.Background(), "tcp", "localhost:8080")
 	assert.Equal(t, customError, err)
 }
+
+func TestForwardingDialer_DialContext_sharedForwarder(t *testing.T) {
+	var created int32
+	stopped := make(chan struct{})
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	d := &ForwardingDialer{
+		store: newForwarderStore(fakeClock),
+		forwarderFactory: func(_ context.Context, _ client.Client, network, addr string) (Forwarder, error) {
+			atomic.AddInt32(&created, 1)
+			return &stubForwarder{
+				network: network, addr: addr,
+				onRun: func(ctx context.Context) error {
+					<-ctx.Done()
+					close(stopped)
+					return nil
+				},
+				onDialContext: func(ctx context.Context) (net.Conn, error) {
+					local, _ := net.Pipe()
+					return local, nil
+				},
+			}, nil
+		},
+	}
+	d.initOnce.Do(func() {}) // don't init with kubeconfig
+	defer d.Close()
+
+	isStopped := func() bool {
+		select {
+		case <-stopped:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// different addresses of the same pod port share a single forwarder
+	first, err := d.DialContext(context.Background(), "tcp", "foo.bar.pod:9200")
+	require.NoError(t, err)
+	second, err := d.DialContext(context.Background(), "tcp", "foo.bar.pod.cluster.local:9200")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&created))
+
+	// the forwarder keeps running once the connections are closed, to be reused by the next dials
+	require.NoError(t, first.Close())
+	require.NoError(t, first.Close())
+	require.NoError(t, second.Close())
+	require.Never(t, isStopped, 50*time.Millisecond, time.Millisecond)
+	third, err := d.DialContext(context.Background(), "tcp", "foo.bar.pod:9200")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&created))
+	require.NoError(t, third.Close())
+
+	// until it is idle for too long
+	require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+	fakeClock.Step(defaultIdleTimeout)
+	require.Eventually(t, isStopped, 5*time.Second, time.Millisecond)
+}
+
+func TestNewScopedForwardingDialer(t *testing.T) {
+	scope, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stopped := make(chan struct{})
+	d := NewScopedForwardingDialer(scope)
+	d.forwarderFactory = func(_ context.Context, _ client.Client, network, addr string) (Forwarder, error) {
+		return &stubForwarder{
+			network: network, addr: addr,
+			onRun: func(ctx context.Context) error {
+				<-ctx.Done()
+				close(stopped)
+				return nil
+			},
+		}, nil
+	}
+	d.initOnce.Do(func() {}) // don't init with kubeconfig
+
+	_, err := d.DialContext(context.Background(), "tcp", "localhost:8080")
+	require.NoError(t, err)
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("forwarder was not stopped when the scope was cancelled")
+	}
+
+	_, err = d.DialContext(context.Background(), "tcp", "localhost:8080")
+	require.ErrorIs(t, err, ErrStoreClosed)
+}
+
+func TestForwardingDialer_Close(t *testing.T) {
+	d := NewForwardingDialer()
+	d.forwarderFactory = func(_ context.Context, _ client.Client, network, addr string) (Forwarder, error) {
+		return &stubForwarder{network: network, addr: addr}, nil
+	}
+	d.initOnce.Do(func() {}) // don't init with kubeconfig
+
+	addrs := []string{"localhost:8080", "localhost:8081"}
+	for _, addr := range addrs {
+		_, err := d.DialContext(context.Background(), "tcp", addr)
+		require.NoError(t, err)
+	}
+	d.store.Lock()
+	require.Len(t, d.store.shared, len(addrs))
+	d.store.Unlock()
+
+	d.Close()
+	// forwarders are removed from the store once they return
+	require.Empty(t, d.store.shared)
+	_, err := d.DialContext(context.Background(), "tcp", "localhost:8080")
+	require.ErrorIs(t, err, ErrStoreClosed)
+}
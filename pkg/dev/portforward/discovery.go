@@ -0,0 +1,66 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDiscoveryTimeout is returned when the API calls made to find the pods to forward to take too long
+var ErrDiscoveryTimeout = errors.New("timed out discovering the pods to forward to")
+
+// DiscoveryTimeouts bound the API calls made to find the pods to forward to, independently of the time spent waiting
+// for the forwarding to be ready. Zero values mean no timeout other than the deadline of the caller's context.
+type DiscoveryTimeouts struct {
+	// Overall bounds the time spent on all the API calls of a discovery.
+	Overall time.Duration
+	// PerAttempt bounds the time spent on each API call.
+	PerAttempt time.Duration
+}
+
+// enabled returns true if any of the timeouts is set.
+func (t DiscoveryTimeouts) enabled() bool {
+	return t.Overall > 0 || t.PerAttempt > 0
+}
+
+// withOverallTimeout returns a context bounded by the overall discovery timeout.
+func (t DiscoveryTimeouts) withOverallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t.Overall <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, t.Overall)
+}
+
+// call runs the given API call, returning an error wrapping ErrDiscoveryTimeout if it is not done within the
+// per-attempt timeout or before ctx expires. The call is abandoned rather than waited for if it ignores its context.
+func (t DiscoveryTimeouts) call(ctx context.Context, what string, fn func(ctx context.Context) error) error {
+	if !t.enabled() {
+		return fn(ctx)
+	}
+	if t.PerAttempt > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.PerAttempt)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w while %s: %s", ErrDiscoveryTimeout, what, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDiscoveryTimeouts_call(t *testing.T) {
+	blocking := func(release chan struct{}) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			// ignore the context like a slow fake client would
+			<-release
+			return nil
+		}
+	}
+	tests := []struct {
+		name     string
+		timeouts DiscoveryTimeouts
+		wantErr  error
+	}{
+		{
+			name:     "per attempt timeout",
+			timeouts: DiscoveryTimeouts{PerAttempt: 10 * time.Millisecond},
+			wantErr:  ErrDiscoveryTimeout,
+		},
+		{
+			name:     "overall timeout",
+			timeouts: DiscoveryTimeouts{Overall: 10 * time.Millisecond},
+			wantErr:  ErrDiscoveryTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			defer close(release)
+			ctx, cancel := tt.timeouts.withOverallTimeout(context.Background())
+			defer cancel()
+			err := tt.timeouts.call(ctx, "testing", blocking(release))
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+
+	t.Run("errors are returned as is", func(t *testing.T) {
+		callErr := errors.New("forbidden")
+		err := DiscoveryTimeouts{PerAttempt: time.Minute}.call(context.Background(), "testing", func(context.Context) error {
+			return callErr
+		})
+		require.Equal(t, callErr, err)
+	})
+}
+
+func Test_findPodForSelector_discoveryTimeout(t *testing.T) {
+	masterLabels := map[string]string{"role": "master"}
+	clientset := fake.NewSimpleClientset(newTestPod("es-master-0", true, masterLabels))
+	release := make(chan struct{})
+	defer close(release)
+	clientset.PrependReactor("list", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		// delay the list call past the discovery timeout
+		<-release
+		return false, nil, nil
+	})
+
+	_, err := findPodForSelector(context.Background(), PodSelector{
+		Namespace:         "ns",
+		Selector:          labels.SelectorFromSet(masterLabels),
+		DiscoveryTimeouts: DiscoveryTimeouts{PerAttempt: 10 * time.Millisecond},
+	}, clientset)
+	require.ErrorIs(t, err, ErrDiscoveryTimeout)
+	require.Contains(t, err.Error(), "while listing pods")
+}
@@ -0,0 +1,86 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// endpointEjector keeps the pods of a service that fail consecutive dials out of the endpoints a service forwarder
+// selects from for a while, so that connections only go to the healthy pods meanwhile.
+type endpointEjector struct {
+	// threshold is the number of consecutive failed dials after which a pod is ejected
+	threshold int
+	// duration is the time during which an ejected pod is not selected
+	duration time.Duration
+	// clock is used to facilitate testing time-based behavior
+	clock clock
+
+	mu sync.Mutex
+	// failures is the number of consecutive failed dials per pod address
+	failures map[string]int
+	// ejectedUntil is the time until which a pod is ejected per pod address
+	ejectedUntil map[string]time.Time
+}
+
+// newEndpointEjector returns an endpointEjector ejecting pods for duration after threshold consecutive failed dials.
+func newEndpointEjector(threshold int, duration time.Duration) *endpointEjector {
+	return &endpointEjector{
+		threshold:    threshold,
+		duration:     duration,
+		clock:        realClock,
+		failures:     make(map[string]int),
+		ejectedUntil: make(map[string]time.Time),
+	}
+}
+
+// filter returns the endpoints whose pods are not ejected, or all of them if they all are, since a connection to a
+// possibly unhealthy pod beats no connection at all.
+func (e *endpointEjector) filter(
+	endpoints []*corev1.ObjectReference,
+	targetPort intstr.IntOrString,
+) []*corev1.ObjectReference {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.clock.Now()
+	healthy := make([]*corev1.ObjectReference, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		addr := podTargetAddr(endpoint, targetPort)
+		if until, ejected := e.ejectedUntil[addr]; ejected {
+			if now.Before(until) {
+				continue
+			}
+			delete(e.ejectedUntil, addr)
+		}
+		healthy = append(healthy, endpoint)
+	}
+	if len(healthy) == 0 {
+		return endpoints
+	}
+	return healthy
+}
+
+// record records the outcome of a dial to the pod at the given address, ejecting the pod once it failed threshold
+// consecutive dials.
+func (e *endpointEjector) record(podAddr string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err == nil {
+		delete(e.failures, podAddr)
+		return
+	}
+	e.failures[podAddr]++
+	if e.failures[podAddr] < e.threshold {
+		return
+	}
+	log.Info("Ejecting pod after consecutive dial failures",
+		"addr", podAddr, "failures", e.failures[podAddr], "duration", e.duration, "error", err.Error())
+	e.ejectedUntil[podAddr] = e.clock.Now().Add(e.duration)
+	delete(e.failures, podAddr)
+}
@@ -0,0 +1,92 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	testingclock "k8s.io/utils/clock/testing"
+
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+func Test_serviceForwarder_DialContext_ejection(t *testing.T) {
+	c := k8s.NewFakeClient(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "es-headless", Namespace: "ns"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 9200}}},
+		},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "es-headless", Namespace: "ns"},
+			Subsets: []corev1.EndpointSubset{
+				{
+					Ports: []corev1.EndpointPort{{Port: 9200}},
+					Addresses: []corev1.EndpointAddress{
+						{TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "es-0", Namespace: "ns"}},
+						{TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "es-1", Namespace: "ns"}},
+					},
+				},
+			},
+		},
+	)
+	f, err := NewServiceForwarder(c, "tcp", "es-headless.ns.svc:9200",
+		WithEndpointSelector(RoundRobin()), WithEjection(2, time.Minute))
+	require.NoError(t, err)
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	f.ejector.clock = fakeClock
+
+	var dialed []string
+	f.podForwarderFactory = func(_ context.Context, _, addr string) (Forwarder, error) {
+		return &stubForwarder{
+			onDialContext: func(ctx context.Context) (net.Conn, error) {
+				dialed = append(dialed, addr)
+				if addr == "es-0.ns.pod:9200" {
+					return nil, errors.New("connection refused")
+				}
+				return nil, nil
+			},
+		}, nil
+	}
+
+	dial := func(times int) {
+		for i := 0; i < times; i++ {
+			_, _ = f.DialContext(context.Background())
+		}
+	}
+	// es-0 is ejected after its second consecutive failure
+	dial(5)
+	require.Equal(t, []string{
+		"es-0.ns.pod:9200", "es-1.ns.pod:9200", "es-0.ns.pod:9200", "es-1.ns.pod:9200", "es-1.ns.pod:9200",
+	}, dialed)
+
+	// and selected again once the ejection is over
+	fakeClock.Step(time.Minute)
+	dial(1)
+	require.Equal(t, "es-0.ns.pod:9200", dialed[len(dialed)-1])
+}
+
+func Test_endpointEjector_filter(t *testing.T) {
+	e := newEndpointEjector(1, time.Minute)
+	endpoints := []*corev1.ObjectReference{
+		{Kind: "Pod", Name: "es-0", Namespace: "ns"},
+		{Kind: "Pod", Name: "es-1", Namespace: "ns"},
+	}
+	port := intstr.FromInt(9200)
+
+	e.record("es-0.ns.pod:9200", errors.New("connection refused"))
+	require.Equal(t, endpoints[1:], e.filter(endpoints, port))
+
+	// all the pods are selected if they are all ejected
+	e.record("es-1.ns.pod:9200", errors.New("connection refused"))
+	require.Equal(t, endpoints, e.filter(endpoints, port))
+}
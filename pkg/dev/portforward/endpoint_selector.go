@@ -0,0 +1,56 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"math/rand"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// EndpointSelector chooses the pod a service forwarder forwards a connection to.
+type EndpointSelector interface {
+	// Select returns one of the given ready endpoints, which are sorted by namespace and name and never empty.
+	Select(endpoints []*corev1.ObjectReference) *corev1.ObjectReference
+}
+
+// EndpointSelectorFunc is a function that implements EndpointSelector
+type EndpointSelectorFunc func(endpoints []*corev1.ObjectReference) *corev1.ObjectReference
+
+// Select implements EndpointSelector.
+func (f EndpointSelectorFunc) Select(endpoints []*corev1.ObjectReference) *corev1.ObjectReference {
+	return f(endpoints)
+}
+
+// FirstReady always selects the first ready endpoint, so that connections go to the same pod as long as it is ready.
+var FirstReady EndpointSelector = EndpointSelectorFunc(func(endpoints []*corev1.ObjectReference) *corev1.ObjectReference {
+	return endpoints[0]
+})
+
+// Random selects a random ready endpoint for each connection, as an approximation to load balancing.
+var Random EndpointSelector = EndpointSelectorFunc(func(endpoints []*corev1.ObjectReference) *corev1.ObjectReference {
+	return endpoints[rand.Intn(len(endpoints))] //nolint:gosec
+})
+
+// RoundRobin returns an EndpointSelector that cycles through the ready endpoints.
+func RoundRobin() EndpointSelector {
+	return &roundRobinSelector{}
+}
+
+// roundRobinSelector selects the ready endpoints in turn
+type roundRobinSelector struct {
+	mu   sync.Mutex
+	next int
+}
+
+// Select implements EndpointSelector.
+func (s *roundRobinSelector) Select(endpoints []*corev1.ObjectReference) *corev1.ObjectReference {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoint := endpoints[s.next%len(endpoints)]
+	s.next = (s.next + 1) % len(endpoints)
+	return endpoint
+}
@@ -0,0 +1,47 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func testEndpoints(names ...string) []*corev1.ObjectReference {
+	endpoints := make([]*corev1.ObjectReference, 0, len(names))
+	for _, name := range names {
+		endpoints = append(endpoints, &corev1.ObjectReference{Kind: "Pod", Namespace: "ns", Name: name})
+	}
+	return endpoints
+}
+
+func selectNames(selector EndpointSelector, endpoints []*corev1.ObjectReference, times int) []string {
+	names := make([]string, 0, times)
+	for i := 0; i < times; i++ {
+		names = append(names, selector.Select(endpoints).Name)
+	}
+	return names
+}
+
+func TestFirstReady(t *testing.T) {
+	endpoints := testEndpoints("a", "b", "c")
+	require.Equal(t, []string{"a", "a", "a"}, selectNames(FirstReady, endpoints, 3))
+}
+
+func TestRandom(t *testing.T) {
+	endpoints := testEndpoints("a", "b", "c")
+	for _, name := range selectNames(Random, endpoints, 10) {
+		require.Contains(t, []string{"a", "b", "c"}, name)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	selector := RoundRobin()
+	require.Equal(t, []string{"a", "b", "c", "a"}, selectNames(selector, testEndpoints("a", "b", "c"), 4))
+	// the rotation carries on when the set of endpoints changes
+	require.Equal(t, []string{"b", "a", "b"}, selectNames(selector, testEndpoints("a", "b"), 3))
+}
@@ -0,0 +1,112 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// FailoverForwarder forwards connections to the highest priority forwarder that is healthy among several pod
+// forwarders, for example to a primary pod and to a secondary one when the forwarding to the primary is broken.
+//
+// Connections go back to a higher priority forwarder as soon as it is healthy again.
+type FailoverForwarder struct {
+	network string
+	// forwarders are ordered by decreasing priority
+	forwarders []Forwarder
+}
+
+var _ Forwarder = &FailoverForwarder{}
+
+// stateReporter is implemented by forwarders reporting their state, such as *PodForwarder
+type stateReporter interface {
+	State() ForwarderState
+}
+
+// NewFailoverForwarder returns a new initialized failover forwarder to the given pod addresses, ordered by decreasing
+// priority.
+func NewFailoverForwarder(network string, addrs ...string) (*FailoverForwarder, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("at least one address is required to fail over")
+	}
+	clients, err := getDefaultSharedClients()
+	if err != nil {
+		return nil, err
+	}
+	newForwarder := clients.PodForwarderFactory()
+	forwarders := make([]Forwarder, 0, len(addrs))
+	for _, addr := range addrs {
+		fwd, err := newForwarder(context.Background(), network, addr)
+		if err != nil {
+			return nil, err
+		}
+		forwarders = append(forwarders, fwd)
+	}
+	return &FailoverForwarder{network: network, forwarders: forwarders}, nil
+}
+
+// Run runs all the forwarders and blocks until all of them are done running. A forwarder returning an error does not
+// stop the others, which keep forwarding connections.
+func (f *FailoverForwarder) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, fwd := range f.forwarders {
+		wg.Add(1)
+		go func(fwd Forwarder) {
+			defer wg.Done()
+			if err := fwd.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Error(err, "Failover forwarder member returned with an error")
+			}
+		}(fwd)
+	}
+	wg.Wait()
+	return nil
+}
+
+// DialContext dials the highest priority forwarder that is healthy, falling back to the next ones if dialing it fails.
+// The highest priority forwarder is dialed if none of them is healthy, waiting for it to be ready.
+func (f *FailoverForwarder) DialContext(ctx context.Context) (net.Conn, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var firstErr error
+	for _, fwd := range f.forwarders {
+		if !healthy(fwd) {
+			continue
+		}
+		conn, err := fwd.DialContext(ctx)
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return f.forwarders[0].DialContext(ctx)
+}
+
+// Draining returns true once all the forwarders stopped accepting new connections.
+func (f *FailoverForwarder) Draining() bool {
+	for _, fwd := range f.forwarders {
+		if !fwd.Draining() {
+			return false
+		}
+	}
+	return true
+}
+
+// healthy returns true if the forwarder is ready to redirect connections, or does not report its state.
+func healthy(fwd Forwarder) bool {
+	reporter, ok := fwd.(stateReporter)
+	return !ok || reporter.State() == StateReady
+}
@@ -0,0 +1,133 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stateStubForwarder is a stubForwarder reporting a state that can be changed, and dialing an error naming it.
+type stateStubForwarder struct {
+	stubForwarder
+
+	mu    sync.Mutex
+	state ForwarderState
+}
+
+func newStateStubForwarder(name string, state ForwarderState) *stateStubForwarder {
+	f := &stateStubForwarder{state: state}
+	f.onDialContext = func(ctx context.Context) (net.Conn, error) {
+		return nil, fmt.Errorf("would dial: %s", name)
+	}
+	return f
+}
+
+func (f *stateStubForwarder) State() ForwarderState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+func (f *stateStubForwarder) setState(state ForwarderState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = state
+}
+
+func TestFailoverForwarder_DialContext(t *testing.T) {
+	primary := newStateStubForwarder("es-0", StateReady)
+	secondary := newStateStubForwarder("es-1", StateReady)
+	f := &FailoverForwarder{network: "tcp", forwarders: []Forwarder{primary, secondary}}
+
+	_, err := f.DialContext(context.Background())
+	require.EqualError(t, err, "would dial: es-0")
+
+	// the forwarding to the primary is broken
+	primary.setState(StateReconnecting)
+	_, err = f.DialContext(context.Background())
+	require.EqualError(t, err, "would dial: es-1")
+
+	// back to the primary once it recovers
+	primary.setState(StateReady)
+	_, err = f.DialContext(context.Background())
+	require.EqualError(t, err, "would dial: es-0")
+
+	// the primary is dialed, waiting for it to be ready, if none is healthy
+	primary.setState(StateFailed)
+	secondary.setState(StateInitializing)
+	_, err = f.DialContext(context.Background())
+	require.EqualError(t, err, "would dial: es-0")
+}
+
+func TestFailoverForwarder_DialContext_fallbackOnDialError(t *testing.T) {
+	primary := newStateStubForwarder("es-0", StateReady)
+	primary.onDialContext = func(ctx context.Context) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	secondary := newStateStubForwarder("es-1", StateReady)
+	secondary.onDialContext = func(ctx context.Context) (net.Conn, error) {
+		local, _ := net.Pipe()
+		return local, nil
+	}
+	f := &FailoverForwarder{network: "tcp", forwarders: []Forwarder{primary, secondary}}
+
+	conn, err := f.DialContext(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+}
+
+func TestFailoverForwarder_Run(t *testing.T) {
+	primaryErr := errors.New("pod deleted")
+	secondaryStopped := make(chan struct{})
+	f := &FailoverForwarder{network: "tcp", forwarders: []Forwarder{
+		&stubForwarder{onRun: func(ctx context.Context) error {
+			return primaryErr
+		}},
+		&stubForwarder{onRun: func(ctx context.Context) error {
+			defer close(secondaryStopped)
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() {
+		runErr <- f.Run(ctx)
+	}()
+
+	// the primary stopping does not stop the secondary
+	select {
+	case <-secondaryStopped:
+		t.Fatal("the secondary forwarder stopped with the primary")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cancel()
+	require.NoError(t, <-runErr)
+	<-secondaryStopped
+}
+
+func TestNewFailoverForwarder(t *testing.T) {
+	setTestKubeconfig(t)
+
+	f, err := NewFailoverForwarder("tcp", "es-0.ns.pod:9200", "es-1.ns.pod:9200")
+	require.NoError(t, err)
+	require.Len(t, f.forwarders, 2)
+	require.Equal(t, "es-0.ns.pod:9200", f.forwarders[0].(*PodForwarder).addr)
+
+	_, err = NewFailoverForwarder("tcp")
+	require.Error(t, err)
+	_, err = NewFailoverForwarder("tcp", "es-0.ns.svc:9200")
+	require.Error(t, err)
+}
@@ -0,0 +1,44 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+// dialOutcomeWindow is the number of most recent dials the failure rate of a forwarder is computed over
+const dialOutcomeWindow = 100
+
+// dialOutcomes is a ring of the outcomes of the most recent dials. It is not safe for concurrent use.
+type dialOutcomes struct {
+	// failed holds whether each of the recorded dials failed, oldest first from next once full
+	failed [dialOutcomeWindow]bool
+	// next is the index of the next outcome to record
+	next int
+	// count is the number of outcomes recorded, up to dialOutcomeWindow
+	count int
+	// failures is the number of failed dials among the recorded ones
+	failures int
+}
+
+// record records the outcome of a dial, evicting the oldest one if the ring is full.
+func (o *dialOutcomes) record(failed bool) {
+	if o.count == dialOutcomeWindow {
+		if o.failed[o.next] {
+			o.failures--
+		}
+	} else {
+		o.count++
+	}
+	o.failed[o.next] = failed
+	if failed {
+		o.failures++
+	}
+	o.next = (o.next + 1) % dialOutcomeWindow
+}
+
+// rate returns the fraction of the recorded dials that failed, 0 if none was recorded.
+func (o *dialOutcomes) rate() float64 {
+	if o.count == 0 {
+		return 0
+	}
+	return float64(o.failures) / float64(o.count)
+}
@@ -0,0 +1,74 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_dialOutcomes(t *testing.T) {
+	var outcomes dialOutcomes
+	require.Equal(t, 0.0, outcomes.rate())
+
+	outcomes.record(true)
+	outcomes.record(false)
+	outcomes.record(false)
+	outcomes.record(false)
+	require.Equal(t, 0.25, outcomes.rate())
+
+	// fill the window with successes, evicting the failure
+	for i := 0; i < dialOutcomeWindow-4; i++ {
+		outcomes.record(false)
+	}
+	require.Equal(t, 0.01, outcomes.rate())
+	outcomes.record(false)
+	require.Equal(t, 0.0, outcomes.rate())
+
+	// only the most recent outcomes count
+	for i := 0; i < dialOutcomeWindow/2; i++ {
+		outcomes.record(true)
+	}
+	require.Equal(t, 0.5, outcomes.rate())
+	for i := 0; i < dialOutcomeWindow; i++ {
+		outcomes.record(true)
+	}
+	require.Equal(t, 1.0, outcomes.rate())
+}
+
+func Test_podForwarder_FailureRate(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	require.Equal(t, 0.0, fwd.FailureRate())
+
+	fwd.setReady("127.0.0.1:12345", nil)
+	close(fwd.initChan)
+	dialErr := errors.New("connection refused")
+	fail := true
+	fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+		if fail {
+			return nil, dialErr
+		}
+		local, _ := net.Pipe()
+		return local, nil
+	}
+
+	// the forwarder is ready but fails most dials
+	for i := 0; i < 3; i++ {
+		_, err := fwd.DialContext(context.Background())
+		require.ErrorIs(t, err, dialErr)
+	}
+	fail = false
+	conn, err := fwd.DialContext(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Equal(t, StateReady, fwd.State())
+	require.Equal(t, 0.75, fwd.FailureRate())
+	require.Equal(t, 0.75, fwd.Stats().FailureRate)
+}
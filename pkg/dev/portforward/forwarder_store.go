@@ -6,57 +6,176 @@ package portforward
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
+)
+
+// ErrStoreClosed is returned when getting a forwarder from a closed store
+var ErrStoreClosed = errors.New("forwarder store is closed")
+
+const (
+	// defaultMaxForwarders is the default number of forwarders above which a store stops the least recently used one
+	defaultMaxForwarders = 100
+	// defaultIdleTimeout is the default time after which a store stops a forwarder that was not used
+	defaultIdleTimeout = 5 * time.Minute
 )
 
 // ForwarderStore is a store for Forwarders that handles the forwarder lifecycle.
 type ForwarderStore struct {
-	forwarders map[string]Forwarder
+	forwarders map[string]*storedForwarder
+	// shared are the reference counted forwarders handed out by AcquireForwarder
+	shared map[string]*sharedForwarder
 	sync.Mutex
+
+	// ctx is the context the forwarders run with, cancel stops them all
+	ctx    context.Context
+	cancel context.CancelFunc
+	// closed is true once Close was called
+	closed bool
+	// running tracks the forwarders that are still running
+	running sync.WaitGroup
+
+	// maxForwarders is the number of forwarders above which the least recently used one is stopped, 0 means no limit
+	maxForwarders int
+	// idleTimeout is the time after which a forwarder that was not used is stopped, 0 means no timeout
+	idleTimeout time.Duration
+	// clock is used to facilitate testing time-based behavior
+	clock clock
+}
+
+// storedForwarder is a forwarder cached by a ForwarderStore
+type storedForwarder struct {
+	fwd Forwarder
+	// cancel stops the forwarder
+	cancel context.CancelFunc
+	// lastUsed is the last time the forwarder was returned by the store
+	lastUsed time.Time
+}
+
+// ForwarderStoreOption configures optional behavior of a ForwarderStore
+type ForwarderStoreOption func(s *ForwarderStore)
+
+// WithMaxForwarders limits the number of forwarders cached by the store, stopping the least recently used one when a
+// new forwarder would exceed the limit. Shared forwarders still acquired by a consumer are not stopped. A limit lower
+// than 1 means no limit. It defaults to 100.
+func WithMaxForwarders(maxForwarders int) ForwarderStoreOption {
+	return func(s *ForwarderStore) {
+		s.maxForwarders = maxForwarders
+	}
+}
+
+// WithIdleTimeout stops the forwarders of the store that were not used for the given duration, to release the
+// resources of the pods that are not dialed anymore in long-running sessions. Forwarders are used every time they are
+// returned by GetOrCreateForwarder, which happens for every dial of the service forwarders, and shared forwarders are
+// idle once all their consumers released them. A timeout lower than or equal to 0 means no timeout, shared
+// forwarders stopping as soon as they are released. It defaults to 5 minutes.
+func WithIdleTimeout(timeout time.Duration) ForwarderStoreOption {
+	return func(s *ForwarderStore) {
+		s.idleTimeout = timeout
+	}
+}
+
+// sharedForwarder is a forwarder of a ForwarderStore shared by several consumers, which stops running once the last
+// consumer releases it
+type sharedForwarder struct {
+	fwd Forwarder
+	// cancel stops the forwarder
+	cancel context.CancelFunc
+	// refs is the number of consumers that did not release the forwarder yet
+	refs int
+	// lastUsed is the last time the forwarder was released by its last consumer
+	lastUsed time.Time
 }
 
 // ForwarderFactory is a function that can produce forwarders
 type ForwarderFactory func(ctx context.Context, network, addr string) (Forwarder, error)
 
-// NewForwarderStore creates a new initialized forwarderStore
-func NewForwarderStore() *ForwarderStore {
-	return &ForwarderStore{
-		forwarders: make(map[string]Forwarder),
+// NewForwarderStore creates a new initialized forwarderStore, which stops the least recently used forwarders above 100
+// forwarders and the forwarders idle for 5 minutes unless configured otherwise.
+func NewForwarderStore(opts ...ForwarderStoreOption) *ForwarderStore {
+	return newForwarderStore(realClock, opts...)
+}
+
+// newForwarderStore creates a new initialized forwarderStore measuring time with the given clock
+func newForwarderStore(clock clock, opts ...ForwarderStoreOption) *ForwarderStore {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &ForwarderStore{
+		forwarders: make(map[string]*storedForwarder),
+		shared:     make(map[string]*sharedForwarder),
+		ctx:        ctx,
+		cancel:     cancel,
+		clock:      clock,
+
+		maxForwarders: defaultMaxForwarders,
+		idleTimeout:   defaultIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	if s.idleTimeout > 0 {
+		s.running.Add(1)
+		go func() {
+			defer s.running.Done()
+			s.stopIdleForwarders()
+		}()
+	}
+	return s
 }
 
 // GetOrCreateForwarder returns a cached Forwarder if it exists, or a new one.
 //
-// The forwarder will be running when returned and automatically removed from the store when it stops running.
+// The forwarder will be running when returned and automatically removed from the store when it stops running, is
+// evicted as the least recently used one or is idle for too long.
 func (s *ForwarderStore) GetOrCreateForwarder(network, addr string, factory ForwarderFactory) (Forwarder, error) {
 	s.Lock()
 	defer s.Unlock()
 
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+
 	key := netAddrToKey(network, addr)
 
-	fwd, ok := s.forwarders[key]
+	stored, ok := s.forwarders[key]
 	if ok {
-		return fwd, nil
+		if !stored.fwd.Draining() {
+			stored.lastUsed = s.clock.Now()
+			return stored.fwd, nil
+		}
+		// replace the draining forwarder, which keeps running until its active connections are closed
+		log.V(1).Info("Replacing draining forwarder", "addr", addr)
+		delete(s.forwarders, key)
+	}
+
+	if s.maxForwarders > 0 && len(s.forwarders) >= s.maxForwarders {
+		s.evictLeastRecentlyUsedLocked()
 	}
 
 	fwd, err := factory(context.Background(), network, addr)
 	if err != nil {
 		return nil, err
 	}
-	s.forwarders[key] = fwd
+	ctx, cancel := context.WithCancel(s.ctx)
+	stored = &storedForwarder{fwd: fwd, cancel: cancel, lastUsed: s.clock.Now()}
+	s.forwarders[key] = stored
 
 	// run the forwarder in a goroutine
+	s.running.Add(1)
 	go func() {
+		defer s.running.Done()
 		// remove the forwarder from the map when done running
 		defer func() {
 			s.Lock()
 			defer s.Unlock()
 
-			delete(s.forwarders, key)
+			// the forwarder may have been replaced already
+			if s.forwarders[key] == stored {
+				delete(s.forwarders, key)
+			}
 		}()
-		// TODO: cancel this at some point to GC?
-		if err := fwd.Run(context.Background()); err != nil {
+		if err := fwd.Run(ctx); err != nil {
 			log.Error(err, "Forwarder returned with an error", "addr", addr)
 		} else {
 			log.Info("Forwarder returned without an error", "addr", addr)
@@ -66,6 +185,178 @@ func (s *ForwarderStore) GetOrCreateForwarder(network, addr string, factory Forw
 	return fwd, nil
 }
 
+// evictLeastRecentlyUsedLocked stops and removes the least recently used forwarder. The store must be locked.
+func (s *ForwarderStore) evictLeastRecentlyUsedLocked() {
+	var lruKey string
+	var lru *storedForwarder
+	for key, stored := range s.forwarders {
+		if lru == nil || stored.lastUsed.Before(lru.lastUsed) {
+			lruKey, lru = key, stored
+		}
+	}
+	if lru == nil {
+		return
+	}
+	log.V(1).Info("Evicting least recently used forwarder", "key", lruKey)
+	lru.cancel()
+	delete(s.forwarders, lruKey)
+}
+
+// stopIdleForwarders stops and removes the forwarders that were not used within the idle timeout, checking them every
+// idle timeout until the store is closed.
+func (s *ForwarderStore) stopIdleForwarders() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.clock.After(s.idleTimeout):
+		}
+
+		s.Lock()
+		now := s.clock.Now()
+		for key, stored := range s.forwarders {
+			if now.Sub(stored.lastUsed) >= s.idleTimeout {
+				log.V(1).Info("Stopping idle forwarder", "key", key, "idle_timeout", s.idleTimeout)
+				stored.cancel()
+				delete(s.forwarders, key)
+			}
+		}
+		for key, shared := range s.shared {
+			if shared.refs == 0 && now.Sub(shared.lastUsed) >= s.idleTimeout {
+				log.V(1).Info("Stopping idle shared forwarder", "key", key, "idle_timeout", s.idleTimeout)
+				shared.cancel()
+				delete(s.shared, key)
+			}
+		}
+		s.Unlock()
+	}
+}
+
+// AcquireForwarder returns a running forwarder to addr shared with the other consumers of the same target, along with
+// a function releasing it that must be called once the consumer is done dialing.
+//
+// Pod addresses targeting the same port of the same pod share a forwarder, and thus a single port forwarding session
+// and local listener, even if they are written differently. The forwarder stops running once all its consumers
+// released it and it is idle for the idle timeout of the store, or right away without idle timeout. A draining
+// forwarder is replaced by a new one for the next consumers.
+func (s *ForwarderStore) AcquireForwarder(
+	network, addr string,
+	factory ForwarderFactory,
+) (Forwarder, func(), error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed {
+		return nil, nil, ErrStoreClosed
+	}
+
+	key := sharedForwarderKey(network, addr)
+	shared, ok := s.shared[key]
+	if ok && shared.fwd.Draining() {
+		// replace the draining forwarder, which keeps running until its consumers release it
+		log.V(1).Info("Replacing draining shared forwarder", "addr", addr)
+		delete(s.shared, key)
+		ok = false
+	}
+	if !ok {
+		if s.maxForwarders > 0 && len(s.shared) >= s.maxForwarders {
+			s.evictLeastRecentlyReleasedLocked()
+		}
+		fwd, err := factory(context.Background(), network, addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		ctx, cancel := context.WithCancel(s.ctx)
+		shared = &sharedForwarder{fwd: fwd, cancel: cancel}
+		s.shared[key] = shared
+
+		s.running.Add(1)
+		go func() {
+			defer s.running.Done()
+			// remove the forwarder from the map when done running, even if it is still referenced
+			defer func() {
+				s.Lock()
+				defer s.Unlock()
+
+				if s.shared[key] == shared {
+					delete(s.shared, key)
+				}
+			}()
+			if err := fwd.Run(ctx); err != nil {
+				log.Error(err, "Shared forwarder returned with an error", "addr", addr)
+			} else {
+				log.V(1).Info("Shared forwarder returned without an error", "addr", addr)
+			}
+		}()
+	}
+	shared.refs++
+
+	var releaseOnce sync.Once
+	release := func() {
+		releaseOnce.Do(func() {
+			s.Lock()
+			defer s.Unlock()
+
+			shared.refs--
+			if shared.refs > 0 {
+				return
+			}
+			if s.idleTimeout > 0 && s.shared[key] == shared {
+				// keep the forwarder running for the next consumers until it is idle for too long
+				shared.lastUsed = s.clock.Now()
+				return
+			}
+			shared.cancel()
+			if s.shared[key] == shared {
+				delete(s.shared, key)
+			}
+		})
+	}
+	return shared.fwd, release, nil
+}
+
+// evictLeastRecentlyReleasedLocked stops and removes the shared forwarder released the least recently by its last
+// consumer, if any. The store must be locked.
+func (s *ForwarderStore) evictLeastRecentlyReleasedLocked() {
+	var lruKey string
+	var lru *sharedForwarder
+	for key, shared := range s.shared {
+		if shared.refs > 0 {
+			continue
+		}
+		if lru == nil || shared.lastUsed.Before(lru.lastUsed) {
+			lruKey, lru = key, shared
+		}
+	}
+	if lru == nil {
+		return
+	}
+	log.V(1).Info("Evicting least recently released shared forwarder", "key", lruKey)
+	lru.cancel()
+	delete(s.shared, lruKey)
+}
+
+// sharedForwarderKey returns the key of the shared forwarders to addr: the namespace, name and port of the pod for pod
+// addresses, the network and address otherwise.
+func sharedForwarderKey(network, addr string) string {
+	target, err := parseAddr(context.Background(), addr, nil, "")
+	if err != nil || target.Kind != podAddrKind {
+		return netAddrToKey(network, addr)
+	}
+	return fmt.Sprintf("pod/%s/%s:%s", target.Namespace, target.Name, target.Port)
+}
+
+// Close stops all the forwarders of the store and waits for them to return. Getting a forwarder from the store fails
+// with ErrStoreClosed afterwards.
+func (s *ForwarderStore) Close() {
+	s.Lock()
+	s.closed = true
+	s.Unlock()
+
+	s.cancel()
+	s.running.Wait()
+}
+
 // netAddrToKey returns the map key to use for this network+address tuple
 func netAddrToKey(network, addr string) string {
 	return fmt.Sprintf("%s/%s", network, addr)
@@ -0,0 +1,281 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+// drainableStubForwarder is a stubForwarder that can be marked as draining.
+type drainableStubForwarder struct {
+	stubForwarder
+	draining int32
+}
+
+func (f *drainableStubForwarder) Draining() bool {
+	return atomic.LoadInt32(&f.draining) == 1
+}
+
+func TestForwarderStore_GetOrCreateForwarder_draining(t *testing.T) {
+	s := NewForwarderStore()
+	defer s.Close()
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	created := 0
+	factory := func(_ context.Context, network, addr string) (Forwarder, error) {
+		created++
+		if created > 1 {
+			return &drainableStubForwarder{stubForwarder: stubForwarder{network: network, addr: addr}}, nil
+		}
+		return &drainableStubForwarder{stubForwarder: stubForwarder{
+			network: network, addr: addr,
+			onRun: func(ctx context.Context) error {
+				defer close(stopped)
+				<-stop
+				return nil
+			},
+		}}, nil
+	}
+
+	first, err := s.GetOrCreateForwarder("tcp", "foo.bar.pod:9200", factory)
+	require.NoError(t, err)
+	same, err := s.GetOrCreateForwarder("tcp", "foo.bar.pod:9200", factory)
+	require.NoError(t, err)
+	require.Same(t, first, same)
+
+	// a draining forwarder is replaced
+	atomic.StoreInt32(&first.(*drainableStubForwarder).draining, 1)
+	second, err := s.GetOrCreateForwarder("tcp", "foo.bar.pod:9200", factory)
+	require.NoError(t, err)
+	require.NotSame(t, first, second)
+
+	// the draining forwarder stopping does not remove its replacement
+	close(stop)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("draining forwarder did not stop")
+	}
+	require.Never(t, func() bool {
+		s.Lock()
+		defer s.Unlock()
+		return len(s.forwarders) == 0
+	}, 50*time.Millisecond, time.Millisecond)
+	third, err := s.GetOrCreateForwarder("tcp", "foo.bar.pod:9200", factory)
+	require.NoError(t, err)
+	require.Same(t, second, third)
+}
+
+// stubForwarderFactory returns stubForwarders running until their context is done.
+func stubForwarderFactory(_ context.Context, network, addr string) (Forwarder, error) {
+	return &stubForwarder{network: network, addr: addr}, nil
+}
+
+func TestForwarderStore_AcquireForwarder(t *testing.T) {
+	// without idle timeout, shared forwarders stop as soon as they are released
+	s := NewForwarderStore(WithIdleTimeout(0))
+	defer s.Close()
+
+	var created, dials int32
+	stopped := make(chan struct{})
+	factory := func(_ context.Context, network, addr string) (Forwarder, error) {
+		atomic.AddInt32(&created, 1)
+		return &stubForwarder{
+			network: network, addr: addr,
+			onRun: func(ctx context.Context) error {
+				<-ctx.Done()
+				close(stopped)
+				return nil
+			},
+			onDialContext: func(ctx context.Context) (net.Conn, error) {
+				atomic.AddInt32(&dials, 1)
+				local, _ := net.Pipe()
+				return local, nil
+			},
+		}, nil
+	}
+
+	// different addresses of the same pod port share a single forwarder
+	first, releaseFirst, err := s.AcquireForwarder("tcp", "foo.bar.pod:9200", factory)
+	require.NoError(t, err)
+	second, releaseSecond, err := s.AcquireForwarder("tcp", "foo.bar.pod.cluster.local:9200", factory)
+	require.NoError(t, err)
+	require.Same(t, first, second)
+
+	for _, fwd := range []Forwarder{first, second} {
+		conn, err := fwd.DialContext(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&created))
+	require.Equal(t, int32(2), atomic.LoadInt32(&dials))
+
+	// another port is another forwarder
+	other, releaseOther, err := s.AcquireForwarder("tcp", "foo.bar.pod:9300", stubForwarderFactory)
+	require.NoError(t, err)
+	require.NotSame(t, first, other)
+	releaseOther()
+
+	// the forwarder keeps running until the last consumer releases it
+	releaseFirst()
+	releaseFirst()
+	require.Never(t, func() bool {
+		select {
+		case <-stopped:
+			return true
+		default:
+			return false
+		}
+	}, 50*time.Millisecond, time.Millisecond)
+	releaseSecond()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shared forwarder did not stop once released")
+	}
+
+	// a new forwarder is created for the next consumer
+	third, releaseThird, err := s.AcquireForwarder("tcp", "foo.bar.pod:9200", stubForwarderFactory)
+	require.NoError(t, err)
+	defer releaseThird()
+	require.NotSame(t, first, third)
+}
+
+func TestForwarderStore_AcquireForwarder_closed(t *testing.T) {
+	s := NewForwarderStore()
+	s.Close()
+	_, _, err := s.AcquireForwarder("tcp", "foo.bar.pod:9200", stubForwarderFactory)
+	require.ErrorIs(t, err, ErrStoreClosed)
+}
+
+// stoppedForwarderFactory returns stubForwarders running until their context is done, sending their address to
+// stopped when they stop.
+func stoppedForwarderFactory(stopped chan<- string) ForwarderFactory {
+	return func(_ context.Context, network, addr string) (Forwarder, error) {
+		return &stubForwarder{
+			network: network, addr: addr,
+			onRun: func(ctx context.Context) error {
+				<-ctx.Done()
+				stopped <- addr
+				return nil
+			},
+		}, nil
+	}
+}
+
+func TestForwarderStore_WithMaxForwarders(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	s := newForwarderStore(fakeClock, WithMaxForwarders(2))
+	defer s.Close()
+
+	stopped := make(chan string, 3)
+	factory := stoppedForwarderFactory(stopped)
+	for _, addr := range []string{"a.ns.pod:9200", "b.ns.pod:9200", "a.ns.pod:9200"} {
+		_, err := s.GetOrCreateForwarder("tcp", addr, factory)
+		require.NoError(t, err)
+		fakeClock.Step(time.Second)
+	}
+
+	// b was used less recently than a
+	_, err := s.GetOrCreateForwarder("tcp", "c.ns.pod:9200", factory)
+	require.NoError(t, err)
+	select {
+	case addr := <-stopped:
+		require.Equal(t, "b.ns.pod:9200", addr)
+	case <-time.After(5 * time.Second):
+		t.Fatal("least recently used forwarder was not stopped")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	require.Len(t, s.forwarders, 2)
+	require.Contains(t, s.forwarders, netAddrToKey("tcp", "a.ns.pod:9200"))
+	require.Contains(t, s.forwarders, netAddrToKey("tcp", "c.ns.pod:9200"))
+}
+
+func TestForwarderStore_WithMaxForwarders_shared(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	s := newForwarderStore(fakeClock, WithMaxForwarders(2))
+	defer s.Close()
+
+	stopped := make(chan string, 3)
+	factory := stoppedForwarderFactory(stopped)
+	_, releaseA, err := s.AcquireForwarder("tcp", "a.ns.pod:9200", factory)
+	require.NoError(t, err)
+	_, releaseB, err := s.AcquireForwarder("tcp", "b.ns.pod:9200", factory)
+	require.NoError(t, err)
+
+	// forwarders still acquired are not evicted
+	_, releaseC, err := s.AcquireForwarder("tcp", "c.ns.pod:9200", factory)
+	require.NoError(t, err)
+	defer releaseC()
+	require.Never(t, func() bool {
+		return len(stopped) > 0
+	}, 50*time.Millisecond, time.Millisecond)
+
+	// b was released less recently than a
+	releaseB()
+	fakeClock.Step(time.Second)
+	releaseA()
+	_, releaseD, err := s.AcquireForwarder("tcp", "d.ns.pod:9200", factory)
+	require.NoError(t, err)
+	defer releaseD()
+	select {
+	case addr := <-stopped:
+		require.Equal(t, "b.ns.pod:9200", addr)
+	case <-time.After(5 * time.Second):
+		t.Fatal("least recently released forwarder was not stopped")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	require.Len(t, s.shared, 3)
+	require.NotContains(t, s.shared, sharedForwarderKey("tcp", "b.ns.pod:9200"))
+}
+
+func TestForwarderStore_WithIdleTimeout(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	s := newForwarderStore(fakeClock, WithIdleTimeout(time.Minute))
+	defer s.Close()
+
+	stopped := make(chan string, 2)
+	factory := stoppedForwarderFactory(stopped)
+	_, err := s.GetOrCreateForwarder("tcp", "a.ns.pod:9200", factory)
+	require.NoError(t, err)
+	_, err = s.GetOrCreateForwarder("tcp", "b.ns.pod:9200", factory)
+	require.NoError(t, err)
+
+	// only b is used within the idle timeout
+	require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+	fakeClock.Step(30 * time.Second)
+	_, err = s.GetOrCreateForwarder("tcp", "b.ns.pod:9200", factory)
+	require.NoError(t, err)
+	fakeClock.Step(30 * time.Second)
+
+	select {
+	case addr := <-stopped:
+		require.Equal(t, "a.ns.pod:9200", addr)
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle forwarder was not stopped")
+	}
+	require.Never(t, func() bool {
+		return len(stopped) > 0
+	}, 50*time.Millisecond, time.Millisecond)
+
+	// a new forwarder is created for the next dial
+	s.Lock()
+	require.Len(t, s.forwarders, 1)
+	s.Unlock()
+	_, err = s.GetOrCreateForwarder("tcp", "a.ns.pod:9200", factory)
+	require.NoError(t, err)
+}
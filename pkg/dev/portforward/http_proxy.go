@@ -0,0 +1,105 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// HTTPConnectProxy is an HTTP proxy tunneling the CONNECT requests of its clients through a dialer, so that tools
+// honoring the HTTPS_PROXY environment variable can reach the pods and services of the cluster by their DNS names
+// through forwarders.
+//
+// Only the CONNECT method is supported, which is what clients use for TLS endpoints, and for plain HTTP endpoints
+// when they are configured to tunnel through the proxy. The proxy is meant to listen on a loopback address.
+type HTTPConnectProxy struct {
+	dialer ContextDialer
+}
+
+var _ http.Handler = &HTTPConnectProxy{}
+
+// NewHTTPConnectProxy returns an HTTP CONNECT proxy tunneling through the given dialer, such as a ForwardingDialer.
+func NewHTTPConnectProxy(dialer ContextDialer) *HTTPConnectProxy {
+	return &HTTPConnectProxy{dialer: dialer}
+}
+
+// ListenAndServe listens on the given TCP address and serves HTTP proxy clients until the context is done.
+func (p *HTTPConnectProxy) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return p.Serve(ctx, l)
+}
+
+// Serve serves the HTTP proxy clients connecting to the listener until the context is done, closing the listener and
+// the tunnels before returning nil. It returns the error of the server if it fails first.
+func (p *HTTPConnectProxy) Serve(ctx context.Context, l net.Listener) error {
+	server := &http.Server{
+		Handler: p,
+		// the tunnels are bound to the requests contexts, which are derived from this one
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	err := server.Serve(l)
+	if ctx.Err() != nil && errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// ServeHTTP tunnels a CONNECT request to the requested address until either side closes its connection or the
+// request context is done.
+func (p *HTTPConnectProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "only the CONNECT method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection hijacking is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	upstream, err := p.dialer.DialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		log.Info("Failed to dial for HTTP proxy client", "addr", r.Host, "error", err.Error())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+	// close the connections when the proxy stops, to interrupt the tunnel
+	defer closeWhenDone(r.Context(), upstream)()
+
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		log.V(1).Info("Failed to hijack HTTP proxy client connection", "addr", r.Host, "error", err.Error())
+		return
+	}
+	defer conn.Close()
+	defer closeWhenDone(r.Context(), conn)()
+
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		return
+	}
+	// the client may have sent data right after its request, which was read along with it
+	if n := buffered.Reader.Buffered(); n > 0 {
+		data, err := buffered.Reader.Peek(n)
+		if err != nil {
+			return
+		}
+		if _, err := upstream.Write(data); err != nil {
+			return
+		}
+	}
+	relay(conn, upstream)
+}
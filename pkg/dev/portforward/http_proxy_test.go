@@ -0,0 +1,93 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPConnectProxy(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		dialErr    error
+		wantStatus int
+		wantDials  []string
+	}{
+		{
+			name:       "connect",
+			method:     http.MethodConnect,
+			wantStatus: http.StatusOK,
+			wantDials:  []string{"es-http.ns.svc:9200"},
+		},
+		{
+			name:       "dial failure",
+			method:     http.MethodConnect,
+			dialErr:    errors.New("no pod addresses found in service endpoints"),
+			wantStatus: http.StatusBadGateway,
+			wantDials:  []string{"es-http.ns.svc:9200"},
+		},
+		{
+			name:       "unsupported method",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := &stubContextDialer{err: tt.dialErr}
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			serveErr := make(chan error)
+			go func() {
+				serveErr <- NewHTTPConnectProxy(dialer).Serve(ctx, l)
+			}()
+
+			conn, err := net.Dial("tcp", l.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+			require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+			// the request is followed by data the proxy must not lose
+			_, err = fmt.Fprintf(conn, "%s es-http.ns.svc:9200 HTTP/1.1\r\nHost: es-http.ns.svc:9200\r\n\r\nping", tt.method)
+			require.NoError(t, err)
+			reader := bufio.NewReader(conn)
+			resp, err := http.ReadResponse(reader, &http.Request{Method: tt.method})
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus == http.StatusOK {
+				pong := make([]byte, 4)
+				_, err = io.ReadFull(reader, pong)
+				require.NoError(t, err)
+				assert.Equal(t, "ping", string(pong))
+			}
+
+			// the tunnels are closed once the proxy stops
+			cancel()
+			require.NoError(t, <-serveErr)
+			if tt.wantStatus == http.StatusOK {
+				_, err = reader.ReadByte()
+				require.Error(t, err)
+			}
+
+			dialer.mu.Lock()
+			defer dialer.mu.Unlock()
+			assert.Equal(t, tt.wantDials, dialer.addresses)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"io"
+	"net"
+)
+
+// inMemoryViaAddr is the address reported by in-memory forwarders, which do not listen locally
+const inMemoryViaAddr = "pipe"
+
+// ConnHandler serves the remote side of the connections of an in-memory forwarder.
+type ConnHandler func(conn net.Conn)
+
+// EchoHandler is a ConnHandler writing back everything it reads until the connection is closed.
+func EchoHandler(conn net.Conn) {
+	defer conn.Close()
+	_, _ = io.Copy(conn, conn)
+}
+
+// NewInMemoryPodForwarder returns a pod forwarder for testing and benchmarking code that dials through forwarders
+// without a cluster. The forwarder is ready as soon as it is returned and must not be run: each dial returns the
+// local side of a net.Pipe whose remote side is served by handler in its own goroutine. Connections go through the
+// same wrapping and accounting as the ones of a running forwarder, so the options of the forwarder still apply.
+func NewInMemoryPodForwarder(addr string, handler ConnHandler, opts ...PodForwarderOption) (*PodForwarder, error) {
+	f, err := NewPodForwarder(context.Background(), "tcp", addr, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	f.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+		local, remote := net.Pipe()
+		go handler(remote)
+		return local, nil
+	}
+	f.setReady(inMemoryViaAddr, nil)
+	close(f.initChan)
+	return f, nil
+}
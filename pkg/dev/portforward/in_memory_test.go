@@ -0,0 +1,63 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewInMemoryPodForwarder(t *testing.T) {
+	_, err := NewInMemoryPodForwarder("foo.bar.svc:9200", EchoHandler)
+	require.Error(t, err)
+
+	fwd, err := NewInMemoryPodForwarder("foo.bar.pod:9200", EchoHandler)
+	require.NoError(t, err)
+	require.Equal(t, StateReady, fwd.State())
+
+	conn, err := fwd.DialContext(context.Background())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	pong := make([]byte, 4)
+	_, err = io.ReadFull(conn, pong)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(pong))
+	require.Equal(t, int64(1), fwd.ActiveConnections())
+
+	require.NoError(t, conn.Close())
+	require.Equal(t, int64(0), fwd.ActiveConnections())
+	stats := fwd.Stats()
+	require.Equal(t, int64(1), stats.TotalDials)
+	require.Equal(t, int64(4), stats.BytesRead)
+	require.Equal(t, int64(4), stats.BytesWritten)
+}
+
+func BenchmarkPodForwarder_DialContext(b *testing.B) {
+	fwd, err := NewInMemoryPodForwarder("foo.bar.pod:9200", EchoHandler)
+	require.NoError(b, err)
+	ctx := context.Background()
+	payload := []byte("ping")
+	buf := make([]byte, len(payload))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn, err := fwd.DialContext(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := conn.Write(payload); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			b.Fatal(err)
+		}
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "elastic"
+	metricsSubsystem = "portforward"
+
+	addrLabel      = "addr"
+	directionLabel = "direction"
+)
+
+// the forwarder metrics are always recorded, and only exposed once registered with RegisterMetrics. Only the gauges
+// are labelled with the forwarded address, their series being deleted once the forwarder stops, so that the counters
+// do not accumulate series as pods come and go.
+var (
+	sessionsActiveGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "sessions_active",
+		Help:      "Number of port forwarding sessions ready to redirect connections",
+	}, []string{addrLabel})
+
+	dialErrorsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "dial_errors_total",
+		Help:      "Total number of failed dials through the forwarders",
+	})
+
+	reconnectsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "reconnects_total",
+		Help:      "Total number of port forwarding sessions started to replace a lost or failed one",
+	})
+
+	proxiedBytesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "proxied_bytes_total",
+		Help:      "Total number of bytes read from and written to the connections dialed through the forwarders",
+	}, []string{directionLabel})
+
+	healthyGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "healthy",
+		Help:      "Whether the last health probe of the local side of the forwarders succeeded",
+	}, []string{addrLabel})
+)
+
+// RegisterMetrics registers the metrics of the forwarders with the given registerer, such as the registry of the
+// operator metrics endpoint. Registering them more than once is not an error.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{
+		sessionsActiveGauge, dialErrorsCounter, reconnectsCounter, proxiedBytesCounter, healthyGauge,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			var existsErr prometheus.AlreadyRegisteredError
+			if errors.As(err, &existsErr) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteMetrics deletes the series of the forwarder at addr, so that they do not accumulate as pods come and go.
+func deleteMetrics(addr string) {
+	sessionsActiveGauge.DeleteLabelValues(addr)
+	healthyGauge.DeleteLabelValues(addr)
+}
+
+// runHealthProbes probes the local side of the current port forwarding session every health probe interval until ctx
+// is done, recording the outcome in the healthy metric.
+func (f *PodForwarder) runHealthProbes(ctx context.Context) {
+	for {
+		select {
+		case <-f.clock.After(f.healthProbeInterval):
+		case <-ctx.Done():
+			return
+		}
+
+		f.mu.RLock()
+		state, viaAddr := f.state, f.viaAddr
+		f.mu.RUnlock()
+		if state != StateReady {
+			healthyGauge.WithLabelValues(f.addr).Set(0)
+			continue
+		}
+
+		if err := f.probeLocalAddr(ctx, viaAddr); err != nil {
+			f.logger(ctx).V(1).Info("Health probe failed", "addr", f.addr, "via", viaAddr, "error", err.Error())
+			healthyGauge.WithLabelValues(f.addr).Set(0)
+			continue
+		}
+		healthyGauge.WithLabelValues(f.addr).Set(1)
+	}
+}
+
+// probeLocalAddr connects to the local side of the port forwarding session at viaAddr, which goes through to the pod,
+// without counting as a dial of the forwarder.
+func (f *PodForwarder) probeLocalAddr(ctx context.Context, viaAddr string) error {
+	ctx, cancel := context.WithTimeout(ctx, f.healthProbeInterval)
+	defer cancel()
+	conn, err := f.dialerFunc(ctx, f.localNetwork(viaAddr), viaAddr)
+	if err != nil {
+		return err
+	}
+	if conn != nil {
+		_ = conn.Close()
+	}
+	return nil
+}
+
+// defaultHealthProbeInterval is the interval between health probes of the forwarders created by the default factories
+// once enabled with EnableHealthProbes
+const defaultHealthProbeInterval = 30 * time.Second
+
+// healthProbesEnabled is 1 once the health probes of the forwarders created by the default factories are enabled
+var healthProbesEnabled int32
+
+// EnableHealthProbes makes the pod forwarders created by the default factories, such as the ones of the forwarding
+// dialer, probe the local side of their sessions every 30 seconds, reporting the outcome in the healthy metric. The
+// probes are disabled by default, and meant to be enabled along with RegisterMetrics.
+func EnableHealthProbes() {
+	atomic.StoreInt32(&healthProbesEnabled, 1)
+}
+
+// defaultPodForwarderOptions returns the options of the pod forwarders created by the default factories.
+func defaultPodForwarderOptions() []PodForwarderOption {
+	if atomic.LoadInt32(&healthProbesEnabled) == 0 {
+		return nil
+	}
+	return []PodForwarderOption{WithHealthProbe(defaultHealthProbeInterval)}
+}
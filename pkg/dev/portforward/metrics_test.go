@@ -0,0 +1,149 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestRegisterMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	require.NoError(t, RegisterMetrics(registry))
+	// registering again is a no-op
+	require.NoError(t, RegisterMetrics(registry))
+}
+
+// readyPortForwarderFactory returns port forwarders that are ready right away and run until their context is done.
+func readyPortForwarderFactory(
+	ctx context.Context,
+	_, _ string,
+	_ []string,
+	readyChan chan struct{},
+	_, _ io.Writer,
+) (PortForwarder, error) {
+	close(readyChan)
+	return &stubPortForwarder{ctx: ctx}, nil
+}
+
+func Test_podForwarder_metrics(t *testing.T) {
+	// the metrics are global, use an address no other test uses
+	addr := "metrics.ns.pod:9200"
+	fwd := NewPodForwarderWithTest(t, "tcp", addr)
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = readyPortForwarderFactory
+	var failDials int32
+	fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+		if atomic.LoadInt32(&failDials) == 1 {
+			return nil, errors.New("connection refused")
+		}
+		local, remote := net.Pipe()
+		go EchoHandler(remote)
+		return local, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(ctx)
+	}()
+
+	written := testutil.ToFloat64(proxiedBytesCounter.WithLabelValues("written"))
+	read := testutil.ToFloat64(proxiedBytesCounter.WithLabelValues("read"))
+	dialErrors := testutil.ToFloat64(dialErrorsCounter)
+
+	conn, err := fwd.DialContext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, float64(1), testutil.ToFloat64(sessionsActiveGauge.WithLabelValues(addr)))
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	_, err = io.ReadFull(conn, make([]byte, 4))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	require.Equal(t, written+4, testutil.ToFloat64(proxiedBytesCounter.WithLabelValues("written")))
+	require.Equal(t, read+4, testutil.ToFloat64(proxiedBytesCounter.WithLabelValues("read")))
+
+	atomic.StoreInt32(&failDials, 1)
+	_, err = fwd.DialContext(ctx)
+	require.Error(t, err)
+	require.Equal(t, dialErrors+1, testutil.ToFloat64(dialErrorsCounter))
+
+	cancel()
+	require.NoError(t, <-runErr)
+	// the series of the forwarder are deleted once it stops
+	require.False(t, sessionsActiveGauge.DeleteLabelValues(addr))
+}
+
+func Test_defaultPodForwarderOptions(t *testing.T) {
+	defer atomic.StoreInt32(&healthProbesEnabled, 0)
+
+	// the health probes are opt-in
+	fwd := &PodForwarder{}
+	for _, opt := range defaultPodForwarderOptions() {
+		opt(fwd)
+	}
+	require.Equal(t, time.Duration(0), fwd.healthProbeInterval)
+
+	EnableHealthProbes()
+	for _, opt := range defaultPodForwarderOptions() {
+		opt(fwd)
+	}
+	require.Equal(t, defaultHealthProbeInterval, fwd.healthProbeInterval)
+}
+
+func Test_podForwarder_healthProbe(t *testing.T) {
+	// the metrics are global, use an address no other test uses
+	addr := "health.ns.pod:9200"
+	fwd := NewPodForwarderWithTest(t, "tcp", addr, WithHealthProbe(time.Second))
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fwd.clock = fakeClock
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = readyPortForwarderFactory
+	var healthy int32 = 1
+	fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			return nil, errors.New("connection reset")
+		}
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(ctx)
+	}()
+	_, err := fwd.LocalAddr()
+	require.NoError(t, err)
+
+	probe := func(want float64) {
+		t.Helper()
+		require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+		fakeClock.Step(time.Second)
+		require.Eventually(t, func() bool {
+			return testutil.ToFloat64(healthyGauge.WithLabelValues(addr)) == want
+		}, 5*time.Second, time.Millisecond)
+	}
+	probe(1)
+	atomic.StoreInt32(&healthy, 0)
+	probe(0)
+
+	cancel()
+	require.NoError(t, <-runErr)
+	require.False(t, healthyGauge.DeleteLabelValues(addr))
+}
@@ -0,0 +1,69 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ForwardPlan is the concrete target a forwarder would forward connections to.
+type ForwardPlan struct {
+	// Addr is the address of the forwarder.
+	Addr string
+	// Pod is the pod connections would be forwarded to.
+	Pod types.NamespacedName
+	// Port is the port of the pod connections would be forwarded to.
+	Port int
+}
+
+// Plan checks that the pod of the forwarder exists and resolves the port connections would be forwarded to, without
+// opening a port forwarding session.
+func (f *PodForwarder) Plan(ctx context.Context) (*ForwardPlan, error) {
+	if f.clientset == nil {
+		return nil, fmt.Errorf("a clientset is required to plan forwarding to pod %s", f.podNSN)
+	}
+	if _, err := f.clientset.CoreV1().Pods(f.podNSN.Namespace).Get(ctx, f.podNSN.Name, metav1.GetOptions{}); err != nil {
+		return nil, fmt.Errorf("looking up pod %s: %w", f.podNSN, err)
+	}
+
+	_, port, err := net.SplitHostPort(f.addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err = resolveContainerPort(ctx, f.clientset, f.podNSN, port)
+	if err != nil {
+		return nil, err
+	}
+	return newForwardPlan(f.addr, f.podNSN, port)
+}
+
+// Plan looks up the service and its endpoints and returns the pod and port a connection would be forwarded to, without
+// opening a port forwarding session. Each call consumes a choice of the endpoint selector, as dialing does.
+func (f *ServiceForwarder) Plan(ctx context.Context) (*ForwardPlan, error) {
+	podAddr, err := f.selectPodAddr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	target, err := parseAddr(ctx, podAddr, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return newForwardPlan(f.addr, target.NamespacedName(), target.Port)
+}
+
+// newForwardPlan returns the plan of the forwarder at addr to the given pod and numeric port.
+func newForwardPlan(addr string, pod types.NamespacedName, port string) (*ForwardPlan, error) {
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %s of pod %s: %w", port, pod, err)
+	}
+	return &ForwardPlan{Addr: addr, Pod: pod, Port: portNumber}, nil
+}
@@ -0,0 +1,117 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+func TestPodForwarder_Plan(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "bar", Name: "foo"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "main", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}},
+			},
+		},
+	})
+	tests := []struct {
+		name    string
+		addr    string
+		want    *ForwardPlan
+		wantErr string
+	}{
+		{
+			name: "numeric port",
+			addr: "foo.bar.pod:9200",
+			want: &ForwardPlan{Addr: "foo.bar.pod:9200", Pod: types.NamespacedName{Namespace: "bar", Name: "foo"}, Port: 9200},
+		},
+		{
+			name: "named container port",
+			addr: "foo.bar.pod:main/http",
+			want: &ForwardPlan{Addr: "foo.bar.pod:main/http", Pod: types.NamespacedName{Namespace: "bar", Name: "foo"}, Port: 8080},
+		},
+		{
+			name:    "unknown container port",
+			addr:    "foo.bar.pod:main/transport",
+			wantErr: "container main of pod bar/foo has no port named transport",
+		},
+		{
+			name:    "missing pod",
+			addr:    "baz.bar.pod:9200",
+			wantErr: `looking up pod bar/baz: pods "baz" not found`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fwd, err := NewPodForwarder(context.Background(), "tcp", tt.addr, clientset)
+			require.NoError(t, err)
+
+			got, err := fwd.Plan(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("without clientset", func(t *testing.T) {
+		_, err := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200").Plan(context.Background())
+		require.EqualError(t, err, "a clientset is required to plan forwarding to pod bar/foo")
+	})
+}
+
+func TestServiceForwarder_Plan(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 9200}}},
+	}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Ports: []corev1.EndpointPort{{Port: 9200}},
+				Addresses: []corev1.EndpointAddress{
+					{TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "pod-a", Namespace: "bar"}},
+				},
+			},
+		},
+	}
+
+	t.Run("ready endpoint", func(t *testing.T) {
+		f, err := NewServiceForwarder(k8s.NewFakeClient(service, endpoints), "tcp", "foo.bar.svc:9200")
+		require.NoError(t, err)
+		f.podForwarderFactory = func(_ context.Context, _, _ string) (Forwarder, error) {
+			t.Error("planning should not create pod forwarders")
+			return nil, nil
+		}
+
+		got, err := f.Plan(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, &ForwardPlan{
+			Addr: "foo.bar.svc:9200",
+			Pod:  types.NamespacedName{Namespace: "bar", Name: "pod-a"},
+			Port: 9200,
+		}, got)
+	})
+
+	t.Run("missing service", func(t *testing.T) {
+		f, err := NewServiceForwarder(k8s.NewFakeClient(), "tcp", "foo.bar.svc:9200")
+		require.NoError(t, err)
+		_, err = f.Plan(context.Background())
+		require.Error(t, err)
+	})
+}
@@ -8,19 +8,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
-	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/go-logr/logr"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
-	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
-	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
 	utilsnet "github.com/elastic/cloud-on-k8s/pkg/utils/net"
 )
 
@@ -29,34 +32,196 @@ type PodForwarder struct {
 	network, addr string
 	podNSN        types.NamespacedName
 
+	// defaultNamespace is the namespace of the pod when the address does not specify one
+	defaultNamespace string
+
 	// clientset is used to stop the pod forwarder if the pod is deleted, may be set to nil to skip checking
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
 
 	// initChan is used to wait for the port-forwarder to be set up before redirecting connections
 	initChan chan struct{}
-	// viaErr is set when there's an error during initialization
+
+	// mu protects state, viaErr, viaAddr, forwardedPorts, draining, dialOutcomes and stateChanged
+	mu sync.RWMutex
+	// state is the current state of the forwarder
+	state ForwarderState
+	// viaErr is set when there's an error during initialization or when the forwarding stopped
 	viaErr error
 	// viaAddr is the address that we use when redirecting connections
 	viaAddr string
+	// forwardedPorts are the ports forwarded by the current port forwarding session
+	forwardedPorts []ForwardedPort
+	// stateChanged is closed and replaced on every state change, to wake up the dials waiting for a reconnection
+	stateChanged chan struct{}
+	// reconnectWaitTimeout bounds the time a dial waits for a lost session to be re-established, 0 means no timeout
+	reconnectWaitTimeout time.Duration
+	// draining is true once the forwarder stopped accepting new connections to stop when the active ones are closed
+	draining bool
+	// dialOutcomes are the outcomes of the most recent calls to DialContext
+	dialOutcomes dialOutcomes
+
+	// activeConns is the number of connections returned by DialContext that are not closed yet
+	activeConns int64
+	// totalDials is the number of connections returned by DialContext
+	totalDials int64
+	// bytesRead and bytesWritten are the number of bytes read from and written to the connections returned by
+	// DialContext
+	bytesRead, bytesWritten int64
+	// readinessTimeouts is the number of DialContext calls whose context was done while waiting for readiness
+	readinessTimeouts int64
+	// onReadinessTimeout is called when the context of DialContext is done while waiting for readiness, if not nil
+	onReadinessTimeout func(err error)
+	// connClosed is signalled when a connection returned by DialContext is closed
+	connClosed chan struct{}
+	// connSlots limits the number of active connections when not nil, each connection holding one slot until closed
+	connSlots chan struct{}
+	// failWhenSaturated makes DialContext fail with ErrTooManyConnections instead of waiting for a free slot
+	failWhenSaturated bool
+	// contextScopedConns makes the connections returned by DialContext close when the context of the dial is done
+	contextScopedConns bool
+
+	// output retains the most recent lines written by the port forwarders of this forwarder
+	output *outputBuffer
+	// stdout and stderr receive the output of the port forwarders instead of the log if not nil
+	stdout, stderr io.Writer
+	// trace holds the hooks called at the different phases of port forwarding, if not nil
+	trace *Trace
+
+	// logDedupWindow is the window within which identical lines logged by the port forwarders are coalesced, 0
+	// means every line is logged
+	logDedupWindow time.Duration
+	// logDedup coalesces the repeated lines logged by the port forwarding sessions of Run if not nil
+	logDedup *logDeduplicator
+
+	// additionalPorts are the remote ports forwarded in addition to the port of addr over each session
+	additionalPorts []string
+
+	// reconnectDelay is the time to wait before re-establishing a lost port-forwarding session
+	reconnectDelay time.Duration
+	// followPodRestarts makes Run wait for a deleted pod to be replaced instead of stopping
+	followPodRestarts bool
+	// podRestartBackoff and podRestartMaxBackoff bound the exponential backoff between checks of a restarting pod
+	podRestartBackoff, podRestartMaxBackoff time.Duration
+	// readinessProbeDelay is the time after which, and between which, the local port of a session is probed while
+	// waiting for the port forwarder to signal readiness, 0 means the local port is never probed
+	readinessProbeDelay time.Duration
+	// maxAge is the time after which the forwarder is drained and stops running, 0 means no maximum age
+	maxAge time.Duration
+	// shutdownTimeout bounds the time Run keeps the session running for the active connections to be closed once its
+	// context is done, 0 means the session is torn down right away
+	shutdownTimeout time.Duration
+	// healthProbeInterval is the time between health probes of the local side of the sessions, 0 means no probes
+	healthProbeInterval time.Duration
+
+	// clock is used to facilitate testing time-based behavior
+	clock clock
+
+	// breaker stops retrying for a while after too many consecutive failures, nil means no retry on failures
+	breaker *circuitBreaker
+	// retry retries failed attempts with an exponential backoff, nil means no retry on failures unless breaker is set
+	retry *retryPolicy
+
+	// logContextKeys are the context values added to the log entries
+	logContextKeys []logContextKey
 
 	// ephemeralPortFinder is used to find an available ephemeral port
 	ephemeralPortFinder func() (string, error)
 
 	// portForwarderFactory is used to facilitate testing without using the API
 	portForwarderFactory PortForwarderFactory
+	// kubectl holds the settings of the default port forwarder factory
+	kubectl kubectlSettings
 
 	// dialerFunc is used to facilitate testing without making new connections
 	dialerFunc dialerFunc
+
+	// unixSocket exposes the local side on the UNIX socket at unixSocketPath instead of a TCP port
+	unixSocket bool
+	// unixSocketPath is the path of the UNIX socket, a temporary one is created by Run if empty
+	unixSocketPath string
+
+	// dialTimeout bounds the time spent connecting to viaAddr once the forwarder is ready, 0 means no timeout
+	dialTimeout time.Duration
+	// dialRetries is the number of times a refused or reset connection to viaAddr is retried
+	dialRetries int
+	// dialRetryDelay is the time to wait between two attempts to connect to viaAddr
+	dialRetryDelay time.Duration
 }
 
 var _ Forwarder = &PodForwarder{}
 
+// ForwarderState is the state of a PodForwarder
+type ForwarderState string
+
+const (
+	// StateInitializing is the state of a forwarder that has not been ready yet
+	StateInitializing ForwarderState = "initializing"
+	// StateReady is the state of a forwarder that redirects connections
+	StateReady ForwarderState = "ready"
+	// StateReconnecting is the state of a forwarder re-establishing a lost port forwarding session
+	StateReconnecting ForwarderState = "reconnecting"
+	// StateFailed is the state of a forwarder that is not currently forwarding
+	StateFailed ForwarderState = "failed"
+)
+
+// ErrLostConnection is returned when the port-forwarding session to the pod was lost
+var ErrLostConnection = errors.New("lost connection to pod")
+
+// ErrNotReady is returned when the port-forwarding session stopped before being ready to redirect connections
+var ErrNotReady = errors.New("port forwarding is not ready")
+
+// ErrMaxAgeReached is returned when dialing a forwarder that is draining because it reached its maximum age
+var ErrMaxAgeReached = errors.New("forwarder reached its maximum age")
+
+// ErrShuttingDown is returned when dialing a forwarder that is draining because the context of Run is done
+var ErrShuttingDown = errors.New("forwarder is shutting down")
+
+// ErrProbeFailed is returned when the check of Probe fails on a connection established through the forwarder
+var ErrProbeFailed = errors.New("probe failed")
+
+// ProbeError is returned by Probe when the check fails on an established connection. It matches ErrProbeFailed and
+// unwraps to the error of the check.
+type ProbeError struct {
+	// Err is the error returned by the check.
+	Err error
+}
+
+func (e *ProbeError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrProbeFailed.Error(), e.Err.Error())
+}
+
+// Is returns true if the target is ErrProbeFailed.
+func (e *ProbeError) Is(target error) bool {
+	return target == ErrProbeFailed
+}
+
+// Unwrap returns the error of the check.
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+// ErrTooManyConnections is returned when dialing a forwarder that has reached its maximum number of active connections
+var ErrTooManyConnections = errors.New("too many active connections")
+
+// defaultReconnectDelay is the default time to wait before re-establishing a lost port-forwarding session
+const defaultReconnectDelay = time.Second
+
+const (
+	// stableSessionDuration is the time after which a port-forwarding session is considered stable, a session lost
+	// before counting as a failure of the port forwarding rather than resetting the retries
+	stableSessionDuration = 30 * time.Second
+	// maxUnstableLosses is the number of consecutive sessions lost before being stable after which Run gives up when
+	// neither a retry policy nor a circuit breaker is configured
+	maxUnstableLosses = 5
+)
+
 // PortForwarderFactory is a factory for port forwarders
 type PortForwarderFactory func(
 	ctx context.Context,
 	namespace, podName string,
 	ports []string,
 	readyChan chan struct{},
+	out, errOut io.Writer,
 ) (PortForwarder, error)
 
 // PortForwarder is a port forwarder that may be started.
@@ -64,99 +229,113 @@ type PortForwarder interface {
 	ForwardPorts() error
 }
 
+// portsGetter is implemented by port forwarders able to report the ports they forward once ready.
+type portsGetter interface {
+	GetPorts() ([]ForwardedPort, error)
+}
+
 // dialerFunc is a factory for connections
 type dialerFunc func(ctx context.Context, network, address string) (net.Conn, error)
 
 // NewPodForwarder returns a new initialized podForwarder
-func NewPodForwarder(ctx context.Context, network, addr string, clientset *kubernetes.Clientset) (*PodForwarder, error) {
-	podNSN, err := parsePodAddr(ctx, addr, clientset)
+//
+// The port of the address may be specified as {container}/{port name} to forward to the named port of a container,
+// which is looked up with the clientset when the forwarder runs.
+func NewPodForwarder(
+	ctx context.Context,
+	network, addr string,
+	clientset kubernetes.Interface,
+	opts ...PodForwarderOption,
+) (*PodForwarder, error) {
+	f := newPodForwarder(network, addr, types.NamespacedName{}, clientset, opts...)
+
+	target, err := parseAddr(ctx, addr, clientset, f.defaultNamespace)
 	if err != nil {
 		return nil, err
 	}
+	if target.Kind != podAddrKind {
+		return nil, newAddrFormatError(podAddrKind, addr)
+	}
+	f.podNSN = target.NamespacedName()
+
+	return f, nil
+}
+
+// NewPodForwarderForPod returns a new initialized podForwarder to the given port of the given pod, without relying on
+// the pod address format.
+func NewPodForwarderForPod(
+	network string,
+	pod types.NamespacedName,
+	port int,
+	clientset kubernetes.Interface,
+	opts ...PodForwarderOption,
+) *PodForwarder {
+	// the address is only used to identify the forwarder and for the remote port
+	addr := net.JoinHostPort(fmt.Sprintf("%s.%s.%s", pod.Name, pod.Namespace, syntheticDNSSegment), strconv.Itoa(port))
+	return newPodForwarder(network, addr, pod, clientset, opts...)
+}
 
-	return &PodForwarder{
+// newPodForwarder returns a new podForwarder to the given pod, with the given options applied
+func newPodForwarder(
+	network, addr string,
+	podNSN types.NamespacedName,
+	clientset kubernetes.Interface,
+	opts ...PodForwarderOption,
+) *PodForwarder {
+	f := &PodForwarder{
 		network: network,
 		addr:    addr,
 
-		podNSN:    *podNSN,
+		podNSN:    podNSN,
 		clientset: clientset,
 
-		initChan: make(chan struct{}),
-
-		ephemeralPortFinder:  utilsnet.GetRandomPort,
-		portForwarderFactory: defaultPortForwarderFactory,
-		dialerFunc:           defaultDialerFunc,
-	}, nil
-}
-
-// newDefaultKubernetesClientset creates a new Clientset
-func newDefaultKubernetesClientset() (*kubernetes.Clientset, error) {
-	cfg, err := config.GetConfig()
-	if err != nil {
-		return nil, err
-	}
-	return kubernetes.NewForConfig(cfg)
-}
+		initChan:     make(chan struct{}),
+		stateChanged: make(chan struct{}),
+		state:        StateInitializing,
 
-// podDNSRegex matches pods FQDN such as {name}.{namespace}.pod
-var podDNSRegex = regexp.MustCompile(`^.+\..+$`)
+		connClosed: make(chan struct{}, 1),
+		output:     newOutputBuffer(maxOutputLines),
 
-// podIPRegex matches any ipv4 address.
-var podIPv4Regex = regexp.MustCompile(`^\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}$`)
+		reconnectDelay: defaultReconnectDelay,
+		clock:          realClock,
 
-// parsePodAddr parses the pod name and namespace from an address.
-func parsePodAddr(ctx context.Context, addr string, clientSet *kubernetes.Clientset) (*types.NamespacedName, error) {
-	host, _, err := net.SplitHostPort(addr)
-	if err != nil {
-		return nil, err
-	}
-	if podIPv4Regex.MatchString(host) {
-		// we got an IP address
-		// try to map it to a pod name and namespace
-		return getPodWithIP(ctx, host, clientSet)
+		ephemeralPortFinder: utilsnet.GetRandomPort,
+		kubectl:             defaultKubectlSettings(),
+		dialerFunc:          defaultDialerFunc,
 	}
-	if podDNSRegex.MatchString(host) {
-		// retrieve pod name and namespace from addr
-		parts := strings.SplitN(host, ".", 4)
-		if len(parts) <= 1 {
-			return nil, fmt.Errorf("unsupported pod address format: %s", host)
-		}
-		if len(parts) == 2 || parts[2] == syntheticDNSSegment {
-			// podname.ns[.pod] from service forwarder or direct call
-			return &types.NamespacedName{Namespace: parts[1], Name: parts[0]}, nil
-		}
-		// podname.subdomain.ns
-		return &types.NamespacedName{Namespace: parts[2], Name: parts[0]}, nil
+	f.portForwarderFactory = f.kubectlPortForwarderFactory
+	for _, opt := range opts {
+		opt(f)
 	}
-	return nil, fmt.Errorf("unsupported pod address format: %s", host)
+	return f
 }
 
-// getPodWithIP requests the apiserver for pods with the given IP assigned.
-func getPodWithIP(ctx context.Context, ip string, clientSet *kubernetes.Clientset) (*types.NamespacedName, error) {
-	pods, err := clientSet.CoreV1().
-		Pods("").
-		List(ctx,
-			metav1.ListOptions{
-				FieldSelector: fmt.Sprintf("status.podIP=%s", ip),
-			})
+// Validate checks that the configuration used for port forwarding is usable by requesting the version of the API
+// server, so that a missing or invalid configuration is reported before running the forwarder.
+func (f *PodForwarder) Validate(ctx context.Context) error {
+	cfg, err := f.kubectl.loadRestConfig()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("portforward: building kube client: %w", err)
 	}
-	if pods == nil || len(pods.Items) == 0 {
-		return nil, fmt.Errorf("pod with IP %s not found", ip)
+	if err := clientset.CoreV1().RESTClient().Get().AbsPath("/version").Do(ctx).Error(); err != nil {
+		return fmt.Errorf("portforward: reaching the API server: %w", err)
 	}
-	nsn := k8s.ExtractNamespacedName(&(pods.Items[0].ObjectMeta))
-	return &nsn, nil
+	return nil
 }
 
-// defaultPortForwarderFactory is the default factory used for port forwarders outside of tests
-var defaultPortForwarderFactory PortForwarderFactory = func(
+// kubectlPortForwarderFactory is the default factory used for port forwarders outside of tests
+func (f *PodForwarder) kubectlPortForwarderFactory(
 	ctx context.Context,
 	namespace, podName string,
 	ports []string,
 	readyChan chan struct{},
+	out, errOut io.Writer,
 ) (PortForwarder, error) {
-	return newKubectlPortForwarder(ctx, namespace, podName, ports, readyChan)
+	return newKubectlPortForwarder(ctx, f.kubectl, namespace, podName, ports, readyChan, out, errOut)
 }
 
 // defaultDialerFunc is the default dialer function we use outside of tests
@@ -165,12 +344,199 @@ var defaultDialerFunc dialerFunc = func(ctx context.Context, network, address st
 	return d.DialContext(ctx, network, address)
 }
 
-// DialContext connects to the podForwarder address using the provided context.
+// State returns the current state of the forwarder.
+func (f *PodForwarder) State() ForwarderState {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.state
+}
+
+// ForwardedPorts returns the local and remote ports of the current port forwarding session, waiting for the
+// forwarder to be initialized.
+func (f *PodForwarder) ForwardedPorts() ([]ForwardedPort, error) {
+	<-f.initChan
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.viaErr != nil {
+		return nil, f.viaErr
+	}
+	return append([]ForwardedPort(nil), f.forwardedPorts...), nil
+}
+
+// WaitForReady blocks until the forwarder is initialized or the context is done. It returns nil if the forwarder is
+// ready to redirect connections, or the reason why it is not.
+func (f *PodForwarder) WaitForReady(ctx context.Context) error {
+	select {
+	case <-f.initChan:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.viaErr
+}
+
+// Probe checks the forwarded path end to end, by dialing through the forwarder and running the given check on the
+// connection, for example reading a banner or sending an HTTP request. The deadline of the context, if any, applies to
+// the connection. A ProbeError, matching ErrProbeFailed and wrapping the error of the check, is returned if the check
+// fails on an established connection, which tells a forwarded service that does not respond apart from a broken
+// forwarder.
+func (f *PodForwarder) Probe(ctx context.Context, probe func(conn net.Conn) error) error {
+	conn, err := f.DialContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	if err := probe(conn); err != nil {
+		return &ProbeError{Err: err}
+	}
+	return nil
+}
+
+// setReady marks the forwarder as ready to redirect connections to viaAddr.
+func (f *PodForwarder) setReady(viaAddr string, forwardedPorts []ForwardedPort) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = StateReady
+	f.viaAddr = viaAddr
+	f.viaErr = nil
+	f.forwardedPorts = forwardedPorts
+	f.notifyStateChangedLocked()
+}
+
+// setLostConnection marks the forwarder as re-establishing the session because the connection to the pod was lost.
+func (f *PodForwarder) setLostConnection() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = StateReconnecting
+	f.viaErr = fmt.Errorf("not currently forwarding: %w", ErrLostConnection)
+	f.notifyStateChangedLocked()
+}
+
+// stopReconnecting marks a forwarder re-establishing a lost session as failed, since it is not going to be.
+func (f *PodForwarder) stopReconnecting() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.state != StateReconnecting {
+		return
+	}
+	f.state = StateFailed
+	f.notifyStateChangedLocked()
+}
+
+// notifyStateChangedLocked wakes up the goroutines waiting for a state change. mu must be held.
+func (f *PodForwarder) notifyStateChangedLocked() {
+	close(f.stateChanged)
+	f.stateChanged = make(chan struct{})
+}
+
+// setDraining stops accepting new connections, letting the active ones complete. New connections are refused with
+// the given cause.
+func (f *PodForwarder) setDraining(cause error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.draining = true
+	f.state = StateFailed
+	f.viaErr = fmt.Errorf("not currently forwarding: %w", cause)
+	f.notifyStateChangedLocked()
+}
+
+// Draining returns true once the forwarder stopped accepting new connections because it reached its maximum age or
+// is shutting down gracefully. It stops running when its active connections are closed, and should be replaced by a
+// new forwarder in the meantime.
+func (f *PodForwarder) Draining() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.draining
+}
+
+// waitForDrain blocks until all the connections returned by DialContext are closed, the context is done or the
+// timeout channel receives, returning true in the first case. A nil timeout channel never receives.
+func (f *PodForwarder) waitForDrain(ctx context.Context, timeout <-chan time.Time) bool {
+	for atomic.LoadInt64(&f.activeConns) > 0 {
+		select {
+		case <-f.connClosed:
+		case <-ctx.Done():
+			return false
+		case <-timeout:
+			return false
+		}
+	}
+	return true
+}
+
+// setFailed marks the forwarder as not currently forwarding because of err.
+func (f *PodForwarder) setFailed(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = StateFailed
+	f.viaErr = err
+	f.notifyStateChangedLocked()
+}
+
+// setNotReady records why the forwarder stopped without being ready, unless an error is already recorded.
+func (f *PodForwarder) setNotReady(cause error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.viaErr != nil {
+		return
+	}
+	f.state = StateFailed
+	f.viaErr = ErrNotReady
+	if cause != nil {
+		f.viaErr = fmt.Errorf("%w: %s", ErrNotReady, cause.Error())
+	}
+	f.notifyStateChangedLocked()
+}
+
+// DialContext connects to the podForwarder address using the provided context. A nil context is treated as
+// context.Background().
 func (f *PodForwarder) DialContext(ctx context.Context) (net.Conn, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	conn, err := f.dialContext(ctx, 0)
+	f.recordDialOutcome(err)
+	return conn, err
+}
+
+// DialPort connects to the given port of the pod over the port forwarding session of the forwarder, using the
+// provided context. The port must be the one of the forwarder address or one of its additional ports. A nil context is
+// treated as context.Background().
+func (f *PodForwarder) DialPort(ctx context.Context, port int) (net.Conn, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	conn, err := f.dialContext(ctx, port)
+	f.recordDialOutcome(err)
+	return conn, err
+}
+
+// dialContext connects to the given port of the pod using the provided context, which must not be nil. Port 0 is the
+// port of the forwarder address.
+func (f *PodForwarder) dialContext(ctx context.Context, port int) (net.Conn, error) {
 	// wait until we're initialized or context is done
 	select {
 	case <-f.initChan:
 	case <-ctx.Done():
+		select {
+		case <-f.initChan:
+			// both are done and select picked one at random, the forwarder did not keep us waiting
+		default:
+			// we gave up before the forwarder was ever ready, as opposed to failing to dial a ready forwarder
+			atomic.AddInt64(&f.readinessTimeouts, 1)
+			if f.onReadinessTimeout != nil {
+				f.onReadinessTimeout(ctx.Err())
+			}
+		}
 	}
 
 	// context has an error, so we can give up, most likely exceeded our timeout
@@ -178,37 +544,349 @@ func (f *PodForwarder) DialContext(ctx context.Context) (net.Conn, error) {
 		return nil, ctx.Err()
 	}
 
-	// we have an error to return
-	if f.viaErr != nil {
-		return nil, f.viaErr
+	// wait for a lost session to be re-established rather than failing a dial that may succeed shortly, we may then
+	// have an error to return
+	viaAddr, err := f.waitForReconnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// this should not happen once initChan is closed, but never dial an empty address if it does
+	if viaAddr == "" {
+		return nil, ErrNotReady
+	}
+	if port != 0 {
+		if viaAddr, err = f.localAddrForPort(port); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := f.acquireConnSlot(ctx); err != nil {
+		return nil, err
+	}
+
+	f.logger(ctx).V(1).Info("Redirecting dial call", "addr", f.addr, "via", viaAddr)
+
+	// the dial timeout only applies to the redirected dial, not to waiting for the forwarder to be ready
+	dialCtx := ctx
+	if f.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, f.dialTimeout)
+		defer cancel()
+	}
+	conn, err := f.dialWithRetries(dialCtx, viaAddr)
+	f.trace.dialDone(err)
+	if err != nil {
+		f.releaseConnSlot()
+		return nil, err
+	}
+	tracked := f.trackConn(conn)
+	if f.contextScopedConns {
+		tracked.closeWhenDone(ctx)
+	}
+	return tracked, nil
+}
+
+// localAddrForPort returns the local address the given port of the pod is forwarded to by the current session.
+func (f *PodForwarder) localAddrForPort(port int) (string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, forwarded := range f.forwardedPorts {
+		if int(forwarded.Remote) == port {
+			return net.JoinHostPort("127.0.0.1", strconv.Itoa(int(forwarded.Local))), nil
+		}
+	}
+	return "", fmt.Errorf("port %d is not forwarded by the forwarder to %s", port, f.addr)
+}
+
+// localNetwork returns the network of the local side of the forwarding at viaAddr. The local listener is always on a
+// loopback address whose family may differ from the requested network, for example tcp4 on dual-stack clusters, so
+// the network is derived from viaAddr rather than from the network of the forwarded address.
+func (f *PodForwarder) localNetwork(viaAddr string) string {
+	if f.unixSocket && viaAddr == f.unixSocketPath {
+		return "unix"
+	}
+	host, _, err := net.SplitHostPort(viaAddr)
+	if err != nil {
+		return "tcp"
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "tcp"
+	case ip.To4() != nil:
+		return "tcp4"
+	default:
+		return "tcp6"
+	}
+}
+
+// dialWithRetries dials viaAddr, retrying up to dialRetries times when the local listener refuses or resets the
+// connection, which may happen right after the forwarder is signalled ready.
+func (f *PodForwarder) dialWithRetries(ctx context.Context, viaAddr string) (net.Conn, error) {
+	logger := f.logger(ctx)
+	conn, err := f.dialerFunc(ctx, f.localNetwork(viaAddr), viaAddr)
+	for attempt := 1; err != nil && attempt <= f.dialRetries && isTransientDialError(err); attempt++ {
+		logger.V(1).Info("Retrying dial call", "addr", f.addr, "via", viaAddr, "attempt", attempt, "error", err.Error())
+		if !f.sleep(ctx, f.dialRetryDelay) {
+			return nil, ctx.Err()
+		}
+		conn, err = f.dialerFunc(ctx, f.localNetwork(viaAddr), viaAddr)
+	}
+	return conn, err
+}
+
+// isTransientDialError returns true if the error is a refused or reset connection.
+func isTransientDialError(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// waitForReconnection waits until the forwarder is not re-establishing a lost session, and returns the address to
+// redirect connections to, or the reason why the forwarder is not currently forwarding. It stops waiting after the
+// reconnect wait timeout, if any.
+func (f *PodForwarder) waitForReconnection(ctx context.Context) (string, error) {
+	var timeout <-chan time.Time
+	if f.reconnectWaitTimeout > 0 {
+		timer := f.clock.NewTimer(f.reconnectWaitTimeout)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+	for {
+		f.mu.RLock()
+		state, stateChanged, viaErr, viaAddr := f.state, f.stateChanged, f.viaErr, f.viaAddr
+		f.mu.RUnlock()
+
+		if state != StateReconnecting {
+			return viaAddr, viaErr
+		}
+		select {
+		case <-stateChanged:
+		case <-timeout:
+			return "", viaErr
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// Dial connects to the podForwarder address using a background context, for compatibility with APIs that expect a
+// dial function without context. It waits until the forwarder is initialized, without any other deadline than the
+// configured dial timeout, if any, which still applies to connecting once the forwarder is ready.
+func (f *PodForwarder) Dial() (net.Conn, error) {
+	return f.DialContext(context.Background())
+}
+
+// acquireConnSlot reserves a connection slot if the number of active connections is limited, waiting for a slot to
+// be released unless the forwarder is configured to fail fast.
+func (f *PodForwarder) acquireConnSlot(ctx context.Context) error {
+	if f.connSlots == nil {
+		return nil
+	}
+	if f.failWhenSaturated {
+		select {
+		case f.connSlots <- struct{}{}:
+			return nil
+		default:
+			return ErrTooManyConnections
+		}
+	}
+	select {
+	case f.connSlots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releaseConnSlot frees a connection slot reserved by acquireConnSlot.
+func (f *PodForwarder) releaseConnSlot() {
+	if f.connSlots != nil {
+		<-f.connSlots
+	}
+}
+
+// trackConn wraps a connection to keep track of the active connections and of their usage.
+func (f *PodForwarder) trackConn(conn net.Conn) *trackedConn {
+	atomic.AddInt64(&f.activeConns, 1)
+	atomic.AddInt64(&f.totalDials, 1)
+	return &trackedConn{
+		Conn: conn,
+		onClose: func() {
+			atomic.AddInt64(&f.activeConns, -1)
+			f.releaseConnSlot()
+			select {
+			case f.connClosed <- struct{}{}:
+			default:
+			}
+		},
+		bytesRead:          &f.bytesRead,
+		bytesWritten:       &f.bytesWritten,
+		bytesReadMetric:    proxiedBytesCounter.WithLabelValues("read"),
+		bytesWrittenMetric: proxiedBytesCounter.WithLabelValues("written"),
+	}
+}
+
+// LocalAddr returns the local address connections are redirected to by the current port forwarding session, which is
+// a UNIX socket if the forwarder is configured to use one. It blocks until the forwarder is initialized.
+func (f *PodForwarder) LocalAddr() (net.Addr, error) {
+	<-f.initChan
+
+	f.mu.RLock()
+	viaErr, viaAddr := f.viaErr, f.viaAddr
+	f.mu.RUnlock()
+	if viaErr != nil {
+		return nil, viaErr
+	}
+	if f.unixSocket {
+		return &net.UnixAddr{Name: viaAddr, Net: "unix"}, nil
+	}
+	return net.ResolveTCPAddr(f.localNetwork(viaAddr), viaAddr)
+}
+
+// LastOutput returns the most recent lines written to stdout and stderr by the port forwarding sessions, oldest
+// first, to help diagnosing a misbehaving forwarder.
+func (f *PodForwarder) LastOutput() []string {
+	return f.output.snapshot()
+}
+
+// ReadinessTimeouts returns the number of DialContext calls that returned because their context was done while the
+// forwarder was not initialized yet, which tells forwarders stuck initializing apart from unreachable ready ones.
+func (f *PodForwarder) ReadinessTimeouts() int64 {
+	return atomic.LoadInt64(&f.readinessTimeouts)
+}
+
+// ActiveConnections returns the number of connections returned by DialContext that are not closed yet.
+func (f *PodForwarder) ActiveConnections() int64 {
+	return atomic.LoadInt64(&f.activeConns)
+}
+
+// recordDialOutcome records the outcome of a call to DialContext to compute the failure rate.
+func (f *PodForwarder) recordDialOutcome(err error) {
+	if err != nil {
+		dialErrorsCounter.Inc()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dialOutcomes.record(err != nil)
+}
+
+// FailureRate returns the fraction of the most recent calls to DialContext that failed, between 0 and 1, to detect a
+// forwarder failing a high share of dials even though it is ready.
+func (f *PodForwarder) FailureRate() float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.dialOutcomes.rate()
+}
+
+// ForwarderStats is a snapshot of the state and usage of a PodForwarder.
+type ForwarderStats struct {
+	// State is the current state of the forwarder.
+	State ForwarderState
+	// LocalAddr is the local address connections are redirected to, empty if the forwarder is not ready.
+	LocalAddr string
+	// ActiveConnections is the number of connections returned by DialContext that are not closed yet.
+	ActiveConnections int64
+	// TotalDials is the number of connections returned by DialContext.
+	TotalDials int64
+	// BytesRead is the number of bytes read from the connections returned by DialContext.
+	BytesRead int64
+	// BytesWritten is the number of bytes written to the connections returned by DialContext.
+	BytesWritten int64
+	// LastError is the reason why the forwarder is not ready, if any.
+	LastError error
+	// FailureRate is the fraction of the most recent calls to DialContext that failed.
+	FailureRate float64
+}
+
+// Stats returns a consistent snapshot of the state and usage of the forwarder, for example to report it on a status
+// endpoint.
+func (f *PodForwarder) Stats() ForwarderStats {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	stats := ForwarderStats{
+		State:             f.state,
+		ActiveConnections: atomic.LoadInt64(&f.activeConns),
+		TotalDials:        atomic.LoadInt64(&f.totalDials),
+		BytesRead:         atomic.LoadInt64(&f.bytesRead),
+		BytesWritten:      atomic.LoadInt64(&f.bytesWritten),
+		LastError:         f.viaErr,
+		FailureRate:       f.dialOutcomes.rate(),
+	}
+	if f.state == StateReady {
+		stats.LocalAddr = f.viaAddr
 	}
+	return stats
+}
 
-	log.V(1).Info("Redirecting dial call", "addr", f.addr, "via", f.viaAddr)
-	return f.dialerFunc(ctx, f.network, f.viaAddr)
+// logContextKey is a context value added to log entries under a given name
+type logContextKey struct {
+	name string
+	key  interface{}
+}
+
+// contextLogValues returns the log key and value pairs of the configured context values found in ctx.
+func (f *PodForwarder) contextLogValues(ctx context.Context) []interface{} {
+	var keysAndValues []interface{}
+	for _, k := range f.logContextKeys {
+		if value := ctx.Value(k.key); value != nil {
+			keysAndValues = append(keysAndValues, k.name, value)
+		}
+	}
+	return keysAndValues
+}
+
+// logger returns the package logger with the configured context values found in ctx.
+func (f *PodForwarder) logger(ctx context.Context) logr.Logger {
+	keysAndValues := f.contextLogValues(ctx)
+	if len(keysAndValues) == 0 {
+		return log
+	}
+	return log.WithValues(keysAndValues...)
 }
 
 // Run starts a port forwarder and blocks until either the port forwarding fails or the context is done.
-func (f *PodForwarder) Run(ctx context.Context) error {
-	log.Info("Running port-forwarder for", "addr", f.addr)
-	defer log.Info("No longer running port-forwarder for", "addr", f.addr)
+//
+// If the connection to the pod is lost while forwarding, a new port forwarding session is established. A session lost
+// before being stable counts as a failure: Run gives up with ErrLostConnection after too many of them in a row, or
+// applies the retry policy or circuit breaker if any. If a circuit breaker is configured, failures are retried until
+// too many of them happen in a row, in which case dialing fails fast with ErrCircuitOpen until the cooldown period
+// elapses and a new attempt is made.
+func (f *PodForwarder) Run(ctx context.Context) (err error) {
+	logger := f.logger(ctx)
+	logger.Info("Running port-forwarder for", "addr", f.addr)
+	defer logger.Info("No longer running port-forwarder for", "addr", f.addr)
+	// the series of the forwarder are deleted once all the goroutines recording them are done
+	defer deleteMetrics(f.addr)
 
 	// used as a safeguard to ensure we only close the init channel once
 	initCloser := sync.Once{}
 
 	// wrap this in a sync.Once because it will panic if it happens more than once
-	// ensure that initChan is closed even if we were never ready.
+	// ensure that initChan is closed even if we were never ready, with an error explaining why.
 	defer initCloser.Do(func() {
+		f.setNotReady(err)
 		close(f.initChan)
 	})
 
+	// dials waiting for a reconnection must not wait for a forwarder that is not running anymore
+	defer f.stopReconnecting()
+
+	// goroutines started below must be done before we return, they all stop once runCtx is done
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
 	// derive a new context so we can ensure the port-forwarding is stopped before we return and that we return as
 	// soon as the port-forwarding stops, whichever occurs first
-	runCtx, runCtxCancel := context.WithCancel(ctx)
+	parentCtx := ctx
+	if f.shutdownTimeout > 0 {
+		// the session outlives ctx while the active connections are drained, runCtx is cancelled once they are
+		parentCtx = detachedContext{parent: ctx}
+	}
+	runCtx, runCtxCancel := context.WithCancel(parentCtx)
 	defer runCtxCancel()
 
 	if f.clientset != nil {
-		log.V(1).Info("Watching pod for changes", "namespace", f.podNSN.Namespace, "pod_name", f.podNSN.Name)
-		w, err := f.clientset.CoreV1().Pods(f.podNSN.Namespace).Watch(ctx, metav1.ListOptions{
+		logger.V(1).Info("Watching pod for changes", "namespace", f.podNSN.Namespace, "pod_name", f.podNSN.Name)
+		w, err := f.clientset.CoreV1().Pods(f.podNSN.Namespace).Watch(runCtx, metav1.ListOptions{
 			FieldSelector: fields.OneTermEqualSelector("metadata.name", f.podNSN.Name).String(),
 		})
 		if err != nil {
@@ -216,12 +894,23 @@ func (f *PodForwarder) Run(ctx context.Context) error {
 		}
 		defer w.Stop()
 
+		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			for {
 				select {
 				case evt := <-w.ResultChan():
+					if evt.Type == watch.Deleted && f.followPodRestarts {
+						// the session is lost with the pod and re-established once the pod is replaced
+						logger.V(1).Info(
+							"Pod is deleted, waiting for it to be replaced",
+							"namespace", f.podNSN.Namespace,
+							"pod_name", f.podNSN.Name,
+						)
+						continue
+					}
 					if evt.Type == watch.Deleted || evt.Type == watch.Error || evt.Type == "" {
-						log.V(1).Info(
+						logger.V(1).Info(
 							"Pod is deleted or watch failed/closed, closing pod forwarder",
 							"namespace", f.podNSN.Namespace,
 							"pod_name", f.podNSN.Name,
@@ -236,47 +925,348 @@ func (f *PodForwarder) Run(ctx context.Context) error {
 		}()
 	}
 
+	if f.maxAge > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-f.clock.After(f.maxAge):
+			case <-runCtx.Done():
+				return
+			}
+			logger.Info("Forwarder reached its maximum age, draining", "addr", f.addr, "max_age", f.maxAge)
+			f.setDraining(ErrMaxAgeReached)
+			// keep the port forwarding session running until the active connections are closed
+			f.waitForDrain(runCtx, nil)
+			runCtxCancel()
+		}()
+	}
+
+	if f.shutdownTimeout > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.drainOnShutdown(ctx, runCtx, runCtxCancel)
+		}()
+	}
+
+	if f.healthProbeInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.runHealthProbes(runCtx)
+		}()
+	}
+
 	_, port, err := net.SplitHostPort(f.addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", f.addr, err)
+	}
+	// ports may be specified as {container}/{port name} to disambiguate the containers of multi-container pods
+	port, err = resolveContainerPort(runCtx, f.clientset, f.podNSN, port)
 	if err != nil {
 		return err
 	}
 
+	if f.unixSocket {
+		socketPath, cleanup, err := prepareUnixSocketPath(f.unixSocketPath)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		f.unixSocketPath = socketPath
+	}
+
+	if f.logDedupWindow > 0 {
+		// shared by all the sessions, as a flapping forwarder logs the same lines for each of them
+		f.logDedup = newLogDeduplicator(f.logDedupWindow, f.clock)
+	}
+
+	// unstableLosses is the number of consecutive sessions lost before being stable
+	unstableLosses := 0
+	for sessions := 0; ; sessions++ {
+		if sessions > 0 {
+			reconnectsCounter.Inc()
+		}
+		started := f.clock.Now()
+		wasReady, err := f.runSession(runCtx, port, &initCloser)
+		if runCtx.Err() != nil {
+			return err
+		}
+		if f.Draining() {
+			// the active connections were lost with the session, there is nothing left to drain
+			return err
+		}
+		// a session lost right after being ready does not tell the pod is healthy again
+		stable := wasReady && f.clock.Now().Sub(started) >= stableSessionDuration
+		if stable && f.breaker != nil {
+			f.breaker.recordSuccess()
+		}
+		if stable && f.retry != nil {
+			f.retry.recordSuccess()
+		}
+
+		// the session was lost because the pod is restarting, re-establish it once the pod runs again
+		if f.followPodRestarts && f.clientset != nil && f.podRestarting(runCtx) {
+			logger.Info("Pod is restarting, waiting for it to run again", "addr", f.addr)
+			f.setLostConnection()
+			if !f.waitForPodRunning(runCtx) {
+				return nil
+			}
+			continue
+		}
+
+		// a lost session counts as a failure of the port forwarding, like an error
+		cause := err
+		if err == nil {
+			cause = ErrLostConnection
+		}
+		if err == nil && !stable {
+			unstableLosses++
+		} else {
+			unstableLosses = 0
+		}
+
+		delay := f.reconnectDelay
+		switch {
+		case f.breaker == nil && f.retry == nil && err != nil:
+			return err
+		case f.breaker == nil && f.retry == nil:
+			if unstableLosses > maxUnstableLosses {
+				logger.Info("Lost connection to pod too many times in a row, giving up", "addr", f.addr, "sessions", unstableLosses)
+				return fmt.Errorf("%w %d times in a row", ErrLostConnection, unstableLosses)
+			}
+			logger.Info("Lost connection to pod, reconnecting", "addr", f.addr, "delay", delay)
+		default:
+			// the retry policy limits the attempts made after the cooldown periods of the circuit breaker as well
+			if f.retry != nil {
+				var retry bool
+				if delay, retry = f.retry.recordFailure(); !retry {
+					logger.Info("Port-forwarding failed too many times, giving up", "addr", f.addr, "error", cause.Error())
+					return cause
+				}
+			}
+			if f.breaker != nil && f.breaker.recordFailure(f.clock.Now()) {
+				delay = f.breaker.cooldown
+				f.setFailed(fmt.Errorf("not currently forwarding: %w, last error: %s", ErrCircuitOpen, cause.Error()))
+				f.stopReconnecting()
+				// let pending and future dials fail fast rather than wait for a forward that is not coming
+				initCloser.Do(func() {
+					close(f.initChan)
+				})
+				logger.Info("Too many consecutive port-forwarding failures, pausing", "addr", f.addr, "cooldown", delay)
+			} else {
+				logger.Info("Port-forwarding failed, retrying", "addr", f.addr, "delay", delay, "error", cause.Error())
+			}
+		}
+
+		if !f.sleep(runCtx, delay) {
+			return nil
+		}
+	}
+}
+
+// sleep waits for the given duration and returns true, or returns false if the context is done first.
+func (f *PodForwarder) sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-f.clock.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// runSession runs a single port forwarding session to the given remote port and blocks until it stops. It returns
+// whether the session became ready to redirect connections.
+//
+// The session stopping without an error while runCtx is not done means the connection to the pod was lost.
+func (f *PodForwarder) runSession(runCtx context.Context, port string, initCloser *sync.Once) (bool, error) {
+	logger := f.logger(runCtx)
+
+	// do not start a session if we are already stopping, which may happen if Run is cancelled right after starting
+	if runCtx.Err() != nil {
+		f.setFailed(fmt.Errorf("not currently forwarding: %w", ErrNotReady))
+		return false, nil
+	}
+
+	sessionCtx, sessionCtxCancel := context.WithCancel(runCtx)
+	defer sessionCtxCancel()
+
 	// find an available local ephemeral port
 	localPort, err := f.ephemeralPortFinder()
 	if err != nil {
-		return err
+		f.setFailed(fmt.Errorf("not currently forwarding: %w", err))
+		return false, err
+	}
+
+	ports := []string{localPort + ":" + port}
+	// the additional ports are forwarded over the same session, each to its own local port
+	for _, additionalPort := range f.additionalPorts {
+		additionalLocalPort, err := f.ephemeralPortFinder()
+		if err != nil {
+			f.setFailed(fmt.Errorf("not currently forwarding: %w", err))
+			return false, err
+		}
+		ports = append(ports, additionalLocalPort+":"+additionalPort)
+	}
+
+	// wrap stdout / stderr through logging or the configured writers, retaining the latest stderr output to surface it on failures
+	out := &logWriter{
+		keysAndValues: append([]interface{}{
+			"namespace", f.podNSN.Namespace,
+			"pod", f.podNSN.Name,
+			"ports", ports,
+		}, f.contextLogValues(runCtx)...),
+		output: f.output,
+		dedup:  f.logDedup,
+		next:   f.stdout,
+	}
+	errLog := *out
+	errLog.next = f.stderr
+	errOut := &stderrWriter{
+		logWriter: errLog,
+		// stop the session as soon as the connection is reported lost, so it can be re-established
+		onLostConnection: sessionCtxCancel,
+	}
+
+	// expose the local side on a UNIX socket relaying connections to the local port
+	var bridge *unixSocketBridge
+	if f.unixSocket {
+		bridge, err = newUnixSocketBridge(f.unixSocketPath)
+		if err != nil {
+			f.setFailed(fmt.Errorf("not currently forwarding: %w", err))
+			return false, err
+		}
+		defer bridge.Close()
 	}
 
 	readyChan := make(chan struct{})
 	fwd, err := f.portForwarderFactory(
-		runCtx,
+		sessionCtx,
 		f.podNSN.Namespace,
 		f.podNSN.Name,
-		[]string{localPort + ":" + port},
+		ports,
 		readyChan,
+		out,
+		errOut,
 	)
 	if err != nil {
-		return err
+		f.setFailed(fmt.Errorf("not currently forwarding: %w", err))
+		return false, err
 	}
 
 	// wait for our context to be done or the port forwarder to become ready
+	wasReady := false
+	readinessDone := make(chan struct{})
 	go func() {
+		defer close(readinessDone)
+		if !f.waitForSessionReady(sessionCtx, readyChan, "127.0.0.1:"+localPort) {
+			return
+		}
+		wasReady = true
+		// the port forwarder may not listen on the requested local port, connect to the one it actually bound
+		forwarded := forwardedPorts(fwd, ports)
+		bound := boundLocalPort(forwarded, localPort)
+		if bound != localPort {
+			logger.Info("Port forwarder bound a different local port", "addr", f.addr, "requested", localPort, "bound", bound)
+		}
+		viaAddr := "127.0.0.1:" + bound
+		if bridge != nil {
+			bridge.serve(viaAddr)
+			viaAddr = f.unixSocketPath
+		}
+		f.setReady(viaAddr, forwarded)
+		sessionsActiveGauge.WithLabelValues(f.addr).Inc()
+
+		logger.Info("Ready to redirect connections", "addr", f.addr, "via", viaAddr)
+		f.trace.ready(viaAddr)
+
+		// wrap this in a sync.Once because it will panic if it happens more than once, which it may if our
+		// outer function returned just as readyChan was closed.
+		initCloser.Do(func() {
+			close(f.initChan)
+		})
+	}()
+
+	err = errOut.wrapError(fwd.ForwardPorts())
+
+	// make sure the readiness goroutine is done before recording why we stopped forwarding
+	sessionCtxCancel()
+	<-readinessDone
+	if wasReady {
+		sessionsActiveGauge.WithLabelValues(f.addr).Dec()
+	}
+
+	switch {
+	case err != nil:
+		f.setFailed(fmt.Errorf("not currently forwarding: %w", err))
+	case runCtx.Err() == nil:
+		// Run re-establishes lost sessions, let dials wait for it
+		f.setLostConnection()
+	case !wasReady:
+		f.setFailed(fmt.Errorf("not currently forwarding: %w", ErrNotReady))
+	default:
+		f.setFailed(errors.New("not currently forwarding"))
+	}
+	return wasReady, err
+}
+
+// waitForSessionReady waits for the port forwarder to signal that it is ready, or to accept connections on localAddr
+// if a readiness probe delay is set, for port forwarders that never signal it. It returns false if ctx is done first.
+func (f *PodForwarder) waitForSessionReady(ctx context.Context, readyChan chan struct{}, localAddr string) bool {
+	for {
+		var probe <-chan time.Time
+		if f.readinessProbeDelay > 0 {
+			probe = f.clock.After(f.readinessProbeDelay)
+		}
 		select {
-		case <-runCtx.Done():
+		case <-ctx.Done():
+			return false
 		case <-readyChan:
-			f.viaAddr = "127.0.0.1:" + localPort
+			return true
+		case <-probe:
+			conn, err := f.dialerFunc(ctx, f.localNetwork(localAddr), localAddr)
+			if err != nil {
+				f.logger(ctx).V(1).Info("Port forwarder is not ready yet", "addr", f.addr, "error", err.Error())
+				continue
+			}
+			if conn != nil {
+				_ = conn.Close()
+			}
+			f.logger(ctx).Info(
+				"Port forwarder accepts connections without signalling readiness", "addr", f.addr, "via", localAddr,
+			)
+			return true
+		}
+	}
+}
 
-			log.Info("Ready to redirect connections", "addr", f.addr, "via", f.viaAddr)
+// boundLocalPort returns the local port bound by the port forwarder according to the forwarded ports, or the requested
+// one if they do not report it.
+func boundLocalPort(ports []ForwardedPort, requested string) string {
+	if len(ports) == 0 || ports[0].Local == 0 {
+		return requested
+	}
+	return strconv.Itoa(int(ports[0].Local))
+}
 
-			// wrap this in a sync.Once because it will panic if it happens more than once, which it may if our
-			// outer function returned just as readyChan was closed.
-			initCloser.Do(func() {
-				close(f.initChan)
-			})
+// forwardedPorts returns the ports forwarded by fwd, or the requested {local}:{remote} ones if fwd is not able to
+// report them.
+func forwardedPorts(fwd PortForwarder, requested []string) []ForwardedPort {
+	if getter, ok := fwd.(portsGetter); ok {
+		if ports, err := getter.GetPorts(); err == nil {
+			return ports
 		}
-	}()
-
-	err = fwd.ForwardPorts()
-	f.viaErr = errors.New("not currently forwarding")
-	return err
+	}
+	ports := make([]ForwardedPort, 0, len(requested))
+	for _, pair := range requested {
+		separator := strings.Index(pair, ":")
+		local, _ := strconv.ParseUint(pair[:separator], 10, 16)
+		remote, _ := strconv.ParseUint(pair[separator+1:], 10, 16)
+		ports = append(ports, ForwardedPort{Local: uint16(local), Remote: uint16(remote)})
+	}
+	return ports
 }
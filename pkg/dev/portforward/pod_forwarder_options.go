@@ -0,0 +1,323 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport/spdy"
+
+	utilsnet "github.com/elastic/cloud-on-k8s/pkg/utils/net"
+)
+
+// PodForwarderOption configures optional behavior of a PodForwarder
+type PodForwarderOption func(f *PodForwarder)
+
+// WithDialTimeout bounds the time spent connecting to the local forwarded address once the forwarder is ready,
+// independently of the deadline of the context given to DialContext, which still applies to waiting for readiness.
+func WithDialTimeout(timeout time.Duration) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.dialTimeout = timeout
+	}
+}
+
+// WithDialRetries retries connecting to the local forwarded address up to retries times, waiting delay between
+// attempts, when the connection is refused or reset, which may happen right after the forwarder is ready. The
+// context given to DialContext and the dial timeout still bound the total time spent dialing.
+func WithDialRetries(retries int, delay time.Duration) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.dialRetries = retries
+		f.dialRetryDelay = delay
+	}
+}
+
+// WithReadinessTimeoutHook sets a function called with the context error when DialContext returns because its context
+// was done while the forwarder was not initialized yet. Dial failures once the forwarder is ready do not call it.
+func WithReadinessTimeoutHook(hook func(err error)) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.onReadinessTimeout = hook
+	}
+}
+
+// WithUnixSocket exposes the local side of the forwarding on a UNIX socket at the given path instead of a loopback TCP
+// port, the remote side still being the TCP port of the pod. A socket is created in a temporary directory if path is
+// empty. LocalAddr reports the socket address.
+func WithUnixSocket(path string) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.unixSocket = true
+		f.unixSocketPath = path
+	}
+}
+
+// WithRoundTripperFactory sets the factory of the transport and upgrader used to establish the SPDY connection to the
+// API server, for example to go through a proxy or to instrument the connection. Defaults to spdy.RoundTripperFor.
+func WithRoundTripperFactory(factory RoundTripperFactory) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.kubectl.roundTripperFactory = factory
+	}
+}
+
+// WithRestConfig sets the configuration used to reach the API server for port forwarding, instead of the ambient
+// configuration loaded when the forwarder runs.
+func WithRestConfig(cfg *rest.Config) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.kubectl.restConfig = cfg
+	}
+}
+
+// WithKubeconfig loads the configuration used to reach the API server for port forwarding from the given kube config
+// file and context, for example to forward to another cluster than the one the operator manages. An empty path or
+// context means the default one. It does not apply along with WithRestConfig, and the clientset given to the forwarder
+// to look up pods must target the same cluster.
+func WithKubeconfig(path, kubeContext string) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.kubectl.kubeconfigPath = path
+		f.kubectl.kubeContext = kubeContext
+	}
+}
+
+// WithImpersonation impersonates the given user, along with its groups and extra attributes, when forwarding ports.
+// Like the other settings applied to the loaded configuration, it does not change a shared transport.
+func WithImpersonation(impersonate rest.ImpersonationConfig) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.kubectl.impersonate = impersonate
+	}
+}
+
+// WithInsecureSkipTLSVerify disables the verification of the API server certificate for port forwarding, for example
+// for development clusters using a self-signed certificate, regardless of the kube config. A warning is logged every
+// time the configuration is loaded with this option. The proxy and round tripper factory options still apply, unlike
+// a shared transport or clientset which this option does not change.
+func WithInsecureSkipTLSVerify() PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.kubectl.insecureSkipTLSVerify = true
+	}
+}
+
+// WithStreamProtocol sets the protocol used to multiplex the forwarded streams over the connection to the API server,
+// SPDY by default. Unsupported protocols make the port forwarding sessions fail with an error.
+func WithStreamProtocol(protocol StreamProtocol) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.kubectl.streamProtocol = protocol
+	}
+}
+
+// WithSharedClientset sets the client used to build the port forwarding URL, so that forwarders created in bulk can
+// reuse a single client instead of building one from the configuration every time they run.
+func WithSharedClientset(clientSet kubernetes.Interface) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.kubectl.clientSet = clientSet
+	}
+}
+
+// WithSharedTransport sets the transport and upgrader used for the SPDY connection to the API server, for example as
+// returned once by spdy.RoundTripperFor, so that forwarders created in bulk can reuse them. The proxy and round
+// tripper factory options do not apply to a shared transport.
+func WithSharedTransport(transport http.RoundTripper, upgrader spdy.Upgrader) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.kubectl.transport = transport
+		f.kubectl.upgrader = upgrader
+	}
+}
+
+// WithRequestHook sets a function called with the port forwarding request to the API server just before it is sent,
+// to let callers add headers or query parameters for example. The hook must not change the scheme or the host of the
+// request URL, since the connection is established for the original ones.
+func WithRequestHook(hook func(req *http.Request)) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.kubectl.requestHook = hook
+	}
+}
+
+// WithMaxAge makes the forwarder stop running once it has been running for the given duration. New connections are
+// refused with ErrMaxAgeReached from then on, while the active ones are left to complete before stopping. Forwarders
+// managed by a ForwarderStore are replaced on the next dial.
+func WithMaxAge(maxAge time.Duration) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.maxAge = maxAge
+	}
+}
+
+// WithGracefulShutdown makes Run drain the forwarder once its context is done rather than tear the port forwarding
+// session down right away: new connections are refused with ErrShuttingDown while the session keeps running until the
+// active connections are closed or the given timeout elapses, whichever occurs first.
+func WithGracefulShutdown(timeout time.Duration) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.shutdownTimeout = timeout
+	}
+}
+
+// WithHealthProbe connects to the local side of the port forwarding session at the given interval while the forwarder
+// runs, reporting the outcome in the healthy metric and logging failures, to tell a silently broken forwarder apart
+// from an idle one. The probes do not count as dials of the forwarder.
+func WithHealthProbe(interval time.Duration) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.healthProbeInterval = interval
+	}
+}
+
+// WithLogDeduplication coalesces the identical lines logged by the port forwarding sessions within the given window
+// into a single entry with a count, to avoid flooding the logs when the forwarder is flapping. Every line is logged by
+// default.
+func WithLogDeduplication(window time.Duration) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.logDedupWindow = window
+	}
+}
+
+// WithLocalPortRange makes the forwarder listen locally on an available port of the inclusive range [min, max] rather
+// than on any port chosen by the OS, for example when firewall rules only allow a fixed band of local ports. Port
+// forwarding sessions fail if no port of the range is available.
+func WithLocalPortRange(min, max int) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.ephemeralPortFinder = func() (string, error) {
+			return utilsnet.GetRandomPortInRange(min, max)
+		}
+	}
+}
+
+// WithReconnectWaitTimeout bounds the time DialContext waits for a lost port forwarding session to be re-established
+// before failing with ErrLostConnection. Dials wait until their context is done by default.
+func WithReconnectWaitTimeout(timeout time.Duration) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.reconnectWaitTimeout = timeout
+	}
+}
+
+// WithTrace sets hooks called at the different phases of port forwarding, to measure them without instrumenting the
+// forwarder. The hooks of the SPDY connection are only called by the default port forwarder factory.
+func WithTrace(trace *Trace) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.trace = trace
+		f.kubectl.trace = trace
+	}
+}
+
+// WithReadinessProbe makes the forwarder probe the local port of a port forwarding session when the port forwarder did
+// not signal readiness within delay, and then every delay, to consider the session ready as soon as the local port
+// accepts connections. This supports port forwarders that serve traffic without ever signalling readiness.
+func WithReadinessProbe(delay time.Duration) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.readinessProbeDelay = delay
+	}
+}
+
+// WithPodRestartFollowing makes the forwarder survive the deletion of its pod, for example during a rolling upgrade,
+// instead of stopping: the port forwarding session is re-established once a pod with the same name runs again, which
+// is checked with an exponential backoff starting at backoff and capped at maxBackoff if not 0. Dials wait for the pod
+// to run again meanwhile. It requires the forwarder to have a clientset.
+func WithPodRestartFollowing(backoff, maxBackoff time.Duration) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.followPodRestarts = true
+		f.podRestartBackoff = backoff
+		f.podRestartMaxBackoff = maxBackoff
+	}
+}
+
+// WithAdditionalPorts forwards the given ports of the pod in addition to the port of the forwarder address over each
+// port forwarding session, each to its own local port, so that dialing several ports of the same pod, such as 9200 and
+// 9300 for Elasticsearch, does not require a session per port. DialPort connects to any of them. The UNIX socket
+// option only applies to the port of the forwarder address.
+func WithAdditionalPorts(ports ...int) PodForwarderOption {
+	return func(f *PodForwarder) {
+		for _, port := range ports {
+			f.additionalPorts = append(f.additionalPorts, strconv.Itoa(port))
+		}
+	}
+}
+
+// WithOutputWriters sets the writers receiving the stdout and stderr output of the port forwarders instead of the log,
+// for example to show it to a user or to capture it to a file. A nil writer keeps the log for that stream. The output
+// is still retained for LastOutput and for the errors of failed sessions.
+func WithOutputWriters(stdout, stderr io.Writer) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.stdout = stdout
+		f.stderr = stderr
+	}
+}
+
+// WithProxyURL sets the proxy used to reach the API server with HTTP CONNECT when establishing the SPDY connection.
+// By default, the proxy of the rest config is used if any, otherwise the one specified by the HTTPS_PROXY and
+// NO_PROXY environment variables.
+func WithProxyURL(proxyURL *url.URL) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.kubectl.proxyURL = proxyURL
+	}
+}
+
+// WithLogContextKey adds the value stored under key in the contexts given to Run and DialContext, if any, to the log
+// entries of the forwarder under the given name, for example to correlate them with a trace ID. It can be specified
+// multiple times to add several values.
+func WithLogContextKey(name string, key interface{}) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.logContextKeys = append(f.logContextKeys, logContextKey{name: name, key: key})
+	}
+}
+
+// WithDefaultNamespace sets the namespace of the pod for addresses that do not specify one, such as {name} or
+// {name}.pod. A namespace specified in the address takes precedence.
+func WithDefaultNamespace(namespace string) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.defaultNamespace = namespace
+	}
+}
+
+// WithCircuitBreaker retries failed port forwarding attempts until threshold consecutive failures happen within
+// window. The forwarder then stops retrying and fails dials fast with ErrCircuitOpen for the cooldown period, after
+// which a single attempt is made: its success resumes normal operation, its failure starts another cooldown period.
+func WithCircuitBreaker(threshold int, window, cooldown time.Duration) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.breaker = &circuitBreaker{
+			threshold: threshold,
+			window:    window,
+			cooldown:  cooldown,
+		}
+	}
+}
+
+// WithRetryPolicy retries failed port forwarding attempts instead of returning the error from Run, waiting
+// initialBackoff after the first failure and doubling the delay after each consecutive one, up to maxBackoff if not 0.
+// Run gives up and returns the error after maxAttempts consecutive failures, unless maxAttempts is 0. A session that
+// stayed ready for 30 seconds resets the backoff, while a session lost before counts as a failure. Along with
+// WithCircuitBreaker, the backoff applies until the circuit opens, and the attempts made after its cooldown periods
+// count towards maxAttempts.
+func WithRetryPolicy(maxAttempts int, initialBackoff, maxBackoff time.Duration) PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.retry = &retryPolicy{
+			maxAttempts:    maxAttempts,
+			initialBackoff: initialBackoff,
+			maxBackoff:     maxBackoff,
+		}
+	}
+}
+
+// WithMaxConcurrentDials limits the number of connections returned by DialContext that are not closed yet. Once the
+// limit is reached, DialContext waits for a connection to be closed or for its context to be done, or fails
+// immediately with ErrTooManyConnections if failFast is true. A limit lower than 1 means no limit.
+func WithMaxConcurrentDials(maxConns int, failFast bool) PodForwarderOption {
+	return func(f *PodForwarder) {
+		if maxConns < 1 {
+			f.connSlots = nil
+			return
+		}
+		f.connSlots = make(chan struct{}, maxConns)
+		f.failWhenSaturated = failFast
+	}
+}
+
+// WithContextScopedConns makes the connections returned by DialContext close when the context given to DialContext is
+// done, for example to tie them to the lifetime of a request. Connections outlive the context of the dial by default,
+// so that callers can keep using them once dialing is done.
+func WithContextScopedConns() PodForwarderOption {
+	return func(f *PodForwarder) {
+		f.contextScopedConns = true
+	}
+}
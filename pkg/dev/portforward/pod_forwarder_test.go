@@ -5,16 +5,27 @@
 package portforward
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net"
+	"os"
+	"runtime"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	testingclock "k8s.io/utils/clock/testing"
 )
 
 type capturingDialer struct {
@@ -26,18 +37,22 @@ func (d *capturingDialer) DialContext(ctx context.Context, network, address stri
 	return nil, nil
 }
 
-func NewPodForwarderWithTest(t *testing.T, network, addr string) *PodForwarder {
+func NewPodForwarderWithTest(t *testing.T, network, addr string, opts ...PodForwarderOption) *PodForwarder {
 	t.Helper()
-	fwd, err := NewPodForwarder(context.Background(), network, addr, nil)
+	fwd, err := NewPodForwarder(context.Background(), network, addr, nil, opts...)
 	require.NoError(t, err)
 	return fwd
 }
 
 type stubPortForwarder struct {
 	ctx context.Context
+	err error
 }
 
 func (c *stubPortForwarder) ForwardPorts() error {
+	if c.err != nil {
+		return c.err
+	}
 	<-c.ctx.Done()
 	return nil
 }
@@ -67,6 +82,7 @@ func Test_podForwarder_DialContext(t *testing.T) {
 					namespace, podName string,
 					ports []string,
 					readyChan chan struct{},
+					_, _ io.Writer,
 				) (PortForwarder, error) {
 					assert.Equal(t, "bar", namespace)
 					assert.Equal(t, "foo", podName)
@@ -123,81 +139,1144 @@ func Test_podForwarder_DialContext(t *testing.T) {
 	}
 }
 
-func Test_parsePodAddr(t *testing.T) {
-	type args struct {
-		addr string
+func Test_podForwarder_Run_neverReady(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+		t.Error("a forwarder that was never ready should not dial")
+		return nil, errors.New("unexpected dial")
+	}
+	started := make(chan struct{})
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		_ chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		// never close readyChan, as if the pod was not listening on the target port
+		close(started)
+		return &stubPortForwarder{ctx: ctx}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(ctx)
+	}()
+	<-started
+	cancel()
+	require.NoError(t, <-runErr)
+
+	_, err := fwd.DialContext(context.Background())
+	require.ErrorIs(t, err, ErrNotReady)
+	require.EqualError(t, err, "not currently forwarding: port forwarding is not ready")
+	require.Equal(t, StateFailed, fwd.State())
+}
+
+func Test_podForwarder_Run_readinessProbe(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithReadinessProbe(time.Second))
+	fwd.clock = fakeClock
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		_ chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		// forward without ever closing readyChan
+		return &stubPortForwarder{ctx: ctx}, nil
+	}
+	var accepting int32
+	var probes []string
+	fwd.dialerFunc = func(_ context.Context, _, address string) (net.Conn, error) {
+		probes = append(probes, address)
+		if atomic.LoadInt32(&accepting) == 0 {
+			return nil, syscall.ECONNREFUSED
+		}
+		local, _ := net.Pipe()
+		return local, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(ctx)
+	}()
+
+	// the local port does not accept connections yet
+	require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+	fakeClock.Step(time.Second)
+	require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+	require.Equal(t, StateInitializing, fwd.State())
+
+	atomic.StoreInt32(&accepting, 1)
+	fakeClock.Step(time.Second)
+	require.NoError(t, fwd.WaitForReady(ctx))
+	require.Equal(t, []string{"127.0.0.1:12345", "127.0.0.1:12345"}, probes)
+
+	cancel()
+	require.NoError(t, <-runErr)
+}
+
+func Test_podForwarder_Run_fastCancel(t *testing.T) {
+	for _, cancelBeforeRun := range []bool{true, false} {
+		t.Run(fmt.Sprintf("cancel before run: %v", cancelBeforeRun), func(t *testing.T) {
+			fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+			fwd.ephemeralPortFinder = func() (string, error) {
+				return "12345", nil
+			}
+			fwd.portForwarderFactory = func(
+				ctx context.Context,
+				_, _ string,
+				_ []string,
+				_ chan struct{},
+				_, _ io.Writer,
+			) (PortForwarder, error) {
+				return &stubPortForwarder{ctx: ctx}, nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if cancelBeforeRun {
+				cancel()
+			}
+			dialErr := make(chan error)
+			go func() {
+				_, err := fwd.DialContext(context.Background())
+				dialErr <- err
+			}()
+			runErr := make(chan error)
+			go func() {
+				runErr <- fwd.Run(ctx)
+			}()
+			cancel()
+
+			select {
+			case err := <-dialErr:
+				require.ErrorIs(t, err, ErrNotReady)
+			case <-time.After(5 * time.Second):
+				t.Fatal("DialContext did not return after Run was cancelled")
+			}
+			require.NoError(t, <-runErr)
+		})
+	}
+}
+
+func Test_podForwarder_Run_factoryError(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = func(
+		_ context.Context,
+		_, _ string,
+		_ []string,
+		_ chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		return nil, errors.New("no such pod")
+	}
+	require.EqualError(t, fwd.Run(context.Background()), "no such pod")
+
+	_, err := fwd.DialContext(context.Background())
+	require.EqualError(t, err, "not currently forwarding: no such pod")
+}
+
+func Test_podForwarder_Run_invalidAddress(t *testing.T) {
+	// skip the address validation of NewPodForwarder
+	fwd := newPodForwarder("tcp", "foo.bar.pod", types.NamespacedName{Namespace: "bar", Name: "foo"}, nil)
+	fwd.portForwarderFactory = func(
+		_ context.Context,
+		_, _ string,
+		_ []string,
+		_ chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		t.Error("no port forwarding session should be started for an invalid address")
+		return nil, errors.New("unexpected session")
+	}
+
+	dialErr := make(chan error)
+	go func() {
+		_, err := fwd.DialContext(context.Background())
+		dialErr <- err
+	}()
+
+	err := fwd.Run(context.Background())
+	require.EqualError(t, err, "invalid address foo.bar.pod: address foo.bar.pod: missing port in address")
+
+	select {
+	case err := <-dialErr:
+		require.ErrorIs(t, err, ErrNotReady)
+		require.Contains(t, err.Error(), "invalid address foo.bar.pod")
+	case <-time.After(5 * time.Second):
+		t.Fatal("DialContext did not return after Run failed")
+	}
+	require.Equal(t, StateFailed, fwd.State())
+}
+
+func Test_podForwarder_Run_stderr(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		_ chan struct{},
+		_, errOut io.Writer,
+	) (PortForwarder, error) {
+		for i := 0; i < maxStderrLines+1; i++ {
+			_, err := fmt.Fprintf(errOut, "stderr line %d\n", i)
+			require.NoError(t, err)
+		}
+		_, err := errOut.Write([]byte("Unable to listen on port 12345\n"))
+		require.NoError(t, err)
+		return &stubPortForwarder{ctx: ctx, err: errors.New("unable to listen on any of the requested ports")}, nil
+	}
+
+	err := fwd.Run(context.Background())
+	require.Error(t, err)
+	assert.Equal(t,
+		"unable to listen on any of the requested ports (stderr: stderr line 2; stderr line 3; stderr line 4; stderr line 5; Unable to listen on port 12345)",
+		err.Error(),
+	)
+
+	_, err = fwd.DialContext(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unable to listen on port 12345")
+}
+
+func Test_podForwarder_LastOutput(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	require.Empty(t, fwd.LastOutput())
+
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		_ chan struct{},
+		out, errOut io.Writer,
+	) (PortForwarder, error) {
+		_, err := out.Write([]byte("Forwarding from 127.0.0.1:12345 -> 9200\n"))
+		require.NoError(t, err)
+		_, err = errOut.Write([]byte("Unable to listen on port 12345\n"))
+		require.NoError(t, err)
+		return &stubPortForwarder{ctx: ctx, err: errors.New("unable to listen on any of the requested ports")}, nil
+	}
+
+	require.Error(t, fwd.Run(context.Background()))
+	require.Equal(t, []string{"Forwarding from 127.0.0.1:12345 -> 9200", "Unable to listen on port 12345"}, fwd.LastOutput())
+}
+
+func Test_podForwarder_Run_outputWriters(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithOutputWriters(&stdout, &stderr))
+
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		_ chan struct{},
+		out, errOut io.Writer,
+	) (PortForwarder, error) {
+		_, err := out.Write([]byte("Forwarding from 127.0.0.1:12345 -> 9200\n"))
+		require.NoError(t, err)
+		_, err = errOut.Write([]byte("Unable to listen on port 12345\n"))
+		require.NoError(t, err)
+		return &stubPortForwarder{ctx: ctx, err: errors.New("unable to listen on any of the requested ports")}, nil
+	}
+
+	err := fwd.Run(context.Background())
+	require.EqualError(t, err, "unable to listen on any of the requested ports (stderr: Unable to listen on port 12345)")
+	require.Equal(t, "Forwarding from 127.0.0.1:12345 -> 9200\n", stdout.String())
+	require.Equal(t, "Unable to listen on port 12345\n", stderr.String())
+	require.Equal(t, []string{"Forwarding from 127.0.0.1:12345 -> 9200", "Unable to listen on port 12345"}, fwd.LastOutput())
+}
+
+func Test_podForwarder_DialContext_dialTimeout(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithDialTimeout(10*time.Millisecond))
+	// pretend the forwarder is ready
+	fwd.viaAddr = "127.0.0.1:12345"
+	close(fwd.initChan)
+	// simulate a local listener that never accepts the connection
+	fwd.dialerFunc = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := fwd.DialContext(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	// the caller's context is left untouched
+	require.NoError(t, ctx.Err())
+}
+
+func Test_podForwarder_DialContext_nilContext(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.viaAddr = "127.0.0.1:12345"
+	close(fwd.initChan)
+	dialer := &capturingDialer{}
+	fwd.dialerFunc = dialer.DialContext
+
+	_, err := fwd.DialContext(nil) //nolint:staticcheck
+	require.NoError(t, err)
+	require.Equal(t, []string{"127.0.0.1:12345"}, dialer.addresses)
+}
+
+func Test_podForwarder_DialContext_localNetwork(t *testing.T) {
 	tests := []struct {
-		name    string
-		args    args
-		want    types.NamespacedName
-		wantErr error
+		name        string
+		network     string
+		viaAddr     string
+		wantNetwork string
 	}{
 		{
-			name: "pod DNS without subdomain",
-			args: args{addr: "foo.bar.pod:1234"},
-			want: types.NamespacedName{Namespace: "bar", Name: "foo"},
+			name:        "ipv4 listener",
+			network:     "tcp",
+			viaAddr:     "127.0.0.1:12345",
+			wantNetwork: "tcp4",
 		},
 		{
-			name: "pod DNS with pod and namespace only",
-			args: args{addr: "foopod.barnamespace:1234"},
-			want: types.NamespacedName{Namespace: "barnamespace", Name: "foopod"},
+			name:        "ipv6 listener for a tcp4 forwarder",
+			network:     "tcp4",
+			viaAddr:     "[::1]:12345",
+			wantNetwork: "tcp6",
 		},
 		{
-			name: "pod DNS with pod, subdomain and namespace",
-			args: args{addr: "foopod.foosubdomain.barnamespace:1234"},
-			want: types.NamespacedName{Namespace: "barnamespace", Name: "foopod"},
+			name:        "ipv4 listener for a tcp6 forwarder",
+			network:     "tcp6",
+			viaAddr:     "127.0.0.1:12345",
+			wantNetwork: "tcp4",
 		},
 		{
-			name:    "invalid",
-			args:    args{addr: "foobar:1234"},
-			wantErr: errors.New("unsupported pod address format: foobar"),
+			name:        "host name listener",
+			network:     "tcp4",
+			viaAddr:     "localhost:12345",
+			wantNetwork: "tcp",
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parsePodAddr(context.Background(), tt.args.addr, nil)
-
-			if tt.wantErr != nil {
-				assert.Equal(t, tt.wantErr, err)
-				return
+			fwd := NewPodForwarderWithTest(t, tt.network, "foo.bar.pod:9200")
+			// pretend the forwarder is ready
+			fwd.viaAddr = tt.viaAddr
+			close(fwd.initChan)
+			var gotNetwork string
+			fwd.dialerFunc = func(_ context.Context, network, _ string) (net.Conn, error) {
+				gotNetwork = network
+				return nil, nil
 			}
+
+			_, err := fwd.DialContext(context.Background())
 			require.NoError(t, err)
-			require.Equal(t, tt.want, *got)
+			require.Equal(t, tt.wantNetwork, gotNetwork)
 		})
 	}
 }
 
-func Test_podIPv4Regex(t *testing.T) {
+func Test_podForwarder_ReadinessTimeouts(t *testing.T) {
+	var hookErrs []error
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithReadinessTimeoutHook(func(err error) {
+		hookErrs = append(hookErrs, err)
+	}))
+
+	// the forwarder is not running, so it never becomes ready
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := fwd.DialContext(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, int64(1), fwd.ReadinessTimeouts())
+	require.Equal(t, []error{context.DeadlineExceeded}, hookErrs)
+
+	// dial failures once ready are not readiness timeouts
+	fwd.viaAddr = "127.0.0.1:12345"
+	close(fwd.initChan)
+	fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+		return nil, errors.New("unreachable")
+	}
+	_, err = fwd.DialContext(context.Background())
+	require.EqualError(t, err, "unreachable")
+	require.Equal(t, int64(1), fwd.ReadinessTimeouts())
+	require.Len(t, hookErrs, 1)
+
+	// nor are dials whose context is done once the forwarder is initialized, whichever the select picks
+	done, cancelDone := context.WithCancel(context.Background())
+	cancelDone()
+	for i := 0; i < 100; i++ {
+		_, err = fwd.DialContext(done)
+		require.ErrorIs(t, err, context.Canceled)
+	}
+	require.Equal(t, int64(1), fwd.ReadinessTimeouts())
+	require.Len(t, hookErrs, 1)
+}
+
+func Test_podForwarder_DialContext_dialRetries(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
 	tests := []struct {
-		name string
-		addr string
-		want bool
+		name      string
+		retries   int
+		dialErrs  []error
+		wantDials int
+		wantErr   error
 	}{
 		{
-			name: "valid ipv4",
-			addr: "10.0.0.2",
-			want: true,
+			name:      "refused once then succeeds",
+			retries:   3,
+			dialErrs:  []error{refused, nil},
+			wantDials: 2,
 		},
 		{
-			name: "invalid ipv4 still correctly parsed",
-			addr: "666.666.666.666",
-			want: true,
+			name:      "no retries by default",
+			retries:   0,
+			dialErrs:  []error{refused},
+			wantDials: 1,
+			wantErr:   syscall.ECONNREFUSED,
 		},
 		{
-			name: "empty string",
-			addr: "",
-			want: false,
+			name:      "gives up after the configured number of retries",
+			retries:   2,
+			dialErrs:  []error{refused, refused, refused, nil},
+			wantDials: 3,
+			wantErr:   syscall.ECONNREFUSED,
 		},
 		{
-			name: "dns",
-			addr: "name.namespace.pod",
-			want: false,
+			name:      "other errors are not retried",
+			retries:   3,
+			dialErrs:  []error{os.ErrPermission, nil},
+			wantDials: 1,
+			wantErr:   os.ErrPermission,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			require.Equal(t, tt.want, podIPv4Regex.MatchString(tt.addr))
+			fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithDialRetries(tt.retries, 0))
+			fwd.viaAddr = "127.0.0.1:12345"
+			close(fwd.initChan)
+			dials := 0
+			fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+				err := tt.dialErrs[dials]
+				dials++
+				if err != nil {
+					return nil, err
+				}
+				local, _ := net.Pipe()
+				return local, nil
+			}
+
+			conn, err := fwd.DialContext(context.Background())
+			require.Equal(t, tt.wantDials, dials)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				require.Equal(t, int64(0), fwd.ActiveConnections())
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, conn.Close())
 		})
 	}
 }
+
+func Test_podForwarder_DialContext_dialRetriesHonorContext(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithDialRetries(3, time.Hour))
+	fwd.viaAddr = "127.0.0.1:12345"
+	close(fwd.initChan)
+	fwd.clock = testingclock.NewFakeClock(time.Now())
+	fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+		return nil, syscall.ECONNRESET
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := fwd.DialContext(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_podForwarder_Run_lostConnection(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.reconnectDelay = 0
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+
+	loseConnection := make(chan struct{})
+	reconnect := make(chan struct{})
+	sessions := 0
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		readyChan chan struct{},
+		_, errOut io.Writer,
+	) (PortForwarder, error) {
+		sessions++
+		if sessions > 1 {
+			// hold the new session until the test is done checking the intermediate state
+			<-reconnect
+		}
+		close(readyChan)
+		if sessions == 1 {
+			go func() {
+				<-loseConnection
+				_, err := errOut.Write([]byte("E0101 00:00:00.000000 portforward.go:234] lost connection to pod\n"))
+				assert.NoError(t, err)
+			}()
+		}
+		return &stubPortForwarder{ctx: ctx}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(ctx)
+	}()
+
+	dialer := &capturingDialer{}
+	fwd.dialerFunc = dialer.DialContext
+	_, err := fwd.DialContext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, StateReady, fwd.State())
+
+	close(loseConnection)
+	require.Eventually(t, func() bool {
+		return fwd.State() == StateReconnecting
+	}, 5*time.Second, time.Millisecond)
+	require.Equal(t, StateReconnecting, fwd.Stats().State)
+	_, err = fwd.ForwardedPorts()
+	require.ErrorIs(t, err, ErrLostConnection)
+
+	// dials wait for the session to be re-established
+	dialErr := make(chan error)
+	go func() {
+		_, err := fwd.DialContext(ctx)
+		dialErr <- err
+	}()
+	select {
+	case err := <-dialErr:
+		t.Fatalf("dial returned while reconnecting: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(reconnect)
+	require.NoError(t, <-dialErr)
+	require.Equal(t, StateReady, fwd.State())
+	require.Equal(t, 2, sessions)
+
+	cancel()
+	require.NoError(t, <-runErr)
+	require.Equal(t, StateFailed, fwd.State())
+}
+
+func Test_podForwarder_DialContext_reconnectingStopped(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.reconnectDelay = time.Hour
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	sessions := make(chan io.Writer, 1)
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		readyChan chan struct{},
+		_, errOut io.Writer,
+	) (PortForwarder, error) {
+		sessions <- errOut
+		close(readyChan)
+		return &stubPortForwarder{ctx: ctx}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(ctx)
+	}()
+	errOut := <-sessions
+	_, err := errOut.Write([]byte(lostConnectionMessage))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return fwd.State() == StateReconnecting
+	}, 5*time.Second, time.Millisecond)
+
+	// a dial waiting for the reconnection does not wait forever once the forwarder stops
+	dialErr := make(chan error)
+	go func() {
+		_, err := fwd.DialContext(context.Background())
+		dialErr <- err
+	}()
+	cancel()
+	require.NoError(t, <-runErr)
+	require.ErrorIs(t, <-dialErr, ErrLostConnection)
+	require.Equal(t, StateFailed, fwd.State())
+}
+
+func Test_podForwarder_DialContext_reconnectWaitTimeout(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithReconnectWaitTimeout(time.Minute))
+	fwd.clock = fakeClock
+	// pretend the forwarder lost its session after being ready
+	fwd.setReady("127.0.0.1:12345", nil)
+	close(fwd.initChan)
+	fwd.setLostConnection()
+
+	dialErr := make(chan error)
+	go func() {
+		_, err := fwd.DialContext(context.Background())
+		dialErr <- err
+	}()
+	require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+	fakeClock.Step(time.Minute)
+	require.ErrorIs(t, <-dialErr, ErrLostConnection)
+	require.Equal(t, StateReconnecting, fwd.State())
+}
+
+func Test_podForwarder_Run_reconnectDelay(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.clock = fakeClock
+	fwd.reconnectDelay = time.Minute
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+
+	sessions := make(chan io.Writer, 2)
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		readyChan chan struct{},
+		_, errOut io.Writer,
+	) (PortForwarder, error) {
+		sessions <- errOut
+		close(readyChan)
+		return &stubPortForwarder{ctx: ctx}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(ctx)
+	}()
+	errOut := <-sessions
+	require.Eventually(t, func() bool {
+		return fwd.State() == StateReady
+	}, 5*time.Second, time.Millisecond)
+
+	// lose the connection, the new session should only be established after the reconnect delay
+	_, err := errOut.Write([]byte(lostConnectionMessage))
+	require.NoError(t, err)
+	require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+	require.Len(t, sessions, 0)
+
+	fakeClock.Step(time.Minute)
+	<-sessions
+
+	cancel()
+	require.NoError(t, <-runErr)
+}
+
+// flappingPortForwarderFactory returns a port forwarder factory whose sessions report the connection to the pod as
+// lost as soon as the forwarder is ready, counting the sessions.
+func flappingPortForwarderFactory(fwd *PodForwarder, sessions *int32) PortForwarderFactory {
+	return func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		readyChan chan struct{},
+		_, errOut io.Writer,
+	) (PortForwarder, error) {
+		atomic.AddInt32(sessions, 1)
+		close(readyChan)
+		go func() {
+			for fwd.State() != StateReady {
+				time.Sleep(time.Millisecond)
+			}
+			_, _ = errOut.Write([]byte(lostConnectionMessage))
+		}()
+		return &stubPortForwarder{ctx: ctx}, nil
+	}
+}
+
+func Test_podForwarder_Run_unstableSessions(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.reconnectDelay = 0
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	var sessions int32
+	fwd.portForwarderFactory = flappingPortForwarderFactory(fwd, &sessions)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// sessions lost right after being ready are not re-established forever
+	require.ErrorIs(t, fwd.Run(ctx), ErrLostConnection)
+	require.Equal(t, int32(maxUnstableLosses+1), atomic.LoadInt32(&sessions))
+	require.Equal(t, StateFailed, fwd.State())
+}
+
+func Test_podForwarder_Run_unstableSessionsRetryPolicy(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithRetryPolicy(3, time.Millisecond, time.Millisecond))
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	var sessions int32
+	fwd.portForwarderFactory = flappingPortForwarderFactory(fwd, &sessions)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// lost sessions count as failed attempts of the retry policy
+	require.ErrorIs(t, fwd.Run(ctx), ErrLostConnection)
+	require.Equal(t, int32(3), atomic.LoadInt32(&sessions))
+	require.Equal(t, StateFailed, fwd.State())
+}
+
+func Test_podForwarder_Run_maxAge(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithMaxAge(time.Hour))
+	fwd.clock = fakeClock
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		readyChan chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		close(readyChan)
+		return &stubPortForwarder{ctx: ctx}, nil
+	}
+	fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+		local, _ := net.Pipe()
+		return local, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(ctx)
+	}()
+	require.NoError(t, fwd.WaitForReady(ctx))
+	conn, err := fwd.DialContext(ctx)
+	require.NoError(t, err)
+
+	require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+	fakeClock.Step(time.Hour)
+	require.Eventually(t, fwd.Draining, 5*time.Second, time.Millisecond)
+
+	// new connections are refused while the active one is left open
+	_, err = fwd.DialContext(ctx)
+	require.ErrorIs(t, err, ErrMaxAgeReached)
+	select {
+	case err := <-runErr:
+		t.Fatalf("Run returned before the active connection was closed: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	require.NoError(t, conn.Close())
+	require.NoError(t, <-runErr)
+	require.NoError(t, ctx.Err())
+}
+
+func Test_podForwarder_Run_noGoroutineLeak(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"}})
+	newForwarder := func(ready bool) *PodForwarder {
+		fwd, err := NewPodForwarder(context.Background(), "tcp", "foo.bar.pod:9200", clientset)
+		require.NoError(t, err)
+		fwd.ephemeralPortFinder = func() (string, error) {
+			return "12345", nil
+		}
+		fwd.portForwarderFactory = func(
+			ctx context.Context,
+			_, _ string,
+			_ []string,
+			readyChan chan struct{},
+			_, _ io.Writer,
+		) (PortForwarder, error) {
+			if !ready {
+				// stop forwarding before being ready
+				return &stubPortForwarder{ctx: ctx, err: errors.New("unable to listen")}, nil
+			}
+			close(readyChan)
+			return &stubPortForwarder{ctx: ctx}, nil
+		}
+		return fwd
+	}
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		// ready, then stopped
+		fwd := newForwarder(true)
+		ctx, cancel := context.WithCancel(context.Background())
+		runErr := make(chan error)
+		go func() {
+			runErr <- fwd.Run(ctx)
+		}()
+		require.Eventually(t, func() bool {
+			return fwd.State() == StateReady
+		}, 5*time.Second, time.Millisecond)
+		cancel()
+		require.NoError(t, <-runErr)
+
+		// failing before being ready
+		require.Error(t, newForwarder(false).Run(context.Background()))
+	}
+
+	require.Eventually(t, func() bool {
+		return runtime.NumGoroutine() <= before
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+type stubPortsGetterForwarder struct {
+	stubPortForwarder
+	ports []ForwardedPort
+}
+
+func (c *stubPortsGetterForwarder) GetPorts() ([]ForwardedPort, error) {
+	return c.ports, nil
+}
+
+func Test_podForwarder_ForwardedPorts(t *testing.T) {
+	tests := []struct {
+		name      string
+		forwarder func(ctx context.Context) PortForwarder
+		want      []ForwardedPort
+	}{
+		{
+			name: "ports reported by the port forwarder",
+			forwarder: func(ctx context.Context) PortForwarder {
+				return &stubPortsGetterForwarder{
+					stubPortForwarder: stubPortForwarder{ctx: ctx},
+					ports:             []ForwardedPort{{Local: 54321, Remote: 9200}},
+				}
+			},
+			want: []ForwardedPort{{Local: 54321, Remote: 9200}},
+		},
+		{
+			name: "requested ports by default",
+			forwarder: func(ctx context.Context) PortForwarder {
+				return &stubPortForwarder{ctx: ctx}
+			},
+			want: []ForwardedPort{{Local: 12345, Remote: 9200}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+			fwd.ephemeralPortFinder = func() (string, error) {
+				return "12345", nil
+			}
+			fwd.portForwarderFactory = func(
+				ctx context.Context,
+				_, _ string,
+				_ []string,
+				readyChan chan struct{},
+				_, _ io.Writer,
+			) (PortForwarder, error) {
+				close(readyChan)
+				return tt.forwarder(ctx), nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			runErr := make(chan error)
+			go func() {
+				runErr <- fwd.Run(ctx)
+			}()
+
+			got, err := fwd.ForwardedPorts()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+
+			cancel()
+			require.NoError(t, <-runErr)
+			_, err = fwd.ForwardedPorts()
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_podForwarder_Run_boundLocalPort(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		ports []string,
+		readyChan chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		assert.Equal(t, []string{"12345:9200"}, ports)
+		close(readyChan)
+		// the port forwarder listens on another local port than the requested one
+		return &stubPortsGetterForwarder{
+			stubPortForwarder: stubPortForwarder{ctx: ctx},
+			ports:             []ForwardedPort{{Local: 54321, Remote: 9200}},
+		}, nil
+	}
+	dialer := &capturingDialer{}
+	fwd.dialerFunc = dialer.DialContext
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(ctx)
+	}()
+
+	_, err := fwd.DialContext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"127.0.0.1:54321"}, dialer.addresses)
+	localAddr, err := fwd.LocalAddr()
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1:54321", localAddr.String())
+
+	cancel()
+	require.NoError(t, <-runErr)
+}
+
+func Test_podForwarder_DialPort(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithAdditionalPorts(9300))
+	localPorts := []string{"12345", "12346"}
+	var found int32
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return localPorts[atomic.AddInt32(&found, 1)-1], nil
+	}
+	var sessions int32
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		ports []string,
+		readyChan chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		atomic.AddInt32(&sessions, 1)
+		// both ports are forwarded by a single session
+		assert.Equal(t, []string{"12345:9200", "12346:9300"}, ports)
+		close(readyChan)
+		return &stubPortForwarder{ctx: ctx}, nil
+	}
+	dialer := &capturingDialer{}
+	fwd.dialerFunc = dialer.DialContext
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(ctx)
+	}()
+
+	_, err := fwd.DialContext(ctx)
+	require.NoError(t, err)
+	_, err = fwd.DialPort(ctx, 9300)
+	require.NoError(t, err)
+	_, err = fwd.DialPort(ctx, 9200)
+	require.NoError(t, err)
+	_, err = fwd.DialPort(ctx, 5601)
+	require.EqualError(t, err, "port 5601 is not forwarded by the forwarder to foo.bar.pod:9200")
+
+	require.Equal(t, []string{"127.0.0.1:12345", "127.0.0.1:12346", "127.0.0.1:12345"}, dialer.addresses)
+	require.Equal(t, int32(1), atomic.LoadInt32(&sessions))
+
+	cancel()
+	require.NoError(t, <-runErr)
+}
+
+func TestNewPodForwarder_WithDefaultNamespace(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "es-master-0:9200", WithDefaultNamespace("elastic"))
+	require.Equal(t, types.NamespacedName{Namespace: "elastic", Name: "es-master-0"}, fwd.podNSN)
+}
+
+func Test_podForwarder_Run_containerPort(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "bar", Name: "foo"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "main", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}},
+				{Name: "sidecar", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 9090}}},
+			},
+		},
+	})
+	fwd, err := NewPodForwarder(context.Background(), "tcp", "foo.bar.pod:sidecar/http", clientset)
+	require.NoError(t, err)
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		ports []string,
+		_ chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		assert.Equal(t, []string{"12345:9090"}, ports)
+		return &stubPortForwarder{ctx: ctx, err: errors.New("done")}, nil
+	}
+	require.EqualError(t, fwd.Run(context.Background()), "done")
+}
+
+type testContextKey string
+
+func Test_podForwarder_contextLogValues(t *testing.T) {
+	traceKey, userKey := testContextKey("trace"), testContextKey("user")
+	ctx := context.WithValue(context.Background(), traceKey, "abc123")
+
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	require.Empty(t, fwd.contextLogValues(ctx))
+
+	fwd = NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200",
+		WithLogContextKey("trace_id", traceKey),
+		WithLogContextKey("user", userKey),
+	)
+	require.Equal(t, []interface{}{"trace_id", "abc123"}, fwd.contextLogValues(ctx))
+
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		_ chan struct{},
+		out, errOut io.Writer,
+	) (PortForwarder, error) {
+		for _, w := range []io.Writer{out, errOut} {
+			var keysAndValues []interface{}
+			switch w := w.(type) {
+			case *logWriter:
+				keysAndValues = w.keysAndValues
+			case *stderrWriter:
+				keysAndValues = w.keysAndValues
+			}
+			assert.Subset(t, keysAndValues, []interface{}{"trace_id", "abc123"})
+		}
+		return &stubPortForwarder{ctx: ctx, err: errors.New("done")}, nil
+	}
+	require.EqualError(t, fwd.Run(ctx), "done")
+}
+
+func Test_podForwarder_Probe(t *testing.T) {
+	newForwarder := func(t *testing.T, dialErr error) *PodForwarder {
+		t.Helper()
+		fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+		fwd.viaAddr = "127.0.0.1:12345"
+		close(fwd.initChan)
+		fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+			if dialErr != nil {
+				return nil, dialErr
+			}
+			local, remote := net.Pipe()
+			go func() {
+				_, _ = remote.Write([]byte("banner"))
+				_ = remote.Close()
+			}()
+			return local, nil
+		}
+		return fwd
+	}
+
+	t.Run("successful probe", func(t *testing.T) {
+		fwd := newForwarder(t, nil)
+		err := fwd.Probe(context.Background(), func(conn net.Conn) error {
+			banner := make([]byte, 6)
+			if _, err := io.ReadFull(conn, banner); err != nil {
+				return err
+			}
+			assert.Equal(t, "banner", string(banner))
+			return nil
+		})
+		require.NoError(t, err)
+		// the probe connection is closed
+		require.Equal(t, int64(0), fwd.ActiveConnections())
+	})
+
+	t.Run("failed probe", func(t *testing.T) {
+		fwd := newForwarder(t, nil)
+		err := fwd.Probe(context.Background(), func(conn net.Conn) error {
+			return errors.New("unexpected banner")
+		})
+		require.ErrorIs(t, err, ErrProbeFailed)
+		require.EqualError(t, err, "probe failed: unexpected banner")
+		var probeErr *ProbeError
+		require.True(t, errors.As(err, &probeErr))
+		require.EqualError(t, probeErr.Err, "unexpected banner")
+		require.Equal(t, int64(0), fwd.ActiveConnections())
+	})
+
+	t.Run("probe deadline", func(t *testing.T) {
+		fwd := newForwarder(t, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := fwd.Probe(ctx, func(conn net.Conn) error {
+			// nobody reads from the other side
+			_, err := conn.Write([]byte("request"))
+			return err
+		})
+		require.ErrorIs(t, err, ErrProbeFailed)
+		// the cause of the failure is still reachable
+		require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+		var netErr net.Error
+		require.True(t, errors.As(err, &netErr) && netErr.Timeout())
+	})
+
+	t.Run("broken forwarder", func(t *testing.T) {
+		dialErr := errors.New("connection refused")
+		fwd := newForwarder(t, dialErr)
+		err := fwd.Probe(context.Background(), func(conn net.Conn) error {
+			t.Error("the probe should not run without a connection")
+			return nil
+		})
+		require.ErrorIs(t, err, dialErr)
+		require.NotErrorIs(t, err, ErrProbeFailed)
+	})
+}
+
+func TestWithLocalPortRange(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithLocalPortRange(1, 0))
+	_, err := fwd.ephemeralPortFinder()
+	require.EqualError(t, err, "invalid port range 1-0")
+}
+
+func TestNewPodForwarderForPod(t *testing.T) {
+	fwd := NewPodForwarderForPod("tcp", types.NamespacedName{Namespace: "bar", Name: "foo"}, 9200, nil)
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		namespace, podName string,
+		ports []string,
+		readyChan chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		assert.Equal(t, "bar", namespace)
+		assert.Equal(t, "foo", podName)
+		assert.Equal(t, []string{"12345:9200"}, ports)
+		return &stubPortForwarder{ctx: ctx, err: errors.New("done")}, nil
+	}
+	require.EqualError(t, fwd.Run(context.Background()), "done")
+}
@@ -0,0 +1,53 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podRestarting returns whether the pod of the forwarder is not running because it was deleted or is being replaced,
+// for example during a rolling upgrade. Errors other than the pod not being found are not considered restarts.
+func (f *PodForwarder) podRestarting(ctx context.Context) bool {
+	pod, err := f.clientset.CoreV1().Pods(f.podNSN.Namespace).Get(ctx, f.podNSN.Name, metav1.GetOptions{})
+	if err != nil {
+		return apierrors.IsNotFound(err)
+	}
+	return !podRunning(pod)
+}
+
+// waitForPodRunning waits for the pod of the forwarder to run again, checking it with an exponential backoff. It
+// returns false if the context is done first.
+func (f *PodForwarder) waitForPodRunning(ctx context.Context) bool {
+	backoff := f.podRestartBackoff
+	if backoff <= 0 {
+		backoff = defaultReconnectDelay
+	}
+	for {
+		if !f.sleep(ctx, backoff) {
+			return false
+		}
+		pod, err := f.clientset.CoreV1().Pods(f.podNSN.Namespace).Get(ctx, f.podNSN.Name, metav1.GetOptions{})
+		switch {
+		case err == nil && podRunning(pod):
+			return true
+		case err != nil && !apierrors.IsNotFound(err):
+			f.logger(ctx).V(1).Info("Failed to get restarting pod", "addr", f.addr, "error", err.Error())
+		}
+		backoff *= 2
+		if f.podRestartMaxBackoff > 0 && backoff > f.podRestartMaxBackoff {
+			backoff = f.podRestartMaxBackoff
+		}
+	}
+}
+
+// podRunning returns whether the pod is running and not being deleted.
+func podRunning(pod *corev1.Pod) bool {
+	return pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning
+}
@@ -0,0 +1,130 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+// lostPortForwarder forwards ports until its context is done or the connection to the pod is lost.
+type lostPortForwarder struct {
+	ctx  context.Context
+	lost chan struct{}
+}
+
+func (c *lostPortForwarder) ForwardPorts() error {
+	select {
+	case <-c.ctx.Done():
+	case <-c.lost:
+	}
+	return nil
+}
+
+func Test_podForwarder_Run_followPodRestarts(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	clientset := fake.NewSimpleClientset(pod)
+	fakeClock := testingclock.NewFakeClock(time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fwd, err := NewPodForwarder(ctx, "tcp", "foo.bar.pod:9200", clientset,
+		WithPodRestartFollowing(time.Second, 4*time.Second),
+	)
+	require.NoError(t, err)
+	fwd.clock = fakeClock
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	lost := make(chan struct{})
+	var sessions int32
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		readyChan chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		close(readyChan)
+		if atomic.AddInt32(&sessions, 1) == 1 {
+			return &lostPortForwarder{ctx: ctx, lost: lost}, nil
+		}
+		return &stubPortForwarder{ctx: ctx}, nil
+	}
+
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(ctx)
+	}()
+	require.NoError(t, fwd.WaitForReady(ctx))
+
+	// the pod is deleted, which loses the session
+	require.NoError(t, clientset.CoreV1().Pods("bar").Delete(ctx, "foo", metav1.DeleteOptions{}))
+	close(lost)
+	require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+	require.Equal(t, StateReconnecting, fwd.State())
+
+	// the pod is still not replaced after the first backoff
+	fakeClock.Step(time.Second)
+	require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+	require.Equal(t, int32(1), atomic.LoadInt32(&sessions))
+
+	// the session is re-established once the pod is replaced
+	_, err = clientset.CoreV1().Pods("bar").Create(ctx, pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+	fakeClock.Step(2 * time.Second)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&sessions) == 2 && fwd.State() == StateReady
+	}, 5*time.Second, time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-runErr)
+}
+
+func Test_podRunning(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name string
+		pod  corev1.Pod
+		want bool
+	}{
+		{
+			name: "running",
+			pod:  corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+			want: true,
+		},
+		{
+			name: "pending",
+			pod:  corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending}},
+			want: false,
+		},
+		{
+			name: "being deleted",
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, podRunning(&tt.pod))
+		})
+	}
+}
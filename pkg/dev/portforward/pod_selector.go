@@ -0,0 +1,98 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
+)
+
+// PodSelector selects the pod to forward to by its labels rather than by its name.
+type PodSelector struct {
+	// Namespace is the namespace of the pod.
+	Namespace string
+	// Selector matches the labels of the pod.
+	Selector labels.Selector
+	// PickFirst picks the first ready pod ordered by name when several pods match, instead of returning an error.
+	PickFirst bool
+	// DiscoveryTimeouts bound the API calls made to list the pods.
+	DiscoveryTimeouts DiscoveryTimeouts
+}
+
+// NewPodForwarderForSelector returns a new initialized podForwarder forwarding to the given port of the single ready
+// pod matching the selector.
+func NewPodForwarderForSelector(
+	ctx context.Context,
+	network string,
+	selector PodSelector,
+	port int,
+	clientset kubernetes.Interface,
+	opts ...PodForwarderOption,
+) (*PodForwarder, error) {
+	podNSN, err := findPodForSelector(ctx, selector, clientset)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPodForwarderForPod(network, *podNSN, port, clientset, opts...), nil
+}
+
+// findPodForSelector returns the ready pod matching the selector.
+func findPodForSelector(ctx context.Context, selector PodSelector, clientset kubernetes.Interface) (*types.NamespacedName, error) {
+	if clientset == nil {
+		return nil, errors.New("a clientset is required to select pods by label")
+	}
+	if selector.Selector == nil {
+		return nil, errors.New("a label selector is required to select pods by label")
+	}
+
+	ctx, cancel := selector.DiscoveryTimeouts.withOverallTimeout(ctx)
+	defer cancel()
+
+	var pods *corev1.PodList
+	err := selector.DiscoveryTimeouts.call(ctx, "listing pods", func(ctx context.Context) error {
+		var err error
+		pods, err = clientset.CoreV1().
+			Pods(selector.Namespace).
+			List(ctx, metav1.ListOptions{LabelSelector: selector.Selector.String()})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var readyPods []corev1.Pod
+	for _, pod := range pods.Items {
+		if k8s.IsPodReady(pod) {
+			readyPods = append(readyPods, pod)
+		}
+	}
+
+	switch {
+	case len(readyPods) == 0:
+		return nil, fmt.Errorf("no ready pod matching %q in namespace %s", selector.Selector, selector.Namespace)
+	case len(readyPods) > 1 && !selector.PickFirst:
+		return nil, fmt.Errorf(
+			"%d ready pods matching %q in namespace %s, expected exactly one",
+			len(readyPods), selector.Selector, selector.Namespace,
+		)
+	}
+
+	sort.Slice(readyPods, func(i, j int) bool {
+		return readyPods[i].Name < readyPods[j].Name
+	})
+	nsn := k8s.ExtractNamespacedName(&readyPods[0])
+	return &nsn, nil
+}
@@ -0,0 +1,108 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestPod(name string, ready bool, podLabels map[string]string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "ns",
+			Labels:    podLabels,
+		},
+	}
+	if ready {
+		pod.Status.Conditions = []corev1.PodCondition{
+			{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			{Type: corev1.ContainersReady, Status: corev1.ConditionTrue},
+		}
+	}
+	return pod
+}
+
+func Test_findPodForSelector(t *testing.T) {
+	masterLabels := map[string]string{"role": "master"}
+	tests := []struct {
+		name     string
+		pods     []runtime.Object
+		selector PodSelector
+		want     types.NamespacedName
+		wantErr  error
+	}{
+		{
+			name: "single ready pod",
+			pods: []runtime.Object{
+				newTestPod("es-master-0", true, masterLabels),
+				newTestPod("es-master-1", false, masterLabels),
+				newTestPod("es-data-0", true, map[string]string{"role": "data"}),
+			},
+			selector: PodSelector{Namespace: "ns", Selector: labels.SelectorFromSet(masterLabels)},
+			want:     types.NamespacedName{Namespace: "ns", Name: "es-master-0"},
+		},
+		{
+			name:     "no matching pod",
+			pods:     []runtime.Object{newTestPod("es-master-0", false, masterLabels)},
+			selector: PodSelector{Namespace: "ns", Selector: labels.SelectorFromSet(masterLabels)},
+			wantErr:  errors.New(`no ready pod matching "role=master" in namespace ns`),
+		},
+		{
+			name: "several ready pods",
+			pods: []runtime.Object{
+				newTestPod("es-master-1", true, masterLabels),
+				newTestPod("es-master-0", true, masterLabels),
+			},
+			selector: PodSelector{Namespace: "ns", Selector: labels.SelectorFromSet(masterLabels)},
+			wantErr:  errors.New(`2 ready pods matching "role=master" in namespace ns, expected exactly one`),
+		},
+		{
+			name: "several ready pods, pick first",
+			pods: []runtime.Object{
+				newTestPod("es-master-1", true, masterLabels),
+				newTestPod("es-master-0", true, masterLabels),
+			},
+			selector: PodSelector{Namespace: "ns", Selector: labels.SelectorFromSet(masterLabels), PickFirst: true},
+			want:     types.NamespacedName{Namespace: "ns", Name: "es-master-0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findPodForSelector(context.Background(), tt.selector, fake.NewSimpleClientset(tt.pods...))
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, *got)
+		})
+	}
+}
+
+func TestNewPodForwarderForSelector(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestPod("es-master-0", true, map[string]string{"role": "master"}))
+	fwd, err := NewPodForwarderForSelector(
+		context.Background(),
+		"tcp",
+		PodSelector{Namespace: "ns", Selector: labels.SelectorFromSet(map[string]string{"role": "master"})},
+		9200,
+		clientset,
+	)
+	require.NoError(t, err)
+	require.Equal(t, types.NamespacedName{Namespace: "ns", Name: "es-master-0"}, fwd.podNSN)
+	require.Equal(t, "es-master-0.ns.pod:9200", fwd.addr)
+}
@@ -0,0 +1,213 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// defaultClusterDomain is the domain of the DNS names of the cluster
+const defaultClusterDomain = "cluster.local"
+
+// errNoSuchHost is returned when a DNS name of the cluster does not match any pod or ready service endpoint
+var errNoSuchHost = errors.New("no such host")
+
+// ClusterResolver answers the DNS queries for the pods and services of the cluster from the Kubernetes API, so that
+// code resolving host names itself rather than dialing through a ForwardingDialer also works outside the cluster.
+//
+// Services are resolved to the IPs of their ready endpoints and pods to their own IPs, which are only routable within
+// the cluster: connections to them must still go through a ForwardingDialer, which looks pods up by IP. Names outside
+// the cluster are resolved by the default resolver.
+type ClusterResolver struct {
+	clientset kubernetes.Interface
+	// clusterDomain is the domain optionally appended to the DNS names of the cluster
+	clusterDomain string
+	// fallback resolves the names outside the cluster
+	fallback *net.Resolver
+}
+
+// NewClusterResolver returns a resolver of the DNS names of the pods and services of the cluster, looked up with the
+// given clientset. The default resolver captured at this point resolves the other names, so that the returned
+// resolver may then replace net.DefaultResolver.
+func NewClusterResolver(clientset kubernetes.Interface) *ClusterResolver {
+	return &ClusterResolver{
+		clientset:     clientset,
+		clusterDomain: defaultClusterDomain,
+		fallback:      net.DefaultResolver,
+	}
+}
+
+// Resolver returns a net.Resolver answering A and AAAA queries in process with the cluster resolver, for example to set
+// on a net.Dialer or an HTTP transport. The answers are not cached.
+func (r *ClusterResolver) Resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go r.serveConn(ctx, server)
+			return client, nil
+		},
+	}
+}
+
+// serveConn answers the DNS queries sent on the connection until it is closed. Since the connection is not a
+// net.PacketConn, the Go resolver uses the TCP framing, each message being prefixed with its length.
+func (r *ClusterResolver) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	for {
+		length := make([]byte, 2)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(length))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		response, err := r.answer(ctx, query)
+		if err != nil {
+			log.V(1).Info("Failed to answer DNS query", "error", err.Error())
+			return
+		}
+		binary.BigEndian.PutUint16(length, uint16(len(response)))
+		if _, err := conn.Write(append(length, response...)); err != nil {
+			return
+		}
+	}
+}
+
+// answer returns the packed response to the given packed DNS query.
+func (r *ClusterResolver) answer(ctx context.Context, query []byte) ([]byte, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	question, err := parser.Question()
+	if err != nil {
+		return nil, err
+	}
+
+	response := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 header.ID,
+			Response:           true,
+			RecursionDesired:   header.RecursionDesired,
+			RecursionAvailable: true,
+		},
+		Questions: []dnsmessage.Question{question},
+	}
+	if question.Type != dnsmessage.TypeA && question.Type != dnsmessage.TypeAAAA {
+		response.Header.RCode = dnsmessage.RCodeNotImplemented
+		return response.Pack()
+	}
+
+	ips, err := r.lookupIPs(ctx, strings.TrimSuffix(question.Name.String(), "."))
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, errNoSuchHost) || apierrors.IsNotFound(err) || (errors.As(err, &dnsErr) && dnsErr.IsNotFound):
+		response.Header.RCode = dnsmessage.RCodeNameError
+	case err != nil:
+		log.V(1).Info("Failed to resolve DNS name", "name", question.Name.String(), "error", err.Error())
+		response.Header.RCode = dnsmessage.RCodeServerFailure
+	}
+
+	for _, ip := range ips {
+		// the answers are not cached, the pods behind a name may change at any time
+		resource := dnsmessage.ResourceHeader{Name: question.Name, Type: question.Type, Class: dnsmessage.ClassINET}
+		ip4 := ip.To4()
+		switch {
+		case question.Type == dnsmessage.TypeA && ip4 != nil:
+			body := &dnsmessage.AResource{}
+			copy(body.A[:], ip4)
+			response.Answers = append(response.Answers, dnsmessage.Resource{Header: resource, Body: body})
+		case question.Type == dnsmessage.TypeAAAA && ip4 == nil:
+			body := &dnsmessage.AAAAResource{}
+			copy(body.AAAA[:], ip.To16())
+			response.Answers = append(response.Answers, dnsmessage.Resource{Header: resource, Body: body})
+		}
+	}
+	return response.Pack()
+}
+
+// lookupIPs returns the IPs of the given host name, from the Kubernetes API for the names of the cluster and from the
+// fallback resolver for the other ones.
+func (r *ClusterResolver) lookupIPs(ctx context.Context, name string) ([]net.IP, error) {
+	host, ok := r.clusterHost(name)
+	if !ok {
+		addrs, err := r.fallback.LookupIPAddr(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		ips := make([]net.IP, 0, len(addrs))
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+		return ips, nil
+	}
+
+	// the port is only required by the address format
+	target, err := parseAddr(ctx, net.JoinHostPort(host, "0"), r.clientset, "")
+	if err != nil {
+		var formatErr *AddrFormatError
+		if errors.As(err, &formatErr) {
+			return nil, fmt.Errorf("%w: %s", errNoSuchHost, err.Error())
+		}
+		return nil, err
+	}
+
+	var ips []net.IP
+	switch target.Kind {
+	case serviceAddrKind:
+		endpoints, err := r.clientset.CoreV1().Endpoints(target.Namespace).Get(ctx, target.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, subset := range endpoints.Subsets {
+			for _, address := range subset.Addresses {
+				ips = append(ips, net.ParseIP(address.IP))
+			}
+		}
+	default:
+		pod, err := r.clientset.CoreV1().Pods(target.Namespace).Get(ctx, target.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, podIP := range pod.Status.PodIPs {
+			ips = append(ips, net.ParseIP(podIP.IP))
+		}
+		if len(ips) == 0 && pod.Status.PodIP != "" {
+			ips = append(ips, net.ParseIP(pod.Status.PodIP))
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%w: %s has no IP", errNoSuchHost, name)
+	}
+	return ips, nil
+}
+
+// clusterHost returns the given name without the cluster domain if it is the name of a pod or service of the cluster,
+// such as {name}.{namespace}.svc or {name}.{namespace}.pod, optionally followed by the cluster domain.
+func (r *ClusterResolver) clusterHost(name string) (string, bool) {
+	host := strings.TrimSuffix(name, "."+r.clusterDomain)
+	if !strings.HasSuffix(host, "."+serviceDNSSegment) && !strings.HasSuffix(host, "."+syntheticDNSSegment) {
+		return "", false
+	}
+	// names of the cluster have at most 4 segments, longer ones are relative names with a search domain appended
+	if strings.Count(host, ".") > 3 {
+		return "", false
+	}
+	return host, true
+}
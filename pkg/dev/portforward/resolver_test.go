@@ -0,0 +1,125 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClusterResolver(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "es-http"},
+			Subsets: []corev1.EndpointSubset{
+				{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}, {IP: "10.0.0.3"}}},
+			},
+		},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "not-ready"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "es-0"},
+			Status: corev1.PodStatus{
+				PodIP:  "10.0.0.2",
+				PodIPs: []corev1.PodIP{{IP: "10.0.0.2"}, {IP: "fd00::2"}},
+			},
+		},
+	)
+	resolver := NewClusterResolver(clientset).Resolver()
+
+	tests := []struct {
+		name         string
+		host         string
+		want         []string
+		wantNotFound bool
+	}{
+		{
+			name: "service",
+			host: "es-http.ns.svc",
+			want: []string{"10.0.0.2", "10.0.0.3"},
+		},
+		{
+			name: "service FQDN with cluster domain",
+			host: "es-http.ns.svc.cluster.local",
+			want: []string{"10.0.0.2", "10.0.0.3"},
+		},
+		{
+			name: "pod",
+			host: "es-0.ns.pod",
+			want: []string{"10.0.0.2", "fd00::2"},
+		},
+		{
+			name: "pod of a headless service",
+			host: "es-0.es-headless.ns.svc.cluster.local",
+			want: []string{"10.0.0.2", "fd00::2"},
+		},
+		{
+			name:         "service without ready endpoints",
+			host:         "not-ready.ns.svc",
+			wantNotFound: true,
+		},
+		{
+			name:         "missing service",
+			host:         "missing.ns.svc",
+			wantNotFound: true,
+		},
+		{
+			name:         "missing pod",
+			host:         "missing.ns.pod",
+			wantNotFound: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			// rooted names are not looked up in the search domains of the host, which would reach its DNS servers
+			got, err := resolver.LookupHost(ctx, tt.host+".")
+			if tt.wantNotFound {
+				var dnsErr *net.DNSError
+				require.True(t, errors.As(err, &dnsErr), "unexpected error: %v", err)
+				assert.True(t, dnsErr.IsNotFound)
+				return
+			}
+			require.NoError(t, err)
+			sort.Strings(got)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClusterResolver_clusterHost(t *testing.T) {
+	r := NewClusterResolver(nil)
+	tests := []struct {
+		name     string
+		wantHost string
+		wantOK   bool
+	}{
+		{name: "es-http.ns.svc", wantHost: "es-http.ns.svc", wantOK: true},
+		{name: "es-http.ns.svc.cluster.local", wantHost: "es-http.ns.svc", wantOK: true},
+		{name: "10-0-0-2.ns.pod.cluster.local", wantHost: "10-0-0-2.ns.pod", wantOK: true},
+		// a search domain appended to a relative name
+		{name: "es-http.ns.svc.ns.svc.cluster.local", wantOK: false},
+		{name: "www.elastic.co", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, ok := r.clusterHost(tt.name)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantHost, host)
+		})
+	}
+}
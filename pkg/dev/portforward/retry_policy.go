@@ -0,0 +1,45 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import "time"
+
+// retryPolicy decides whether and when to retry failed port forwarding attempts, backing off exponentially between
+// consecutive failures.
+//
+// It is not safe for concurrent use.
+type retryPolicy struct {
+	// maxAttempts is the number of consecutive failed attempts after which Run gives up, 0 means no limit
+	maxAttempts int
+	// initialBackoff is the delay after the first failure, doubled after every following one up to maxBackoff
+	initialBackoff, maxBackoff time.Duration
+
+	// failures is the number of consecutive failed attempts
+	failures int
+}
+
+// recordSuccess resets the backoff once a session was stable.
+func (p *retryPolicy) recordSuccess() {
+	p.failures = 0
+}
+
+// recordFailure records a failed attempt and returns the delay before the next one, or false if no attempt is left.
+func (p *retryPolicy) recordFailure() (time.Duration, bool) {
+	p.failures++
+	if p.maxAttempts > 0 && p.failures >= p.maxAttempts {
+		return 0, false
+	}
+	delay := p.initialBackoff
+	if delay <= 0 {
+		delay = defaultReconnectDelay
+	}
+	for i := 1; i < p.failures && (p.maxBackoff <= 0 || delay < p.maxBackoff); i++ {
+		delay *= 2
+	}
+	if p.maxBackoff > 0 && delay > p.maxBackoff {
+		delay = p.maxBackoff
+	}
+	return delay, true
+}
@@ -0,0 +1,114 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func Test_retryPolicy_recordFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     retryPolicy
+		wantDelays []time.Duration
+	}{
+		{
+			name:       "exponential backoff up to the maximum",
+			policy:     retryPolicy{initialBackoff: time.Second, maxBackoff: 5 * time.Second},
+			wantDelays: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:       "no maximum backoff",
+			policy:     retryPolicy{initialBackoff: time.Second},
+			wantDelays: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		{
+			name:       "default initial backoff",
+			policy:     retryPolicy{maxBackoff: 3 * time.Second},
+			wantDelays: []time.Duration{defaultReconnectDelay, 2 * time.Second, 3 * time.Second},
+		},
+		{
+			name:       "give up after the maximum number of attempts",
+			policy:     retryPolicy{maxAttempts: 3, initialBackoff: time.Second},
+			wantDelays: []time.Duration{time.Second, 2 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var delays []time.Duration
+			for i := 0; i < 10; i++ {
+				delay, ok := tt.policy.recordFailure()
+				if !ok {
+					break
+				}
+				delays = append(delays, delay)
+				if tt.policy.maxAttempts == 0 && len(delays) == len(tt.wantDelays) {
+					break
+				}
+			}
+			require.Equal(t, tt.wantDelays, delays)
+		})
+	}
+}
+
+func Test_retryPolicy_recordSuccess(t *testing.T) {
+	policy := retryPolicy{maxAttempts: 2, initialBackoff: time.Second}
+	delay, ok := policy.recordFailure()
+	require.True(t, ok)
+	require.Equal(t, time.Second, delay)
+
+	// a success resets the backoff and the attempts
+	policy.recordSuccess()
+	delay, ok = policy.recordFailure()
+	require.True(t, ok)
+	require.Equal(t, time.Second, delay)
+	_, ok = policy.recordFailure()
+	require.False(t, ok)
+}
+
+func Test_podForwarder_Run_retryPolicy(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithRetryPolicy(3, time.Second, time.Minute))
+	fwd.clock = fakeClock
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	forwardErr := errors.New("error upgrading connection")
+	attempts := make(chan struct{}, 3)
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		_ chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		attempts <- struct{}{}
+		return &stubPortForwarder{ctx: ctx, err: forwardErr}, nil
+	}
+
+	runErr := make(chan error)
+	go func() {
+		runErr <- fwd.Run(context.Background())
+	}()
+
+	// the failed attempts are retried after an exponential backoff
+	<-attempts
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second} {
+		require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+		require.Len(t, attempts, 0)
+		fakeClock.Step(delay)
+		<-attempts
+	}
+	// until the maximum number of attempts is reached
+	require.ErrorIs(t, <-runErr, forwardErr)
+	require.Equal(t, StateFailed, fwd.State())
+}
@@ -0,0 +1,66 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// RunAll runs the given forwarders concurrently and blocks until all of them are done running.
+//
+// If one of the forwarders returns an error, the others are stopped and that first error is returned. Nil is returned
+// if the forwarders stop because the context is done.
+func RunAll(ctx context.Context, forwarders ...Forwarder) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for _, fwd := range forwarders {
+		wg.Add(1)
+		go func(fwd Forwarder) {
+			defer wg.Done()
+			err := fwd.Run(ctx)
+			if err == nil || errors.Is(err, context.Canceled) {
+				return
+			}
+			errOnce.Do(func() {
+				firstErr = err
+				// stop all other forwarders
+				cancel()
+			})
+		}(fwd)
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// WaitForAllReady waits concurrently for the given forwarders to be ready to redirect connections.
+//
+// It returns nil once all of them are ready, or the first error returned by WaitForReady without waiting for the
+// others.
+func WaitForAllReady(ctx context.Context, forwarders ...*PodForwarder) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(forwarders))
+	for _, fwd := range forwarders {
+		go func(fwd *PodForwarder) {
+			errs <- fwd.WaitForReady(ctx)
+		}(fwd)
+	}
+	for range forwarders {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+	return nil
+}
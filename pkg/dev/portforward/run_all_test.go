@@ -0,0 +1,142 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunAll(t *testing.T) {
+	t.Run("returns nil when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+
+		err := RunAll(ctx, &stubForwarder{}, &stubForwarder{
+			onRun: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("stops all forwarders and returns the first error", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		runErr := errors.New("forwarder failed")
+		err := RunAll(ctx,
+			&stubForwarder{},
+			&stubForwarder{},
+			&stubForwarder{
+				onRun: func(ctx context.Context) error {
+					return runErr
+				},
+			},
+		)
+		require.Equal(t, runErr, err)
+		// other forwarders were stopped before the parent context expired
+		require.NoError(t, ctx.Err())
+	})
+
+	t.Run("no forwarders", func(t *testing.T) {
+		require.NoError(t, RunAll(context.Background()))
+	})
+}
+
+// newReadyAfterPodForwarder returns a pod forwarder becoming ready when ready is closed, or failing with the given error.
+func newReadyAfterPodForwarder(t *testing.T, ready chan struct{}, err error) *PodForwarder {
+	t.Helper()
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.ephemeralPortFinder = func() (string, error) {
+		return "12345", nil
+	}
+	fwd.portForwarderFactory = func(
+		ctx context.Context,
+		_, _ string,
+		_ []string,
+		readyChan chan struct{},
+		_, _ io.Writer,
+	) (PortForwarder, error) {
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			<-ready
+			close(readyChan)
+		}()
+		return &stubPortForwarder{ctx: ctx}, nil
+	}
+	return fwd
+}
+
+func TestWaitForAllReady(t *testing.T) {
+	t.Run("returns nil once all forwarders are ready", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ready := make(chan struct{})
+		fwds := []*PodForwarder{newReadyAfterPodForwarder(t, ready, nil), newReadyAfterPodForwarder(t, ready, nil)}
+		for _, fwd := range fwds {
+			go func(fwd *PodForwarder) {
+				_ = fwd.Run(ctx)
+			}(fwd)
+		}
+
+		waitErr := make(chan error)
+		go func() {
+			waitErr <- WaitForAllReady(ctx, fwds...)
+		}()
+		select {
+		case err := <-waitErr:
+			t.Fatalf("WaitForAllReady returned before the forwarders were ready: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		close(ready)
+		require.NoError(t, <-waitErr)
+	})
+
+	t.Run("returns the first error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		factoryErr := errors.New("no such pod")
+		// the first forwarder never becomes ready
+		fwds := []*PodForwarder{
+			newReadyAfterPodForwarder(t, make(chan struct{}), nil),
+			newReadyAfterPodForwarder(t, nil, factoryErr),
+		}
+		for _, fwd := range fwds {
+			go func(fwd *PodForwarder) {
+				_ = fwd.Run(ctx)
+			}(fwd)
+		}
+
+		err := WaitForAllReady(ctx, fwds...)
+		require.ErrorIs(t, err, factoryErr)
+	})
+
+	t.Run("returns the context error", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := WaitForAllReady(ctx, newReadyAfterPodForwarder(t, make(chan struct{}), nil))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("no forwarders", func(t *testing.T) {
+		require.NoError(t, WaitForAllReady(context.Background()))
+	})
+}
@@ -0,0 +1,63 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Runnable runs forwarders along with a controller-runtime manager, to which it is added with mgr.Add.
+type Runnable struct {
+	// run blocks running the forwarders until the context is done
+	run func(ctx context.Context) error
+	// needLeaderElection is true if the forwarders must only run on the elected leader
+	needLeaderElection bool
+}
+
+var (
+	_ manager.Runnable               = &Runnable{}
+	_ manager.LeaderElectionRunnable = &Runnable{}
+)
+
+// NewRunnable returns a Runnable running the given forwarders with RunAll when the manager starts, until it stops.
+//
+// If needLeaderElection is true, the forwarders only run once the manager is elected leader, otherwise they run as
+// soon as the manager starts.
+func NewRunnable(needLeaderElection bool, forwarders ...Forwarder) *Runnable {
+	return &Runnable{
+		run: func(ctx context.Context) error {
+			return RunAll(ctx, forwarders...)
+		},
+		needLeaderElection: needLeaderElection,
+	}
+}
+
+// NewStoreRunnable returns a Runnable closing the given store when the manager stops, which stops all the forwarders
+// it runs. Getting a forwarder from the store fails with ErrStoreClosed afterwards.
+//
+// If needLeaderElection is true, the store is not closed by managers that never got elected leader, which suits stores
+// only used by runnables that also need leader election.
+func NewStoreRunnable(needLeaderElection bool, store *ForwarderStore) *Runnable {
+	return &Runnable{
+		run: func(ctx context.Context) error {
+			<-ctx.Done()
+			store.Close()
+			return nil
+		},
+		needLeaderElection: needLeaderElection,
+	}
+}
+
+// Start runs the forwarders, blocking until the context is done or one of them fails.
+func (r *Runnable) Start(ctx context.Context) error {
+	return r.run(ctx)
+}
+
+// NeedLeaderElection returns whether the forwarders must only run on the elected leader.
+func (r *Runnable) NeedLeaderElection() bool {
+	return r.needLeaderElection
+}
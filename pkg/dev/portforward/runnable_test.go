@@ -0,0 +1,75 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnable_Start(t *testing.T) {
+	var running int32
+	newForwarder := func() Forwarder {
+		return &stubForwarder{onRun: func(ctx context.Context) error {
+			atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			<-ctx.Done()
+			return ctx.Err()
+		}}
+	}
+	r := NewRunnable(true, newForwarder(), newForwarder())
+	require.True(t, r.NeedLeaderElection())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startErr := make(chan error)
+	go func() {
+		startErr <- r.Start(ctx)
+	}()
+
+	// the forwarders run until the manager stops
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&running) == 2
+	}, 5*time.Second, time.Millisecond)
+	cancel()
+	select {
+	case err := <-startErr:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return once the context was done")
+	}
+	require.Equal(t, int32(0), atomic.LoadInt32(&running))
+}
+
+func TestRunnable_Start_error(t *testing.T) {
+	runErr := errors.New("forbidden")
+	r := NewRunnable(false, &stubForwarder{onRun: func(ctx context.Context) error {
+		return runErr
+	}})
+	require.False(t, r.NeedLeaderElection())
+	require.ErrorIs(t, r.Start(context.Background()), runErr)
+}
+
+func TestNewStoreRunnable(t *testing.T) {
+	store := NewForwarderStore()
+	r := NewStoreRunnable(false, store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startErr := make(chan error)
+	go func() {
+		startErr <- r.Start(ctx)
+	}()
+	_, err := store.GetOrCreateForwarder("tcp", "foo.bar.pod:9200", stubForwarderFactory)
+	require.NoError(t, err)
+
+	cancel()
+	require.NoError(t, <-startErr)
+	_, err = store.GetOrCreateForwarder("tcp", "foo.bar.pod:9200", stubForwarderFactory)
+	require.ErrorIs(t, err, ErrStoreClosed)
+}
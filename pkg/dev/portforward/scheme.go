@@ -0,0 +1,120 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const (
+	// PodScheme is the URL scheme used to dial pods, as in pod://{name}.{namespace}:{port}
+	PodScheme = "pod"
+	// ServiceScheme is the URL scheme used to dial services, as in svc://{name}.{namespace}:{port}
+	ServiceScheme = "svc"
+	// ServiceProxyScheme is the URL scheme used to send HTTP requests to services through the API server service
+	// proxy, as in svcproxy://{name}.{namespace}:{port}
+	ServiceProxyScheme = "svcproxy"
+)
+
+// SchemeForwarderFactory creates a Forwarder to the given port of the named resource.
+type SchemeForwarderFactory func(ctx context.Context, network, namespace, name, port string) (Forwarder, error)
+
+var (
+	schemeFactoriesMutex sync.RWMutex
+	schemeFactories      = map[string]SchemeForwarderFactory{
+		PodScheme:          podSchemeForwarderFactory,
+		ServiceScheme:      serviceSchemeForwarderFactory,
+		ServiceProxyScheme: serviceProxySchemeForwarderFactory,
+	}
+
+	// schemeStore holds the forwarders created by Dial
+	schemeStore = NewForwarderStore()
+)
+
+// RegisterScheme registers the factory used by Dial to create forwarders for URLs with the given scheme, replacing
+// any factory previously registered for that scheme.
+func RegisterScheme(scheme string, factory SchemeForwarderFactory) {
+	schemeFactoriesMutex.Lock()
+	defer schemeFactoriesMutex.Unlock()
+	schemeFactories[scheme] = factory
+}
+
+// schemeFactory returns the factory registered for the given scheme.
+func schemeFactory(scheme string) (SchemeForwarderFactory, bool) {
+	schemeFactoriesMutex.RLock()
+	defer schemeFactoriesMutex.RUnlock()
+	factory, ok := schemeFactories[scheme]
+	return factory, ok
+}
+
+// Dial connects to the resource identified by rawurl, such as pod://{name}.{namespace}:{port}, using the forwarder
+// factory registered for its scheme.
+//
+// Forwarders are cached until they are idle for 5 minutes or are the least recently used ones above 100 forwarders.
+func Dial(ctx context.Context, rawurl string) (net.Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	factory, ok := schemeFactory(u.Scheme)
+	if !ok {
+		return nil, fmt.Errorf("no forwarder registered for scheme %q in %s", u.Scheme, rawurl)
+	}
+	parts := strings.Split(u.Hostname(), ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" || u.Port() == "" {
+		return nil, fmt.Errorf("unsupported address format: %s, expected %s://{name}.{namespace}:{port}", rawurl, u.Scheme)
+	}
+	name, namespace, port := parts[0], parts[1], u.Port()
+
+	fwd, err := schemeStore.GetOrCreateForwarder("tcp", rawurl, func(ctx context.Context, network, _ string) (Forwarder, error) {
+		return factory(ctx, network, namespace, name, port)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fwd.DialContext(ctx)
+}
+
+// podSchemeForwarderFactory creates forwarders for the pod scheme
+func podSchemeForwarderFactory(_ context.Context, network, namespace, name, port string) (Forwarder, error) {
+	remotePort, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, err
+	}
+	clients, err := getDefaultSharedClients()
+	if err != nil {
+		return nil, err
+	}
+	pod := types.NamespacedName{Namespace: namespace, Name: name}
+	return NewPodForwarderForPod(network, pod, remotePort, clients.Clientset(), clients.PodForwarderOptions()...), nil
+}
+
+// serviceSchemeForwarderFactory creates forwarders for the service scheme
+func serviceSchemeForwarderFactory(_ context.Context, network, namespace, name, port string) (Forwarder, error) {
+	c, err := newDefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(fmt.Sprintf("%s.%s.svc", name, namespace), port)
+	return NewServiceForwarder(c, network, addr)
+}
+
+// serviceProxySchemeForwarderFactory creates forwarders for the service proxy scheme
+func serviceProxySchemeForwarderFactory(_ context.Context, _, namespace, name, port string) (Forwarder, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("portforward: loading kube config: %w", err)
+	}
+	return NewServiceProxyForwarder(cfg, types.NamespacedName{Namespace: namespace, Name: name}, port)
+}
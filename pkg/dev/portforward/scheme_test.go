@@ -0,0 +1,58 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDial(t *testing.T) {
+	RegisterScheme("test", func(_ context.Context, network, namespace, name, port string) (Forwarder, error) {
+		return &stubForwarder{
+			onDialContext: func(ctx context.Context) (net.Conn, error) {
+				return nil, fmt.Errorf("would dial: %s %s/%s:%s", network, namespace, name, port)
+			},
+		}, nil
+	})
+
+	tests := []struct {
+		name    string
+		rawurl  string
+		wantErr error
+	}{
+		{
+			name:    "registered scheme",
+			rawurl:  "test://foo.bar:9200",
+			wantErr: errors.New("would dial: tcp bar/foo:9200"),
+		},
+		{
+			name:    "unknown scheme",
+			rawurl:  "unknown://foo.bar:9200",
+			wantErr: errors.New(`no forwarder registered for scheme "unknown" in unknown://foo.bar:9200`),
+		},
+		{
+			name:    "missing namespace",
+			rawurl:  "test://foo:9200",
+			wantErr: errors.New("unsupported address format: test://foo:9200, expected test://{name}.{namespace}:{port}"),
+		},
+		{
+			name:    "missing port",
+			rawurl:  "test://foo.bar",
+			wantErr: errors.New("unsupported address format: test://foo.bar, expected test://{name}.{namespace}:{port}"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Dial(context.Background(), tt.rawurl)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
@@ -8,9 +8,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math/rand"
 	"net"
-	"strings"
+	"sort"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -19,8 +19,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-const syntheticDNSSegment = "pod"
-
 // ServiceForwarder forwards one port of a service
 type ServiceForwarder struct {
 	network, addr string
@@ -31,78 +29,132 @@ type ServiceForwarder struct {
 
 	store *ForwarderStore
 
+	// endpointSelector chooses the pod to forward each connection to
+	endpointSelector EndpointSelector
+	// ejector keeps the pods failing consecutive dials out of the selected endpoints for a while, if not nil
+	ejector *endpointEjector
+
+	// discoveryTimeouts bound the API calls made to find the pods behind the service
+	discoveryTimeouts DiscoveryTimeouts
+
+	// pinnedPod is the name of the pod to forward all connections to, if not empty
+	pinnedPod string
+	// pinnedPodPolicy is the behavior when the pinned pod is not ready
+	pinnedPodPolicy PinnedPodPolicy
+	// pinnedPodTimeout bounds the time spent waiting for the pinned pod to be ready, 0 means no timeout
+	pinnedPodTimeout time.Duration
+
 	// podForwarderFactory enables injecting a custom forwarder factory in tests
 	podForwarderFactory ForwarderFactory
 }
 
+// PinnedPodPolicy is the behavior of a service forwarder when its pinned pod is not a ready endpoint of the service
+type PinnedPodPolicy string
+
+const (
+	// FailIfNotReady makes dialing fail immediately if the pinned pod is not ready
+	FailIfNotReady PinnedPodPolicy = "FailIfNotReady"
+	// WaitForSpecific makes dialing wait for the pinned pod to become ready
+	WaitForSpecific PinnedPodPolicy = "WaitForSpecific"
+)
+
+// ServiceForwarderOption configures optional behavior of a ServiceForwarder
+type ServiceForwarderOption func(f *ServiceForwarder)
+
+// WithDiscoveryTimeouts bounds the API calls made to find the pods behind the service when dialing, independently of
+// the time spent waiting for a pinned pod or for the pod forwarder to be ready.
+func WithDiscoveryTimeouts(timeouts DiscoveryTimeouts) ServiceForwarderOption {
+	return func(f *ServiceForwarder) {
+		f.discoveryTimeouts = timeouts
+	}
+}
+
+// WithEjection keeps a pod out of the endpoints the endpoint selector chooses from for the given duration once dialing
+// it failed threshold consecutive times, for example along with RoundRobin to spread the connections across the
+// healthy pods of a headless service like in-cluster clients do. Ejected pods are selected again if all of them are.
+// Dials to a pinned pod are not affected.
+func WithEjection(threshold int, duration time.Duration) ServiceForwarderOption {
+	return func(f *ServiceForwarder) {
+		f.ejector = newEndpointEjector(threshold, duration)
+	}
+}
+
+// WithPinnedPod forwards all connections to the named pod of the service instead of using the endpoint selector, for
+// example to target a particular Elasticsearch node during a rolling restart. If the pod is not a ready endpoint of the
+// service, dialing fails or waits for it to become one, up to timeout if not 0, depending on the policy.
+func WithPinnedPod(name string, policy PinnedPodPolicy, timeout time.Duration) ServiceForwarderOption {
+	return func(f *ServiceForwarder) {
+		f.pinnedPod = name
+		f.pinnedPodPolicy = policy
+		f.pinnedPodTimeout = timeout
+	}
+}
+
+// WithEndpointSelector sets how the pod to forward each connection to is chosen among the ready endpoints of the
+// service. Defaults to Random.
+func WithEndpointSelector(selector EndpointSelector) ServiceForwarderOption {
+	return func(f *ServiceForwarder) {
+		f.endpointSelector = selector
+	}
+}
+
 var _ Forwarder = &ServiceForwarder{}
 
 // defaultPodForwarderFactory is the default pod forwarder factory used outside of tests
 var defaultPodForwarderFactory = ForwarderFactory(func(ctx context.Context, network, addr string) (Forwarder, error) {
-	clientset, err := newDefaultKubernetesClientset()
+	clients, err := getDefaultSharedClients()
 	if err != nil {
 		return nil, err
 	}
-	return NewPodForwarder(ctx, network, addr, clientset)
+	return clients.PodForwarderFactory(defaultPodForwarderOptions()...)(ctx, network, addr)
 })
 
 // NewServiceForwarder returns a new initialized service forwarder
-func NewServiceForwarder(client client.Client, network, addr string) (*ServiceForwarder, error) {
-	serviceNSN, err := parseServiceAddr(addr)
+func NewServiceForwarder(
+	client client.Client,
+	network, addr string,
+	opts ...ServiceForwarderOption,
+) (*ServiceForwarder, error) {
+	target, err := parseAddr(context.Background(), addr, nil, "")
 	if err != nil {
 		return nil, err
 	}
+	if target.Kind != serviceAddrKind {
+		return nil, newAddrFormatError(serviceAddrKind, addr)
+	}
 
-	return &ServiceForwarder{
+	f := &ServiceForwarder{
 		network: network,
 		addr:    addr,
 
 		client: client,
 
-		serviceNSN: *serviceNSN,
+		serviceNSN: target.NamespacedName(),
 
 		store:               NewForwarderStore(),
+		endpointSelector:    Random,
 		podForwarderFactory: defaultPodForwarderFactory,
-	}, nil
-}
-
-// parseServiceAddr parses the service name and namespace from a connection address
-func parseServiceAddr(addr string) (*types.NamespacedName, error) {
-	// services generally look like this (as FQDN): {name}.{namespace}.svc
-	parts := strings.SplitN(addr, ".", 3)
-
-	if len(parts) <= 2 {
-		return nil, fmt.Errorf("unsupported service address format: %s", addr)
 	}
-
-	return &types.NamespacedName{Namespace: parts[1], Name: parts[0]}, nil
-}
-
-// Run starts the service forwarder, blocking until it's done
-func (f *ServiceForwarder) Run(ctx context.Context) error {
-	// TODO: /could/ consider snipping connections here when pods turn unready, but that does not match the default
-	// Service behavior
-	<-ctx.Done()
-	return nil
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f, nil
 }
 
-// DialContext dials one of the ready pods behind this service forwarder.
-//
-// As an approximation to load balancing, a random ready pod will be chosen for each dialing attempt.
-func (f *ServiceForwarder) DialContext(ctx context.Context) (net.Conn, error) {
-	_, servicePortStr, err := net.SplitHostPort(f.addr)
-	if err != nil {
-		return nil, err
-	}
-	servicePort, err := netutils.ParsePort(servicePortStr, false)
-	if err != nil {
-		return nil, err
-	}
+// discoverEndpoints returns the target port of the service for the given service port, and the endpoints of the
+// service.
+func (f *ServiceForwarder) discoverEndpoints(
+	ctx context.Context,
+	servicePort int,
+) (intstr.IntOrString, corev1.Endpoints, error) {
+	ctx, cancel := f.discoveryTimeouts.withOverallTimeout(ctx)
+	defer cancel()
 
 	service := corev1.Service{}
-
-	if err := f.client.Get(ctx, f.serviceNSN, &service); err != nil {
-		return nil, err
+	if err := f.discoveryTimeouts.call(ctx, "getting service", func(ctx context.Context) error {
+		return f.client.Get(ctx, f.serviceNSN, &service)
+	}); err != nil {
+		return intstr.IntOrString{}, corev1.Endpoints{}, err
 	}
 
 	// TODO: support named ports? how it's supposed to work is not quite clear atm, and we don't use it ourselves
@@ -123,15 +175,20 @@ func (f *ServiceForwarder) DialContext(ctx context.Context) (net.Conn, error) {
 	}
 
 	if targetPort.IntValue() == 0 {
-		return nil, fmt.Errorf("service is not listening on port: %d", servicePort)
+		return intstr.IntOrString{}, corev1.Endpoints{}, fmt.Errorf("service is not listening on port: %d", servicePort)
 	}
 
 	endpoints := corev1.Endpoints{}
-
-	if err := f.client.Get(ctx, f.serviceNSN, &endpoints); err != nil {
-		return nil, err
+	if err := f.discoveryTimeouts.call(ctx, "getting endpoints", func(ctx context.Context) error {
+		return f.client.Get(ctx, f.serviceNSN, &endpoints)
+	}); err != nil {
+		return intstr.IntOrString{}, corev1.Endpoints{}, err
 	}
+	return targetPort, endpoints, nil
+}
 
+// podTargetsForPort returns the ready pods of the endpoints that serve the given port, sorted by namespace and name.
+func podTargetsForPort(endpoints corev1.Endpoints, targetPort intstr.IntOrString) []*corev1.ObjectReference {
 	var podTargets []*corev1.ObjectReference
 	for _, subset := range endpoints.Subsets {
 		foundPort := false
@@ -146,24 +203,165 @@ func (f *ServiceForwarder) DialContext(ctx context.Context) (net.Conn, error) {
 		}
 
 		for _, address := range subset.Addresses {
-			if address.TargetRef.Kind == "Pod" {
+			if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
 				podTargets = append(podTargets, address.TargetRef)
 			}
 		}
 	}
 
-	if len(podTargets) == 0 {
-		return nil, errors.New("no pod addresses found in service endpoints")
+	sort.Slice(podTargets, func(i, j int) bool {
+		if podTargets[i].Namespace != podTargets[j].Namespace {
+			return podTargets[i].Namespace < podTargets[j].Namespace
+		}
+		return podTargets[i].Name < podTargets[j].Name
+	})
+	return podTargets
+}
+
+// findPodTarget returns the pod target with the given name, or nil if there is none.
+func findPodTarget(podTargets []*corev1.ObjectReference, name string) *corev1.ObjectReference {
+	for _, target := range podTargets {
+		if target.Name == name {
+			return target
+		}
+	}
+	return nil
+}
+
+// pinnedPodTarget returns the pinned pod if it is a ready endpoint of the service, waiting for it to become one if the
+// pinned pod policy says so.
+func (f *ServiceForwarder) pinnedPodTarget(
+	ctx context.Context,
+	endpoints corev1.Endpoints,
+	targetPort intstr.IntOrString,
+) (*corev1.ObjectReference, error) {
+	if pod := findPodTarget(podTargetsForPort(endpoints, targetPort), f.pinnedPod); pod != nil {
+		return pod, nil
+	}
+	if f.pinnedPodPolicy != WaitForSpecific {
+		return nil, fmt.Errorf("pod %s is not a ready endpoint of service %s", f.pinnedPod, f.serviceNSN)
+	}
+
+	watcher, ok := f.client.(client.WithWatch)
+	if !ok {
+		return nil, fmt.Errorf("cannot wait for pod %s of service %s: client does not support watches", f.pinnedPod, f.serviceNSN)
 	}
+	if f.pinnedPodTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.pinnedPodTimeout)
+		defer cancel()
+	}
+
+	w, err := watcher.Watch(ctx, &corev1.EndpointsList{}, client.InNamespace(f.serviceNSN.Namespace))
+	if err != nil {
+		return nil, err
+	}
+	defer w.Stop()
+
+	// the endpoints may have changed before the watch started
+	if err := f.client.Get(ctx, f.serviceNSN, &endpoints); err != nil {
+		return nil, err
+	}
+	if pod := findPodTarget(podTargetsForPort(endpoints, targetPort), f.pinnedPod); pod != nil {
+		return pod, nil
+	}
+
+	log.V(1).Info("Waiting for pinned pod to be ready", "service", f.serviceNSN, "pod", f.pinnedPod)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("while waiting for pod %s of service %s to be ready: %w", f.pinnedPod, f.serviceNSN, ctx.Err())
+		case evt, ok := <-w.ResultChan():
+			if !ok {
+				return nil, fmt.Errorf("watch closed while waiting for pod %s of service %s to be ready", f.pinnedPod, f.serviceNSN)
+			}
+			updated, isEndpoints := evt.Object.(*corev1.Endpoints)
+			if !isEndpoints || updated.Name != f.serviceNSN.Name {
+				continue
+			}
+			if pod := findPodTarget(podTargetsForPort(*updated, targetPort), f.pinnedPod); pod != nil {
+				return pod, nil
+			}
+		}
+	}
+}
+
+// Run starts the service forwarder, blocking until it's done
+func (f *ServiceForwarder) Run(ctx context.Context) error {
+	// TODO: /could/ consider snipping connections here when pods turn unready, but that does not match the default
+	// Service behavior
+	<-ctx.Done()
+	return nil
+}
 
-	pod := podTargets[rand.Intn(len(podTargets))] //nolint:gosec
+// Draining always returns false, a service forwarder accepts new connections until it stops running.
+func (f *ServiceForwarder) Draining() bool {
+	return false
+}
+
+// DialContext dials one of the ready pods behind this service forwarder.
+//
+// The ready pod to dial is chosen by the endpoint selector of the forwarder for each dialing attempt. A nil context is
+// treated as context.Background().
+func (f *ServiceForwarder) DialContext(ctx context.Context) (net.Conn, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	podAddr, err := f.selectPodAddr(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	// this should match a supported format of parsePodAddr(addr string)
-	podAddr := fmt.Sprintf("%s.%s.%s:%s", pod.Name, pod.Namespace, syntheticDNSSegment, targetPort.String())
 	forwarder, err := f.store.GetOrCreateForwarder(f.network, podAddr, f.podForwarderFactory)
 	if err != nil {
 		return nil, err
 	}
 
-	return forwarder.DialContext(ctx)
+	conn, err := forwarder.DialContext(ctx)
+	// giving up on a dial is not a failure of the pod
+	if f.ejector != nil && ctx.Err() == nil {
+		f.ejector.record(podAddr, err)
+	}
+	return conn, err
+}
+
+// selectPodAddr returns the address of the pod to forward a connection to, in a supported pod format of parseAddr.
+func (f *ServiceForwarder) selectPodAddr(ctx context.Context) (string, error) {
+	_, servicePortStr, err := net.SplitHostPort(f.addr)
+	if err != nil {
+		return "", err
+	}
+	servicePort, err := netutils.ParsePort(servicePortStr, false)
+	if err != nil {
+		return "", err
+	}
+
+	targetPort, endpoints, err := f.discoverEndpoints(ctx, servicePort)
+	if err != nil {
+		return "", err
+	}
+
+	var pod *corev1.ObjectReference
+	if f.pinnedPod != "" {
+		pod, err = f.pinnedPodTarget(ctx, endpoints, targetPort)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		podTargets := podTargetsForPort(endpoints, targetPort)
+		if len(podTargets) == 0 {
+			return "", errors.New("no pod addresses found in service endpoints")
+		}
+		if f.ejector != nil {
+			podTargets = f.ejector.filter(podTargets, targetPort)
+		}
+		pod = f.endpointSelector.Select(podTargets)
+	}
+
+	return podTargetAddr(pod, targetPort), nil
+}
+
+// podTargetAddr returns the address of the given target port of a pod, in a supported pod format of parseAddr.
+func podTargetAddr(pod *corev1.ObjectReference, targetPort intstr.IntOrString) string {
+	return fmt.Sprintf("%s.%s.%s:%s", pod.Name, pod.Namespace, syntheticDNSSegment, targetPort.String())
 }
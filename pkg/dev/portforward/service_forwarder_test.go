@@ -10,8 +10,10 @@ import (
 	"fmt"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -21,42 +23,6 @@ import (
 	"github.com/elastic/cloud-on-k8s/pkg/utils/k8s"
 )
 
-func Test_parseServiceAddr(t *testing.T) {
-	type args struct {
-		addr string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    types.NamespacedName
-		wantErr error
-	}{
-		{
-			name: "service with namespace",
-			args: args{addr: "foo.bar.svc"},
-			want: types.NamespacedName{Namespace: "bar", Name: "foo"},
-		},
-		{
-			name:    "non-fqdn service name",
-			args:    args{addr: "foo"},
-			wantErr: errors.New("unsupported service address format: foo"),
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseServiceAddr(tt.args.addr)
-
-			if tt.wantErr != nil {
-				assert.Equal(t, tt.wantErr, err)
-				return
-			}
-			assert.NoError(t, err)
-
-			assert.Equal(t, tt.want, *got)
-		})
-	}
-}
-
 func Test_serviceForwarder_DialContext(t *testing.T) {
 	type fields struct {
 		client  client.Client
@@ -69,6 +35,7 @@ func Test_serviceForwarder_DialContext(t *testing.T) {
 	type test struct {
 		name    string
 		fields  fields
+		opts    []ServiceForwarderOption
 		tweaks  func(f *ServiceForwarder)
 		args    args
 		want    net.Conn
@@ -129,6 +96,50 @@ func Test_serviceForwarder_DialContext(t *testing.T) {
 			},
 			wantErr: errors.New("would dial: some-pod-name.bar.pod:9200"),
 		},
+		{
+			name: "should forward to the endpoint chosen by the endpoint selector",
+			fields: fields{
+				network: "tcp",
+				addr:    "foo.bar.svc:9200",
+				client: k8s.NewFakeClient(
+					&corev1.Service{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "foo",
+							Namespace: "bar",
+						},
+						Spec: corev1.ServiceSpec{
+							Ports: []corev1.ServicePort{{Port: 9200}},
+						},
+					},
+					&corev1.Endpoints{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "foo",
+							Namespace: "bar",
+						},
+						Subsets: []corev1.EndpointSubset{
+							{
+								Ports: []corev1.EndpointPort{{Port: 9200}},
+								Addresses: []corev1.EndpointAddress{
+									{TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "pod-b", Namespace: "bar"}},
+									{TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "pod-a", Namespace: "bar"}},
+								},
+							},
+						},
+					},
+				),
+			},
+			opts: []ServiceForwarderOption{WithEndpointSelector(FirstReady)},
+			tweaks: func(f *ServiceForwarder) {
+				f.podForwarderFactory = func(_ context.Context, network, addr string) (Forwarder, error) {
+					return &stubForwarder{
+						onDialContext: func(ctx context.Context) (net.Conn, error) {
+							return nil, fmt.Errorf("would dial: %s", addr)
+						},
+					}, nil
+				}
+			},
+			wantErr: errors.New("would dial: pod-a.bar.pod:9200"),
+		},
 		{
 			name: "should fail if the service is not listening on the specified port",
 			fields: fields{
@@ -148,7 +159,7 @@ func Test_serviceForwarder_DialContext(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			f, err := NewServiceForwarder(tt.fields.client, tt.fields.network, tt.fields.addr)
+			f, err := NewServiceForwarder(tt.fields.client, tt.fields.network, tt.fields.addr, tt.opts...)
 			assert.NoError(t, err)
 
 			if tt.tweaks != nil {
@@ -166,3 +177,82 @@ func Test_serviceForwarder_DialContext(t *testing.T) {
 		})
 	}
 }
+
+func Test_serviceForwarder_DialContext_pinnedPod(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 9200}}},
+	}
+	endpointsWith := func(pods ...string) *corev1.Endpoints {
+		addresses := make([]corev1.EndpointAddress, 0, len(pods))
+		for _, pod := range pods {
+			addresses = append(addresses, corev1.EndpointAddress{
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: pod, Namespace: "bar"},
+			})
+		}
+		return &corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"},
+			Subsets: []corev1.EndpointSubset{
+				{Ports: []corev1.EndpointPort{{Port: 9200}}, Addresses: addresses},
+			},
+		}
+	}
+	newForwarder := func(t *testing.T, c client.Client, opts ...ServiceForwarderOption) *ServiceForwarder {
+		t.Helper()
+		f, err := NewServiceForwarder(c, "tcp", "foo.bar.svc:9200", opts...)
+		require.NoError(t, err)
+		f.podForwarderFactory = func(_ context.Context, _, addr string) (Forwarder, error) {
+			return &stubForwarder{
+				onDialContext: func(ctx context.Context) (net.Conn, error) {
+					return nil, fmt.Errorf("would dial: %s", addr)
+				},
+			}, nil
+		}
+		return f
+	}
+
+	t.Run("pinned pod is ready", func(t *testing.T) {
+		c := k8s.NewFakeClient(service, endpointsWith("pod-0", "pod-1"))
+		f := newForwarder(t, c, WithPinnedPod("pod-1", FailIfNotReady, 0))
+		_, err := f.DialContext(context.Background())
+		require.EqualError(t, err, "would dial: pod-1.bar.pod:9200")
+	})
+
+	t.Run("fail if the pinned pod is not ready", func(t *testing.T) {
+		c := k8s.NewFakeClient(service, endpointsWith("pod-0"))
+		f := newForwarder(t, c, WithPinnedPod("pod-1", FailIfNotReady, 0))
+		_, err := f.DialContext(context.Background())
+		require.EqualError(t, err, "pod pod-1 is not a ready endpoint of service bar/foo")
+	})
+
+	t.Run("wait for the pinned pod to be ready", func(t *testing.T) {
+		c := k8s.NewFakeClient(service, endpointsWith("pod-0"))
+		f := newForwarder(t, c, WithPinnedPod("pod-1", WaitForSpecific, 5*time.Second))
+
+		dialErr := make(chan error)
+		go func() {
+			_, err := f.DialContext(context.Background())
+			dialErr <- err
+		}()
+		// keep updating the endpoints until the dial returns, as it may not be watching yet
+		for {
+			select {
+			case err := <-dialErr:
+				require.EqualError(t, err, "would dial: pod-1.bar.pod:9200")
+				return
+			case <-time.After(10 * time.Millisecond):
+				endpoints := corev1.Endpoints{}
+				require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "bar", Name: "foo"}, &endpoints))
+				endpoints.Subsets = endpointsWith("pod-0", "pod-1").Subsets
+				require.NoError(t, c.Update(context.Background(), &endpoints))
+			}
+		}
+	})
+
+	t.Run("give up waiting for the pinned pod after the timeout", func(t *testing.T) {
+		c := k8s.NewFakeClient(service, endpointsWith("pod-0"))
+		f := newForwarder(t, c, WithPinnedPod("pod-1", WaitForSpecific, 10*time.Millisecond))
+		_, err := f.DialContext(context.Background())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ServiceProxyForwarder forwards HTTP traffic to one port of a service through the services/proxy subresource of the
+// API server, for environments where port forwarding to pods is denied but proxying to services is permitted.
+//
+// Connections returned by DialContext only support HTTP/1.x requests, which are sent to the service one at a time.
+type ServiceProxyForwarder struct {
+	serviceNSN types.NamespacedName
+	port       string
+
+	// proxyURL is the URL of the services/proxy subresource for the service port
+	proxyURL *url.URL
+	// transport sends the proxied requests to the API server
+	transport http.RoundTripper
+}
+
+var _ Forwarder = &ServiceProxyForwarder{}
+
+// NewServiceProxyForwarder returns a new initialized forwarder to the given port of a service, using the given API
+// server configuration.
+func NewServiceProxyForwarder(cfg *rest.Config, service types.NamespacedName, port string) (*ServiceProxyForwarder, error) {
+	clientSet, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("portforward: building kube client: %w", err)
+	}
+	transport, err := rest.TransportFor(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("portforward: building transport: %w", err)
+	}
+
+	proxyURL := clientSet.CoreV1().RESTClient().Get().
+		Namespace(service.Namespace).
+		Resource("services").
+		Name(service.Name + ":" + port).
+		SubResource("proxy").
+		URL()
+
+	return &ServiceProxyForwarder{
+		serviceNSN: service,
+		port:       port,
+		proxyURL:   proxyURL,
+		transport:  transport,
+	}, nil
+}
+
+// Run blocks until the context is done, as there is no long-lived connection to maintain.
+func (f *ServiceProxyForwarder) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+// Draining always returns false, there is no long-lived connection to drain.
+func (f *ServiceProxyForwarder) Draining() bool {
+	return false
+}
+
+// DialContext returns an in-memory connection whose HTTP requests are sent to the service through the API server. A
+// nil context is treated as context.Background().
+func (f *ServiceProxyForwarder) DialContext(ctx context.Context) (net.Conn, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	client, server := net.Pipe()
+	go f.serve(server)
+	return client, nil
+}
+
+// serve proxies the HTTP requests read from conn until it is closed or a request asks for the connection to be closed.
+func (f *ServiceProxyForwarder) serve(conn net.Conn) {
+	defer conn.Close()
+
+	// stop in-flight requests as soon as we stop serving
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		resp, err := f.roundTrip(ctx, req)
+		if err != nil {
+			log.V(1).Info("Failed to proxy request", "service", f.serviceNSN, "port", f.port, "error", err.Error())
+			resp = &http.Response{
+				StatusCode: http.StatusBadGateway,
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Close:      true,
+			}
+		}
+		// the API server may have answered with HTTP/2, while the client speaks HTTP/1.x
+		resp.Proto, resp.ProtoMajor, resp.ProtoMinor = "HTTP/1.1", 1, 1
+		err = resp.Write(conn)
+		if resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+		if err != nil || req.Close || resp.Close {
+			return
+		}
+	}
+}
+
+// roundTrip sends req to the service through the API server proxy.
+func (f *ServiceProxyForwarder) roundTrip(ctx context.Context, req *http.Request) (*http.Response, error) {
+	u := *f.proxyURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(req.URL.Path, "/")
+	u.RawQuery = req.URL.RawQuery
+
+	proxied, err := http.NewRequestWithContext(ctx, req.Method, u.String(), req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("while proxying request to service %s: %w", f.serviceNSN, err)
+	}
+	proxied.Header = req.Header.Clone()
+	proxied.ContentLength = req.ContentLength
+	return f.transport.RoundTrip(proxied)
+}
@@ -0,0 +1,74 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+func TestServiceProxyForwarder_DialContext(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/ns/services/es:9200/proxy/_cluster/health", r.URL.Path)
+		assert.Equal(t, "pretty", r.URL.RawQuery)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		_, _ = w.Write([]byte("health: " + string(body)))
+	}))
+	defer apiServer.Close()
+
+	fwd, err := NewServiceProxyForwarder(
+		&rest.Config{Host: apiServer.URL}, types.NamespacedName{Namespace: "ns", Name: "es"}, "9200",
+	)
+	require.NoError(t, err)
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return fwd.DialContext(ctx)
+		},
+	}}
+	defer client.CloseIdleConnections()
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Post("http://es.ns.svc:9200/_cluster/health?pretty", "application/json", strings.NewReader("green"))
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "health: green", string(body))
+	}
+}
+
+func TestServiceProxyForwarder_DialContext_apiServerUnavailable(t *testing.T) {
+	apiServer := httptest.NewServer(http.NotFoundHandler())
+	apiServer.Close()
+
+	fwd, err := NewServiceProxyForwarder(
+		&rest.Config{Host: apiServer.URL}, types.NamespacedName{Namespace: "ns", Name: "es"}, "9200",
+	)
+	require.NoError(t, err)
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return fwd.DialContext(ctx)
+		},
+	}}
+	resp, err := client.Get("http://es.ns.svc:9200/")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+}
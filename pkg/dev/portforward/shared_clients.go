@@ -0,0 +1,89 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport/spdy"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// SharedClients are the clientset and SPDY transport built once from a configuration and shared by the pod forwarders
+// using them, so that creating many forwarders neither reloads the configuration nor opens new connections to the API
+// server for each of them.
+type SharedClients struct {
+	clientset kubernetes.Interface
+	transport http.RoundTripper
+	upgrader  spdy.Upgrader
+}
+
+// NewSharedClients builds the clients shared by pod forwarders from the given configuration. The given clientset is
+// used if not nil, otherwise one is built from the configuration.
+func NewSharedClients(cfg *rest.Config, clientset kubernetes.Interface) (*SharedClients, error) {
+	if clientset == nil {
+		var err error
+		clientset, err = kubernetes.NewForConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("portforward: building kube client: %w", err)
+		}
+	}
+	transport, upgrader, err := spdy.RoundTripperFor(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("portforward: building SPDY transport: %w", err)
+	}
+	return &SharedClients{clientset: clientset, transport: transport, upgrader: upgrader}, nil
+}
+
+// Clientset returns the shared clientset.
+func (c *SharedClients) Clientset() kubernetes.Interface {
+	return c.clientset
+}
+
+// PodForwarderOptions returns the options making a pod forwarder use the shared clients.
+func (c *SharedClients) PodForwarderOptions() []PodForwarderOption {
+	return []PodForwarderOption{
+		WithSharedClientset(c.clientset),
+		WithSharedTransport(c.transport, c.upgrader),
+	}
+}
+
+// PodForwarderFactory returns a factory of pod forwarders using the shared clients, with the given options applied
+// afterwards.
+func (c *SharedClients) PodForwarderFactory(opts ...PodForwarderOption) ForwarderFactory {
+	return func(ctx context.Context, network, addr string) (Forwarder, error) {
+		return NewPodForwarder(ctx, network, addr, c.clientset, append(c.PodForwarderOptions(), opts...)...)
+	}
+}
+
+var (
+	defaultSharedClientsMu sync.Mutex
+	defaultSharedClients   *SharedClients
+)
+
+// getDefaultSharedClients returns the clients built once from the ambient configuration, which are used by the
+// default factories. Failures are not cached, so that a later call succeeds once the configuration is fixed.
+func getDefaultSharedClients() (*SharedClients, error) {
+	defaultSharedClientsMu.Lock()
+	defer defaultSharedClientsMu.Unlock()
+	if defaultSharedClients != nil {
+		return defaultSharedClients, nil
+	}
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("portforward: loading kube config: %w", err)
+	}
+	clients, err := NewSharedClients(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	defaultSharedClients = clients
+	return clients, nil
+}
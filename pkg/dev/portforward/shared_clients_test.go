@@ -0,0 +1,52 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestSharedClients_PodForwarderFactory(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clients, err := NewSharedClients(&rest.Config{Host: "https://10.0.0.1:6443"}, clientset)
+	require.NoError(t, err)
+	require.Same(t, clientset, clients.Clientset())
+
+	factory := clients.PodForwarderFactory(WithDefaultNamespace("ns"))
+	first, err := factory(context.Background(), "tcp", "es-0.ns.pod:9200")
+	require.NoError(t, err)
+	second, err := factory(context.Background(), "tcp", "es-1.ns.pod:9200")
+	require.NoError(t, err)
+
+	// the forwarders share the clients, with the given options applied
+	for _, fwd := range []Forwarder{first, second} {
+		f, ok := fwd.(*PodForwarder)
+		require.True(t, ok)
+		assert.Same(t, clientset, f.kubectl.clientSet)
+		assert.Equal(t, clients.transport, f.kubectl.transport)
+		assert.Equal(t, clients.upgrader, f.kubectl.upgrader)
+		assert.Equal(t, "ns", f.defaultNamespace)
+	}
+}
+
+func Test_getDefaultSharedClients(t *testing.T) {
+	setTestKubeconfig(t)
+	defaultSharedClientsMu.Lock()
+	defaultSharedClients = nil
+	defaultSharedClientsMu.Unlock()
+
+	first, err := getDefaultSharedClients()
+	require.NoError(t, err)
+	second, err := getDefaultSharedClients()
+	require.NoError(t, err)
+	// the clients are only built once
+	require.Same(t, first, second)
+}
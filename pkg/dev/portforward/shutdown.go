@@ -0,0 +1,55 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// detachedContext is a context carrying the values of its parent but never done, so that the port forwarding session
+// of a forwarder shutting down gracefully outlives the context of Run.
+type detachedContext struct {
+	parent context.Context
+}
+
+var _ context.Context = detachedContext{}
+
+func (c detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// drainOnShutdown waits for ctx to be done, then drains the forwarder and cancels runCtx once the active connections
+// are closed or the shutdown timeout elapses. It returns right away if runCtx is done first.
+func (f *PodForwarder) drainOnShutdown(ctx, runCtx context.Context, runCtxCancel context.CancelFunc) {
+	select {
+	case <-ctx.Done():
+	case <-runCtx.Done():
+		return
+	}
+	defer runCtxCancel()
+
+	logger := f.logger(runCtx)
+	logger.Info("Forwarder is shutting down, draining",
+		"addr", f.addr, "active_connections", atomic.LoadInt64(&f.activeConns), "timeout", f.shutdownTimeout)
+	f.setDraining(ErrShuttingDown)
+	if !f.waitForDrain(runCtx, f.clock.After(f.shutdownTimeout)) {
+		logger.Info("Shutdown timeout reached, closing the remaining connections",
+			"addr", f.addr, "active_connections", atomic.LoadInt64(&f.activeConns))
+	}
+}
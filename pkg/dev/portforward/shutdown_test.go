@@ -0,0 +1,93 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func Test_podForwarder_Run_gracefulShutdown(t *testing.T) {
+	tests := []struct {
+		name string
+		// stop either closes the active connection or steps the clock past the shutdown timeout
+		stop func(t *testing.T, conn net.Conn, fakeClock *testingclock.FakeClock)
+	}{
+		{
+			name: "stops once the active connection is closed",
+			stop: func(t *testing.T, conn net.Conn, _ *testingclock.FakeClock) {
+				require.NoError(t, conn.Close())
+			},
+		},
+		{
+			name: "stops once the shutdown timeout elapses",
+			stop: func(_ *testing.T, _ net.Conn, fakeClock *testingclock.FakeClock) {
+				fakeClock.Step(time.Minute)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClock := testingclock.NewFakeClock(time.Now())
+			fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithGracefulShutdown(time.Minute))
+			fwd.clock = fakeClock
+			fwd.ephemeralPortFinder = func() (string, error) {
+				return "12345", nil
+			}
+			sessionDone := make(chan struct{})
+			fwd.portForwarderFactory = func(
+				ctx context.Context,
+				_, _ string,
+				_ []string,
+				readyChan chan struct{},
+				_, _ io.Writer,
+			) (PortForwarder, error) {
+				close(readyChan)
+				go func() {
+					<-ctx.Done()
+					close(sessionDone)
+				}()
+				return &stubPortForwarder{ctx: ctx}, nil
+			}
+			fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+				local, _ := net.Pipe()
+				return local, nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			runErr := make(chan error)
+			go func() {
+				runErr <- fwd.Run(ctx)
+			}()
+			require.NoError(t, fwd.WaitForReady(ctx))
+			conn, err := fwd.DialContext(ctx)
+			require.NoError(t, err)
+
+			cancel()
+			require.Eventually(t, fwd.Draining, 5*time.Second, time.Millisecond)
+
+			// new connections are refused while the session is kept running for the active one
+			_, err = fwd.DialContext(context.Background())
+			require.ErrorIs(t, err, ErrShuttingDown)
+			select {
+			case <-sessionDone:
+				t.Fatal("Session torn down before the active connection was drained")
+			case <-time.After(10 * time.Millisecond):
+			}
+
+			require.Eventually(t, fakeClock.HasWaiters, 5*time.Second, time.Millisecond)
+			tt.stop(t, conn, fakeClock)
+			require.NoError(t, <-runErr)
+			<-sessionDone
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// ContextDialer dials addresses with a context, such as a ForwardingDialer.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// SOCKS5 protocol constants, see RFC 1928
+const (
+	socks5Version        = 0x05
+	socks5NoAuth         = 0x00
+	socks5NoAcceptable   = 0xff
+	socks5CmdConnect     = 0x01
+	socks5AddrIPv4       = 0x01
+	socks5AddrDomainName = 0x03
+	socks5AddrIPv6       = 0x04
+
+	socks5Succeeded               = 0x00
+	socks5GeneralFailure          = 0x01
+	socks5CommandNotSupported     = 0x07
+	socks5AddrTypeNotSupported    = 0x08
+	socks5ReplyHeaderLen          = 4
+	socks5UnspecifiedBoundAddrLen = net.IPv4len + 2
+)
+
+// SOCKS5Proxy is a SOCKS5 proxy connecting its clients through a dialer, so that any client supporting SOCKS5 can
+// reach the pods and services of the cluster by their DNS names through forwarders, without using a dialer itself.
+//
+// Only the CONNECT command without authentication is supported, which is enough for TCP clients such as curl or
+// browsers. The proxy is meant to listen on a loopback address.
+type SOCKS5Proxy struct {
+	dialer ContextDialer
+}
+
+// NewSOCKS5Proxy returns a SOCKS5 proxy connecting its clients through the given dialer, such as a ForwardingDialer.
+func NewSOCKS5Proxy(dialer ContextDialer) *SOCKS5Proxy {
+	return &SOCKS5Proxy{dialer: dialer}
+}
+
+// ListenAndServe listens on the given TCP address and serves SOCKS5 clients until the context is done.
+func (p *SOCKS5Proxy) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return p.Serve(ctx, l)
+}
+
+// Serve serves the SOCKS5 clients connecting to the listener until the context is done, closing the listener and the
+// connections of the clients before returning nil. It returns the error of the listener if it fails first.
+func (p *SOCKS5Proxy) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	go func() {
+		<-ctx.Done()
+		_ = l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// serveConn serves a single SOCKS5 client, relaying its connection once connected to the requested address.
+func (p *SOCKS5Proxy) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	// close the connections when the proxy stops, to interrupt the handshake or the relay
+	defer closeWhenDone(ctx, conn)()
+
+	addr, err := socks5Handshake(conn)
+	if err != nil {
+		log.V(1).Info("SOCKS5 handshake failed", "client", conn.RemoteAddr().String(), "error", err.Error())
+		return
+	}
+
+	upstream, err := p.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		log.Info("Failed to dial for SOCKS5 client", "addr", addr, "error", err.Error())
+		_ = socks5Reply(conn, socks5GeneralFailure)
+		return
+	}
+	defer upstream.Close()
+	defer closeWhenDone(ctx, upstream)()
+
+	if err := socks5Reply(conn, socks5Succeeded); err != nil {
+		return
+	}
+	relay(conn, upstream)
+}
+
+// socks5Handshake negotiates the authentication method with a client and reads its CONNECT request. It returns the
+// requested address, or an error once the failure was replied to the client if possible.
+func socks5Handshake(conn net.Conn) (string, error) {
+	// version, number of methods and methods
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[0] != socks5Version {
+		return "", fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	method := byte(socks5NoAcceptable)
+	for _, m := range methods {
+		if m == socks5NoAuth {
+			method = socks5NoAuth
+			break
+		}
+	}
+	if _, err := conn.Write([]byte{socks5Version, method}); err != nil {
+		return "", err
+	}
+	if method == socks5NoAcceptable {
+		return "", errors.New("no acceptable SOCKS5 authentication method")
+	}
+
+	// version, command, reserved and address type
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", err
+	}
+	if request[1] != socks5CmdConnect {
+		_ = socks5Reply(conn, socks5CommandNotSupported)
+		return "", fmt.Errorf("unsupported SOCKS5 command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socks5AddrIPv4, socks5AddrIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if request[3] == socks5AddrIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case socks5AddrDomainName:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		_ = socks5Reply(conn, socks5AddrTypeNotSupported)
+		return "", fmt.Errorf("unsupported SOCKS5 address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socks5Reply replies to a CONNECT request with the given status. The bound address is left unspecified since the
+// forwarded connections do not have a meaningful one.
+func socks5Reply(conn net.Conn, status byte) error {
+	reply := make([]byte, socks5ReplyHeaderLen+socks5UnspecifiedBoundAddrLen)
+	reply[0], reply[1], reply[3] = socks5Version, status, socks5AddrIPv4
+	_, err := conn.Write(reply)
+	return err
+}
+
+// relay copies data between the two connections until both directions are done, propagating half-closes when the
+// connections support them.
+func relay(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	copyAndCloseWrite := func(dst, src net.Conn) {
+		defer wg.Done()
+		_, _ = io.Copy(dst, src)
+		if cw, ok := dst.(closeWriter); ok && cw.CloseWrite() == nil {
+			return
+		}
+		_ = dst.Close()
+	}
+	go copyAndCloseWrite(a, b)
+	go copyAndCloseWrite(b, a)
+	wg.Wait()
+}
+
+// closeWhenDone closes the connection when the context is done, until the returned function is called.
+func closeWhenDone(ctx context.Context, conn net.Conn) (stop func()) {
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-stopped:
+		}
+	}()
+	return func() {
+		close(stopped)
+	}
+}
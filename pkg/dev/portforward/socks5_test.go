@@ -0,0 +1,146 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubContextDialer records the dialed addresses and serves the connections with an EchoHandler.
+type stubContextDialer struct {
+	mu        sync.Mutex
+	addresses []string
+	err       error
+}
+
+func (d *stubContextDialer) DialContext(_ context.Context, _, addr string) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.addresses = append(d.addresses, addr)
+	if d.err != nil {
+		return nil, d.err
+	}
+	local, remote := net.Pipe()
+	go EchoHandler(remote)
+	return local, nil
+}
+
+// socks5Connect sends a CONNECT request with the given command and address to the proxy, and returns the status of
+// the reply.
+func socks5Connect(t *testing.T, conn net.Conn, cmd byte, host string, port uint16) byte {
+	t.Helper()
+	_, err := conn.Write([]byte{socks5Version, 1, socks5NoAuth})
+	require.NoError(t, err)
+	method := make([]byte, 2)
+	_, err = io.ReadFull(conn, method)
+	require.NoError(t, err)
+	require.Equal(t, []byte{socks5Version, socks5NoAuth}, method)
+
+	request := []byte{socks5Version, cmd, 0, socks5AddrDomainName, byte(len(host))}
+	request = append(request, host...)
+	request = append(request, byte(port>>8), byte(port))
+	_, err = conn.Write(request)
+	require.NoError(t, err)
+
+	reply := make([]byte, socks5ReplyHeaderLen+socks5UnspecifiedBoundAddrLen)
+	_, err = io.ReadFull(conn, reply)
+	require.NoError(t, err)
+	return reply[1]
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	tests := []struct {
+		name       string
+		cmd        byte
+		dialErr    error
+		wantStatus byte
+		wantDials  []string
+	}{
+		{
+			name:       "connect",
+			cmd:        socks5CmdConnect,
+			wantStatus: socks5Succeeded,
+			wantDials:  []string{"es-http.ns.svc:9200"},
+		},
+		{
+			name:       "dial failure",
+			cmd:        socks5CmdConnect,
+			dialErr:    errors.New("no pod addresses found in service endpoints"),
+			wantStatus: socks5GeneralFailure,
+			wantDials:  []string{"es-http.ns.svc:9200"},
+		},
+		{
+			name:       "unsupported command",
+			cmd:        0x02, // BIND
+			wantStatus: socks5CommandNotSupported,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := &stubContextDialer{err: tt.dialErr}
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			serveErr := make(chan error)
+			go func() {
+				serveErr <- NewSOCKS5Proxy(dialer).Serve(ctx, l)
+			}()
+
+			conn, err := net.Dial("tcp", l.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+			require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+			require.Equal(t, tt.wantStatus, socks5Connect(t, conn, tt.cmd, "es-http.ns.svc", 9200))
+			if tt.wantStatus == socks5Succeeded {
+				_, err = conn.Write([]byte("ping"))
+				require.NoError(t, err)
+				pong := make([]byte, 4)
+				_, err = io.ReadFull(conn, pong)
+				require.NoError(t, err)
+				assert.Equal(t, "ping", string(pong))
+			}
+
+			// the connections are closed once the proxy stops
+			cancel()
+			require.NoError(t, <-serveErr)
+			_, err = conn.Read(make([]byte, 1))
+			require.Error(t, err)
+
+			dialer.mu.Lock()
+			defer dialer.mu.Unlock()
+			assert.Equal(t, tt.wantDials, dialer.addresses)
+		})
+	}
+}
+
+func Test_socks5Handshake_noAcceptableMethod(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	handshakeErr := make(chan error)
+	go func() {
+		_, err := socks5Handshake(server)
+		handshakeErr <- err
+	}()
+
+	// username/password authentication only
+	_, err := client.Write([]byte{socks5Version, 1, 0x02})
+	require.NoError(t, err)
+	method := make([]byte, 2)
+	_, err = io.ReadFull(client, method)
+	require.NoError(t, err)
+	require.Equal(t, []byte{socks5Version, socks5NoAcceptable}, method)
+	require.EqualError(t, <-handshakeErr, "no acceptable SOCKS5 authentication method")
+}
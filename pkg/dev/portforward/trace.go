@@ -0,0 +1,60 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"k8s.io/apimachinery/pkg/util/httpstream"
+)
+
+// Trace is a set of hooks called at the different phases of port forwarding, for example to measure how long each of
+// them takes. Any hook may be nil.
+type Trace struct {
+	// SPDYDialStart is called when a port forwarding session starts connecting to the API server.
+	SPDYDialStart func()
+	// SPDYUpgradeDone is called when the connection to the API server is upgraded to a streaming connection, with the
+	// error if the upgrade failed.
+	SPDYUpgradeDone func(err error)
+	// Ready is called when a port forwarding session is ready to redirect connections to the given local address.
+	Ready func(localAddr string)
+	// DialDone is called when DialContext is done connecting to the local address, with the error if it failed.
+	DialDone func(err error)
+}
+
+func (t *Trace) spdyDialStart() {
+	if t != nil && t.SPDYDialStart != nil {
+		t.SPDYDialStart()
+	}
+}
+
+func (t *Trace) spdyUpgradeDone(err error) {
+	if t != nil && t.SPDYUpgradeDone != nil {
+		t.SPDYUpgradeDone(err)
+	}
+}
+
+func (t *Trace) ready(localAddr string) {
+	if t != nil && t.Ready != nil {
+		t.Ready(localAddr)
+	}
+}
+
+func (t *Trace) dialDone(err error) {
+	if t != nil && t.DialDone != nil {
+		t.DialDone(err)
+	}
+}
+
+// tracingDialer calls the hooks of a trace around the dials to the API server.
+type tracingDialer struct {
+	trace *Trace
+	next  httpstream.Dialer
+}
+
+func (d *tracingDialer) Dial(protocols ...string) (httpstream.Connection, string, error) {
+	d.trace.spdyDialStart()
+	conn, protocol, err := d.next.Dial(protocols...)
+	d.trace.spdyUpgradeDone(err)
+	return conn, protocol, err
+}
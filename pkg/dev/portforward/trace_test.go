@@ -0,0 +1,114 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// traceRecorder records the hooks called on a trace.
+type traceRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *traceRecorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *traceRecorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func (r *traceRecorder) trace() *Trace {
+	errString := func(err error) string {
+		if err != nil {
+			return err.Error()
+		}
+		return "<nil>"
+	}
+	return &Trace{
+		SPDYDialStart:   func() { r.record("spdy dial start") },
+		SPDYUpgradeDone: func(err error) { r.record("spdy upgrade done: " + errString(err)) },
+		Ready:           func(localAddr string) { r.record("ready: " + localAddr) },
+		DialDone:        func(err error) { r.record("dial done: " + errString(err)) },
+	}
+}
+
+func TestWithTrace(t *testing.T) {
+	t.Run("SPDY connection", func(t *testing.T) {
+		setTestKubeconfig(t)
+
+		recorder := &traceRecorder{}
+		fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200",
+			WithTrace(recorder.trace()),
+			WithRoundTripperFactory(capturingRoundTripperFactory(&capturingRoundTripper{})),
+		)
+		pf, err := newKubectlPortForwarder(
+			context.Background(), fwd.kubectl, "bar", "foo", []string{"0:9200"}, make(chan struct{}), nil, nil,
+		)
+		require.NoError(t, err)
+		require.Error(t, pf.ForwardPorts())
+
+		events := recorder.recorded()
+		require.Len(t, events, 2)
+		require.Equal(t, "spdy dial start", events[0])
+		require.Contains(t, events[1], "spdy upgrade done: ")
+		require.Contains(t, events[1], "capturing round tripper")
+	})
+
+	t.Run("readiness and dials", func(t *testing.T) {
+		recorder := &traceRecorder{}
+		fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithTrace(recorder.trace()))
+		fwd.ephemeralPortFinder = func() (string, error) {
+			return "12345", nil
+		}
+		fwd.portForwarderFactory = func(
+			ctx context.Context,
+			_, _ string,
+			_ []string,
+			readyChan chan struct{},
+			_, _ io.Writer,
+		) (PortForwarder, error) {
+			close(readyChan)
+			return &stubPortForwarder{ctx: ctx}, nil
+		}
+		dialErr := errors.New("connection refused")
+		fwd.dialerFunc = func(_ context.Context, _, _ string) (net.Conn, error) {
+			return nil, dialErr
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		runErr := make(chan error)
+		go func() {
+			runErr <- fwd.Run(ctx)
+		}()
+		require.NoError(t, fwd.WaitForReady(ctx))
+		_, err := fwd.DialContext(ctx)
+		require.ErrorIs(t, err, dialErr)
+		cancel()
+		require.NoError(t, <-runErr)
+
+		require.Equal(t, []string{"ready: 127.0.0.1:12345", "dial done: connection refused"}, recorder.recorded())
+	})
+
+	t.Run("nil hooks", func(t *testing.T) {
+		var trace *Trace
+		trace.spdyDialStart()
+		trace.ready("127.0.0.1:12345")
+		(&Trace{}).dialDone(nil)
+	})
+}
@@ -0,0 +1,142 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// unixSocketName is the name of the socket created in a temporary directory when no socket path is specified
+const unixSocketName = "forward.sock"
+
+// prepareUnixSocketPath returns the path of the UNIX socket to listen on, creating a temporary directory for it if
+// path is empty, and a function removing what was created.
+func prepareUnixSocketPath(path string) (string, func(), error) {
+	if path != "" {
+		return path, func() {}, nil
+	}
+	dir, err := os.MkdirTemp("", "portforward-")
+	if err != nil {
+		return "", nil, err
+	}
+	return filepath.Join(dir, unixSocketName), func() {
+		_ = os.RemoveAll(dir)
+	}, nil
+}
+
+// unixSocketBridge accepts connections on a UNIX socket and relays them to a TCP address.
+type unixSocketBridge struct {
+	listener net.Listener
+
+	// wg tracks the goroutines started by the bridge
+	wg sync.WaitGroup
+
+	// mu protects conns
+	mu sync.Mutex
+	// conns are the connections relayed by the bridge, closed when the bridge is closed
+	conns map[net.Conn]struct{}
+}
+
+// newUnixSocketBridge listens on the UNIX socket at path, replacing any stale socket left by a previous session.
+func newUnixSocketBridge(path string) (*unixSocketBridge, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &unixSocketBridge{listener: listener, conns: make(map[net.Conn]struct{})}, nil
+}
+
+// serve relays the accepted connections to target until the bridge is closed.
+func (b *unixSocketBridge) serve(target string) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for {
+			conn, err := b.listener.Accept()
+			if err != nil {
+				return
+			}
+			b.wg.Add(1)
+			go func() {
+				defer b.wg.Done()
+				b.relay(conn, target)
+			}()
+		}
+	}()
+}
+
+// relay copies data between conn and a new connection to target until either side is done.
+func (b *unixSocketBridge) relay(conn net.Conn, target string) {
+	defer conn.Close()
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		log.V(1).Info("Failed to relay UNIX socket connection", "target", target, "error", err.Error())
+		return
+	}
+	defer upstream.Close()
+
+	if !b.track(conn, upstream) {
+		return
+	}
+	defer b.untrack(conn, upstream)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	// closing both connections once one direction is done stops the other one
+	<-done
+	_ = conn.Close()
+	_ = upstream.Close()
+	<-done
+}
+
+// track records connections to close when the bridge is closed, returning false if it is already closed.
+func (b *unixSocketBridge) track(conns ...net.Conn) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conns == nil {
+		return false
+	}
+	for _, conn := range conns {
+		b.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+func (b *unixSocketBridge) untrack(conns ...net.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conn := range conns {
+		delete(b.conns, conn)
+	}
+}
+
+// Close stops accepting connections, closes the relayed ones and waits for the bridge goroutines to return.
+func (b *unixSocketBridge) Close() {
+	_ = b.listener.Close()
+
+	b.mu.Lock()
+	for conn := range b.conns {
+		_ = conn.Close()
+	}
+	b.conns = nil
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
@@ -0,0 +1,107 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package portforward
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEchoListener returns a TCP listener echoing back what it receives, standing in for the local port of a port
+// forwarding session.
+func newEchoListener(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener
+}
+
+func Test_podForwarder_WithUnixSocket(t *testing.T) {
+	for _, path := range []string{"", filepath.Join(t.TempDir(), "es.sock")} {
+		t.Run("path: "+path, func(t *testing.T) {
+			echo := newEchoListener(t)
+			fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200", WithUnixSocket(path))
+			fwd.ephemeralPortFinder = func() (string, error) {
+				return strconv.Itoa(echo.Addr().(*net.TCPAddr).Port), nil
+			}
+			fwd.portForwarderFactory = func(
+				ctx context.Context,
+				_, _ string,
+				_ []string,
+				readyChan chan struct{},
+				_, _ io.Writer,
+			) (PortForwarder, error) {
+				close(readyChan)
+				return &stubPortForwarder{ctx: ctx}, nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			runErr := make(chan error)
+			go func() {
+				runErr <- fwd.Run(ctx)
+			}()
+
+			addr, err := fwd.LocalAddr()
+			require.NoError(t, err)
+			require.Equal(t, "unix", addr.Network())
+			if path != "" {
+				require.Equal(t, path, addr.String())
+			}
+
+			conn, err := fwd.DialContext(context.Background())
+			require.NoError(t, err)
+			_, err = conn.Write([]byte("ping"))
+			require.NoError(t, err)
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(conn, buf)
+			require.NoError(t, err)
+			require.Equal(t, "ping", string(buf))
+
+			cancel()
+			require.NoError(t, <-runErr)
+			// relayed connections are closed when the session stops
+			_, err = conn.Read(buf)
+			require.Error(t, err)
+			require.NoError(t, conn.Close())
+			// the socket is removed once the forwarder stops
+			_, err = os.Stat(addr.String())
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func Test_podForwarder_LocalAddr(t *testing.T) {
+	fwd := NewPodForwarderWithTest(t, "tcp", "foo.bar.pod:9200")
+	fwd.viaAddr = "127.0.0.1:12345"
+	close(fwd.initChan)
+
+	addr, err := fwd.LocalAddr()
+	require.NoError(t, err)
+	require.Equal(t, "tcp", addr.Network())
+	require.Equal(t, "127.0.0.1:12345", addr.String())
+}
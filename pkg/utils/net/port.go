@@ -5,7 +5,10 @@
 package net
 
 import (
+	"fmt"
+	"math/rand"
 	"net"
+	"strconv"
 )
 
 // GetRandomPort returns a random port chosen by the OS by binding to :0 and checking what port was bound.
@@ -22,3 +25,26 @@ func GetRandomPort() (string, error) {
 	_, localPort, err := net.SplitHostPort(listener.Addr().String())
 	return localPort, err
 }
+
+// GetRandomPortInRange returns an available port in the inclusive range [min, max], by trying to bind to the ports of
+// the range starting from a random one. An error is returned if no port of the range is available.
+func GetRandomPortInRange(min, max int) (string, error) {
+	if min < 1 || max > 65535 || min > max {
+		return "", fmt.Errorf("invalid port range %d-%d", min, max)
+	}
+	size := max - min + 1
+	start := rand.Intn(size) //nolint:gosec
+	for i := 0; i < size; i++ {
+		port := strconv.Itoa(min + (start+i)%size)
+		listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", port))
+		if err != nil {
+			// most likely in use, try the next one
+			continue
+		}
+		if err := listener.Close(); err != nil {
+			return "", err
+		}
+		return port, nil
+	}
+	return "", fmt.Errorf("no available port in range %d-%d", min, max)
+}
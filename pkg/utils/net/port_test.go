@@ -0,0 +1,39 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+package net
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRandomPortInRange(t *testing.T) {
+	// find a port range of a single available port
+	port, err := GetRandomPort()
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	got, err := GetRandomPortInRange(portNumber, portNumber)
+	require.NoError(t, err)
+	require.Equal(t, port, got)
+
+	// the range is exhausted once that port is in use
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", port))
+	require.NoError(t, err)
+	defer listener.Close()
+	_, err = GetRandomPortInRange(portNumber, portNumber)
+	require.EqualError(t, err, "no available port in range "+port+"-"+port)
+}
+
+func TestGetRandomPortInRange_invalidRange(t *testing.T) {
+	for _, r := range [][2]int{{0, 10}, {10, 70000}, {20, 10}} {
+		_, err := GetRandomPortInRange(r[0], r[1])
+		require.Error(t, err)
+	}
+}